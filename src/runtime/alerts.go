@@ -0,0 +1,259 @@
+package runtime
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Container resource usage alerts
+// The monitor samples StatsAll every Interval. A container whose CPU or memory
+// stays above its threshold for a whole Window fires one alert; a single
+// sample back under the threshold resets the window, or resolves the alert if
+// it already fired. Breach state is saved to StatePath to survive restarts
+
+const (
+	AlertMetricCPU    = "cpu"
+	AlertMetricMemory = "memory"
+
+	defaultAlertInterval = 30 * time.Second
+	defaultAlertWindow   = 5 * time.Minute
+)
+
+// AlertConfig configures the resource usage thresholds
+type AlertConfig struct {
+	CPUPercent    float64       // 0 disables CPU alerts
+	MemoryPercent float64       // 0 disables memory alerts
+	Window        time.Duration // how long a breach must last before alerting
+	Interval      time.Duration // sampling interval
+	StatePath     string        // file persisting breach state, empty keeps it in memory
+}
+
+// Alert is emitted when a sustained breach starts (Resolved false) and ends (Resolved true)
+type Alert struct {
+	ContainerID string
+	Name        string
+	Metric      string
+	Value       float64
+	Threshold   float64
+	Since       time.Time
+	Resolved    bool
+}
+
+// AlertHook receives alert events
+type AlertHook interface {
+	OnAlert(alert Alert)
+}
+
+// AlertHookFunc adapts a function to AlertHook
+type AlertHookFunc func(alert Alert)
+
+// OnAlert calls f(alert)
+func (f AlertHookFunc) OnAlert(alert Alert) { f(alert) }
+
+// breach tracks an ongoing threshold breach of one container metric
+type breach struct {
+	Name   string    `json:"name"`
+	Since  time.Time `json:"since"`
+	Firing bool      `json:"firing"`
+}
+
+// AlertMonitor samples container stats and emits alerts on sustained breaches
+type AlertMonitor struct {
+	runtime  types.ContainerRuntime
+	config   AlertConfig
+	hook     AlertHook
+	mu       sync.Mutex
+	breaches map[string]*breach // "<container id>/<metric>" -> breach
+	stop     chan struct{}
+}
+
+// NewAlertMonitor creates a monitor, restoring the persisted breach state
+func NewAlertMonitor(rt types.ContainerRuntime, config AlertConfig, hook AlertHook) *AlertMonitor {
+	if config.Interval <= 0 {
+		config.Interval = defaultAlertInterval
+	}
+	if config.Window <= 0 {
+		config.Window = defaultAlertWindow
+	}
+
+	m := &AlertMonitor{
+		runtime:  rt,
+		config:   config,
+		hook:     hook,
+		breaches: make(map[string]*breach),
+	}
+
+	if err := m.load(); err != nil {
+		utils.Warn("Failed to load alert state: " + err.Error())
+	}
+	return m
+}
+
+// Start begins sampling in the background
+func (m *AlertMonitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stop != nil {
+		return
+	}
+	m.stop = make(chan struct{})
+
+	go m.loop(m.stop)
+}
+
+// Stop ends sampling
+func (m *AlertMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+func (m *AlertMonitor) loop(stop chan struct{}) {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			stats, err := m.runtime.StatsAll()
+			if err != nil {
+				utils.Warn("Alert monitor failed to get container stats: " + err.Error())
+				continue
+			}
+
+			for _, alert := range m.observe(stats, time.Now()) {
+				if m.hook != nil {
+					m.hook.OnAlert(alert)
+				}
+			}
+		}
+	}
+}
+
+// observe updates the breach state with one sample and returns the alerts to emit
+func (m *AlertMonitor) observe(stats []types.ContainerStats, now time.Time) []Alert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var alerts []Alert
+	changed := false
+	seen := make(map[string]bool)
+
+	check := func(s types.ContainerStats, metric string, value, threshold float64) {
+		if threshold <= 0 {
+			return
+		}
+
+		key := s.ID + "/" + metric
+		seen[key] = true
+		b := m.breaches[key]
+
+		if value <= threshold {
+			if b != nil {
+				if b.Firing {
+					alerts = append(alerts, Alert{
+						ContainerID: s.ID, Name: s.Name, Metric: metric,
+						Value: value, Threshold: threshold, Since: b.Since, Resolved: true,
+					})
+				}
+				delete(m.breaches, key)
+				changed = true
+			}
+			return
+		}
+
+		if b == nil {
+			b = &breach{Name: s.Name, Since: now}
+			m.breaches[key] = b
+			changed = true
+		}
+
+		if !b.Firing && now.Sub(b.Since) >= m.config.Window {
+			b.Firing = true
+			changed = true
+			alerts = append(alerts, Alert{
+				ContainerID: s.ID, Name: s.Name, Metric: metric,
+				Value: value, Threshold: threshold, Since: b.Since,
+			})
+		}
+	}
+
+	for _, s := range stats {
+		check(s, AlertMetricCPU, s.CPUPercent, m.config.CPUPercent)
+		check(s, AlertMetricMemory, s.MemoryPercent, m.config.MemoryPercent)
+	}
+
+	// Containers that disappeared no longer breach
+	for key, b := range m.breaches {
+		if seen[key] {
+			continue
+		}
+		if b.Firing {
+			i := strings.LastIndex(key, "/")
+			id, metric := key[:i], key[i+1:]
+			alerts = append(alerts, Alert{ContainerID: id, Name: b.Name, Metric: metric, Since: b.Since, Resolved: true})
+		}
+		delete(m.breaches, key)
+		changed = true
+	}
+
+	if changed {
+		if err := m.save(); err != nil {
+			utils.Warn("Failed to save alert state: " + err.Error())
+		}
+	}
+
+	return alerts
+}
+
+func (m *AlertMonitor) load() error {
+	if m.config.StatePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(m.config.StatePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, &m.breaches)
+}
+
+// save persists the breach state, the caller must hold m.mu
+func (m *AlertMonitor) save() error {
+	if m.config.StatePath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(m.breaches)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.config.StatePath), 0755); err != nil {
+		return err
+	}
+
+	tmp := m.config.StatePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.config.StatePath)
+}
@@ -0,0 +1,147 @@
+package runtime
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// sample is one StatsAll result: CPU and memory percent by container ID
+type sample map[string][2]float64
+
+func TestAlertBreaches(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []sample
+		want    []string // "<sample index> <id> <metric> firing|resolved"
+	}{
+		{
+			name:    "below the thresholds",
+			samples: []sample{{"a": {10, 20}}, {"a": {79, 89}}, {"a": {80, 90}}, {"a": {80, 90}}, {"a": {80, 90}}},
+		},
+		{
+			name:    "shorter than the window",
+			samples: []sample{{"a": {95, 0}}, {"a": {95, 0}}, {"a": {95, 0}}, {"a": {10, 0}}},
+		},
+		{
+			name:    "sustained breach fires once",
+			samples: []sample{{"a": {95, 0}}, {"a": {95, 0}}, {"a": {99, 0}}, {"a": {95, 0}}, {"a": {100, 0}}, {"a": {95, 0}}},
+			want:    []string{"3 a cpu firing"},
+		},
+		{
+			name:    "resolves when back under the threshold",
+			samples: []sample{{"a": {95, 0}}, {"a": {95, 0}}, {"a": {95, 0}}, {"a": {95, 0}}, {"a": {50, 0}}, {"a": {50, 0}}},
+			want:    []string{"3 a cpu firing", "4 a cpu resolved"},
+		},
+		{
+			name:    "flapping never fires",
+			samples: []sample{{"a": {95, 0}}, {"a": {50, 0}}, {"a": {95, 0}}, {"a": {50, 0}}, {"a": {95, 0}}, {"a": {95, 0}}, {"a": {50, 0}}, {"a": {95, 0}}},
+		},
+		{
+			name: "flapping after firing",
+			samples: []sample{
+				{"a": {95, 0}}, {"a": {95, 0}}, {"a": {95, 0}}, {"a": {95, 0}},
+				{"a": {50, 0}}, {"a": {95, 0}}, {"a": {50, 0}}, {"a": {95, 0}},
+				{"a": {95, 0}}, {"a": {95, 0}}, {"a": {95, 0}},
+			},
+			want: []string{"3 a cpu firing", "4 a cpu resolved", "10 a cpu firing"},
+		},
+		{
+			name:    "metrics are independent",
+			samples: []sample{{"a": {95, 95}}, {"a": {95, 95}}, {"a": {50, 95}}, {"a": {95, 95}}, {"a": {95, 50}}},
+			want:    []string{"3 a memory firing", "4 a memory resolved"},
+		},
+		{
+			name:    "containers are independent",
+			samples: []sample{{"a": {95, 0}, "b": {10, 0}}, {"a": {95, 0}, "b": {95, 0}}, {"a": {95, 0}, "b": {95, 0}}, {"a": {95, 0}, "b": {95, 0}}, {"a": {95, 0}, "b": {95, 0}}},
+			want:    []string{"3 a cpu firing", "4 b cpu firing"},
+		},
+		{
+			name:    "removed container resolves",
+			samples: []sample{{"a": {95, 0}}, {"a": {95, 0}}, {"a": {95, 0}}, {"a": {95, 0}}, {}},
+			want:    []string{"3 a cpu firing", "4 a cpu resolved"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewAlertMonitor(nil, AlertConfig{CPUPercent: 80, MemoryPercent: 90, Window: 3 * time.Minute}, nil)
+
+			var got []string
+			start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, s := range tt.samples {
+				for _, alert := range m.observe(s.stats(), start.Add(time.Duration(i)*time.Minute)) {
+					got = append(got, formatAlert(i, alert))
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("alerts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertDisabledThreshold(t *testing.T) {
+	m := NewAlertMonitor(nil, AlertConfig{MemoryPercent: 90, Window: time.Minute}, nil)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if alerts := m.observe(sample{"a": {100, 10}}.stats(), start.Add(time.Duration(i)*time.Minute)); len(alerts) != 0 {
+			t.Fatalf("alerts with CPU alerts disabled: %+v", alerts)
+		}
+	}
+	if len(m.breaches) != 0 {
+		t.Errorf("breaches tracked for a disabled threshold: %v", m.breaches)
+	}
+}
+
+func TestAlertStatePersisted(t *testing.T) {
+	config := AlertConfig{CPUPercent: 80, Window: 3 * time.Minute, StatePath: filepath.Join(t.TempDir(), "alerts", "state.json")}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	breaching := sample{"a": {95, 0}, "b": {95, 0}}.stats()
+
+	m := NewAlertMonitor(nil, config, nil)
+	m.observe(breaching, start)
+	m.observe(breaching, start.Add(time.Minute))
+	m.observe(sample{"a": {95, 0}}.stats(), start.Add(2*time.Minute))
+
+	// After a restart, a keeps its start time and fires once; b is gone
+	restarted := NewAlertMonitor(nil, config, nil)
+	alerts := restarted.observe(sample{"a": {95, 0}}.stats(), start.Add(4*time.Minute))
+	if len(alerts) != 1 || alerts[0].ContainerID != "a" || !alerts[0].Since.Equal(start) {
+		t.Fatalf("alerts after restart = %+v, want a firing since %s", alerts, start)
+	}
+
+	again := NewAlertMonitor(nil, config, nil)
+	if alerts := again.observe(sample{"a": {95, 0}}.stats(), start.Add(5*time.Minute)); len(alerts) != 0 {
+		t.Errorf("firing alert emitted again after a restart: %+v", alerts)
+	}
+	alerts = again.observe(sample{"a": {10, 0}}.stats(), start.Add(6*time.Minute))
+	if len(alerts) != 1 || !alerts[0].Resolved {
+		t.Errorf("alerts = %+v, want a resolved", alerts)
+	}
+}
+
+// stats returns the sample as StatsAll would, sorted by ID
+func (s sample) stats() []types.ContainerStats {
+	var stats []types.ContainerStats
+	for _, id := range []string{"a", "b"} {
+		if v, ok := s[id]; ok {
+			stats = append(stats, types.ContainerStats{ID: id, Name: "app-" + id, CPUPercent: v[0], MemoryPercent: v[1]})
+		}
+	}
+	return stats
+}
+
+func formatAlert(i int, alert Alert) string {
+	state := "firing"
+	if alert.Resolved {
+		state = "resolved"
+	}
+	return fmt.Sprintf("%d %s %s %s", i, alert.ContainerID, alert.Metric, state)
+}
@@ -0,0 +1,102 @@
+// Package archive builds the tar streams used to copy files into containers
+package archive
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WritePath writes localPath (a file or a directory, recursively) to w as a tar
+// stream whose root entry is named name. File modes and symlinks are preserved.
+func WritePath(w io.Writer, localPath, name string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(localPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(localPath, path)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(filepath.Join(name, rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// WriteFile writes a tar stream holding a single regular file named name, with
+// size bytes read from content and the permission bits of mode
+func WriteFile(w io.Writer, name string, content io.Reader, size int64, mode os.FileMode) error {
+	tw := tar.NewWriter(w)
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     int64(mode.Perm()),
+		ModTime:  time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(tw, content, size); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// ReadFile returns the content of the first entry of a tar stream, which must be a regular file
+func ReadFile(r io.Reader) (io.Reader, error) {
+	tr := tar.NewReader(r)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil, fmt.Errorf("%s is not a regular file", hdr.Name)
+	}
+	return tr, nil
+}
@@ -0,0 +1,448 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// docker-compose services
+// ContainerConfig serializes to JSON and YAML with compose field names, but
+// compose accepts several shapes for most fields (ports as "8080:80/tcp" or
+// as a mapping, environment as a map or a KEY=VALUE list...).
+// ParseComposeService reads any of them into a ContainerConfig usable by every
+// runtime. Fields without a ContainerConfig equivalent (build, depends_on,
+// deploy...) are ignored
+
+// composeService is the subset of a compose service read by ParseComposeService
+type composeService struct {
+	ContainerName string              `yaml:"container_name"`
+	Image         string              `yaml:"image"`
+	Hostname      string              `yaml:"hostname"`
+	Domainname    string              `yaml:"domainname"`
+	User          string              `yaml:"user"`
+	WorkingDir    string              `yaml:"working_dir"`
+	Entrypoint    interface{}         `yaml:"entrypoint"`
+	Command       interface{}         `yaml:"command"`
+	Environment   interface{}         `yaml:"environment"`
+	Labels        interface{}         `yaml:"labels"`
+	Ports         []interface{}       `yaml:"ports"`
+	Volumes       []interface{}       `yaml:"volumes"`
+	Devices       []string            `yaml:"devices"`
+	Networks      interface{}         `yaml:"networks"`
+	Restart       string              `yaml:"restart"`
+	Privileged    bool                `yaml:"privileged"`
+	TTY           bool                `yaml:"tty"`
+	StdinOpen     bool                `yaml:"stdin_open"`
+	MemLimit      interface{}         `yaml:"mem_limit"`
+	MemswapLimit  interface{}         `yaml:"memswap_limit"`
+	CPUs          interface{}         `yaml:"cpus"`
+	CPUShares     int64               `yaml:"cpu_shares"`
+	Healthcheck   *composeHealthcheck `yaml:"healthcheck"`
+	DNS           interface{}         `yaml:"dns"`
+	DNSSearch     interface{}         `yaml:"dns_search"`
+	ExtraHosts    interface{}         `yaml:"extra_hosts"`
+	CapAdd        []string            `yaml:"cap_add"`
+	CapDrop       []string            `yaml:"cap_drop"`
+	SecurityOpt   []string            `yaml:"security_opt"`
+}
+
+type composeHealthcheck struct {
+	Test        interface{} `yaml:"test"`
+	Interval    string      `yaml:"interval"`
+	Timeout     string      `yaml:"timeout"`
+	Retries     int         `yaml:"retries"`
+	StartPeriod string      `yaml:"start_period"`
+	Disable     bool        `yaml:"disable"`
+}
+
+// ParseComposeService converts a docker-compose service definition into a
+// ContainerConfig. data is either the service itself or a compose file with
+// a single service, whose key then defaults the container name
+func ParseComposeService(data []byte) (types.ContainerConfig, error) {
+	var file struct {
+		Services map[string]yaml.MapSlice `yaml:"services"`
+	}
+	defaultName := ""
+	if err := yaml.Unmarshal(data, &file); err == nil && len(file.Services) > 0 {
+		if len(file.Services) > 1 {
+			return types.ContainerConfig{}, fmt.Errorf("compose file defines %d services, expected one", len(file.Services))
+		}
+		for name, service := range file.Services {
+			defaultName = name
+			data, _ = yaml.Marshal(service)
+		}
+	}
+
+	var service composeService
+	if err := yaml.Unmarshal(data, &service); err != nil {
+		return types.ContainerConfig{}, fmt.Errorf("invalid compose service: %w", err)
+	}
+	if service.Image == "" {
+		return types.ContainerConfig{}, fmt.Errorf("compose service has no image")
+	}
+
+	config := types.ContainerConfig{
+		Name:        service.ContainerName,
+		Image:       service.Image,
+		Hostname:    service.Hostname,
+		Domainname:  service.Domainname,
+		User:        service.User,
+		WorkingDir:  service.WorkingDir,
+		Entrypoint:  composeCommand(service.Entrypoint),
+		Command:     composeCommand(service.Command),
+		Environment: composeMap(service.Environment),
+		Labels:      composeMap(service.Labels),
+		Networks:    composeNetworks(service.Networks),
+		Privileged:  service.Privileged,
+		TTY:         service.TTY,
+		StdinOpen:   service.StdinOpen,
+		CPUShares:   service.CPUShares,
+		DNS:         composeList(service.DNS),
+		DNSSearch:   composeList(service.DNSSearch),
+		ExtraHosts:  composeList(service.ExtraHosts),
+		CapAdd:      service.CapAdd,
+		CapDrop:     service.CapDrop,
+		SecurityOpt: service.SecurityOpt,
+	}
+	if config.Name == "" {
+		config.Name = defaultName
+	}
+
+	var err error
+	if config.Memory, err = composeBytes(service.MemLimit); err != nil {
+		return config, fmt.Errorf("invalid mem_limit: %w", err)
+	}
+	if service.MemswapLimit != nil {
+		swap, err := composeBytes(service.MemswapLimit)
+		if err != nil {
+			return config, fmt.Errorf("invalid memswap_limit: %w", err)
+		}
+		config.MemorySwap = &swap
+	}
+	if service.CPUs != nil {
+		if config.CPUs, err = types.ParseCPU(fmt.Sprint(service.CPUs)); err != nil {
+			return config, fmt.Errorf("invalid cpus: %w", err)
+		}
+	}
+
+	for _, port := range service.Ports {
+		mapping, err := composePort(port)
+		if err != nil {
+			return config, err
+		}
+		config.Ports = append(config.Ports, mapping)
+	}
+
+	for _, volume := range service.Volumes {
+		mount, err := composeVolume(volume)
+		if err != nil {
+			return config, err
+		}
+		config.Volumes = append(config.Volumes, mount)
+	}
+
+	for _, device := range service.Devices {
+		mapping, err := composeDevice(device)
+		if err != nil {
+			return config, err
+		}
+		config.Devices = append(config.Devices, mapping)
+	}
+
+	if config.RestartPolicy, err = composeRestart(service.Restart); err != nil {
+		return config, err
+	}
+
+	if service.Healthcheck != nil {
+		if config.HealthCheck, err = composeHealth(service.Healthcheck); err != nil {
+			return config, err
+		}
+	}
+
+	return config, nil
+}
+
+// composePort reads "[ip:][host:]container[/protocol]" or the long port syntax
+func composePort(value interface{}) (types.PortMapping, error) {
+	if long, ok := value.(map[interface{}]interface{}); ok {
+		mapping := types.PortMapping{
+			HostIP:        composeString(long["host_ip"]),
+			HostPort:      composeString(long["published"]),
+			ContainerPort: composeString(long["target"]),
+			Protocol:      composeString(long["protocol"]),
+		}
+		if mapping.ContainerPort == "" {
+			return mapping, fmt.Errorf("port %v has no target", long)
+		}
+		if mapping.Protocol == "" {
+			mapping.Protocol = "tcp"
+		}
+		return mapping, nil
+	}
+
+	spec := composeString(value)
+	mapping := types.PortMapping{Protocol: "tcp"}
+	if rest, protocol, ok := strings.Cut(spec, "/"); ok {
+		spec, mapping.Protocol = rest, protocol
+	}
+
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		mapping.ContainerPort = parts[0]
+	case 2:
+		mapping.HostPort, mapping.ContainerPort = parts[0], parts[1]
+	case 3:
+		mapping.HostIP, mapping.HostPort, mapping.ContainerPort = parts[0], parts[1], parts[2]
+	default:
+		return mapping, fmt.Errorf("invalid port %q", composeString(value))
+	}
+	if _, err := strconv.Atoi(mapping.ContainerPort); err != nil {
+		return mapping, fmt.Errorf("invalid port %q: port ranges are not supported", composeString(value))
+	}
+	return mapping, nil
+}
+
+// composeVolume reads "[source:]target[:ro|rw]" or the long volume syntax.
+// The tmpfs size of the long syntax is kept in Consistency
+func composeVolume(value interface{}) (types.VolumeMount, error) {
+	if long, ok := value.(map[interface{}]interface{}); ok {
+		mount := types.VolumeMount{
+			Type:        types.MountType(composeString(long["type"])),
+			Source:      composeString(long["source"]),
+			Target:      composeString(long["target"]),
+			ReadOnly:    composeString(long["read_only"]) == "true",
+			Consistency: composeString(long["consistency"]),
+		}
+		if tmpfs, ok := long["tmpfs"].(map[interface{}]interface{}); ok && tmpfs["size"] != nil {
+			mount.Consistency = composeString(tmpfs["size"])
+		}
+		if mount.Type == "" {
+			mount.Type = volumeType(mount.Source)
+		}
+		if mount.Target == "" {
+			return mount, fmt.Errorf("volume %v has no target", long)
+		}
+		return mount, nil
+	}
+
+	spec := composeString(value)
+	parts := strings.Split(spec, ":")
+	mount := types.VolumeMount{}
+	switch len(parts) {
+	case 1:
+		mount.Target = parts[0]
+	case 2:
+		mount.Source, mount.Target = parts[0], parts[1]
+	case 3:
+		mount.Source, mount.Target = parts[0], parts[1]
+		mount.ReadOnly = strings.Contains(","+parts[2]+",", ",ro,")
+	default:
+		return mount, fmt.Errorf("invalid volume %q", spec)
+	}
+	mount.Type = volumeType(mount.Source)
+	return mount, nil
+}
+
+// composeDevice reads "host[:container[:permissions]]"
+func composeDevice(spec string) (types.DeviceMapping, error) {
+	parts := strings.Split(spec, ":")
+	mapping := types.DeviceMapping{HostPath: parts[0]}
+	switch len(parts) {
+	case 1:
+	case 2:
+		mapping.ContainerPath = parts[1]
+	case 3:
+		mapping.ContainerPath, mapping.Permissions = parts[1], parts[2]
+	default:
+		return mapping, fmt.Errorf("invalid device %q", spec)
+	}
+	if mapping.HostPath == "" {
+		return mapping, fmt.Errorf("invalid device %q", spec)
+	}
+	return mapping, nil
+}
+
+// volumeType tells host paths (bind mounts) from named volumes
+func volumeType(source string) types.MountType {
+	if strings.HasPrefix(source, "/") || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "~") {
+		return types.MountTypeBind
+	}
+	return types.MountTypeVolume
+}
+
+// composeRestart reads "no", "always", "unless-stopped" or "on-failure[:max]"
+func composeRestart(value string) (types.RestartPolicy, error) {
+	name, retries, _ := strings.Cut(value, ":")
+	policy := types.RestartPolicy{Name: name}
+	switch name {
+	case "", "no", "always", "unless-stopped":
+		if retries != "" {
+			return policy, fmt.Errorf("invalid restart policy %q", value)
+		}
+	case "on-failure":
+		if retries != "" {
+			count, err := strconv.Atoi(retries)
+			if err != nil {
+				return policy, fmt.Errorf("invalid restart policy %q", value)
+			}
+			policy.MaximumRetryCount = count
+		}
+	default:
+		return policy, fmt.Errorf("invalid restart policy %q", value)
+	}
+	return policy, nil
+}
+
+// composeHealth converts a compose healthcheck, durations becoming nanoseconds
+func composeHealth(health *composeHealthcheck) (*types.HealthCheckConfig, error) {
+	if health.Disable {
+		return &types.HealthCheckConfig{Test: []string{"NONE"}}, nil
+	}
+
+	check := &types.HealthCheckConfig{Retries: health.Retries}
+	switch test := health.Test.(type) {
+	case string:
+		check.Test = []string{"CMD-SHELL", test}
+	default:
+		check.Test = composeList(test)
+	}
+
+	for _, d := range []struct {
+		value  string
+		target *int64
+	}{
+		{health.Interval, &check.Interval},
+		{health.Timeout, &check.Timeout},
+		{health.StartPeriod, &check.StartPeriod},
+	} {
+		if d.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid healthcheck duration %q", d.value)
+		}
+		*d.target = int64(duration)
+	}
+	return check, nil
+}
+
+// composeBytes reads a byte count or a size such as "512m" or "2Gi"
+func composeBytes(value interface{}) (int64, error) {
+	if value == nil {
+		return 0, nil
+	}
+	return types.ParseMemory(composeString(value))
+}
+
+// composeCommand reads a command given as a list or as a shell-like string
+func composeCommand(value interface{}) []string {
+	if s, ok := value.(string); ok {
+		return splitWords(s)
+	}
+	return composeList(value)
+}
+
+// splitWords splits a command string on spaces, honoring quotes and backslashes
+func splitWords(s string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// composeList reads a list, or a single string as a one-item list
+func composeList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			list = append(list, composeString(item))
+		}
+		return list
+	}
+	return nil
+}
+
+// composeMap reads a mapping or a KEY=VALUE list; keys without value map to ""
+func composeMap(value interface{}) map[string]string {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]string, len(v))
+		for key, val := range v {
+			m[composeString(key)] = composeString(val)
+		}
+		return m
+	case []interface{}:
+		m := make(map[string]string, len(v))
+		for _, item := range v {
+			key, val, _ := strings.Cut(composeString(item), "=")
+			m[key] = val
+		}
+		return m
+	}
+	return nil
+}
+
+// composeNetworks reads the network names of a list or a mapping
+func composeNetworks(value interface{}) []string {
+	if m, ok := value.(map[interface{}]interface{}); ok {
+		networks := make([]string, 0, len(m))
+		for name := range m {
+			networks = append(networks, composeString(name))
+		}
+		sort.Strings(networks)
+		return networks
+	}
+	return composeList(value)
+}
+
+// composeString formats a scalar, nil being empty
+func composeString(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,132 @@
+package runtime
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestParseComposeService(t *testing.T) {
+	swap := int64(1 << 30)
+
+	tests := []struct {
+		name    string
+		service string
+		want    types.ContainerConfig
+		wantErr bool
+	}{
+		{
+			name: "short syntax",
+			service: `
+container_name: web
+image: nginx:1.25
+command: nginx -g "daemon off;"
+environment:
+  - TZ=UTC
+  - DEBUG
+ports:
+  - "8080:80"
+  - "127.0.0.1:8443:443/tcp"
+  - "53/udp"
+volumes:
+  - /srv/www:/usr/share/nginx/html:ro
+  - cache:/var/cache/nginx
+restart: on-failure:3
+mem_limit: 512m
+memswap_limit: 1g
+cpus: 1.5
+`,
+			want: types.ContainerConfig{
+				Name:        "web",
+				Image:       "nginx:1.25",
+				Command:     []string{"nginx", "-g", "daemon off;"},
+				Environment: map[string]string{"TZ": "UTC", "DEBUG": ""},
+				Ports: []types.PortMapping{
+					{HostPort: "8080", ContainerPort: "80", Protocol: "tcp"},
+					{HostIP: "127.0.0.1", HostPort: "8443", ContainerPort: "443", Protocol: "tcp"},
+					{ContainerPort: "53", Protocol: "udp"},
+				},
+				Volumes: []types.VolumeMount{
+					{Type: types.MountTypeBind, Source: "/srv/www", Target: "/usr/share/nginx/html", ReadOnly: true},
+					{Type: types.MountTypeVolume, Source: "cache", Target: "/var/cache/nginx"},
+				},
+				RestartPolicy: types.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+				Memory:        512 << 20,
+				MemorySwap:    &swap,
+				CPUs:          1.5,
+			},
+		},
+		{
+			name: "long syntax in a compose file",
+			service: `
+services:
+  db:
+    image: postgres:16
+    entrypoint: ["docker-entrypoint.sh"]
+    environment:
+      POSTGRES_DB: app
+    labels:
+      cosmos-stack: blog
+    networks:
+      backend: {}
+      frontend: {}
+    ports:
+      - target: 5432
+        published: 5433
+    volumes:
+      - type: tmpfs
+        target: /tmp
+        tmpfs:
+          size: 64m
+    devices:
+      - /dev/dri/renderD128:/dev/dri/renderD128:rw
+    healthcheck:
+      test: pg_isready
+      interval: 10s
+      retries: 5
+`,
+			want: types.ContainerConfig{
+				Name:        "db",
+				Image:       "postgres:16",
+				Entrypoint:  []string{"docker-entrypoint.sh"},
+				Environment: map[string]string{"POSTGRES_DB": "app"},
+				Labels:      map[string]string{"cosmos-stack": "blog"},
+				Networks:    []string{"backend", "frontend"},
+				Ports:       []types.PortMapping{{HostPort: "5433", ContainerPort: "5432", Protocol: "tcp"}},
+				Volumes:     []types.VolumeMount{{Type: types.MountTypeTmpfs, Target: "/tmp", Consistency: "64m"}},
+				Devices:     []types.DeviceMapping{{HostPath: "/dev/dri/renderD128", ContainerPath: "/dev/dri/renderD128", Permissions: "rw"}},
+				HealthCheck: &types.HealthCheckConfig{Test: []string{"CMD-SHELL", "pg_isready"}, Interval: 10e9, Retries: 5},
+			},
+		},
+		{
+			name:    "disabled healthcheck",
+			service: "image: app\nhealthcheck:\n  disable: true\n",
+			want:    types.ContainerConfig{Image: "app", HealthCheck: &types.HealthCheckConfig{Test: []string{"NONE"}}},
+		},
+		{"no image", "container_name: web\n", types.ContainerConfig{}, true},
+		{"several services", "services:\n  a:\n    image: a\n  b:\n    image: b\n", types.ContainerConfig{}, true},
+		{"port range", "image: app\nports: [\"8000-8010:8000-8010\"]\n", types.ContainerConfig{}, true},
+		{"invalid restart", "image: app\nrestart: sometimes\n", types.ContainerConfig{}, true},
+		{"invalid memory", "image: app\nmem_limit: lots\n", types.ContainerConfig{}, true},
+		{"invalid duration", "image: app\nhealthcheck:\n  test: [CMD, true]\n  interval: soon\n", types.ContainerConfig{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseComposeService([]byte(tt.service))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseComposeService = %+v, want an error", config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseComposeService: %v", err)
+			}
+			if !reflect.DeepEqual(config, tt.want) {
+				t.Errorf("ParseComposeService =\n%+v\nwant\n%+v", config, tt.want)
+			}
+		})
+	}
+}
@@ -1,21 +1,32 @@
 package docker
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/azukaar/cosmos-server/src/runtime/archive"
 	"github.com/azukaar/cosmos-server/src/runtime/types"
 	"github.com/docker/docker/api/types/container"
 	dockertypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/mount"
 	networktypes "github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
 	natting "github.com/docker/go-connections/nat"
 )
 
@@ -53,6 +64,13 @@ func (d *DockerRuntime) Connect() error {
 	if d.config != nil && d.config.Host != "" {
 		opts = append(opts, client.WithHost(d.config.Host))
 	}
+	if d.config != nil && d.config.TLSVerify && d.config.CertPath != "" {
+		opts = append(opts, client.WithTLSClientConfig(
+			filepath.Join(d.config.CertPath, "ca.pem"),
+			filepath.Join(d.config.CertPath, "cert.pem"),
+			filepath.Join(d.config.CertPath, "key.pem"),
+		))
+	}
 
 	cli, err := client.NewClientWithOpts(opts...)
 	if err != nil {
@@ -94,6 +112,38 @@ func (d *DockerRuntime) Close() error {
 
 // Create creates a new container
 func (d *DockerRuntime) Create(config types.ContainerConfig) (string, error) {
+	containerConfig, hostConfig, err := dockerCreateSpec(config)
+	if err != nil {
+		return "", err
+	}
+
+	// Network config
+	var networkConfig *networktypes.NetworkingConfig
+	if len(config.Networks) > 0 {
+		networkConfig = &networktypes.NetworkingConfig{
+			EndpointsConfig: make(map[string]*networktypes.EndpointSettings),
+		}
+		// Connect to first network, others will be connected after creation
+		networkConfig.EndpointsConfig[config.Networks[0]] = dockerEndpoint(config, config.Networks[0])
+	}
+
+	resp, err := d.client.ContainerCreate(d.ctx, containerConfig, hostConfig, networkConfig, nil, config.Name)
+	if err != nil {
+		return "", err
+	}
+
+	// Connect to additional networks
+	for i := 1; i < len(config.Networks); i++ {
+		if err := d.client.NetworkConnect(d.ctx, config.Networks[i], resp.ID, dockerEndpoint(config, config.Networks[i])); err != nil {
+			return resp.ID, fmt.Errorf("container %s created but failed to connect to network %s: %w", resp.ID, config.Networks[i], err)
+		}
+	}
+
+	return resp.ID, nil
+}
+
+// dockerCreateSpec converts a ContainerConfig to the Docker container and host configs
+func dockerCreateSpec(config types.ContainerConfig) (*container.Config, *container.HostConfig, error) {
 	// Convert ContainerConfig to Docker config
 	containerConfig := &container.Config{
 		Image:        config.Image,
@@ -166,13 +216,32 @@ func (d *DockerRuntime) Create(config types.ContainerConfig) (string, error) {
 		hostConfig.Mounts = append(hostConfig.Mounts, m)
 	}
 
+	// Devices
+	for _, device := range config.Devices {
+		containerPath := device.ContainerPath
+		if containerPath == "" {
+			containerPath = device.HostPath
+		}
+		permissions := device.Permissions
+		if permissions == "" {
+			permissions = "rwm"
+		}
+		hostConfig.Devices = append(hostConfig.Devices, container.DeviceMapping{
+			PathOnHost:        device.HostPath,
+			PathInContainer:   containerPath,
+			CgroupPermissions: permissions,
+		})
+	}
+
 	// Resource limits
 	if config.Memory > 0 {
 		hostConfig.Memory = config.Memory
 	}
-	if config.MemorySwap > 0 {
-		hostConfig.MemorySwap = config.MemorySwap
+	swap, err := dockerMemorySwap(config.Memory, config.MemorySwap)
+	if err != nil {
+		return nil, nil, err
 	}
+	hostConfig.MemorySwap = swap
 	if config.CPUShares > 0 {
 		hostConfig.CPUShares = config.CPUShares
 	}
@@ -191,37 +260,44 @@ func (d *DockerRuntime) Create(config types.ContainerConfig) (string, error) {
 		}
 	}
 
-	// Network config
-	var networkConfig *networktypes.NetworkingConfig
-	if len(config.Networks) > 0 {
-		networkConfig = &networktypes.NetworkingConfig{
-			EndpointsConfig: make(map[string]*networktypes.EndpointSettings),
-		}
-		// Connect to first network, others will be connected after creation
-		networkConfig.EndpointsConfig[config.Networks[0]] = &networktypes.EndpointSettings{}
-	}
+	return containerConfig, hostConfig, nil
+}
 
-	resp, err := d.client.ContainerCreate(d.ctx, containerConfig, hostConfig, networkConfig, nil, config.Name)
+// Describe returns the Docker create request of config as indented JSON, without calling Docker.
+// Every network is listed, Create connects all but the first after creation
+func (d *DockerRuntime) Describe(config types.ContainerConfig) (string, error) {
+	containerConfig, hostConfig, err := dockerCreateSpec(config)
 	if err != nil {
 		return "", err
 	}
 
-	// Connect to additional networks
-	for i := 1; i < len(config.Networks); i++ {
-		d.client.NetworkConnect(d.ctx, config.Networks[i], resp.ID, &networktypes.EndpointSettings{})
+	networkConfig := &networktypes.NetworkingConfig{
+		EndpointsConfig: make(map[string]*networktypes.EndpointSettings),
+	}
+	for _, network := range config.Networks {
+		networkConfig.EndpointsConfig[network] = dockerEndpoint(config, network)
 	}
 
-	return resp.ID, nil
+	rendered, err := json.MarshalIndent(map[string]interface{}{
+		"Name":             config.Name,
+		"Config":           containerConfig,
+		"HostConfig":       hostConfig,
+		"NetworkingConfig": networkConfig,
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
 }
 
 // Start starts a container
 func (d *DockerRuntime) Start(id string) error {
-	return d.client.ContainerStart(d.ctx, id, container.StartOptions{})
+	return containerError(d.client.ContainerStart(d.ctx, id, container.StartOptions{}), id)
 }
 
 // Stop stops a container
 func (d *DockerRuntime) Stop(id string) error {
-	return d.client.ContainerStop(d.ctx, id, container.StopOptions{})
+	return containerError(d.client.ContainerStop(d.ctx, id, container.StopOptions{}), id)
 }
 
 // Restart restarts a container
@@ -229,9 +305,19 @@ func (d *DockerRuntime) Restart(id string) error {
 	return d.client.ContainerRestart(d.ctx, id, container.StopOptions{})
 }
 
+// Pause freezes the processes of a container
+func (d *DockerRuntime) Pause(id string) error {
+	return d.client.ContainerPause(d.ctx, id)
+}
+
+// Unpause resumes a paused container
+func (d *DockerRuntime) Unpause(id string) error {
+	return d.client.ContainerUnpause(d.ctx, id)
+}
+
 // Remove removes a container
 func (d *DockerRuntime) Remove(id string) error {
-	return d.client.ContainerRemove(d.ctx, id, container.RemoveOptions{})
+	return containerError(d.client.ContainerRemove(d.ctx, id, container.RemoveOptions{}), id)
 }
 
 // Recreate stops, removes, and recreates a container
@@ -271,6 +357,83 @@ func (d *DockerRuntime) Recreate(id string, config types.ContainerConfig) (strin
 	return newID, nil
 }
 
+// Update changes the resource limits of a container. Docker labels are
+// immutable, so changing them requires a recreate like the image does.
+// Docker keeps the limits left at zero, so removing a limit through
+// UpdateFields requires a recreate too
+func (d *DockerRuntime) Update(id string, config types.ContainerConfig) error {
+	if err := config.ValidateUpdateFields(); err != nil {
+		return err
+	}
+	info, err := d.client.ContainerInspect(d.ctx, id)
+	if err != nil {
+		return containerError(err, id)
+	}
+
+	if config.Updates(types.UpdateImage, config.Image != "") && config.Image != info.Config.Image {
+		return fmt.Errorf("%w: image %s -> %s", types.ErrRecreateRequired, info.Config.Image, config.Image)
+	}
+	if config.Updates(types.UpdatePrivileged, config.Privileged) && config.Privileged != info.HostConfig.Privileged {
+		return fmt.Errorf("%w: privileged mode", types.ErrRecreateRequired)
+	}
+	// Image labels are merged into the container ones, so only the requested labels are compared
+	if config.Updates(types.UpdateLabels, config.Labels != nil) {
+		for k, v := range config.Labels {
+			if info.Config.Labels[k] != v {
+				return fmt.Errorf("%w: Docker labels cannot be changed (%s)", types.ErrRecreateRequired, k)
+			}
+		}
+	}
+
+	resources := container.Resources{}
+	limits := []struct {
+		field string
+		set   bool
+	}{
+		{types.UpdateMemory, config.Memory > 0},
+		{types.UpdateCPUShares, config.CPUShares > 0},
+		{types.UpdateCPUs, config.CPUs > 0},
+	}
+	for _, limit := range limits {
+		if config.Updates(limit.field, limit.set) && !limit.set {
+			return fmt.Errorf("%w: Docker cannot remove the %s limit", types.ErrRecreateRequired, limit.field)
+		}
+	}
+	if config.Updates(types.UpdateMemory, config.Memory > 0) {
+		resources.Memory = config.Memory
+	}
+	if config.Updates(types.UpdateMemorySwap, config.MemorySwap != nil) {
+		// Swap is disabled against the current memory limit when it is kept
+		memory := info.HostConfig.Memory
+		if resources.Memory > 0 {
+			memory = resources.Memory
+		}
+		swap, err := dockerMemorySwap(memory, config.MemorySwap)
+		if err != nil {
+			return err
+		}
+		resources.MemorySwap = swap
+	}
+	if config.Updates(types.UpdateCPUShares, config.CPUShares > 0) {
+		resources.CPUShares = config.CPUShares
+	}
+	if config.Updates(types.UpdateCPUs, config.CPUs > 0) {
+		resources.NanoCPUs = int64(config.CPUs * 1e9)
+	}
+
+	_, err = d.client.ContainerUpdate(d.ctx, id, container.UpdateConfig{Resources: resources})
+	return containerError(err, id)
+}
+
+// Rename changes the name of a container
+func (d *DockerRuntime) Rename(id string, newName string) error {
+	err := d.client.ContainerRename(d.ctx, id, newName)
+	if errdefs.IsConflict(err) {
+		return fmt.Errorf("%w: %s", types.ErrNameInUse, newName)
+	}
+	return containerError(err, id)
+}
+
 // List lists all containers
 func (d *DockerRuntime) List() ([]types.Container, error) {
 	containers, err := d.client.ContainerList(d.ctx, container.ListOptions{All: true})
@@ -280,47 +443,104 @@ func (d *DockerRuntime) List() ([]types.Container, error) {
 
 	result := make([]types.Container, len(containers))
 	for i, c := range containers {
-		name := ""
-		if len(c.Names) > 0 {
-			name = strings.TrimPrefix(c.Names[0], "/")
-		}
+		result[i] = convertContainer(c)
+	}
 
-		var ports []types.PortMapping
-		for _, p := range c.Ports {
-			ports = append(ports, types.PortMapping{
-				HostIP:        p.IP,
-				HostPort:      strconv.Itoa(int(p.PublicPort)),
-				ContainerPort: strconv.Itoa(int(p.PrivatePort)),
-				Protocol:      p.Type,
-			})
-		}
+	return result, nil
+}
 
-		var networks []string
-		for netName := range c.NetworkSettings.Networks {
-			networks = append(networks, netName)
-		}
+// FindByName returns the container with the given name. Docker names are unique
+func (d *DockerRuntime) FindByName(name string) (*types.Container, error) {
+	// The name filter matches substrings of "/<name>", anchor it for an exact match
+	containers, err := d.client.ContainerList(d.ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", "^/"+regexp.QuoteMeta(strings.TrimPrefix(name, "/"))+"$")),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("%w: %s", types.ErrContainerNotFound, name)
+	}
 
-		result[i] = types.Container{
-			ID:       c.ID,
-			Name:     name,
-			Image:    c.Image,
-			State:    types.ContainerState(c.State),
-			Status:   c.Status,
-			Created:  c.Created,
-			Labels:   c.Labels,
-			Ports:    ports,
-			Networks: networks,
-		}
+	found := convertContainer(containers[0])
+	return &found, nil
+}
+
+// convertContainer converts a Docker container summary
+func convertContainer(c dockertypes.Container) types.Container {
+	name := ""
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
 	}
 
-	return result, nil
+	var ports []types.PortMapping
+	for _, p := range c.Ports {
+		ports = append(ports, types.PortMapping{
+			HostIP:        p.IP,
+			HostPort:      strconv.Itoa(int(p.PublicPort)),
+			ContainerPort: strconv.Itoa(int(p.PrivatePort)),
+			Protocol:      p.Type,
+		})
+	}
+
+	var networks []string
+	for netName := range c.NetworkSettings.Networks {
+		networks = append(networks, netName)
+	}
+
+	return types.Container{
+		ID:       c.ID,
+		Name:     name,
+		Image:    c.Image,
+		State:    mapDockerState(c.State),
+		Status:   c.Status,
+		Created:  c.Created,
+		Labels:   c.Labels,
+		Ports:    ports,
+		Networks: networks,
+	}
+}
+
+// dockerMemorySwap converts MemorySwap to Docker's memory plus swap limit for a
+// memory limit of memory. Docker has no swap-only limit: no swap means a limit
+// equal to the memory one, so swap cannot be disabled without a memory limit
+// (Docker reads a 0 limit as unset, leaving swap unlimited)
+func dockerMemorySwap(memory int64, swap *int64) (int64, error) {
+	switch {
+	case swap == nil:
+		return 0, nil
+	case *swap == 0 && memory <= 0:
+		return 0, fmt.Errorf("swap cannot be disabled without a memory limit on Docker")
+	case *swap == 0:
+		return memory, nil
+	default:
+		return *swap, nil
+	}
+}
+
+// dockerEndpoint returns the endpoint settings of the container on network,
+// Docker names interfaces itself so only the MAC address is used
+func dockerEndpoint(config types.ContainerConfig, network string) *networktypes.EndpointSettings {
+	return &networktypes.EndpointSettings{MacAddress: config.NetworkEndpoints[network].MacAddress}
+}
+
+// WaitForState polls the container state until it is state or timeout elapses
+func (d *DockerRuntime) WaitForState(id string, state types.ContainerState, timeout time.Duration) error {
+	return types.PollState(id, state, timeout, func() (types.ContainerState, error) {
+		info, err := d.client.ContainerInspect(d.ctx, id)
+		if err != nil {
+			return "", containerError(err, id)
+		}
+		return mapDockerState(info.State.Status), nil
+	})
 }
 
 // Inspect returns detailed container information
 func (d *DockerRuntime) Inspect(id string) (*types.ContainerDetails, error) {
 	info, err := d.client.ContainerInspect(d.ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, containerError(err, id)
 	}
 
 	name := strings.TrimPrefix(info.Name, "/")
@@ -398,7 +618,7 @@ func (d *DockerRuntime) Inspect(id string) (*types.ContainerDetails, error) {
 			ID:       info.ID,
 			Name:     name,
 			Image:    info.Config.Image,
-			State:    types.ContainerState(info.State.Status),
+			State:    mapDockerState(info.State.Status),
 			Status:   info.State.Status,
 			Created:  createdUnix,
 			Labels:   info.Config.Labels,
@@ -450,7 +670,7 @@ func (d *DockerRuntime) Logs(id string, opts types.LogOptions) (io.ReadCloser, e
 func (d *DockerRuntime) Stats(id string) (*types.ContainerStats, error) {
 	statsBody, err := d.client.ContainerStats(d.ctx, id, false)
 	if err != nil {
-		return nil, err
+		return nil, containerError(err, id)
 	}
 	defer statsBody.Body.Close()
 
@@ -471,12 +691,15 @@ func (d *DockerRuntime) Stats(id string) (*types.ContainerStats, error) {
 		netTx += int64(net.TxBytes)
 	}
 
+	// One entry per device and operation ("Read"/"Write" on cgroup v1, "read"/"write" on v2)
 	var blockRead, blockWrite int64
-	if len(stats.BlkioStats.IoServiceBytesRecursive) > 0 {
-		blockRead = int64(stats.BlkioStats.IoServiceBytesRecursive[0].Value)
-	}
-	if len(stats.BlkioStats.IoServiceBytesRecursive) > 1 {
-		blockWrite = int64(stats.BlkioStats.IoServiceBytesRecursive[1].Value)
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			blockRead += int64(entry.Value)
+		case "write":
+			blockWrite += int64(entry.Value)
+		}
 	}
 
 	return &types.ContainerStats{
@@ -514,6 +737,60 @@ func (d *DockerRuntime) StatsAll() ([]types.ContainerStats, error) {
 	return result, nil
 }
 
+// dockerEventActions maps Docker container actions to lifecycle events. A stop
+// sends both "stop" and "die", deduplicated by Events
+var dockerEventActions = map[events.Action]types.EventAction{
+	events.ActionCreate:  types.EventCreated,
+	events.ActionStart:   types.EventStarted,
+	events.ActionStop:    types.EventStopped,
+	events.ActionDie:     types.EventStopped,
+	events.ActionDestroy: types.EventRemoved,
+}
+
+// Events proxies the Docker container events as lifecycle events
+func (d *DockerRuntime) Events(ctx context.Context) (<-chan types.ContainerEvent, error) {
+	messages, errs := d.client.Events(ctx, dockertypes.EventsOptions{
+		Filters: filters.NewArgs(filters.Arg("type", string(events.ContainerEventType))),
+	})
+
+	out := make(chan types.ContainerEvent, 64)
+	go func() {
+		defer close(out)
+
+		last := make(map[string]types.EventAction)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-errs:
+				return
+			case msg := <-messages:
+				action, ok := dockerEventActions[msg.Action]
+				if !ok || last[msg.Actor.ID] == action {
+					continue
+				}
+				last[msg.Actor.ID] = action
+				if action == types.EventRemoved {
+					delete(last, msg.Actor.ID)
+				}
+
+				event := types.ContainerEvent{
+					ID:     msg.Actor.ID,
+					Name:   msg.Actor.Attributes["name"],
+					Action: action,
+					Time:   msg.Time,
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
 // CreateNetwork creates a new network
 func (d *DockerRuntime) CreateNetwork(config types.NetworkConfig) (string, error) {
 	var ipamConfig []networktypes.IPAMConfig
@@ -641,6 +918,124 @@ func (d *DockerRuntime) ListVolumes() ([]types.Volume, error) {
 	return result, nil
 }
 
+// Exec runs a command inside a container and returns its output and exit code
+func (d *DockerRuntime) Exec(id string, cmd []string, opts types.ExecOptions) (*types.ExecResult, error) {
+	env := make([]string, 0, len(opts.Environment))
+	for k, v := range opts.Environment {
+		env = append(env, k+"="+v)
+	}
+
+	exec, err := d.client.ContainerExecCreate(d.ctx, id, dockertypes.ExecConfig{
+		Cmd:          cmd,
+		Env:          env,
+		WorkingDir:   opts.WorkingDir,
+		Tty:          opts.TTY,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attach, err := d.client.ContainerExecAttach(d.ctx, exec.ID, dockertypes.ExecStartCheck{Tty: opts.TTY})
+	if err != nil {
+		return nil, err
+	}
+	defer attach.Close()
+
+	var stdout, stderr bytes.Buffer
+	if opts.TTY {
+		_, err = io.Copy(&stdout, attach.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(&stdout, &stderr, attach.Reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	inspect, err := d.client.ContainerExecInspect(d.ctx, exec.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ExecResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: inspect.ExitCode,
+	}, nil
+}
+
+// CopyTo copies a local file or directory into a container
+func (d *DockerRuntime) CopyTo(id string, localPath string, containerPath string) error {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(archive.WritePath(writer, localPath, path.Base(containerPath)))
+	}()
+	defer reader.Close()
+
+	return d.client.CopyToContainer(d.ctx, id, path.Dir(containerPath), reader, dockertypes.CopyToContainerOptions{})
+}
+
+// CopyFrom writes a file or directory of a container to localWriter as a tar stream
+func (d *DockerRuntime) CopyFrom(id string, containerPath string, localWriter io.Writer) error {
+	reader, _, err := d.client.CopyFromContainer(d.ctx, id, containerPath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(localWriter, reader)
+	return err
+}
+
+// CopyToContainer streams content into the file dstPath of a container. The tar
+// header needs the size up front, so content is spooled to a temporary file
+// rather than held in memory
+func (d *DockerRuntime) CopyToContainer(id string, dstPath string, content io.Reader, mode os.FileMode) error {
+	spool, err := os.CreateTemp("", "cosmos-copy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := io.Copy(spool, content)
+	if err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Docker creates the missing parent directories of the entry
+	name := strings.TrimPrefix(path.Join("/", dstPath), "/")
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(archive.WriteFile(writer, name, spool, size, mode))
+	}()
+	defer reader.Close()
+
+	return containerError(d.client.CopyToContainer(d.ctx, id, "/", reader, dockertypes.CopyToContainerOptions{}), id)
+}
+
+// CopyFromContainer streams the content of the file srcPath of a container
+func (d *DockerRuntime) CopyFromContainer(id string, srcPath string) (io.ReadCloser, error) {
+	stream, _, err := d.client.CopyFromContainer(d.ctx, id, srcPath)
+	if err != nil {
+		return nil, containerError(err, id)
+	}
+
+	file, err := archive.ReadFile(stream)
+	if err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("failed to read %s from container %s: %w", srcPath, id, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{file, stream}, nil
+}
+
 // PullImage pulls an image
 func (d *DockerRuntime) PullImage(ref string) (io.ReadCloser, error) {
 	return d.client.ImagePull(d.ctx, ref, dockertypes.ImagePullOptions{})
@@ -692,6 +1087,32 @@ func (d *DockerRuntime) Version() string {
 
 // Helper functions
 
+// containerError maps Docker "no such container" errors to ErrContainerNotFound
+func containerError(err error, id string) error {
+	if errdefs.IsNotFound(err) {
+		return fmt.Errorf("%w: %s", types.ErrContainerNotFound, id)
+	}
+	return err
+}
+
+// mapDockerState converts a Docker container state to a ContainerState
+func mapDockerState(state string) types.ContainerState {
+	switch state {
+	case "created":
+		return types.StateCreated
+	case "running":
+		return types.StateRunning
+	case "paused":
+		return types.StatePaused
+	case "restarting":
+		return types.StateRestarting
+	case "exited":
+		return types.StateExited
+	default: // "dead", "removing"
+		return types.StateDead
+	}
+}
+
 func calculateCPUPercent(stats *dockertypes.StatsJSON) float64 {
 	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
 	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
@@ -0,0 +1,118 @@
+package docker
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestDockerMemorySwap(t *testing.T) {
+	swap := func(bytes int64) *int64 { return &bytes }
+
+	tests := []struct {
+		name    string
+		memory  int64
+		swap    *int64
+		want    int64
+		wantErr bool
+	}{
+		{"unset", 1 << 30, nil, 0, false},
+		{"explicitly zero", 1 << 30, swap(0), 1 << 30, false},
+		{"positive", 1 << 30, swap(2 << 30), 2 << 30, false},
+		{"unset without memory limit", 0, nil, 0, false},
+		{"positive without memory limit", 0, swap(2 << 30), 2 << 30, false},
+		{"explicitly zero without memory limit", 0, swap(0), 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dockerMemorySwap(tt.memory, tt.swap)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("dockerMemorySwap = %d, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dockerMemorySwap: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("dockerMemorySwap = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	d, err := New(&Config{})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	swap := int64(0)
+	described, err := d.Describe(types.ContainerConfig{
+		Name:        "web",
+		Image:       "nginx:1.25",
+		Environment: map[string]string{"TZ": "UTC"},
+		Ports:       []types.PortMapping{{HostPort: "8080", ContainerPort: "80", Protocol: "tcp"}},
+		Volumes:     []types.VolumeMount{{Type: types.MountTypeBind, Source: "/srv/www", Target: "/usr/share/nginx/html", ReadOnly: true}},
+		Networks:    []string{"frontend", "backend"},
+		Memory:      512 << 20,
+		MemorySwap:  &swap,
+		CPUs:        1.5,
+	})
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+
+	var spec struct {
+		Name   string
+		Config struct {
+			Image string
+			Env   []string
+		}
+		HostConfig struct {
+			Memory       int64
+			MemorySwap   int64
+			NanoCPUs     int64
+			PortBindings map[string][]struct{ HostPort string }
+			Mounts       []struct {
+				Type     string
+				Source   string
+				ReadOnly bool
+			}
+		}
+		NetworkingConfig struct {
+			EndpointsConfig map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal([]byte(described), &spec); err != nil {
+		t.Fatalf("Describe is not JSON: %v\n%s", err, described)
+	}
+
+	fields := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"Name", spec.Name, "web"},
+		{"Image", spec.Config.Image, "nginx:1.25"},
+		{"Env", strings.Join(spec.Config.Env, ","), "TZ=UTC"},
+		{"Memory", spec.HostConfig.Memory, int64(512 << 20)},
+		{"MemorySwap", spec.HostConfig.MemorySwap, int64(512 << 20)},
+		{"NanoCPUs", spec.HostConfig.NanoCPUs, int64(1.5e9)},
+		{"PortBindings", len(spec.HostConfig.PortBindings["80/tcp"]) == 1 && spec.HostConfig.PortBindings["80/tcp"][0].HostPort == "8080", true},
+		{"Mounts", len(spec.HostConfig.Mounts) == 1 && spec.HostConfig.Mounts[0].Type == "bind" && spec.HostConfig.Mounts[0].ReadOnly, true},
+		{"Networks", len(spec.NetworkingConfig.EndpointsConfig), 2},
+	}
+	for _, f := range fields {
+		if f.got != f.want {
+			t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+		}
+	}
+
+	if _, err := d.Describe(types.ContainerConfig{Name: "web", Image: "nginx:1.25", MemorySwap: &swap}); err == nil {
+		t.Error("Describe disabled swap without a memory limit, want an error")
+	}
+}
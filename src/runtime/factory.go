@@ -3,6 +3,7 @@ package runtime
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/azukaar/cosmos-server/src/runtime/docker"
 	"github.com/azukaar/cosmos-server/src/runtime/proxmox"
@@ -31,23 +32,12 @@ func IsRuntimeConnected() bool {
 	return r.IsConnected()
 }
 
-// InitRuntime initializes the container runtime based on configuration
+// InitRuntime initializes the container runtime registered for config.Type
 func InitRuntime(config types.RuntimeConfig) (types.ContainerRuntime, error) {
 	runtimeMutex.Lock()
 	defer runtimeMutex.Unlock()
 
-	var rt types.ContainerRuntime
-	var err error
-
-	switch config.Type {
-	case types.RuntimeDocker:
-		rt, err = NewDockerRuntime(config.Docker)
-	case types.RuntimeProxmox:
-		rt, err = NewProxmoxRuntime(config.Proxmox)
-	default:
-		return nil, errors.New("unknown container runtime: " + string(config.Type))
-	}
-
+	rt, err := newRuntime(config)
 	if err != nil {
 		return nil, err
 	}
@@ -96,15 +86,54 @@ func NewProxmoxRuntime(config *types.ProxmoxConfig) (types.ContainerRuntime, err
 
 	// Convert types.ProxmoxConfig to proxmox.Config
 	pxConfig := &proxmox.Config{
-		Host:          config.Host,
-		Node:          config.Node,
-		TokenID:       config.TokenID,
-		TokenSecret:   config.TokenSecret,
-		Storage:       config.Storage,
-		VMIDStart:     config.VMIDStart,
-		VMIDEnd:       config.VMIDEnd,
-		SkipTLSVerify: config.SkipTLSVerify,
+		Host:                  config.Host,
+		Node:                  config.Node,
+		TokenID:               config.TokenID,
+		TokenSecret:           config.TokenSecret,
+		TokenSecretFile:       config.TokenSecretFile,
+		TokenSecretEnv:        config.TokenSecretEnv,
+		Storage:               config.Storage,
+		TemplateStorage:       config.TemplateStorage,
+		VMIDStart:             config.VMIDStart,
+		VMIDEnd:               config.VMIDEnd,
+		SkipTLSVerify:         config.SkipTLSVerify,
+		CACertPath:            config.CACertPath,
+		ClientCertPath:        config.ClientCertPath,
+		ClientKeyPath:         config.ClientKeyPath,
+		NameTemplate:          config.NameTemplate,
+		SSHUser:               config.SSHUser,
+		SSHPort:               config.SSHPort,
+		SSHKeyPath:            config.SSHKeyPath,
+		SSHPassword:           config.SSHPassword,
+		SSHKnownHosts:         config.SSHKnownHosts,
+		MetadataKey:           config.MetadataKey,
+		TaskTimeout:           time.Duration(config.TaskTimeout) * time.Second,
+		MaxRetries:            config.MaxRetries,
+		RetryBaseDelay:        time.Duration(config.RetryBaseDelay) * time.Millisecond,
+		DryRun:                config.DryRun,
+		CacheTTL:              time.Duration(config.CacheTTL) * time.Millisecond,
+		StopTimeout:           time.Duration(config.StopTimeout) * time.Second,
+		IncludeUnmanaged:      config.IncludeUnmanaged,
+		AllNodes:              config.AllNodes,
+		BatchConcurrency:      config.BatchConcurrency,
+		DialTimeout:           time.Duration(config.DialTimeout) * time.Second,
+		ResponseHeaderTimeout: time.Duration(config.ResponseHeaderTimeout) * time.Second,
+		RequestTimeout:        time.Duration(config.RequestTimeout) * time.Second,
+		AdoptUnmanaged:        config.AdoptUnmanaged,
+		DefaultBridge:         config.DefaultBridge,
+		DefaultVLAN:           config.DefaultVLAN,
+		DebugAPI:              config.DebugAPI,
+		APITransport:          config.APITransport,
+		Pool:                  config.Pool,
+		LockTimeout:           time.Duration(config.LockTimeout) * time.Second,
+		AllocationStrategy:    config.AllocationStrategy,
+	}
+
+	backend, err := proxmox.NewMetadataBackend(config.MetadataBackend, config.MetadataPath)
+	if err != nil {
+		return nil, err
 	}
+	pxConfig.MetadataBackend = backend
 
 	return proxmox.New(pxConfig)
 }
@@ -16,34 +16,60 @@ func InitFromConfig() error {
 		runtimeType = "docker"
 	}
 
-	var runtimeConfig types.RuntimeConfig
-
-	switch runtimeType {
-	case "proxmox":
-		runtimeConfig = types.RuntimeConfig{
-			Type: types.RuntimeProxmox,
-			Proxmox: &types.ProxmoxConfig{
-				Host:          config.ProxmoxConfig.Host,
-				Node:          config.ProxmoxConfig.Node,
-				TokenID:       config.ProxmoxConfig.TokenID,
-				TokenSecret:   config.ProxmoxConfig.TokenSecret,
-				Storage:       config.ProxmoxConfig.Storage,
-				VMIDStart:     config.ProxmoxConfig.VMIDStart,
-				VMIDEnd:       config.ProxmoxConfig.VMIDEnd,
-				SkipTLSVerify: config.ProxmoxConfig.SkipTLSVerify,
-			},
-		}
-		utils.Log("Initializing Proxmox LXC runtime...")
-
-	default: // "docker" or empty
-		runtimeConfig = types.RuntimeConfig{
-			Type: types.RuntimeDocker,
-			Docker: &types.DockerConfig{
-				// Docker config is minimal - uses environment by default
-			},
-		}
-		utils.Log("Initializing Docker runtime...")
+	// Every backend gets the whole configuration, the factory registered for
+	// the runtime type picks its own section (see RegisterRuntime)
+	runtimeConfig := types.RuntimeConfig{
+		Type:   types.RuntimeType(runtimeType),
+		Docker: &types.DockerConfig{
+			// Empty fields fall back to DOCKER_HOST, DOCKER_TLS_VERIFY and DOCKER_CERT_PATH
+		},
+		Proxmox: &types.ProxmoxConfig{
+			Host:                  config.ProxmoxConfig.Host,
+			Node:                  config.ProxmoxConfig.Node,
+			TokenID:               config.ProxmoxConfig.TokenID,
+			TokenSecret:           config.ProxmoxConfig.TokenSecret,
+			TokenSecretFile:       config.ProxmoxConfig.TokenSecretFile,
+			TokenSecretEnv:        config.ProxmoxConfig.TokenSecretEnv,
+			Storage:               config.ProxmoxConfig.Storage,
+			TemplateStorage:       config.ProxmoxConfig.TemplateStorage,
+			VMIDStart:             config.ProxmoxConfig.VMIDStart,
+			VMIDEnd:               config.ProxmoxConfig.VMIDEnd,
+			SkipTLSVerify:         config.ProxmoxConfig.SkipTLSVerify,
+			CACertPath:            config.ProxmoxConfig.CACertPath,
+			ClientCertPath:        config.ProxmoxConfig.ClientCertPath,
+			ClientKeyPath:         config.ProxmoxConfig.ClientKeyPath,
+			NameTemplate:          config.ProxmoxConfig.NameTemplate,
+			SSHUser:               config.ProxmoxConfig.SSHUser,
+			SSHPort:               config.ProxmoxConfig.SSHPort,
+			SSHKeyPath:            config.ProxmoxConfig.SSHKeyPath,
+			SSHPassword:           config.ProxmoxConfig.SSHPassword,
+			SSHKnownHosts:         config.ProxmoxConfig.SSHKnownHosts,
+			MetadataKey:           config.ProxmoxConfig.MetadataKey,
+			MetadataBackend:       config.ProxmoxConfig.MetadataBackend,
+			MetadataPath:          config.ProxmoxConfig.MetadataPath,
+			TaskTimeout:           config.ProxmoxConfig.TaskTimeout,
+			MaxRetries:            config.ProxmoxConfig.MaxRetries,
+			RetryBaseDelay:        config.ProxmoxConfig.RetryBaseDelay,
+			DryRun:                config.ProxmoxConfig.DryRun,
+			CacheTTL:              config.ProxmoxConfig.CacheTTL,
+			StopTimeout:           config.ProxmoxConfig.StopTimeout,
+			IncludeUnmanaged:      config.ProxmoxConfig.IncludeUnmanaged,
+			AllNodes:              config.ProxmoxConfig.AllNodes,
+			BatchConcurrency:      config.ProxmoxConfig.BatchConcurrency,
+			DialTimeout:           config.ProxmoxConfig.DialTimeout,
+			ResponseHeaderTimeout: config.ProxmoxConfig.ResponseHeaderTimeout,
+			RequestTimeout:        config.ProxmoxConfig.RequestTimeout,
+			AdoptUnmanaged:        config.ProxmoxConfig.AdoptUnmanaged,
+			DefaultBridge:         config.ProxmoxConfig.DefaultBridge,
+			DefaultVLAN:           config.ProxmoxConfig.DefaultVLAN,
+			DebugAPI:              config.ProxmoxConfig.DebugAPI,
+			APITransport:          config.ProxmoxConfig.APITransport,
+			Pool:                  config.ProxmoxConfig.Pool,
+			LockTimeout:           config.ProxmoxConfig.LockTimeout,
+			AllocationStrategy:    config.ProxmoxConfig.AllocationStrategy,
+		},
 	}
+	utils.Log("Initializing " + runtimeType + " runtime...")
 
 	_, err := InitRuntime(runtimeConfig)
 	if err != nil {
@@ -19,9 +19,44 @@ const (
 	StateExited     = types.StateExited
 	StateDead       = types.StateDead
 
+	EventCreated = types.EventCreated
+	EventStarted = types.EventStarted
+	EventStopped = types.EventStopped
+	EventRemoved = types.EventRemoved
+
 	MountTypeBind   = types.MountTypeBind
 	MountTypeVolume = types.MountTypeVolume
 	MountTypeTmpfs  = types.MountTypeTmpfs
+
+	UpdateImage         = types.UpdateImage
+	UpdatePrivileged    = types.UpdatePrivileged
+	UpdateHostname      = types.UpdateHostname
+	UpdateMemory        = types.UpdateMemory
+	UpdateMemorySwap    = types.UpdateMemorySwap
+	UpdateCPUs          = types.UpdateCPUs
+	UpdateCPUShares     = types.UpdateCPUShares
+	UpdateStartupOrder  = types.UpdateStartupOrder
+	UpdateStartupDelay  = types.UpdateStartupDelay
+	UpdateShutdownDelay = types.UpdateShutdownDelay
+	UpdateLabels        = types.UpdateLabels
+)
+
+// Re-export errors
+var (
+	ErrNotConnected      = types.ErrNotConnected
+	ErrContainerNotFound = types.ErrContainerNotFound
+	ErrImageNotFound     = types.ErrImageNotFound
+	ErrVMIDExhausted     = types.ErrVMIDExhausted
+	ErrNotSupported      = types.ErrNotSupported
+	ErrRecreateRequired  = types.ErrRecreateRequired
+	ErrNameInUse         = types.ErrNameInUse
+	ErrNotFound          = types.ErrNotFound
+
+	PollState    = types.PollState
+	ParseMemory  = types.ParseMemory
+	FormatMemory = types.FormatMemory
+	ParseCPU     = types.ParseCPU
+	FormatCPU    = types.FormatCPU
 )
 
 // Re-export types for backward compatibility
@@ -33,8 +68,14 @@ type (
 	ContainerState        = types.ContainerState
 	ContainerDetails      = types.ContainerDetails
 	ContainerStats        = types.ContainerStats
+	ContainerEvent        = types.ContainerEvent
+	WaitTimeoutError      = types.WaitTimeoutError
+	EventAction           = types.EventAction
+	DiskUsage             = types.DiskUsage
+	FilesystemUsage       = types.FilesystemUsage
 	PortMapping           = types.PortMapping
 	VolumeMount           = types.VolumeMount
+	DeviceMapping         = types.DeviceMapping
 	MountType             = types.MountType
 	VolumeConfig          = types.VolumeConfig
 	Volume                = types.Volume
@@ -49,8 +90,19 @@ type (
 	HostConfig            = types.HostConfig
 	RestartPolicy         = types.RestartPolicy
 	HealthCheckConfig     = types.HealthCheckConfig
+	ReadinessProbe        = types.ReadinessProbe
+	Provisioning          = types.Provisioning
 	Image                 = types.Image
 	LogOptions            = types.LogOptions
+	ExecOptions           = types.ExecOptions
+	ChangeRecord          = types.ChangeRecord
+	FieldChange           = types.FieldChange
+	LabelSelector         = types.LabelSelector
+	AffinityRule          = types.AffinityRule
+	ExecResult            = types.ExecResult
+	ProcessInfo           = types.ProcessInfo
+	Progress              = types.Progress
+	CreateResult          = types.CreateResult
 	RouteConfig           = types.RouteConfig
 	SmartShieldConfig     = types.SmartShieldConfig
 	RuntimeConfig         = types.RuntimeConfig
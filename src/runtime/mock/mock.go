@@ -0,0 +1,1017 @@
+// Package mock provides an in-memory ContainerRuntime for tests of code
+// consuming the runtime, without Docker or Proxmox
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azukaar/cosmos-server/src/runtime/archive"
+	"github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// RuntimeMock is the RuntimeType reported by MockRuntime
+const RuntimeMock types.RuntimeType = "mock"
+
+// container is the state kept for one container
+type container struct {
+	types.Container
+	config types.ContainerConfig
+	files  map[string][]byte // container path -> tar stream written by CopyTo
+}
+
+// MockRuntime implements ContainerRuntime in memory. Containers follow the
+// Docker lifecycle: Create leaves them created, Start runs them, Stop exits
+// them, Pause/Unpause only apply to running/paused containers and running
+// containers cannot be removed. Errors can be injected per method with FailOn
+// and FailAlways, and canned values set with SetStats, SetExecResult, SetLogs
+// and SetProcesses.
+type MockRuntime struct {
+	mu         sync.Mutex
+	connected  bool
+	nextID     int
+	containers map[string]*container
+	networks   map[string]types.Network
+	volumes    map[string]types.Volume
+	images     map[string]types.Image
+
+	stats     map[string]types.ContainerStats
+	execs     map[string]types.ExecResult // "<id> <command>" -> result
+	logs      map[string]string
+	processes map[string][]types.ProcessInfo
+
+	subscribers []chan types.ContainerEvent
+
+	failures map[string][]error // method -> errors returned by its next calls
+	always   map[string]error   // method -> error returned by every call
+	calls    []string
+}
+
+// New creates an empty, connected mock runtime
+func New() *MockRuntime {
+	return &MockRuntime{
+		connected:  true,
+		containers: make(map[string]*container),
+		networks:   make(map[string]types.Network),
+		volumes:    make(map[string]types.Volume),
+		images:     make(map[string]types.Image),
+		stats:      make(map[string]types.ContainerStats),
+		execs:      make(map[string]types.ExecResult),
+		logs:       make(map[string]string),
+		processes:  make(map[string][]types.ProcessInfo),
+		failures:   make(map[string][]error),
+		always:     make(map[string]error),
+	}
+}
+
+// FailOn makes the next call of method (e.g. "Start") return err. Repeated
+// calls queue one error per call.
+func (m *MockRuntime) FailOn(method string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures[method] = append(m.failures[method], err)
+}
+
+// FailAlways makes every call of method return err until Reset
+func (m *MockRuntime) FailAlways(method string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.always[method] = err
+}
+
+// Reset clears the injected errors and recorded calls
+func (m *MockRuntime) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.failures = make(map[string][]error)
+	m.always = make(map[string]error)
+	m.calls = nil
+}
+
+// Calls returns the methods called so far, in order
+func (m *MockRuntime) Calls() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.calls...)
+}
+
+// SetStats sets the stats returned for a container
+func (m *MockRuntime) SetStats(id string, stats types.ContainerStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats[id] = stats
+}
+
+// SetExecResult sets the result of running cmd in a container
+func (m *MockRuntime) SetExecResult(id string, cmd []string, result types.ExecResult) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.execs[id+" "+strings.Join(cmd, " ")] = result
+}
+
+// SetLogs sets the logs returned for a container
+func (m *MockRuntime) SetLogs(id, logs string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logs[id] = logs
+}
+
+// SetProcesses sets the processes returned by Top for a container
+func (m *MockRuntime) SetProcesses(id string, processes []types.ProcessInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processes[id] = processes
+}
+
+// call records a call and returns its injected error, the caller must hold m.mu
+func (m *MockRuntime) call(method string) error {
+	m.calls = append(m.calls, method)
+
+	if err, ok := m.always[method]; ok {
+		return err
+	}
+
+	queue := m.failures[method]
+	if len(queue) == 0 {
+		return nil
+	}
+	m.failures[method] = queue[1:]
+	return queue[0]
+}
+
+// lookup finds a container by ID or name, the caller must hold m.mu
+func (m *MockRuntime) lookup(id string) (*container, error) {
+	if c, ok := m.containers[id]; ok {
+		return c, nil
+	}
+	for _, c := range m.containers {
+		if c.Name == id {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", types.ErrContainerNotFound, id)
+}
+
+// Connect marks the runtime connected
+func (m *MockRuntime) Connect() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Connect"); err != nil {
+		return err
+	}
+	m.connected = true
+	return nil
+}
+
+// IsConnected returns whether Connect succeeded since the last Close
+func (m *MockRuntime) IsConnected() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.connected
+}
+
+// Close marks the runtime disconnected
+func (m *MockRuntime) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Close"); err != nil {
+		return err
+	}
+	m.connected = false
+	return nil
+}
+
+// Describe returns config as indented JSON
+func (m *MockRuntime) Describe(config types.ContainerConfig) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Describe"); err != nil {
+		return "", err
+	}
+
+	rendered, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
+
+// Create adds a container in the created state
+func (m *MockRuntime) Create(config types.ContainerConfig) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Create"); err != nil {
+		return "", err
+	}
+	if !m.connected {
+		return "", types.ErrNotConnected
+	}
+	if config.Name != "" {
+		if _, err := m.lookup(config.Name); err == nil {
+			return "", fmt.Errorf("container name %s is already in use", config.Name)
+		}
+	}
+
+	m.nextID++
+	id := strconv.Itoa(m.nextID)
+
+	labels := make(map[string]string, len(config.Labels))
+	for k, v := range config.Labels {
+		labels[k] = v
+	}
+
+	m.containers[id] = &container{
+		Container: types.Container{
+			ID:       id,
+			Name:     config.Name,
+			Image:    config.Image,
+			State:    types.StateCreated,
+			Status:   "Created",
+			Created:  time.Now().Unix(),
+			Labels:   labels,
+			Ports:    append([]types.PortMapping(nil), config.Ports...),
+			Networks: append([]string(nil), config.Networks...),
+		},
+		config: config,
+		files:  make(map[string][]byte),
+	}
+	m.emit(m.containers[id], types.EventCreated)
+	return id, nil
+}
+
+// Start runs a created, exited or already running container
+func (m *MockRuntime) Start(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Start"); err != nil {
+		return err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	if c.State == types.StatePaused {
+		return fmt.Errorf("container %s is paused, unpause it instead", id)
+	}
+	if c.State != types.StateRunning {
+		m.emit(c, types.EventStarted)
+	}
+	c.State, c.Status = types.StateRunning, "Up"
+	return nil
+}
+
+// Stop exits a container
+func (m *MockRuntime) Stop(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Stop"); err != nil {
+		return err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	if c.State == types.StateRunning || c.State == types.StatePaused {
+		c.State, c.Status = types.StateExited, "Exited (0)"
+		m.emit(c, types.EventStopped)
+	}
+	return nil
+}
+
+// Restart stops then starts a container
+func (m *MockRuntime) Restart(id string) error {
+	m.mu.Lock()
+	err := m.call("Restart")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := m.Stop(id); err != nil {
+		return err
+	}
+	return m.Start(id)
+}
+
+// Remove deletes a container that is not running
+func (m *MockRuntime) Remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Remove"); err != nil {
+		return err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	if c.State == types.StateRunning || c.State == types.StatePaused {
+		return fmt.Errorf("container %s is running, stop it before removing", id)
+	}
+	delete(m.containers, c.ID)
+	m.emit(c, types.EventRemoved)
+	return nil
+}
+
+// Pause freezes a running container
+func (m *MockRuntime) Pause(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Pause"); err != nil {
+		return err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	if c.State != types.StateRunning {
+		return fmt.Errorf("container %s is not running", id)
+	}
+	c.State, c.Status = types.StatePaused, "Up (Paused)"
+	return nil
+}
+
+// Unpause resumes a paused container
+func (m *MockRuntime) Unpause(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Unpause"); err != nil {
+		return err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	if c.State != types.StatePaused {
+		return fmt.Errorf("container %s is not paused", id)
+	}
+	c.State, c.Status = types.StateRunning, "Up"
+	return nil
+}
+
+// Recreate replaces a container with a new one built from config, keeping its networks if unset
+func (m *MockRuntime) Recreate(id string, config types.ContainerConfig) (string, error) {
+	m.mu.Lock()
+	err := m.call("Recreate")
+	var networks []string
+	if c, lookupErr := m.lookup(id); lookupErr == nil {
+		networks = c.Networks
+	} else if err == nil {
+		err = lookupErr
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if err := m.Stop(id); err != nil {
+		return "", err
+	}
+	if err := m.Remove(id); err != nil {
+		return "", err
+	}
+	if len(config.Networks) == 0 {
+		config.Networks = networks
+	}
+	return m.Create(config)
+}
+
+// Update changes the resources and labels of a container in place
+func (m *MockRuntime) Update(id string, config types.ContainerConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Update"); err != nil {
+		return err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	if err := config.ValidateUpdateFields(); err != nil {
+		return err
+	}
+	if config.Updates(types.UpdateImage, config.Image != "") && config.Image != c.Image {
+		return fmt.Errorf("%w: image %s -> %s", types.ErrRecreateRequired, c.Image, config.Image)
+	}
+	if config.Updates(types.UpdatePrivileged, config.Privileged) && config.Privileged != c.config.Privileged {
+		return fmt.Errorf("%w: privileged mode", types.ErrRecreateRequired)
+	}
+
+	if config.Updates(types.UpdateHostname, config.Hostname != "") {
+		c.config.Hostname = config.Hostname
+	}
+	if config.Updates(types.UpdateMemory, config.Memory > 0) {
+		c.config.Memory = config.Memory
+	}
+	if config.Updates(types.UpdateMemorySwap, config.MemorySwap != nil) {
+		c.config.MemorySwap = config.MemorySwap
+	}
+	if config.Updates(types.UpdateCPUs, config.CPUs > 0) {
+		c.config.CPUs = config.CPUs
+	}
+	if config.Updates(types.UpdateCPUShares, config.CPUShares > 0) {
+		c.config.CPUShares = config.CPUShares
+	}
+	if config.Updates(types.UpdateStartupOrder, config.StartupOrder != 0) {
+		c.config.StartupOrder = config.StartupOrder
+	}
+	if config.Updates(types.UpdateStartupDelay, config.StartupDelay != 0) {
+		c.config.StartupDelay = config.StartupDelay
+	}
+	if config.Updates(types.UpdateShutdownDelay, config.ShutdownDelay != 0) {
+		c.config.ShutdownDelay = config.ShutdownDelay
+	}
+	if config.Updates(types.UpdateLabels, config.Labels != nil) {
+		c.Labels = make(map[string]string, len(config.Labels))
+		for k, v := range config.Labels {
+			c.Labels[k] = v
+		}
+		c.config.Labels = config.Labels
+	}
+	return nil
+}
+
+// Rename changes the name of a container
+func (m *MockRuntime) Rename(id string, newName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Rename"); err != nil {
+		return err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+	if other, err := m.lookup(newName); err == nil && other != c {
+		return fmt.Errorf("%w: %s", types.ErrNameInUse, newName)
+	}
+
+	c.Name = newName
+	c.config.Name = newName
+	return nil
+}
+
+// List returns every container, sorted by ID
+func (m *MockRuntime) List() ([]types.Container, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("List"); err != nil {
+		return nil, err
+	}
+
+	containers := make([]types.Container, 0, len(m.containers))
+	for _, c := range m.containers {
+		containers = append(containers, c.Container)
+	}
+	sort.Slice(containers, func(i, j int) bool {
+		a, _ := strconv.Atoi(containers[i].ID)
+		b, _ := strconv.Atoi(containers[j].ID)
+		return a < b
+	})
+	return containers, nil
+}
+
+// FindByName returns the container with the given name
+func (m *MockRuntime) FindByName(name string) (*types.Container, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("FindByName"); err != nil {
+		return nil, err
+	}
+	for _, c := range m.containers {
+		if c.Name == name {
+			found := c.Container
+			return &found, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", types.ErrContainerNotFound, name)
+}
+
+// WaitForState polls the container state until it is state or timeout elapses
+func (m *MockRuntime) WaitForState(id string, state types.ContainerState, timeout time.Duration) error {
+	m.mu.Lock()
+	err := m.call("WaitForState")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return types.PollState(id, state, timeout, func() (types.ContainerState, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		c, err := m.lookup(id)
+		if err != nil {
+			return "", err
+		}
+		return c.State, nil
+	})
+}
+
+// Inspect returns the container and the config it was created with
+func (m *MockRuntime) Inspect(id string) (*types.ContainerDetails, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Inspect"); err != nil {
+		return nil, err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints := make(map[string]types.NetworkEndpoint, len(c.Networks))
+	for _, network := range c.Networks {
+		endpoints[network] = types.NetworkEndpoint{NetworkID: network}
+	}
+
+	return &types.ContainerDetails{
+		Container:       c.Container,
+		Config:          c.config,
+		NetworkSettings: types.NetworkSettings{Networks: endpoints},
+		Mounts:          c.config.Volumes,
+		HostConfig: types.HostConfig{
+			RestartPolicy: c.config.RestartPolicy,
+			Privileged:    c.config.Privileged,
+			DNS:           c.config.DNS,
+			DNSSearch:     c.config.DNSSearch,
+			ExtraHosts:    c.config.ExtraHosts,
+			CapAdd:        c.config.CapAdd,
+			CapDrop:       c.config.CapDrop,
+		},
+	}, nil
+}
+
+// Logs returns the logs set with SetLogs
+func (m *MockRuntime) Logs(id string, opts types.LogOptions) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Logs"); err != nil {
+		return nil, err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(m.logs[c.ID])), nil
+}
+
+// Stats returns the stats set with SetStats, zero usage otherwise
+func (m *MockRuntime) Stats(id string) (*types.ContainerStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Stats"); err != nil {
+		return nil, err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	stats := m.containerStats(c)
+	return &stats, nil
+}
+
+// StatsAll returns the stats of every running container
+func (m *MockRuntime) StatsAll() ([]types.ContainerStats, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("StatsAll"); err != nil {
+		return nil, err
+	}
+
+	var all []types.ContainerStats
+	for _, c := range m.containers {
+		if c.State == types.StateRunning {
+			all = append(all, m.containerStats(c))
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all, nil
+}
+
+// Events streams the lifecycle changes made through the mock until ctx is done.
+// Events are dropped when the reader falls more than 64 behind
+func (m *MockRuntime) Events(ctx context.Context) (<-chan types.ContainerEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Events"); err != nil {
+		return nil, err
+	}
+
+	out := make(chan types.ContainerEvent, 64)
+	m.subscribers = append(m.subscribers, out)
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for i, sub := range m.subscribers {
+			if sub == out {
+				m.subscribers = append(m.subscribers[:i], m.subscribers[i+1:]...)
+				break
+			}
+		}
+		close(out)
+	}()
+	return out, nil
+}
+
+// emit sends a lifecycle event to the Events subscribers, the caller must hold m.mu
+func (m *MockRuntime) emit(c *container, action types.EventAction) {
+	event := types.ContainerEvent{ID: c.ID, Name: c.Name, Action: action, Time: time.Now().Unix()}
+	for _, sub := range m.subscribers {
+		select {
+		case sub <- event:
+		default:
+		}
+	}
+}
+
+// containerStats returns the canned stats of a container, the caller must hold m.mu
+func (m *MockRuntime) containerStats(c *container) types.ContainerStats {
+	stats, ok := m.stats[c.ID]
+	if !ok {
+		stats = m.stats[c.Name]
+	}
+	stats.ID, stats.Name = c.ID, c.Name
+	return stats
+}
+
+// Top returns the processes set with SetProcesses
+func (m *MockRuntime) Top(id string) ([]types.ProcessInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Top"); err != nil {
+		return nil, err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if c.State != types.StateRunning {
+		return nil, fmt.Errorf("container %s is not running", id)
+	}
+	return m.processes[c.ID], nil
+}
+
+// CreateNetwork adds a network
+func (m *MockRuntime) CreateNetwork(config types.NetworkConfig) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("CreateNetwork"); err != nil {
+		return "", err
+	}
+	if _, ok := m.networks[config.Name]; ok {
+		return "", fmt.Errorf("network %s already exists", config.Name)
+	}
+
+	m.networks[config.Name] = types.Network{
+		ID:       config.Name,
+		Name:     config.Name,
+		Driver:   config.Driver,
+		Scope:    "local",
+		Internal: config.Internal,
+		Labels:   config.Labels,
+		IPAM:     config.IPAM,
+	}
+	return config.Name, nil
+}
+
+// RemoveNetwork deletes a network no container is connected to
+func (m *MockRuntime) RemoveNetwork(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("RemoveNetwork"); err != nil {
+		return err
+	}
+	if _, ok := m.networks[id]; !ok {
+		return fmt.Errorf("network %s not found", id)
+	}
+	for _, c := range m.containers {
+		for _, network := range c.Networks {
+			if network == id {
+				return fmt.Errorf("network %s is in use by container %s", id, c.ID)
+			}
+		}
+	}
+	delete(m.networks, id)
+	return nil
+}
+
+// ListNetworks returns every network, sorted by name
+func (m *MockRuntime) ListNetworks() ([]types.Network, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("ListNetworks"); err != nil {
+		return nil, err
+	}
+
+	networks := make([]types.Network, 0, len(m.networks))
+	for _, network := range m.networks {
+		networks = append(networks, network)
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].Name < networks[j].Name })
+	return networks, nil
+}
+
+// ConnectToNetwork adds the network to the container networks
+func (m *MockRuntime) ConnectToNetwork(containerID, networkID string, opts types.NetworkConnectOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("ConnectToNetwork"); err != nil {
+		return err
+	}
+	c, err := m.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.networks[networkID]; !ok {
+		return fmt.Errorf("network %s not found", networkID)
+	}
+	for _, network := range c.Networks {
+		if network == networkID {
+			return fmt.Errorf("container %s is already connected to network %s", containerID, networkID)
+		}
+	}
+	c.Networks = append(c.Networks, networkID)
+	return nil
+}
+
+// DisconnectFromNetwork removes the network from the container networks
+func (m *MockRuntime) DisconnectFromNetwork(containerID, networkID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("DisconnectFromNetwork"); err != nil {
+		return err
+	}
+	c, err := m.lookup(containerID)
+	if err != nil {
+		return err
+	}
+	for i, network := range c.Networks {
+		if network == networkID {
+			c.Networks = append(c.Networks[:i:i], c.Networks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("container %s is not connected to network %s", containerID, networkID)
+}
+
+// CreateVolume adds a volume
+func (m *MockRuntime) CreateVolume(config types.VolumeConfig) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("CreateVolume"); err != nil {
+		return "", err
+	}
+	if _, ok := m.volumes[config.Name]; ok {
+		return "", fmt.Errorf("volume %s already exists", config.Name)
+	}
+
+	m.volumes[config.Name] = types.Volume{
+		Name:       config.Name,
+		Driver:     config.Driver,
+		Mountpoint: "/mock/volumes/" + config.Name,
+		Labels:     config.Labels,
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		Size:       config.Size,
+	}
+	return config.Name, nil
+}
+
+// RemoveVolume deletes a volume
+func (m *MockRuntime) RemoveVolume(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("RemoveVolume"); err != nil {
+		return err
+	}
+	if _, ok := m.volumes[id]; !ok {
+		return fmt.Errorf("volume %s not found", id)
+	}
+	delete(m.volumes, id)
+	return nil
+}
+
+// ListVolumes returns every volume, sorted by name
+func (m *MockRuntime) ListVolumes() ([]types.Volume, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("ListVolumes"); err != nil {
+		return nil, err
+	}
+
+	volumes := make([]types.Volume, 0, len(m.volumes))
+	for _, volume := range m.volumes {
+		volumes = append(volumes, volume)
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+	return volumes, nil
+}
+
+// Exec returns the result set with SetExecResult, an empty success otherwise
+func (m *MockRuntime) Exec(id string, cmd []string, opts types.ExecOptions) (*types.ExecResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("Exec"); err != nil {
+		return nil, err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	if c.State != types.StateRunning {
+		return nil, fmt.Errorf("container %s is not running", id)
+	}
+
+	result := m.execs[c.ID+" "+strings.Join(cmd, " ")]
+	return &result, nil
+}
+
+// CopyTo stores localPath as a tar stream, returned by CopyFrom for the same containerPath
+func (m *MockRuntime) CopyTo(id string, localPath string, containerPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("CopyTo"); err != nil {
+		return err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := archive.WritePath(&buf, localPath, path.Base(containerPath)); err != nil {
+		return err
+	}
+	c.files[path.Clean(containerPath)] = buf.Bytes()
+	return nil
+}
+
+// CopyFrom writes the tar stream stored by CopyTo for containerPath
+func (m *MockRuntime) CopyFrom(id string, containerPath string, localWriter io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("CopyFrom"); err != nil {
+		return err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	data, ok := c.files[path.Clean(containerPath)]
+	if !ok {
+		return fmt.Errorf("%s: no such file or directory in container %s", containerPath, id)
+	}
+	_, err = localWriter.Write(data)
+	return err
+}
+
+// CopyToContainer stores content as the file dstPath, read back by CopyFrom and CopyFromContainer
+func (m *MockRuntime) CopyToContainer(id string, dstPath string, content io.Reader, mode os.FileMode) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("CopyToContainer"); err != nil {
+		return err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := archive.WriteFile(&buf, path.Base(dstPath), bytes.NewReader(data), int64(len(data)), mode); err != nil {
+		return err
+	}
+	c.files[path.Clean(dstPath)] = buf.Bytes()
+	return nil
+}
+
+// CopyFromContainer returns the content of a file stored by CopyTo or CopyToContainer
+func (m *MockRuntime) CopyFromContainer(id string, srcPath string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("CopyFromContainer"); err != nil {
+		return nil, err
+	}
+	c, err := m.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := c.files[path.Clean(srcPath)]
+	if !ok {
+		return nil, fmt.Errorf("%s: no such file or directory in container %s", srcPath, id)
+	}
+	file, err := archive.ReadFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(file), nil
+}
+
+// PullImage adds an image
+func (m *MockRuntime) PullImage(ref string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("PullImage"); err != nil {
+		return nil, err
+	}
+
+	m.images[ref] = types.Image{ID: ref, Name: ref, Tags: []string{ref}, Created: time.Now().Unix()}
+	return io.NopCloser(strings.NewReader("Pulled " + ref + "\n")), nil
+}
+
+// ListImages returns every pulled image, sorted by name
+func (m *MockRuntime) ListImages() ([]types.Image, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("ListImages"); err != nil {
+		return nil, err
+	}
+
+	images := make([]types.Image, 0, len(m.images))
+	for _, image := range m.images {
+		images = append(images, image)
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].Name < images[j].Name })
+	return images, nil
+}
+
+// RemoveImage deletes a pulled image
+func (m *MockRuntime) RemoveImage(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.call("RemoveImage"); err != nil {
+		return err
+	}
+	if _, ok := m.images[id]; !ok {
+		return fmt.Errorf("%w: %s", types.ErrImageNotFound, id)
+	}
+	delete(m.images, id)
+	return nil
+}
+
+// RuntimeType returns RuntimeMock
+func (m *MockRuntime) RuntimeType() types.RuntimeType {
+	return RuntimeMock
+}
+
+// Version returns a fixed version
+func (m *MockRuntime) Version() string {
+	return "mock"
+}
+
+var _ types.ContainerRuntime = (*MockRuntime)(nil)
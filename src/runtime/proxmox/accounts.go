@@ -0,0 +1,154 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// First-boot accounts and SSH keys
+// LXC has no cloud-init: the only provisioning option Proxmox offers at
+// creation is ssh-public-keys, installed for root. Root keys go through it,
+// everything else (another user, a password hash, the first-boot commands)
+// is kept in the cosmos-secret.provisioning label, encrypted at rest, and
+// applied with a script fed on stdin at the first Start, so neither the
+// hash nor the keys show up in process arguments or logs
+
+// LabelProvisioning holds the pending provisioning applied at the first start
+const LabelProvisioning = "cosmos-secret.provisioning"
+
+var (
+	userNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+	sshKeyPattern   = regexp.MustCompile(`^(ssh-(rsa|ed25519|dss)|ecdsa-sha2-nistp(256|384|521)|sk-(ssh-ed25519|ecdsa-sha2-nistp256)@openssh\.com) [A-Za-z0-9+/=]+( .*)?$`)
+)
+
+// validateProvisioning checks the account, password hash and keys of a provisioning section
+func validateProvisioning(prov *runtime.Provisioning) error {
+	if prov == nil {
+		return nil
+	}
+	if prov.User != "" && !userNamePattern.MatchString(prov.User) {
+		return fmt.Errorf("invalid provisioning user %q", prov.User)
+	}
+	if prov.PasswordHash != "" && (!strings.HasPrefix(prov.PasswordHash, "$") || strings.ContainsAny(prov.PasswordHash, ": \t\n")) {
+		return fmt.Errorf("provisioning password must be a crypt(3) hash such as $6$..., not a plain password")
+	}
+	for i, key := range prov.SSHKeys {
+		if strings.Contains(key, "PRIVATE KEY") {
+			return fmt.Errorf("provisioning SSH key %d is a private key, only public keys are accepted", i+1)
+		}
+		if !sshKeyPattern.MatchString(strings.TrimSpace(key)) {
+			return fmt.Errorf("provisioning SSH key %d is not an OpenSSH public key", i+1)
+		}
+	}
+	return nil
+}
+
+// rootSSHKeys renders the ssh-public-keys option, for keys installed for root
+func rootSSHKeys(prov *runtime.Provisioning) string {
+	if prov == nil || !isRootUser(prov.User) {
+		return ""
+	}
+	keys := make([]string, len(prov.SSHKeys))
+	for i, key := range prov.SSHKeys {
+		keys[i] = strings.TrimSpace(key)
+	}
+	return strings.Join(keys, "\n")
+}
+
+func isRootUser(user string) bool {
+	return user == "" || user == "root"
+}
+
+// storeProvisioning keeps the provisioning steps the create options cannot do
+func (p *ProxmoxRuntime) storeProvisioning(vmid int, prov *runtime.Provisioning) {
+	if prov == nil {
+		return
+	}
+
+	pending := *prov
+	if isRootUser(pending.User) {
+		pending.User = ""
+		pending.SSHKeys = nil
+	}
+	if pending.User == "" && pending.PasswordHash == "" && len(pending.Commands) == 0 {
+		return
+	}
+
+	encoded, err := json.Marshal(pending)
+	if err != nil {
+		return
+	}
+	p.metadata.SetLabel(vmid, LabelProvisioning, string(encoded))
+}
+
+// applyProvisioning sets up the account and runs the first-boot commands of a started container, if still pending
+func (p *ProxmoxRuntime) applyProvisioning(vmid int, report progressFunc) error {
+	value := p.metadata.GetLabel(vmid, LabelProvisioning)
+	if value == "" {
+		return nil
+	}
+
+	var prov runtime.Provisioning
+	if err := json.Unmarshal([]byte(value), &prov); err != nil {
+		return fmt.Errorf("invalid provisioning of container %d: %w", vmid, err)
+	}
+
+	user := prov.User
+	if user == "" {
+		user = "root"
+	}
+	utils.Log(fmt.Sprintf("Provisioning LXC container VMID %d: user %s, %d SSH keys, password set: %t, %d commands",
+		vmid, user, len(prov.SSHKeys), prov.PasswordHash != "", len(prov.Commands)))
+	report(PhaseProvision, "Setting up the container account", 86, false)
+
+	id := fmt.Sprint(vmid)
+	result, err := p.execWithInput(id, []string{"sh", "-s"}, runtime.ExecOptions{}, strings.NewReader(provisioningScript(user, prov)))
+	if err != nil {
+		return fmt.Errorf("failed to provision container %s: %w", id, err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("provisioning of container %s exited with code %d: %s", id, result.ExitCode, result.Stderr)
+	}
+
+	return p.metadata.UpdateLabels(vmid, nil, []string{LabelProvisioning})
+}
+
+// provisioningScript renders the shell script creating the account, read by sh on stdin
+func provisioningScript(user string, prov runtime.Provisioning) string {
+	var script strings.Builder
+	script.WriteString("set -e\n")
+	fmt.Fprintf(&script, "user=%s\n", shellQuote(user))
+	script.WriteString(`if ! id "$user" >/dev/null 2>&1; then
+  if command -v useradd >/dev/null 2>&1; then useradd -m -s /bin/sh "$user"; else adduser -D -s /bin/sh "$user"; fi
+fi
+`)
+
+	if prov.PasswordHash != "" {
+		fmt.Fprintf(&script, "printf '%%s\\n' %s | chpasswd -e\n", shellQuote(user+":"+prov.PasswordHash))
+	}
+
+	if len(prov.SSHKeys) > 0 {
+		script.WriteString(`home=$(getent passwd "$user" | cut -d: -f6)
+mkdir -p "$home/.ssh"
+cat > "$home/.ssh/authorized_keys" <<'COSMOS_KEYS'
+`)
+		for _, key := range prov.SSHKeys {
+			script.WriteString(strings.TrimSpace(key) + "\n")
+		}
+		script.WriteString(`COSMOS_KEYS
+chmod 700 "$home/.ssh"
+chmod 600 "$home/.ssh/authorized_keys"
+chown -R "$user" "$home/.ssh"
+`)
+	}
+
+	for _, command := range prov.Commands {
+		fmt.Fprintf(&script, "sh -c %s\n", shellQuote(command))
+	}
+	return script.String()
+}
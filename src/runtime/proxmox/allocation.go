@@ -0,0 +1,64 @@
+package proxmox
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// VMID allocation strategies
+// Config.AllocationStrategy picks where getNextVMID starts looking for a free
+// VMID in the configured range:
+//   - lowest-free (default): the lowest free VMID, reusing the VMIDs of removed guests
+//   - sequential: after the highest VMID in use, so VMIDs are not reused until the end of the range is reached
+//   - name-hashed: a slot derived from the container name, so the same app gets
+//     the same VMID on every cluster
+// Whatever the start, taken VMIDs are skipped and the search wraps around the
+// range, so every strategy falls back to the next free VMID on collision
+
+const (
+	AllocateLowestFree = "lowest-free"
+	AllocateSequential = "sequential"
+	AllocateNameHashed = "name-hashed"
+)
+
+// validateAllocationStrategy checks the configured strategy is known
+func validateAllocationStrategy(strategy string) error {
+	switch strategy {
+	case "", AllocateLowestFree, AllocateSequential, AllocateNameHashed:
+		return nil
+	}
+	return fmt.Errorf("unknown VMID allocation strategy %q, use %s, %s or %s", strategy, AllocateLowestFree, AllocateSequential, AllocateNameHashed)
+}
+
+// allocationStart returns the first VMID to try for a container named name.
+// used holds the VMIDs in use, nil when they are unknown. The caller must hold p.mutex
+func (p *ProxmoxRuntime) allocationStart(name string, used map[int]bool) int {
+	switch p.config.AllocationStrategy {
+	case AllocateSequential:
+		start := p.vmidCounter
+		for vmid := range used {
+			if vmid >= start && vmid < p.config.VMIDEnd {
+				start = vmid + 1
+			}
+		}
+		return start
+
+	case AllocateNameHashed:
+		if name != "" {
+			return hashedVMID(name, p.config.VMIDStart, p.config.VMIDEnd)
+		}
+	}
+
+	// Without the used VMIDs, the local counter skips the ones handed out already
+	if used == nil {
+		return p.vmidCounter
+	}
+	return p.config.VMIDStart
+}
+
+// hashedVMID maps name to a VMID of the range start-end (end excluded)
+func hashedVMID(name string, start, end int) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return start + int(h.Sum32()%uint32(end-start))
+}
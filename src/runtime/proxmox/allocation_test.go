@@ -0,0 +1,189 @@
+package proxmox
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestGetNextVMID(t *testing.T) {
+	// 100-109, with gaps at 102, 104 and 106-109
+	existing := map[int]string{100: "lxc", 101: "lxc", 103: "qemu", 105: "lxc"}
+
+	tests := []struct {
+		name     string
+		strategy string
+		want     []int // successive allocations, without releasing
+		wantErr  error
+	}{
+		{"lowest free", AllocateLowestFree, []int{102, 104, 106, 107}, nil},
+		{"default", "", []int{102, 104, 106}, nil},
+		{"sequential", AllocateSequential, []int{106, 107, 108, 109, 102, 104}, nil},
+		{"exhausted", AllocateLowestFree, []int{102, 104, 106, 107, 108, 109}, runtime.ErrVMIDExhausted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			for vmid, kind := range existing {
+				cluster.addGuest(vmid, fakeGuest{Type: kind})
+			}
+			p := newTestRuntime(t, cluster, func(c *Config) {
+				c.AllocationStrategy = tt.strategy
+				c.VMIDEnd = 110
+			})
+
+			var got []int
+			for range tt.want {
+				vmid, err := p.getNextVMID("app")
+				if err != nil {
+					t.Fatalf("getNextVMID after %v: %v", got, err)
+				}
+				got = append(got, vmid)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allocated %v, want %v", got, tt.want)
+			}
+
+			if tt.wantErr != nil {
+				if _, err := p.getNextVMID("app"); !errors.Is(err, tt.wantErr) {
+					t.Errorf("getNextVMID error = %v, want %v", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestGetNextVMIDReleased(t *testing.T) {
+	cluster := newFakeCluster(t)
+	cluster.addGuest(100, fakeGuest{})
+	p := newTestRuntime(t, cluster)
+
+	first, _ := p.getNextVMID("a")
+	second, _ := p.getNextVMID("b")
+	p.releaseVMID(first)
+	third, _ := p.getNextVMID("c")
+
+	if first != 101 || second != 102 || third != 101 {
+		t.Errorf("allocated %d, %d then %d after releasing %d, want 101, 102, 101", first, second, third, first)
+	}
+}
+
+func TestNameHashedVMID(t *testing.T) {
+	cluster := newFakeCluster(t)
+	p := newTestRuntime(t, cluster, func(c *Config) { c.AllocationStrategy = AllocateNameHashed })
+
+	slot := hashedVMID("nextcloud", 100, 200)
+	cluster.addGuest(slot, fakeGuest{Type: "qemu"})
+
+	vmid, err := p.getNextVMID("nextcloud")
+	if err != nil {
+		t.Fatalf("getNextVMID: %v", err)
+	}
+	want := slot + 1
+	if want == 200 {
+		want = 100
+	}
+	if vmid != want {
+		t.Errorf("allocated %d with slot %d taken, want %d", vmid, slot, want)
+	}
+}
+
+func TestCreateVMIDTakenMeanwhile(t *testing.T) {
+	cluster := newFakeCluster(t)
+	cluster.addGuest(100, fakeGuest{})
+	cluster.addGuest(102, fakeGuest{Type: "qemu"})
+	p := newTestRuntime(t, cluster)
+
+	// Another controller takes the first two VMIDs picked, between the listing and the create
+	var attempted []int
+	cluster.handle("POST /nodes/pve/lxc", func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+		vmid := int(floatValue(body["vmid"]))
+		attempted = append(attempted, vmid)
+		if len(attempted) <= 2 {
+			cluster.addGuest(vmid, fakeGuest{Type: "qemu"})
+		}
+		return cluster.route(r, strings.TrimPrefix(r.URL.Path, "/api2/json"), body)
+	})
+
+	id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if id != "104" || !reflect.DeepEqual(attempted, []int{101, 103, 104}) {
+		t.Errorf("created %s after trying %v, want 104 after 101 and 103", id, attempted)
+	}
+	if p.metadata.Get(101) != nil || p.metadata.Get(103) != nil {
+		t.Errorf("metadata stored for the VMIDs taken by the other controller")
+	}
+}
+
+func TestCreateMixedGuests(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing map[int]fakeGuest
+		want     []string
+	}{
+		{
+			name:     "QEMU on the same node",
+			existing: map[int]fakeGuest{100: {Type: "qemu"}, 101: {Type: "lxc"}, 103: {Type: "qemu"}},
+			want:     []string{"102", "104", "105"},
+		},
+		{
+			name:     "QEMU on another node",
+			existing: map[int]fakeGuest{100: {Type: "qemu", Node: "pve2"}, 102: {Type: "qemu", Node: "pve2"}, 103: {Type: "lxc", Node: "pve2"}},
+			want:     []string{"101", "104", "105"},
+		},
+		{
+			name:     "stopped and running VMs",
+			existing: map[int]fakeGuest{100: {Type: "qemu", Status: "running"}, 101: {Type: "qemu"}, 102: {Type: "qemu", Node: "pve2", Status: "running"}},
+			want:     []string{"103", "104", "105"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t, "pve", "pve2")
+			for vmid, guest := range tt.existing {
+				cluster.addGuest(vmid, guest)
+			}
+			p := newTestRuntime(t, cluster)
+
+			var attempted []int
+			cluster.handle("POST /nodes/pve/lxc", func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+				attempted = append(attempted, int(floatValue(body["vmid"])))
+				return cluster.route(r, strings.TrimPrefix(r.URL.Path, "/api2/json"), body)
+			})
+
+			var got []string
+			for range tt.want {
+				id, err := p.Create(runtime.ContainerConfig{Name: "app" + itoa(len(got)), Image: testImage})
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+				got = append(got, id)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("created %v, want %v", got, tt.want)
+			}
+			if len(attempted) != len(tt.want) {
+				t.Errorf("%d creations on pve, want %d", len(attempted), len(tt.want))
+			}
+			for _, vmid := range attempted {
+				if _, taken := tt.existing[vmid]; taken {
+					t.Errorf("tried to create VMID %d of an existing %s", vmid, tt.existing[vmid].Type)
+				}
+			}
+			for vmid, guest := range tt.existing {
+				if g := cluster.guest(vmid); g == nil || g.Type != guest.Type {
+					t.Errorf("guest %d replaced", vmid)
+				}
+			}
+		})
+	}
+}
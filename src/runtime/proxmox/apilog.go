@@ -0,0 +1,88 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// API call tracing
+// With Config.DebugAPI every API call is logged at debug level, and
+// Config.OnAPICall receives each call for custom handling. Bodies are
+// redacted before they leave the runtime: values of JSON keys that look like
+// credentials (see isSensitiveKey), such as the generated root password, are
+// replaced and long bodies are truncated. Headers, and so the API token, are
+// never passed on
+
+const maxTracedBody = 4096
+
+// APICall describes one Proxmox API call, with redacted bodies
+type APICall struct {
+	Method       string
+	Path         string
+	StatusCode   int // 0 when no response was received
+	Duration     time.Duration
+	RequestBody  string
+	ResponseBody string
+	Err          error
+}
+
+// tracing reports whether API calls are traced
+func (p *ProxmoxRuntime) tracing() bool {
+	return p.config.DebugAPI || p.config.OnAPICall != nil
+}
+
+// traceCall passes a finished API call to the logger and the hook
+func (p *ProxmoxRuntime) traceCall(call APICall) {
+	if p.config.DebugAPI {
+		status := fmt.Sprint(call.StatusCode)
+		if call.Err != nil && call.StatusCode == 0 {
+			status = call.Err.Error()
+		}
+		utils.Debug(fmt.Sprintf("Proxmox API %s %s -> %s in %s, request: %s, response: %s",
+			call.Method, call.Path, status, call.Duration.Round(time.Millisecond), call.RequestBody, call.ResponseBody))
+	}
+	if p.config.OnAPICall != nil {
+		p.config.OnAPICall(call)
+	}
+}
+
+// redactBody returns a body safe to log
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err == nil {
+		if encoded, err := json.Marshal(redactJSON(value)); err == nil {
+			body = encoded
+		}
+	}
+
+	if len(body) > maxTracedBody {
+		return string(body[:maxTracedBody]) + fmt.Sprintf("... (%d bytes)", len(body))
+	}
+	return string(body)
+}
+
+// redactJSON replaces the values of sensitive keys in decoded JSON
+func redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSensitiveKey(key) {
+				v[key] = "***"
+			} else {
+				v[key] = redactJSON(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+	}
+	return value
+}
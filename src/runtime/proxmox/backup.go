@@ -0,0 +1,223 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Container backups
+// Backup runs vzdump into a storage holding backup content and returns the
+// volume ID of the archive. The name of the container it was taken from is
+// kept with the volume labels (cosmos-name, cosmos-backup-of), so that
+// Restore can bring it back under the same name in a new container
+
+const (
+	// LabelBackupOf holds the VMID a backup was taken from
+	LabelBackupOf = "cosmos-backup-of"
+
+	defaultBackupStorage = "local"
+)
+
+var (
+	backupCompressions = map[string]bool{"0": true, "gzip": true, "lzo": true, "zstd": true}
+	backupModes        = map[string]bool{"snapshot": true, "suspend": true, "stop": true}
+)
+
+// BackupOptions configures a backup
+type BackupOptions struct {
+	Storage  string // storage receiving the archive, defaults to "local"
+	Compress string // 0, gzip, lzo or zstd (default)
+	Mode     string // snapshot (default), suspend or stop
+	Notes    string
+}
+
+// Backup archives a container with vzdump, waits for the task and returns the backup volume ID
+func (p *ProxmoxRuntime) Backup(id string, opts BackupOptions) (string, error) {
+	if !p.connected {
+		return "", errNotConnected
+	}
+
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid container ID: %s", id)
+	}
+	node := p.nodeFor(vmid)
+
+	if opts.Storage == "" {
+		opts.Storage = defaultBackupStorage
+	}
+	if opts.Compress == "" {
+		opts.Compress = "zstd"
+	}
+	if opts.Mode == "" {
+		opts.Mode = "snapshot"
+	}
+	if !backupCompressions[opts.Compress] {
+		return "", fmt.Errorf("invalid backup compression %q (0, gzip, lzo or zstd)", opts.Compress)
+	}
+	if !backupModes[opts.Mode] {
+		return "", fmt.Errorf("invalid backup mode %q (snapshot, suspend or stop)", opts.Mode)
+	}
+
+	body := map[string]interface{}{
+		"vmid":     vmid,
+		"storage":  opts.Storage,
+		"compress": opts.Compress,
+		"mode":     opts.Mode,
+	}
+	if opts.Notes != "" {
+		body["notes-template"] = opts.Notes
+	}
+	encoded, _ := json.Marshal(body)
+
+	resp, err := p.apiRequest("POST", fmt.Sprintf("/nodes/%s/vzdump", node), strings.NewReader(string(encoded)))
+	if isNotFound(err) {
+		return "", p.notFound(vmid)
+	}
+	if err == nil {
+		err = p.waitForTask(taskUPID(resp))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to back up container %s: %w", id, err)
+	}
+
+	volid, err := p.latestBackup(node, opts.Storage, vmid)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the backup of container %s: %w", id, err)
+	}
+
+	p.metadata.SetVolumeLabels(volid, map[string]string{
+		"cosmos-name": p.metadata.GetLabel(vmid, "cosmos-name"),
+		LabelBackupOf: id,
+	})
+
+	utils.Log(fmt.Sprintf("Backed up LXC container VMID %d to %s", vmid, volid))
+	return volid, nil
+}
+
+// latestBackup returns the volume ID of the newest backup of vmid in storage
+func (p *ProxmoxRuntime) latestBackup(node, storage string, vmid int) (string, error) {
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/storage/%s/content?content=backup&vmid=%d", node, storage, vmid), nil)
+	if err != nil {
+		return "", err
+	}
+
+	latest, created := "", -1.0
+	for _, item := range listItems(resp) {
+		volid, _ := item["volid"].(string)
+		if ctime := floatValue(item["ctime"]); volid != "" && ctime > created {
+			latest, created = volid, ctime
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no backup in storage %s", storage)
+	}
+	return latest, nil
+}
+
+// Restore creates a new container from a backup volume ID. config.Name defaults
+// to the name of the container the backup was taken from; config.Labels are
+// added and Memory and CPUs override the backed up settings when set
+func (p *ProxmoxRuntime) Restore(backupRef string, config runtime.ContainerConfig) (string, error) {
+	defer p.cache.invalidate()
+
+	if !p.connected {
+		return "", errNotConnected
+	}
+	if !strings.Contains(backupRef, ":") {
+		return "", fmt.Errorf("invalid backup reference %q (storage:backup/file)", backupRef)
+	}
+
+	origin := p.metadata.VolumeLabels(backupRef)
+	if config.Name == "" {
+		config.Name = origin["cosmos-name"]
+	}
+	if config.Name == "" {
+		return "", fmt.Errorf("backup %s has no known container name, set one in config", backupRef)
+	}
+
+	node, err := p.CheckAffinity(config)
+	if err != nil {
+		return "", err
+	}
+
+	body := map[string]interface{}{
+		"ostemplate": backupRef,
+		"restore":    1,
+		"storage":    p.rootfsStorage(config),
+	}
+	if pool := p.containerPool(config); pool != "" {
+		body["pool"] = pool
+	}
+	if config.Memory > 0 {
+		body["memory"] = config.Memory / (1024 * 1024)
+	}
+	if config.CPUs > 0 {
+		cpu, err := lxcCPU(runtime.ContainerConfig{CPUs: config.CPUs})
+		if err != nil {
+			return "", err
+		}
+		body["cores"] = cpu["cores"]
+		body["cpulimit"] = cpu["cpulimit"]
+	}
+
+	// Same VMID allocation as create, retried when the VMID is taken concurrently
+	var vmid int
+	var resp map[string]interface{}
+	var reserved []int
+	defer func() {
+		for _, id := range reserved {
+			p.releaseVMID(id)
+		}
+	}()
+
+	for attempt := 0; ; attempt++ {
+		vmid, err = p.getNextVMID(config.Name)
+		if err != nil {
+			return "", err
+		}
+		reserved = append(reserved, vmid)
+
+		body["vmid"] = vmid
+		body["hostname"] = lxcHostname(config.Name, vmid)
+		encoded, _ := json.Marshal(body)
+		resp, err = p.apiRequest("POST", fmt.Sprintf("/nodes/%s/lxc", node), strings.NewReader(string(encoded)))
+		if err == nil {
+			break
+		}
+		if !isVMIDInUse(err) || attempt >= maxVMIDAttempts {
+			return "", fmt.Errorf("failed to restore backup %s: %w", backupRef, err)
+		}
+
+		utils.Warn(fmt.Sprintf("VMID %d is already in use, retrying with the next free VMID", vmid))
+	}
+
+	if err := p.waitForTask(taskUPID(resp)); err != nil {
+		return "", fmt.Errorf("failed to restore backup %s: %w", backupRef, err)
+	}
+
+	labels := make(map[string]string, len(config.Labels)+5)
+	for k, v := range userLabels(config.Labels) {
+		labels[k] = v
+	}
+	labels["cosmos-name"] = config.Name
+	labels["cosmos-template"] = backupRef
+	labels[LabelManaged] = "true"
+	labels[LabelNode] = node
+	labels[LabelCreated] = createdLabel()
+	p.metadata.Set(vmid, labels)
+	p.storeAllocatedVolumes(vmid)
+
+	p.recordChange(vmid, "restore", []runtime.FieldChange{
+		{Field: "Backup", New: backupRef},
+		{Field: "Name", New: config.Name},
+	})
+
+	utils.Log(fmt.Sprintf("Restored backup %s into %s (VMID: %d)", backupRef, config.Name, vmid))
+	return strconv.Itoa(vmid), nil
+}
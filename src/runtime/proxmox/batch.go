@@ -0,0 +1,83 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Bulk operations on Proxmox containers
+// Operations on many containers (start all, stop all, per-container stats)
+// run in parallel, at most BatchConcurrency at a time so the node is not
+// flooded with tasks. A failing container does not stop the others: every
+// failure is reported in the joined error
+
+const defaultBatchConcurrency = 4
+
+// forEach calls fn for every ID with bounded parallelism and joins the errors
+func (p *ProxmoxRuntime) forEach(ids []string, fn func(i int, id string) error) error {
+	limit := p.config.BatchConcurrency
+	if limit <= 0 {
+		limit = defaultBatchConcurrency
+	}
+
+	errs := make([]error, len(ids))
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i, id := range ids {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := fn(i, id); err != nil {
+				errs[i] = fmt.Errorf("container %s: %w", id, err)
+			}
+		}(i, id)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// StartMany starts the containers, returning the joined errors of those that failed
+func (p *ProxmoxRuntime) StartMany(ids []string) error {
+	return p.forEach(ids, func(_ int, id string) error { return p.Start(id) })
+}
+
+// StopMany stops the containers, returning the joined errors of those that failed
+func (p *ProxmoxRuntime) StopMany(ids []string) error {
+	return p.forEach(ids, func(_ int, id string) error { return p.Stop(id) })
+}
+
+// statsEach returns the stats of every listed container with one request each
+func (p *ProxmoxRuntime) statsEach() ([]runtime.ContainerStats, error) {
+	containers, err := p.List()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(containers))
+	for i, c := range containers {
+		ids[i] = c.ID
+	}
+
+	results := make([]*runtime.ContainerStats, len(ids))
+	_ = p.forEach(ids, func(i int, id string) error {
+		stats, err := p.Stats(id)
+		results[i] = stats
+		return err
+	})
+
+	var allStats []runtime.ContainerStats
+	for _, stats := range results {
+		if stats != nil {
+			allStats = append(allStats, *stats)
+		}
+	}
+
+	return allStats, nil
+}
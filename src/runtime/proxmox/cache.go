@@ -0,0 +1,78 @@
+package proxmox
+
+import (
+	"sync"
+	"time"
+)
+
+// Short-lived cache of read-only API responses
+// The dashboard polls List and Version constantly, so their raw API responses
+// are reused for CacheTTL, keyed by path (hence per node). Labels are not
+// cached, they are always read from the metadata store. Every lifecycle
+// change (create, start, stop, remove, pause) invalidates the cache so a
+// mutation is never followed by stale state; ForceRefresh does the same for
+// changes made outside of Cosmos
+
+const defaultCacheTTL = 3 * time.Second
+
+type cacheEntry struct {
+	value   map[string]interface{}
+	expires time.Time
+}
+
+// responseCache holds API responses by path
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+}
+
+func newResponseCache(ttl time.Duration) *responseCache {
+	if ttl == 0 {
+		ttl = defaultCacheTTL
+	}
+	return &responseCache{ttl: ttl, entries: make(map[string]cacheEntry)}
+}
+
+// get returns the cached response for path, calling fetch when missing or expired
+func (c *responseCache) get(path string, fetch func() (map[string]interface{}, error)) (map[string]interface{}, error) {
+	if c.ttl < 0 {
+		return fetch()
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[path] = cacheEntry{value: value, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return value, nil
+}
+
+// invalidate drops every cached response
+func (c *responseCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]cacheEntry)
+}
+
+// cachedGet is a GET served from the cache when fresh
+func (p *ProxmoxRuntime) cachedGet(path string) (map[string]interface{}, error) {
+	return p.cache.get(path, func() (map[string]interface{}, error) {
+		return p.apiRequest("GET", path, nil)
+	})
+}
+
+// ForceRefresh drops the cached API responses, the next List and Version hit the API
+func (p *ProxmoxRuntime) ForceRefresh() {
+	p.cache.invalidate()
+}
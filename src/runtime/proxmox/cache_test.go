@@ -0,0 +1,119 @@
+package proxmox
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListCache(t *testing.T) {
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		// API calls to the container list after 5 Lists, a Start, 5 Lists,
+		// a ForceRefresh and 5 Lists
+		want int
+	}{
+		{"cached", 0, 3},
+		{"disabled", -1, 15},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster, func(c *Config) { c.CacheTTL = tt.ttl })
+			addManagedGuests(p, cluster, 3)
+
+			poll := func(want string) {
+				for i := 0; i < 5; i++ {
+					containers, err := p.List()
+					if err != nil {
+						t.Fatalf("List: %v", err)
+					}
+					if len(containers) != 3 || string(containers[0].State) != want {
+						t.Fatalf("List = %+v, want 3 containers, the first %s", containers, want)
+					}
+				}
+			}
+
+			poll("exited")
+			if err := p.Start("100"); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			poll("running")
+			p.ForceRefresh()
+			poll("running")
+
+			if n := cluster.count("GET /nodes/pve/lxc"); n != tt.want {
+				t.Errorf("%d container list requests, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	cache := newResponseCache(20 * time.Millisecond)
+	fetches := 0
+	fetch := func() (map[string]interface{}, error) {
+		fetches++
+		return map[string]interface{}{"data": fetches}, nil
+	}
+
+	cache.get("/version", fetch)
+	cache.get("/version", fetch)
+	cache.get("/nodes/pve2/lxc", fetch)
+	if fetches != 2 {
+		t.Fatalf("%d fetches for two paths, want 2", fetches)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if resp, _ := cache.get("/version", fetch); resp["data"] != 3 {
+		t.Errorf("expired entry served: %v", resp)
+	}
+}
+
+// BenchmarkDashboardPolling polls List and Version as the dashboard does,
+// reporting the API requests per poll with and without the cache
+func BenchmarkDashboardPolling(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		ttl  time.Duration
+	}{
+		{"cached", 0},
+		{"uncached", -1},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			cluster := newFakeCluster(b)
+			p := newTestRuntime(b, cluster, func(c *Config) { c.CacheTTL = bb.ttl })
+			addManagedGuests(p, cluster, 20)
+
+			// The first List looks up the creation time of every container once
+			if _, err := p.List(); err != nil {
+				b.Fatal(err)
+			}
+			p.ForceRefresh()
+			before := cluster.requests()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.List(); err != nil {
+					b.Fatal(err)
+				}
+				if p.Version() == "" {
+					b.Fatal("no version")
+				}
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(cluster.requests()-before)/float64(b.N), "requests/op")
+		})
+	}
+}
+
+// addManagedGuests adds n containers created by Cosmos, VMIDs 100 and up
+func addManagedGuests(p *ProxmoxRuntime, cluster *fakeCluster, n int) {
+	for i := 0; i < n; i++ {
+		vmid := 100 + i
+		cluster.addGuest(vmid, fakeGuest{Config: map[string]interface{}{"hostname": "app" + itoa(i), "memory": 512.0, "cores": 1.0}})
+		p.metadata.Set(vmid, map[string]string{"cosmos-name": "app" + itoa(i), LabelManaged: "true"})
+	}
+}
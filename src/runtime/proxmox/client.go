@@ -0,0 +1,231 @@
+package proxmox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Typed Proxmox API client
+// ProxmoxClient shares the connection, authentication, timeouts and retries
+// of the runtime, and decodes the "data" field of responses into the caller's
+// value. Proxmox is not strict about JSON types (numbers are sometimes sent as
+// strings), so the response types use Number for numeric fields
+
+// ProxmoxClient is a typed client of the Proxmox API, see ProxmoxRuntime.Client
+type ProxmoxClient struct {
+	runtime *ProxmoxRuntime
+}
+
+// Client returns a typed client of the API the runtime is connected to
+func (p *ProxmoxRuntime) Client() *ProxmoxClient {
+	return &ProxmoxClient{runtime: p}
+}
+
+// Get decodes the response of a GET request into out, which may be nil
+func (c *ProxmoxClient) Get(path string, out interface{}) error {
+	return c.do("GET", path, nil, out)
+}
+
+// Post sends body as JSON and decodes the response into out, which may be nil
+func (c *ProxmoxClient) Post(path string, body, out interface{}) error {
+	return c.do("POST", path, body, out)
+}
+
+// Put sends body as JSON and decodes the response into out, which may be nil
+func (c *ProxmoxClient) Put(path string, body, out interface{}) error {
+	return c.do("PUT", path, body, out)
+}
+
+// Delete decodes the response of a DELETE request into out, which may be nil
+func (c *ProxmoxClient) Delete(path string, out interface{}) error {
+	return c.do("DELETE", path, nil, out)
+}
+
+func (c *ProxmoxClient) do(method, path string, body, out interface{}) error {
+	if !c.runtime.connected {
+		return errNotConnected
+	}
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s %s: %w", method, path, err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	data, err := c.runtime.rawRequest(method, path, reader, c.runtime.requestTimeout())
+	if err != nil {
+		return err
+	}
+	if out == nil || len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// decodeInto converts an untyped response (e.g. a cached one) into a typed value
+func decodeInto(value, out interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, out)
+}
+
+// listEntries decodes the rows of a container list response one by one, so that
+// a malformed row (not an object, unexpected field type, no VMID) is skipped
+// with a warning instead of failing the whole list
+func listEntries(data interface{}, source string) []LXCListItem {
+	rows, _ := data.([]interface{})
+	items := make([]LXCListItem, 0, len(rows))
+	for i, row := range rows {
+		var item LXCListItem
+		if err := decodeInto(row, &item); err != nil || row == nil || item.VMID <= 0 {
+			if err == nil {
+				err = fmt.Errorf("no VMID")
+			}
+			utils.Warn(fmt.Sprintf("Skipping malformed entry %d of %s: %s", i, source, err))
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// containerStatus reads the status of a container. A field of an unexpected
+// type is left zero with a warning instead of failing the whole status
+func (p *ProxmoxRuntime) containerStatus(node string, vmid int) (LXCStatus, error) {
+	var fields map[string]json.RawMessage
+	if err := p.Client().Get(fmt.Sprintf("/nodes/%s/lxc/%d/status/current", node, vmid), &fields); err != nil {
+		return LXCStatus{}, err
+	}
+
+	var status LXCStatus
+	for key, value := range fields {
+		field, _ := json.Marshal(map[string]json.RawMessage{key: value})
+		if err := json.Unmarshal(field, &status); err != nil {
+			utils.Warn(fmt.Sprintf("Ignoring malformed field %s in the status of LXC container VMID %d: %s", key, vmid, err))
+		}
+	}
+	return status, nil
+}
+
+// Number is a numeric field of a response, sent by Proxmox as a number or a string
+type Number float64
+
+// UnmarshalJSON accepts numbers, numeric strings, empty strings and null
+func (n *Number) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" {
+		*n = 0
+		return nil
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", data)
+	}
+	*n = Number(value)
+	return nil
+}
+
+// Int returns the number as an int
+func (n Number) Int() int {
+	return int(n)
+}
+
+// VersionInfo is the response of /version
+type VersionInfo struct {
+	Version string `json:"version"`
+	Release string `json:"release"`
+	RepoID  string `json:"repoid"`
+}
+
+// LXCStatus is the response of /nodes/{node}/lxc/{vmid}/status/current
+type LXCStatus struct {
+	VMID    Number `json:"vmid"`
+	Name    string `json:"name"`
+	Status  string `json:"status"` // running, stopped...
+	Lock    string `json:"lock"`
+	Tags    string `json:"tags"`
+	Uptime  Number `json:"uptime"` // seconds
+	CPU     Number `json:"cpu"`    // usage, 1 is one core
+	CPUs    Number `json:"cpus"`
+	Mem     Number `json:"mem"` // bytes
+	MaxMem  Number `json:"maxmem"`
+	Swap    Number `json:"swap"`
+	MaxSwap Number `json:"maxswap"`
+	Disk    Number `json:"disk"`
+	MaxDisk Number `json:"maxdisk"`
+	NetIn   Number `json:"netin"`
+	NetOut  Number `json:"netout"`
+}
+
+// LXCListItem is an entry of /nodes/{node}/lxc and /cluster/resources?type=vm
+type LXCListItem struct {
+	LXCStatus
+	Node string `json:"node"` // cluster resources only
+	Type string `json:"type"` // lxc or qemu, cluster resources only
+}
+
+// LXCConfig is the response of /nodes/{node}/lxc/{vmid}/config. Indexed
+// options are gathered by index: Net holds net0, net1... and MountPoints mp0, mp1...
+type LXCConfig struct {
+	Hostname     string `json:"hostname"`
+	OSType       string `json:"ostype"`
+	Arch         string `json:"arch"`
+	Description  string `json:"description"`
+	Tags         string `json:"tags"`
+	Memory       Number `json:"memory"` // MiB
+	Swap         Number `json:"swap"`   // MiB
+	Cores        Number `json:"cores"`
+	CPUUnits     Number `json:"cpuunits"`
+	OnBoot       Number `json:"onboot"`
+	Unprivileged Number `json:"unprivileged"`
+	RootFS       string `json:"rootfs"`
+	Nameserver   string `json:"nameserver"`
+	Searchdomain string `json:"searchdomain"`
+	Features     string `json:"features"`
+	Digest       string `json:"digest"`
+
+	Net         map[int]string `json:"-"`
+	MountPoints map[int]string `json:"-"`
+}
+
+// UnmarshalJSON decodes the named options and gathers the indexed ones
+func (c *LXCConfig) UnmarshalJSON(data []byte) error {
+	type named LXCConfig
+	if err := json.Unmarshal(data, (*named)(c)); err != nil {
+		return err
+	}
+
+	var options map[string]interface{}
+	if err := json.Unmarshal(data, &options); err != nil {
+		return err
+	}
+	c.Net = indexedOptions(options, "net")
+	c.MountPoints = indexedOptions(options, "mp")
+	return nil
+}
+
+// indexedOptions returns the string options named prefix followed by an index
+func indexedOptions(options map[string]interface{}, prefix string) map[int]string {
+	indexed := make(map[int]string)
+	for _, key := range configKeys(options, prefix) {
+		index, _ := strconv.Atoi(strings.TrimPrefix(key, prefix))
+		if value, ok := options[key].(string); ok {
+			indexed[index] = value
+		}
+	}
+	return indexed
+}
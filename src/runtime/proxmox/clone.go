@@ -0,0 +1,153 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Cloning Proxmox containers
+// Clone makes a full copy of an existing container (rootfs, mount points and
+// provisioning state included) under a new VMID, without going back to the
+// template like Recreate does. The copy starts stopped, so blue/green deploys
+// can start it, check it and then remove the old container
+
+// cloneSkippedLabels describe the source container itself and are not copied
+var cloneSkippedLabels = map[string]bool{
+	LabelHistory:      true,
+	LabelReady:        true,
+	LabelStackIndex:   true,
+	LabelPortRules:    true,
+	LabelSnapshots:    true,
+	LabelProvisioning: true,
+	LabelBuildArgs:    true,
+	LabelGoldenImage:  true,
+
+	LabelAllocatedVolumes: true,
+}
+
+// Clone copies the container sourceID into a new container named config.Name
+// (defaults to "<source name>-clone"). config.Labels are added to the copied
+// labels; Hostname, Memory and CPUs override the source settings when set.
+func (p *ProxmoxRuntime) Clone(sourceID string, config runtime.ContainerConfig) (string, error) {
+	defer p.cache.invalidate()
+
+	if !p.connected {
+		return "", errNotConnected
+	}
+
+	source, err := strconv.Atoi(sourceID)
+	if err != nil {
+		return "", fmt.Errorf("invalid container ID: %s", sourceID)
+	}
+	node := p.nodeFor(source)
+
+	sourceLabels := p.metadata.Get(source)
+	if config.Name == "" {
+		config.Name = sourceLabels["cosmos-name"] + "-clone"
+		if sourceLabels["cosmos-name"] == "" {
+			config.Name = fmt.Sprintf("ct%d-clone", source)
+		}
+	}
+	if config.Hostname == "" {
+		config.Hostname = config.Name
+	}
+
+	cpu, err := lxcCPU(runtime.ContainerConfig{CPUs: config.CPUs})
+	if err != nil {
+		return "", err
+	}
+
+	body := map[string]interface{}{
+		"full": 1,
+	}
+	if storage := config.Labels[LabelStorage]; storage != "" {
+		body["storage"] = storage
+	}
+	if pool := p.containerPool(config); pool != "" {
+		body["pool"] = pool
+	}
+
+	// Same VMID allocation as create, retried when the VMID is taken concurrently
+	var vmid int
+	var resp map[string]interface{}
+	var reserved []int
+	defer func() {
+		for _, id := range reserved {
+			p.releaseVMID(id)
+		}
+	}()
+
+	for attempt := 0; ; attempt++ {
+		vmid, err = p.getNextVMID(config.Name)
+		if err != nil {
+			return "", err
+		}
+		reserved = append(reserved, vmid)
+
+		body["newid"] = vmid
+		body["hostname"] = lxcHostname(config.Hostname, vmid)
+		encoded, _ := json.Marshal(body)
+		resp, err = p.apiRequest("POST", fmt.Sprintf("/nodes/%s/lxc/%d/clone", node, source), strings.NewReader(string(encoded)))
+		if err == nil {
+			break
+		}
+		if isNotFound(err) {
+			return "", p.notFound(source)
+		}
+		if !isVMIDInUse(err) || attempt >= maxVMIDAttempts {
+			return "", fmt.Errorf("failed to clone container %s: %w", sourceID, err)
+		}
+
+		utils.Warn(fmt.Sprintf("VMID %d is already in use, retrying with the next free VMID", vmid))
+	}
+
+	if err := p.waitForTask(taskUPID(resp)); err != nil {
+		return "", fmt.Errorf("failed to clone container %s: %w", sourceID, err)
+	}
+
+	// Resources
+	update := map[string]interface{}{}
+	if config.Memory > 0 {
+		update["memory"] = config.Memory / (1024 * 1024)
+	}
+	if config.CPUs > 0 {
+		update["cores"] = cpu["cores"]
+		update["cpulimit"] = cpu["cpulimit"]
+	}
+	if len(update) > 0 {
+		encoded, _ := json.Marshal(update)
+		if _, err := p.apiRequest("PUT", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), strings.NewReader(string(encoded))); err != nil {
+			utils.Warn(fmt.Sprintf("Failed to update resources of cloned container %d: %s", vmid, err))
+		}
+	}
+
+	// Metadata, renamed after the clone
+	labels := make(map[string]string, len(sourceLabels)+len(config.Labels))
+	for k, v := range sourceLabels {
+		if !cloneSkippedLabels[k] {
+			labels[k] = v
+		}
+	}
+	for k, v := range userLabels(config.Labels) {
+		labels[k] = v
+	}
+	labels["cosmos-name"] = config.Name
+	labels[LabelManaged] = "true"
+	labels[LabelNode] = node
+	labels[LabelCreated] = createdLabel()
+	p.metadata.Set(vmid, labels)
+	p.storeAllocatedVolumes(vmid)
+
+	p.recordChange(vmid, "clone", []runtime.FieldChange{
+		{Field: "Source", New: sourceID},
+		{Field: "Name", Old: sourceLabels["cosmos-name"], New: config.Name},
+	})
+
+	utils.Log(fmt.Sprintf("Cloned LXC container %s into %s (VMID: %d)", sourceID, config.Name, vmid))
+	return strconv.Itoa(vmid), nil
+}
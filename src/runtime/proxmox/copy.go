@@ -0,0 +1,118 @@
+package proxmox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/azukaar/cosmos-server/src/runtime/archive"
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// File copy for Proxmox containers
+// Files are streamed as tar archives through pct exec, so modes, symlinks and
+// directories are preserved. As with Docker, CopyFrom returns a tar stream
+
+// CopyTo copies a local file or directory to containerPath inside a container
+func (p *ProxmoxRuntime) CopyTo(id string, localPath string, containerPath string) error {
+	dir, name := path.Split(path.Clean(containerPath))
+	if dir == "" {
+		dir = "."
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(archive.WritePath(writer, localPath, name))
+	}()
+	defer reader.Close()
+
+	script := "mkdir -p " + shellQuote(dir) + " && tar -xpf - -C " + shellQuote(dir)
+	exitCode, stderr, err := p.execStream(id, []string{"sh", "-c", script}, reader, io.Discard)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("failed to copy %s to container %s: exit code %d: %s", localPath, id, exitCode, stderr)
+	}
+	return nil
+}
+
+// CopyFrom writes a file or directory of a container to localWriter as a tar stream
+func (p *ProxmoxRuntime) CopyFrom(id string, containerPath string, localWriter io.Writer) error {
+	dir, name := path.Split(path.Clean(containerPath))
+	if dir == "" {
+		dir = "."
+	}
+
+	exitCode, stderr, err := p.execStream(id, []string{"tar", "-cf", "-", "-C", dir, name}, nil, localWriter)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("failed to copy %s from container %s: exit code %d: %s", containerPath, id, exitCode, stderr)
+	}
+	return nil
+}
+
+// CopyToContainer streams content into the file dstPath of a container. The file is
+// written next to dstPath then renamed, so readers never see it half written
+func (p *ProxmoxRuntime) CopyToContainer(id string, dstPath string, content io.Reader, mode os.FileMode) error {
+	dstPath = path.Clean(dstPath)
+	tmp := dstPath + ".cosmos-tmp"
+	script := fmt.Sprintf("mkdir -p %s && cat > %s && chmod %o %s && mv -f %s %s",
+		shellQuote(path.Dir(dstPath)), shellQuote(tmp), mode.Perm(), shellQuote(tmp), shellQuote(tmp), shellQuote(dstPath))
+
+	exitCode, stderr, err := p.execStream(id, []string{"sh", "-c", script}, content, io.Discard)
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return fmt.Errorf("failed to write %s in container %s: exit code %d: %s", dstPath, id, exitCode, stderr)
+	}
+	return nil
+}
+
+// CopyFromContainer streams the content of the file srcPath of a container
+func (p *ProxmoxRuntime) CopyFromContainer(id string, srcPath string) (io.ReadCloser, error) {
+	if _, err := strconv.Atoi(id); err != nil {
+		return nil, fmt.Errorf("invalid container ID: %s", id)
+	}
+
+	reader, writer := io.Pipe()
+	p.goBackground(func(ctx context.Context) {
+		// Close ends the stream, so a write to a reader that is never read returns
+		defer context.AfterFunc(ctx, func() { writer.CloseWithError(errNotConnected) })()
+
+		exitCode, stderr, err := p.execStream(id, []string{"cat", "--", srcPath}, nil, writer)
+		if err == nil && exitCode != 0 {
+			err = fmt.Errorf("failed to read %s from container %s: exit code %d: %s", srcPath, id, exitCode, stderr)
+		}
+		writer.CloseWithError(err)
+	})
+	return reader, nil
+}
+
+// execStream runs a command inside a container, streaming stdin and stdout
+func (p *ProxmoxRuntime) execStream(id string, cmd []string, stdin io.Reader, stdout io.Writer) (int, string, error) {
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid container ID: %s", id)
+	}
+
+	transport, err := p.execTransport()
+	if err != nil {
+		return 0, "", err
+	}
+
+	var stderr bytes.Buffer
+	command := p.nodeCommand(p.nodeFor(vmid), buildPctExec(vmid, cmd, runtime.ExecOptions{}), false)
+	exitCode, err := transport.Run(command, stdin, stdout, &stderr)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to exec in container %s: %w", id, err)
+	}
+	return exitCode, stderr.String(), nil
+}
@@ -0,0 +1,202 @@
+package proxmox
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCopyTo(t *testing.T) {
+	local := t.TempDir()
+	file := filepath.Join(local, "config.yml")
+	site := filepath.Join(local, "site")
+	os.WriteFile(file, []byte("port: 8080\n"), 0640)
+	os.MkdirAll(filepath.Join(site, "css"), 0755)
+	os.WriteFile(filepath.Join(site, "index.html"), []byte("<h1>hi</h1>"), 0644)
+	os.WriteFile(filepath.Join(site, "css", "main.css"), []byte("body{}"), 0644)
+
+	tests := []struct {
+		name          string
+		localPath     string
+		containerPath string
+		exitCode      int
+		wantDir       string
+		want          map[string]string // tar entries, directories end with /
+		wantErr       bool
+	}{
+		{
+			name:          "file",
+			localPath:     file,
+			containerPath: "/etc/app/config.yml",
+			wantDir:       "/etc/app/",
+			want:          map[string]string{"config.yml": "port: 8080\n"},
+		},
+		{
+			name:          "directory",
+			localPath:     site,
+			containerPath: "/var/www/html/",
+			wantDir:       "/var/www/",
+			want: map[string]string{
+				"html/":             "",
+				"html/css/":         "",
+				"html/css/main.css": "body{}",
+				"html/index.html":   "<h1>hi</h1>",
+			},
+		},
+		{
+			name:          "extraction failure",
+			localPath:     file,
+			containerPath: "/readonly/config.yml",
+			exitCode:      2,
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+
+			var received string
+			transport := &fakeTransport{run: func(command, stdin string) (string, string, int) {
+				received = stdin
+				if tt.exitCode != 0 {
+					return "", "tar: Read-only file system", tt.exitCode
+				}
+				return "", "", 0
+			}}
+			p.SetExecTransport(transport)
+
+			err := p.CopyTo("100", tt.localPath, tt.containerPath)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "Read-only file system") {
+					t.Fatalf("CopyTo error = %v, want the tar error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CopyTo: %v", err)
+			}
+
+			want := [][]string{{"sh", "-c", "mkdir -p " + shellQuote(tt.wantDir) + " && tar -xpf - -C " + shellQuote(tt.wantDir)}}
+			if got := transport.execs(); !reflect.DeepEqual(got, want) || len(transport.ran("pct exec 100 ")) != 1 {
+				t.Errorf("ran %q, want %q in container 100", got, want)
+			}
+			if got := tarEntries(t, received); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("copied %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyFrom(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	tw.WriteHeader(&tar.Header{Name: "hosts", Mode: 0644, Size: 9, Typeflag: tar.TypeReg})
+	tw.Write([]byte("127.0.0.1"))
+	tw.Close()
+
+	tests := []struct {
+		name          string
+		containerPath string
+		exitCode      int
+		wantCommand   []string
+		wantErr       bool
+	}{
+		{"absolute path", "/etc/hosts", 0, []string{"tar", "-cf", "-", "-C", "/etc/", "hosts"}, false},
+		{"relative path", "hosts", 0, []string{"tar", "-cf", "-", "-C", ".", "hosts"}, false},
+		{"directory", "/etc/nginx/", 0, []string{"tar", "-cf", "-", "-C", "/etc/", "nginx"}, false},
+		{"missing file", "/etc/missing", 2, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+
+			transport := &fakeTransport{run: func(command, stdin string) (string, string, int) {
+				if tt.exitCode != 0 {
+					return "", "tar: missing: No such file or directory", tt.exitCode
+				}
+				return archive.String(), "", 0
+			}}
+			p.SetExecTransport(transport)
+
+			var out bytes.Buffer
+			err := p.CopyFrom("100", tt.containerPath, &out)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "No such file") {
+					t.Fatalf("CopyFrom error = %v, want the tar error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CopyFrom: %v", err)
+			}
+			if got := transport.execs(); !reflect.DeepEqual(got, [][]string{tt.wantCommand}) {
+				t.Errorf("ran %q, want %q", got, tt.wantCommand)
+			}
+			if got := tarEntries(t, out.String()); !reflect.DeepEqual(got, map[string]string{"hosts": "127.0.0.1"}) {
+				t.Errorf("CopyFrom wrote %v", got)
+			}
+		})
+	}
+}
+
+func TestCopyToContainer(t *testing.T) {
+	cluster := newFakeCluster(t)
+	p := newTestRuntime(t, cluster)
+
+	var received string
+	transport := &fakeTransport{run: func(command, stdin string) (string, string, int) {
+		received = stdin
+		return "", "", 0
+	}}
+	p.SetExecTransport(transport)
+
+	if err := p.CopyToContainer("100", "/etc/app/../app/.env", strings.NewReader("KEY=value\n"), 0600); err != nil {
+		t.Fatalf("CopyToContainer: %v", err)
+	}
+	if received != "KEY=value\n" {
+		t.Errorf("content = %q", received)
+	}
+	want := []string{"sh", "-c", "mkdir -p '/etc/app' && cat > '/etc/app/.env.cosmos-tmp' && chmod 600 '/etc/app/.env.cosmos-tmp' && mv -f '/etc/app/.env.cosmos-tmp' '/etc/app/.env'"}
+	if got := transport.execs(); !reflect.DeepEqual(got, [][]string{want}) {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+
+	transport.run = func(command, stdin string) (string, string, int) {
+		return "secret", "", 0
+	}
+	reader, err := p.CopyFromContainer("100", "/etc/app/.env")
+	if err != nil {
+		t.Fatalf("CopyFromContainer: %v", err)
+	}
+	defer reader.Close()
+	if content, err := io.ReadAll(reader); err != nil || string(content) != "secret" {
+		t.Errorf("CopyFromContainer read %q, %v", content, err)
+	}
+}
+
+// tarEntries returns the entries of a tar archive, the content of files by name
+func tarEntries(t *testing.T, data string) map[string]string {
+	t.Helper()
+	entries := make(map[string]string)
+	tr := tar.NewReader(strings.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatalf("invalid tar archive: %v", err)
+		}
+		content, _ := io.ReadAll(tr)
+		entries[hdr.Name] = string(content)
+	}
+}
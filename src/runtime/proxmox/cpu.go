@@ -0,0 +1,47 @@
+package proxmox
+
+import (
+	"fmt"
+	"math"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// CPU limits of Proxmox containers
+// ContainerConfig.CPUs becomes cpulimit, which accepts fractions, and cores,
+// the number of CPUs the container sees, rounded up from it. Without CPUs the
+// container gets one core and no limit. CPUShares becomes cpuunits, the
+// relative weight of the container when CPUs are contended
+
+const (
+	maxCPULimit = 8192
+	maxCPUUnits = 500000
+)
+
+// lxcCPU returns the cores, cpulimit and cpuunits options of config
+func lxcCPU(config runtime.ContainerConfig) (map[string]interface{}, error) {
+	if config.CPUs < 0 || config.CPUs > maxCPULimit || math.IsNaN(config.CPUs) {
+		return nil, fmt.Errorf("invalid CPUs %g (0 to %d)", config.CPUs, maxCPULimit)
+	}
+	if config.CPUShares < 0 || config.CPUShares > maxCPUUnits {
+		return nil, fmt.Errorf("invalid CPU shares %d (1 to %d)", config.CPUShares, maxCPUUnits)
+	}
+
+	options := map[string]interface{}{"cores": 1}
+	if config.CPUs > 0 {
+		options["cores"] = int(math.Ceil(config.CPUs))
+		options["cpulimit"] = config.CPUs
+	}
+	if config.CPUShares > 0 {
+		options["cpuunits"] = config.CPUShares
+	}
+	return options, nil
+}
+
+// configCPUs returns the CPUs of a container config: its cpulimit, or its cores when unlimited
+func configCPUs(config map[string]interface{}) float64 {
+	if limit := floatValue(config["cpulimit"]); limit > 0 {
+		return limit
+	}
+	return floatValue(config["cores"])
+}
@@ -0,0 +1,76 @@
+package proxmox
+
+import (
+	"math"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestCreateCPU(t *testing.T) {
+	tests := []struct {
+		name      string
+		cpus      float64
+		shares    int64
+		wantCores float64
+		wantLimit float64 // 0 for unlimited
+		wantUnits float64 // 0 for the Proxmox default
+		wantErr   bool
+		wantCPUs  float64 // CPUs reported by Inspect
+	}{
+		{"unset", 0, 0, 1, 0, 0, false, 1},
+		{"fractional", 0.5, 0, 1, 0.5, 0, false, 0.5},
+		{"fraction above a core", 1.25, 0, 2, 1.25, 0, false, 1.25},
+		{"whole cores", 4, 0, 4, 4, 0, false, 4},
+		{"smallest fraction", 0.01, 0, 1, 0.01, 0, false, 0.01},
+		{"maximum", maxCPULimit, 0, maxCPULimit, maxCPULimit, 0, false, maxCPULimit},
+		{"shares", 2, 2048, 2, 2, 2048, false, 2},
+		{"maximum shares", 0, maxCPUUnits, 1, 0, maxCPUUnits, false, 1},
+		{"oversized", maxCPULimit + 1, 0, 0, 0, 0, true, 0},
+		{"negative", -1, 0, 0, 0, 0, true, 0},
+		{"not a number", math.NaN(), 0, 0, 0, 0, true, 0},
+		{"infinite", math.Inf(1), 0, 0, 0, 0, true, 0},
+		{"oversized shares", 1, maxCPUUnits + 1, 0, 0, 0, true, 0},
+		{"negative shares", 1, -5, 0, 0, 0, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, CPUs: tt.cpus, CPUShares: tt.shares})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Create succeeded, want an error")
+				}
+				if n := cluster.lxcCount(); n != 0 {
+					t.Errorf("%d containers created", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			config := cluster.guest(atoi(t, id)).Config
+			for key, want := range map[string]float64{"cores": tt.wantCores, "cpulimit": tt.wantLimit, "cpuunits": tt.wantUnits} {
+				got, set := config[key]
+				if want == 0 && set {
+					t.Errorf("%s = %v, want it unset", key, got)
+				}
+				if want != 0 && floatValue(got) != want {
+					t.Errorf("%s = %v, want %g", key, got, want)
+				}
+			}
+
+			details, err := p.Inspect(id)
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+			if details.Config.CPUs != tt.wantCPUs {
+				t.Errorf("Inspect CPUs = %g, want %g", details.Config.CPUs, tt.wantCPUs)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package proxmox
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Container creation time
+// Proxmox does not record when a container was created, so Create, Clone and
+// RestoreBackup store it in the cosmos-created label (unix seconds). For
+// containers created outside of Cosmos, the time of their oldest snapshot is
+// used when they have one, looked up once per container
+
+// LabelCreated holds the creation time of containers created by Cosmos
+const LabelCreated = "cosmos-created"
+
+// createdLabel returns the cosmos-created value of a container created now
+func createdLabel() string {
+	return strconv.FormatInt(time.Now().Unix(), 10)
+}
+
+// createdAt returns the creation time of a container, 0 when unknown
+func (p *ProxmoxRuntime) createdAt(vmid int, labels map[string]string) int64 {
+	if created, err := strconv.ParseInt(labels[LabelCreated], 10, 64); err == nil && created > 0 {
+		return created
+	}
+	return p.reportedCreated(vmid)
+}
+
+// reportedCreated returns the time of the oldest snapshot of a container
+// created outside of Cosmos, 0 without snapshots
+func (p *ProxmoxRuntime) reportedCreated(vmid int) int64 {
+	p.createdMu.Lock()
+	created, known := p.adoptedCreated[vmid]
+	p.createdMu.Unlock()
+	if known {
+		return created
+	}
+
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/snapshot", p.nodeFor(vmid), vmid), nil)
+	if err != nil {
+		// Not remembered, the next call tries again
+		return 0
+	}
+	for _, item := range listItems(resp) {
+		if snaptime := int64(floatValue(item["snaptime"])); snaptime > 0 && (created == 0 || snaptime < created) {
+			created = snaptime
+		}
+	}
+
+	p.createdMu.Lock()
+	if p.adoptedCreated == nil {
+		p.adoptedCreated = make(map[int]int64)
+	}
+	p.adoptedCreated[vmid] = created
+	p.createdMu.Unlock()
+	return created
+}
@@ -0,0 +1,118 @@
+package proxmox
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestCreatedAfterCreate(t *testing.T) {
+	cluster := newFakeCluster(t)
+	p := newTestRuntime(t, cluster)
+
+	before := time.Now().Unix()
+	id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	after := time.Now().Unix()
+
+	details, err := p.Inspect(id)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if details.Created < before || details.Created > after {
+		t.Errorf("Inspect Created = %d, want between %d and %d", details.Created, before, after)
+	}
+
+	containers, err := p.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(containers) != 1 || containers[0].Created != details.Created {
+		t.Errorf("List = %+v, want Created %d", containers, details.Created)
+	}
+	if n := cluster.count("GET /nodes/pve/lxc/" + id + "/snapshot"); n != 0 {
+		t.Errorf("snapshots listed %d times for a container created by Cosmos", n)
+	}
+}
+
+func TestCreatedAdopted(t *testing.T) {
+	tests := []struct {
+		name      string
+		label     string // cosmos-created label, empty when not set
+		snapshots func(r *http.Request, body map[string]interface{}) (int, interface{})
+		want      int64
+		wantCalls int // snapshot listings for two Inspect calls
+	}{
+		{
+			name: "oldest snapshot",
+			snapshots: func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+				return http.StatusOK, []map[string]interface{}{
+					{"name": "weekly", "snaptime": 1700000300},
+					{"name": "install", "snaptime": "1700000200"},
+					{"name": "current"},
+				}
+			},
+			want:      1700000200,
+			wantCalls: 1,
+		},
+		{
+			name:      "no snapshots",
+			want:      0,
+			wantCalls: 1,
+		},
+		{
+			name: "snapshot listing fails",
+			snapshots: func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+				return http.StatusForbidden, "Permission check failed (/vms/100, VM.Audit)"
+			},
+			want:      0,
+			wantCalls: 2,
+		},
+		{
+			name:      "label set",
+			label:     "1700000100",
+			want:      1700000100,
+			wantCalls: 0,
+		},
+		{
+			name:  "malformed label",
+			label: "yesterday",
+			snapshots: func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+				return http.StatusOK, []map[string]interface{}{{"name": "install", "snaptime": 1700000200}}
+			},
+			want:      1700000200,
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			cluster.addGuest(100, fakeGuest{Config: map[string]interface{}{"hostname": "adopted"}})
+			if tt.snapshots != nil {
+				cluster.handle("GET /nodes/pve/lxc/100/snapshot", tt.snapshots)
+			}
+			p := newTestRuntime(t, cluster)
+			if tt.label != "" {
+				p.metadata.SetLabel(100, LabelCreated, tt.label)
+			}
+
+			for i := 0; i < 2; i++ {
+				details, err := p.Inspect("100")
+				if err != nil {
+					t.Fatalf("Inspect: %v", err)
+				}
+				if details.Created != tt.want {
+					t.Errorf("Created = %d, want %d", details.Created, tt.want)
+				}
+			}
+			if n := cluster.count("GET /nodes/pve/lxc/100/snapshot"); n != tt.wantCalls {
+				t.Errorf("snapshots listed %d times, want %d", n, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package proxmox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Container locks
+// Proxmox locks a container while it is created, snapshotted, backed up or
+// migrated, and refuses start, stop and delete in the meantime ("CT is locked
+// (backup)"). Lifecycle operations wait for the lock field of the container
+// config to clear and send their request again, for up to LockTimeout
+
+const (
+	defaultLockTimeout = 2 * time.Minute
+	lockPollInterval   = 2 * time.Second
+)
+
+// lockTimeout returns how long an operation waits for a lock, 0 when disabled
+func (p *ProxmoxRuntime) lockTimeout() time.Duration {
+	if p.config.LockTimeout < 0 {
+		return 0
+	}
+	return durationOr(p.config.LockTimeout, defaultLockTimeout)
+}
+
+// isLocked reports whether Proxmox refused an operation because the container is locked
+func isLocked(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return strings.Contains(apiErr.Body, "is locked") || strings.Contains(apiErr.Body, "can't lock file")
+}
+
+// lockedRequest is apiRequest for an operation on container vmid that Proxmox
+// refuses while the container is locked: the request is sent again once the
+// lock has cleared, until LockTimeout elapses
+func (p *ProxmoxRuntime) lockedRequest(node string, vmid int, method, path string, payload []byte) (map[string]interface{}, error) {
+	deadline := time.Now().Add(p.lockTimeout())
+	waited := false
+
+	for {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+
+		resp, err := p.apiRequest(method, path, body)
+		if !isLocked(err) || !time.Now().Before(deadline) {
+			return resp, err
+		}
+
+		if !waited {
+			utils.Log(fmt.Sprintf("LXC container VMID %d is locked, waiting up to %s for the lock to clear", vmid, p.lockTimeout()))
+			waited = true
+		}
+		if werr := p.waitUnlocked(node, vmid, deadline); werr != nil {
+			return nil, fmt.Errorf("%w (%v)", err, werr)
+		}
+	}
+}
+
+// waitUnlocked polls the container config until it has no lock or deadline passes
+func (p *ProxmoxRuntime) waitUnlocked(node string, vmid int, deadline time.Time) error {
+	for {
+		var config struct {
+			Lock string `json:"lock"`
+		}
+		if err := p.Client().Get(fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), &config); err != nil {
+			return fmt.Errorf("failed to get the lock state: %w", err)
+		}
+		if config.Lock == "" {
+			return nil
+		}
+
+		if !time.Now().Add(lockPollInterval).Before(deadline) {
+			return fmt.Errorf("still locked (%s) after %s", config.Lock, p.lockTimeout())
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
@@ -0,0 +1,75 @@
+package proxmox
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLockedThenUnlocked(t *testing.T) {
+	operations := []struct {
+		name   string
+		status string // status of the guest before the operation
+		run    func(p *ProxmoxRuntime) error
+		check  func(g *fakeGuest) bool
+	}{
+		{"Start", "stopped", func(p *ProxmoxRuntime) error { return p.Start("100") }, func(g *fakeGuest) bool { return g != nil && g.Status == "running" }},
+		{"Stop", "running", func(p *ProxmoxRuntime) error { return p.Stop("100") }, func(g *fakeGuest) bool { return g != nil && g.Status == "stopped" }},
+		{"Remove", "stopped", func(p *ProxmoxRuntime) error { return p.Remove("100") }, func(g *fakeGuest) bool { return g == nil }},
+	}
+
+	tests := []struct {
+		name        string
+		lock        string
+		unlockAfter int32 // config polls before the lock clears, 0 never
+		timeout     time.Duration
+		wantErr     bool
+	}{
+		{name: "not locked"},
+		{name: "unlocked on the first poll", lock: "backup", unlockAfter: 1},
+		{name: "unlocked on the second poll", lock: "snapshot", unlockAfter: 2},
+		{name: "never unlocked", lock: "backup", timeout: time.Second, wantErr: true},
+		{name: "waiting disabled", lock: "backup", unlockAfter: 1, timeout: -1, wantErr: true},
+	}
+
+	for _, op := range operations {
+		for _, tt := range tests {
+			t.Run(op.name+"/"+tt.name, func(t *testing.T) {
+				cluster := newFakeCluster(t)
+				cluster.addGuest(100, fakeGuest{Status: op.status, Lock: tt.lock, Config: map[string]interface{}{"hostname": "app"}})
+
+				var polls atomic.Int32
+				cluster.handle("GET /nodes/pve/lxc/100/config", func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+					if n := polls.Add(1); tt.unlockAfter > 0 && n >= tt.unlockAfter {
+						cluster.setLock(100, "")
+					}
+					return cluster.route(r, strings.TrimPrefix(r.URL.Path, "/api2/json"), body)
+				})
+
+				p := newTestRuntime(t, cluster, func(c *Config) { c.LockTimeout = tt.timeout })
+				err := op.run(p)
+
+				if tt.wantErr {
+					if !isLocked(err) {
+						t.Fatalf("%s = %v, want a locked error", op.name, err)
+					}
+					if g := cluster.guest(100); g == nil || g.Status != op.status {
+						t.Errorf("locked container changed: %+v", g)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", op.name, err)
+				}
+				if g := cluster.guest(100); !op.check(g) {
+					t.Errorf("container after %s: %+v", op.name, g)
+				}
+				if tt.lock == "" && polls.Load() != 0 {
+					t.Errorf("lock state polled %d times for an unlocked container", polls.Load())
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  runtime.ContainerConfig
+		want    map[string]interface{} // expected options, as decoded from JSON
+		wantErr bool
+	}{
+		{
+			name:   "defaults",
+			config: runtime.ContainerConfig{Name: "web", Image: testImage},
+			want: map[string]interface{}{
+				"ostemplate": testImage,
+				"hostname":   "web",
+				"memory":     float64(defaultMemoryMB),
+				"swap":       float64(defaultMemoryMB),
+				"cores":      1.0,
+				"net0":       "name=eth0,bridge=vmbr0,ip=dhcp",
+				"tags":       createdTag,
+			},
+		},
+		{
+			name: "resources, networks and volumes",
+			config: runtime.ContainerConfig{
+				Name:       "db",
+				Image:      testImage,
+				Memory:     2 << 30,
+				CPUs:       1.5,
+				Networks:   []string{"vmbr1", "backend"},
+				Volumes:    []runtime.VolumeMount{{Type: runtime.MountTypeVolume, Source: "pgdata", Target: "/var/lib/postgresql"}},
+				DNS:        []string{"10.0.0.53"},
+				Privileged: true,
+			},
+			want: map[string]interface{}{
+				"hostname":     "db",
+				"memory":       2048.0,
+				"cores":        2.0,
+				"cpulimit":     1.5,
+				"net0":         "name=eth0,bridge=vmbr1,ip=dhcp",
+				"net1":         "name=eth1,bridge=backend,ip=dhcp",
+				"mp0":          "pgdata,mp=/var/lib/postgresql",
+				"nameserver":   "10.0.0.53",
+				"unprivileged": false,
+			},
+		},
+		{
+			name:    "invalid environment",
+			config:  runtime.ContainerConfig{Name: "web", Image: testImage, Environment: map[string]string{"1BAD": "x"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid port",
+			config:  runtime.ContainerConfig{Name: "web", Image: testImage, Ports: []runtime.PortMapping{{HostPort: "http", ContainerPort: "80"}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid DNS server",
+			config:  runtime.ContainerConfig{Name: "web", Image: testImage, DNS: []string{"dns.example.com"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+			before := cluster.requests()
+
+			described, err := p.Describe(tt.config)
+			if n := cluster.requests() - before; n != 0 {
+				t.Errorf("Describe sent %d API requests", n)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Describe = %s, want an error", described)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Describe: %v", err)
+			}
+
+			var options map[string]interface{}
+			if err := json.Unmarshal([]byte(described), &options); err != nil {
+				t.Fatalf("Describe is not JSON: %v\n%s", err, described)
+			}
+			for key, want := range tt.want {
+				if got := options[key]; got != want {
+					t.Errorf("%s = %#v, want %#v", key, got, want)
+				}
+			}
+			for _, key := range []string{"vmid", "password"} {
+				if _, ok := options[key]; ok {
+					t.Errorf("Describe shows %s", key)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package proxmox
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Device passthrough
+// Each entry of ContainerConfig.Devices becomes a devN option: Proxmox
+// creates the device node in the container at the same path as on the host,
+// owned by the container root user, for privileged and unprivileged
+// containers alike. Permissions without "w" make it read-only (deny-write=1).
+// devN options need Proxmox VE 8.1 or later, and Proxmox only lets root@pam
+// (or one of its API tokens) set them. A GPU render node for hardware
+// transcoding is passed as
+//   Devices: []DeviceMapping{{HostPath: "/dev/dri/renderD128"}}
+// When the node can be reached over SSH, Create checks the devices exist on it
+
+// lxcDevices returns the devN values of the devices of config
+func lxcDevices(devices []runtime.DeviceMapping) ([]string, error) {
+	values := make([]string, 0, len(devices))
+	for _, device := range devices {
+		if err := validateDevice(device); err != nil {
+			return nil, err
+		}
+
+		value := "path=" + device.HostPath
+		if !strings.Contains(devicePermissions(device), "w") {
+			value += ",deny-write=1"
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// validateDevice checks a device can be passed to an LXC container
+func validateDevice(device runtime.DeviceMapping) error {
+	host := device.HostPath
+	if !strings.HasPrefix(host, "/dev/") || path.Clean(host) != host || strings.ContainsAny(host, ",=; \t\n") {
+		return fmt.Errorf("invalid device %q, expected a path under /dev (e.g. /dev/dri/renderD128)", host)
+	}
+	if device.ContainerPath != "" && device.ContainerPath != host {
+		return fmt.Errorf("device %s cannot be mapped to %s, LXC containers get devices at their host path", host, device.ContainerPath)
+	}
+
+	permissions := devicePermissions(device)
+	if strings.Trim(permissions, "rwm") != "" {
+		return fmt.Errorf("invalid permissions %q for device %s, expected a combination of r, w and m", permissions, host)
+	}
+	return nil
+}
+
+// devicePermissions returns the cgroup permissions of a device, rwm by default
+func devicePermissions(device runtime.DeviceMapping) string {
+	if device.Permissions == "" {
+		return "rwm"
+	}
+	return device.Permissions
+}
+
+// checkDevices returns an error listing the devices missing from node. The
+// check is skipped when the node cannot be reached, Proxmox then reports them
+func (p *ProxmoxRuntime) checkDevices(node string, devices []runtime.DeviceMapping) error {
+	if len(devices) == 0 {
+		return nil
+	}
+	if node != p.node {
+		utils.Debug(fmt.Sprintf("Skipping the device check on node %s, commands only run on %s", node, p.node))
+		return nil
+	}
+	if _, err := p.execTransport(); err != nil {
+		utils.Debug("Skipping the device check: " + err.Error())
+		return nil
+	}
+
+	var script strings.Builder
+	for _, device := range devices {
+		quoted := shellQuote(device.HostPath)
+		script.WriteString(fmt.Sprintf("[ -c %s ] || [ -b %s ] || echo %s\n", quoted, quoted, quoted))
+	}
+
+	output, err := p.runOnNodeOutput(script.String())
+	if err != nil {
+		return fmt.Errorf("failed to check devices on node %s: %w", node, err)
+	}
+	if missing := strings.Fields(output); len(missing) > 0 {
+		return fmt.Errorf("device %s does not exist on node %s", strings.Join(missing, ", "), node)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package proxmox
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// devicePath matches the device checked by a line of the device check script
+var devicePath = regexp.MustCompile(`/dev/[^'"\s]+`)
+
+// hostDevices is a transport answering the device check of a node having devices
+func hostDevices(devices ...string) *fakeTransport {
+	return &fakeTransport{run: func(command, stdin string) (string, string, int) {
+		var missing []string
+		for _, line := range strings.Split(command, "\n") {
+			device := devicePath.FindString(line)
+			if device == "" {
+				continue
+			}
+			found := false
+			for _, d := range devices {
+				found = found || d == device
+			}
+			if !found {
+				missing = append(missing, device)
+			}
+		}
+		return strings.Join(missing, "\n"), "", 0
+	}}
+}
+
+func TestCreateDevices(t *testing.T) {
+	const render = "/dev/dri/renderD128"
+
+	tests := []struct {
+		name       string
+		devices    []runtime.DeviceMapping
+		privileged bool
+		transport  *fakeTransport // nil when the node cannot be reached
+		want       []string       // dev0, dev1...
+		wantErr    bool
+	}{
+		{
+			name:      "GPU render node",
+			devices:   []runtime.DeviceMapping{{HostPath: render}},
+			transport: hostDevices(render),
+			want:      []string{"path=" + render},
+		},
+		{
+			name:       "GPU render node in a privileged container",
+			devices:    []runtime.DeviceMapping{{HostPath: render}},
+			privileged: true,
+			transport:  hostDevices(render),
+			want:       []string{"path=" + render},
+		},
+		{
+			name:      "render node and read-only card",
+			devices:   []runtime.DeviceMapping{{HostPath: render, ContainerPath: render}, {HostPath: "/dev/dri/card0", Permissions: "rm"}},
+			transport: hostDevices(render, "/dev/dri/card0"),
+			want:      []string{"path=" + render, "path=/dev/dri/card0,deny-write=1"},
+		},
+		{
+			name:    "node cannot be reached",
+			devices: []runtime.DeviceMapping{{HostPath: render}},
+			want:    []string{"path=" + render},
+		},
+		{
+			name:      "render node missing on the node",
+			devices:   []runtime.DeviceMapping{{HostPath: render}},
+			transport: hostDevices("/dev/dri/card0"),
+			wantErr:   true,
+		},
+		{
+			name:      "different container path",
+			devices:   []runtime.DeviceMapping{{HostPath: render, ContainerPath: "/dev/dri/renderD129"}},
+			transport: hostDevices(render),
+			wantErr:   true,
+		},
+		{
+			name:      "invalid permissions",
+			devices:   []runtime.DeviceMapping{{HostPath: render, Permissions: "rx"}},
+			transport: hostDevices(render),
+			wantErr:   true,
+		},
+		{
+			name:      "not a device path",
+			devices:   []runtime.DeviceMapping{{HostPath: "/dev/../etc/shadow"}},
+			transport: hostDevices(render),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+			if tt.transport != nil {
+				p.SetExecTransport(tt.transport)
+			}
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, Devices: tt.devices, Privileged: tt.privileged})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Create succeeded, want an error")
+				}
+				if n := cluster.lxcCount(); n != 0 {
+					t.Errorf("%d containers created", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			guest := cluster.guest(atoi(t, id))
+			for i, want := range tt.want {
+				if got := guest.Config["dev"+itoa(i)]; got != want {
+					t.Errorf("dev%d = %v, want %s", i, got, want)
+				}
+			}
+			if extra, ok := guest.Config["dev"+itoa(len(tt.want))]; ok {
+				t.Errorf("unexpected dev%d = %v", len(tt.want), extra)
+			}
+			if unprivileged := fmt.Sprint(guest.Config["unprivileged"]); (unprivileged == "true" || unprivileged == "1") == tt.privileged {
+				t.Errorf("unprivileged = %v with privileged %v", guest.Config["unprivileged"], tt.privileged)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package proxmox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Filesystem usage of Proxmox containers
+// Usage is read with "df" inside the container. When exec is unavailable
+// (no SSH access, container stopped) the rootfs usage reported by
+// status/current is used instead, without per mount point details.
+// Inspect only includes the usage of running containers when an exec
+// transport is configured, read with the config it already has, so it costs
+// a single exec and never falls back to another API call
+
+// DiskUsage reports used/total/available space of the rootfs and each mount point
+func (p *ProxmoxRuntime) DiskUsage(id string) (*runtime.DiskUsage, error) {
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid container ID: %s", id)
+	}
+
+	if usage, err := p.execDiskUsage(id, p.mountTargets(vmid)); err == nil {
+		return usage, nil
+	}
+
+	// Fall back to the rootfs usage known by Proxmox
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/status/current", p.nodeFor(vmid), vmid), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk usage: %w", err)
+	}
+
+	used := int64(floatValue(resp["disk"]))
+	total := int64(floatValue(resp["maxdisk"]))
+	return &runtime.DiskUsage{
+		RootFS: runtime.FilesystemUsage{
+			Path:      "/",
+			Used:      used,
+			Total:     total,
+			Available: total - used,
+		},
+	}, nil
+}
+
+// execDiskUsage reads the usage with df inside the container
+func (p *ProxmoxRuntime) execDiskUsage(id string, targets map[string]bool) (*runtime.DiskUsage, error) {
+	result, err := p.Exec(id, []string{"df", "-P", "-k"}, runtime.ExecOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("df exited with code %d: %s", result.ExitCode, result.Stderr)
+	}
+	return parseDiskUsage(result.Stdout, targets), nil
+}
+
+// canExec reports whether a transport to run commands in containers is configured
+func (p *ProxmoxRuntime) canExec() bool {
+	p.transportMu.Lock()
+	defer p.transportMu.Unlock()
+	return p.transport != nil || p.config.SSHUser != "" || p.local
+}
+
+// mountTargets returns the in-container paths of the container's mount points
+func (p *ProxmoxRuntime) mountTargets(vmid int) map[string]bool {
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/config", p.nodeFor(vmid), vmid), nil)
+	if err != nil {
+		return map[string]bool{}
+	}
+	return configMountTargets(resp)
+}
+
+// configMountTargets returns the in-container paths of the mount points of a container config
+func configMountTargets(resp map[string]interface{}) map[string]bool {
+	targets := make(map[string]bool)
+	for key, value := range resp {
+		if !strings.HasPrefix(key, "mp") {
+			continue
+		}
+		if s, ok := value.(string); ok {
+			if target := configOption(s, "mp"); target != "" {
+				targets[target] = true
+			}
+		}
+	}
+	return targets
+}
+
+// parseDiskUsage parses POSIX "df -P -k" output. Only the rootfs and the given
+// mount targets are kept; with no targets every non-pseudo filesystem is kept.
+func parseDiskUsage(output string, targets map[string]bool) *runtime.DiskUsage {
+	usage := &runtime.DiskUsage{}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+
+		total, errTotal := strconv.ParseInt(fields[1], 10, 64)
+		used, errUsed := strconv.ParseInt(fields[2], 10, 64)
+		available, errAvail := strconv.ParseInt(fields[3], 10, 64)
+		if errTotal != nil || errUsed != nil || errAvail != nil {
+			continue
+		}
+
+		fs := runtime.FilesystemUsage{
+			Path:      strings.Join(fields[5:], " "),
+			Used:      used * 1024,
+			Total:     total * 1024,
+			Available: available * 1024,
+		}
+
+		switch {
+		case fs.Path == "/":
+			usage.RootFS = fs
+		case len(targets) > 0 && targets[fs.Path]:
+			usage.Mounts = append(usage.Mounts, fs)
+		case len(targets) == 0 && !isPseudoFilesystem(fields[0]):
+			usage.Mounts = append(usage.Mounts, fs)
+		}
+	}
+
+	return usage
+}
+
+func isPseudoFilesystem(fs string) bool {
+	switch fs {
+	case "none", "tmpfs", "devtmpfs", "proc", "sysfs", "udev", "overlay", "shm", "lxcfs", "cgroup", "cgroup2":
+		return true
+	}
+	return false
+}
+
+// configOption returns the value of key in a Proxmox "a=b,c=d" option string
+func configOption(value, key string) string {
+	for _, part := range strings.Split(value, ",") {
+		if k, v, ok := strings.Cut(part, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,145 @@
+package proxmox
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+const dfOutput = `Filesystem                        1024-blocks    Used Available Capacity Mounted on
+/dev/mapper/pve-vm--100--disk--0      8191416 2097152   5675448      27% /
+/dev/mapper/pve-vm--100--disk--1     16382832 1048576  14495672       7% /data
+/mnt/pve/nfs/images/100/vm-100-disk-2.raw 1048576 524288 524288 50% /srv/my media
+none                                      492       4       488       1% /dev
+tmpfs                                 1024000       0   1024000       0% /dev/shm
+`
+
+func TestParseDiskUsage(t *testing.T) {
+	root := runtime.FilesystemUsage{Path: "/", Used: 2097152 * 1024, Total: 8191416 * 1024, Available: 5675448 * 1024}
+	data := runtime.FilesystemUsage{Path: "/data", Used: 1048576 * 1024, Total: 16382832 * 1024, Available: 14495672 * 1024}
+	media := runtime.FilesystemUsage{Path: "/srv/my media", Used: 524288 * 1024, Total: 1048576 * 1024, Available: 524288 * 1024}
+
+	tests := []struct {
+		name    string
+		output  string
+		targets map[string]bool
+		want    runtime.DiskUsage
+	}{
+		{"mount points of the config", dfOutput, map[string]bool{"/data": true, "/srv/my media": true},
+			runtime.DiskUsage{RootFS: root, Mounts: []runtime.FilesystemUsage{data, media}}},
+		{"only listed targets", dfOutput, map[string]bool{"/data": true},
+			runtime.DiskUsage{RootFS: root, Mounts: []runtime.FilesystemUsage{data}}},
+		{"without targets pseudo filesystems are skipped", dfOutput, nil,
+			runtime.DiskUsage{RootFS: root, Mounts: []runtime.FilesystemUsage{data, media}}},
+		{"rootfs only", strings.Join(strings.Split(dfOutput, "\n")[:2], "\n"), nil, runtime.DiskUsage{RootFS: root}},
+		{"garbage", "df: unrecognized option\nfoo bar\n", nil, runtime.DiskUsage{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseDiskUsage(tt.output, tt.targets)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("parseDiskUsage = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+// diskCluster returns a cluster with container 100 holding two mount points
+func diskCluster(t *testing.T, status string) *fakeCluster {
+	cluster := newFakeCluster(t)
+	cluster.addGuest(100, fakeGuest{Status: status, Config: map[string]interface{}{
+		"hostname": "files",
+		"rootfs":   "local-lvm:vm-100-disk-0,size=8G",
+		"mp0":      "local-lvm:vm-100-disk-1,mp=/data,size=16G",
+		"mp1":      "nfs:100/vm-100-disk-2.raw,mp=/srv/my media,size=1G",
+	}})
+	return cluster
+}
+
+func dfTransport(exitCode int) *fakeTransport {
+	return &fakeTransport{run: func(command, stdin string) (string, string, int) {
+		if exitCode != 0 {
+			return "", "df: not found", exitCode
+		}
+		return dfOutput, "", 0
+	}}
+}
+
+func TestDiskUsage(t *testing.T) {
+	cluster := diskCluster(t, "running")
+	p := newTestRuntime(t, cluster)
+	transport := dfTransport(0)
+	p.SetExecTransport(transport)
+
+	usage, err := p.DiskUsage("100")
+	if err != nil {
+		t.Fatalf("DiskUsage: %v", err)
+	}
+	if usage.RootFS.Path != "/" || len(usage.Mounts) != 2 {
+		t.Errorf("DiskUsage = %+v, want the rootfs and 2 mount points", usage)
+	}
+	if commands := transport.ran("pct exec 100"); len(commands) != 1 || !strings.Contains(commands[0], "df") {
+		t.Errorf("commands = %v, want a single df", commands)
+	}
+}
+
+func TestDiskUsageFallback(t *testing.T) {
+	cluster := diskCluster(t, "stopped")
+	cluster.handle("GET /nodes/pve/lxc/100/status/current", func(*http.Request, map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, map[string]interface{}{"vmid": 100, "status": "stopped", "disk": 1024, "maxdisk": 4096}
+	})
+	p := newTestRuntime(t, cluster)
+	p.SetExecTransport(dfTransport(1))
+
+	usage, err := p.DiskUsage("100")
+	if err != nil {
+		t.Fatalf("DiskUsage: %v", err)
+	}
+	want := runtime.DiskUsage{RootFS: runtime.FilesystemUsage{Path: "/", Used: 1024, Total: 4096, Available: 3072}}
+	if !reflect.DeepEqual(*usage, want) {
+		t.Errorf("DiskUsage = %+v, want %+v", *usage, want)
+	}
+}
+
+func TestInspectDiskUsage(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		transport bool
+		want      bool // DiskUsage is set and df ran
+	}{
+		{"running with exec", "running", true, true},
+		{"stopped", "stopped", true, false},
+		{"running without exec", "running", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := diskCluster(t, tt.status)
+			p := newTestRuntime(t, cluster)
+			transport := dfTransport(0)
+			if tt.transport {
+				p.SetExecTransport(transport)
+			}
+
+			details, err := p.Inspect("100")
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+			ran := len(transport.ran("df")) > 0
+			if (details.DiskUsage != nil) != tt.want || ran != tt.want {
+				t.Errorf("DiskUsage = %+v, df ran: %v, want %v", details.DiskUsage, ran, tt.want)
+			}
+			if tt.want && len(details.DiskUsage.Mounts) != 2 {
+				t.Errorf("DiskUsage mounts = %+v, want 2", details.DiskUsage.Mounts)
+			}
+			if n := cluster.count("GET /nodes/pve/lxc/100/config"); n != 1 {
+				t.Errorf("config read %d times, want once", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package proxmox
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// DNS of Proxmox containers
+// DNS and DNSSearch become the nameserver and searchdomain options, which
+// Proxmox writes into the resolv.conf of the container. Domainname has no
+// option of its own: it is put first in the search domains, which Proxmox
+// also uses for the FQDN of the container in /etc/hosts. Without them the
+// container keeps the resolver of the node
+
+// lxcDNS renders the nameserver and searchdomain options of a container
+func lxcDNS(config runtime.ContainerConfig) (nameserver, searchdomain string, err error) {
+	for _, server := range config.DNS {
+		if net.ParseIP(server) == nil {
+			return "", "", fmt.Errorf("invalid DNS server %q: must be an IP address", server)
+		}
+	}
+
+	var domains []string
+	if config.Domainname != "" {
+		domains = append(domains, config.Domainname)
+	}
+	for _, domain := range config.DNSSearch {
+		if domain != config.Domainname {
+			domains = append(domains, domain)
+		}
+	}
+	for _, domain := range domains {
+		if domain == "" || strings.ContainsAny(domain, " \t,;") {
+			return "", "", fmt.Errorf("invalid DNS search domain %q", domain)
+		}
+	}
+
+	return strings.Join(config.DNS, " "), strings.Join(domains, " "), nil
+}
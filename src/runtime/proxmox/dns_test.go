@@ -0,0 +1,60 @@
+package proxmox
+
+import (
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestCreateDNS(t *testing.T) {
+	tests := []struct {
+		name             string
+		dns              []string
+		search           []string
+		domain           string
+		wantNameserver   string // empty for inherited from the node
+		wantSearchdomain string
+		wantErr          bool
+	}{
+		{"inherited", nil, nil, "", "", "", false},
+		{"servers", []string{"10.0.0.53", "2001:db8::53"}, nil, "", "10.0.0.53 2001:db8::53", "", false},
+		{"search domains", nil, []string{"lan", "corp.example.com"}, "", "", "lan corp.example.com", false},
+		{"domain name first", []string{"1.1.1.1"}, []string{"lan", "example.com"}, "example.com", "1.1.1.1", "example.com lan", false},
+		{"domain name alone", nil, nil, "example.com", "", "example.com", false},
+		{"hostname server", []string{"dns.example.com"}, nil, "", "", "", true},
+		{"empty search domain ignored", nil, []string{"", "lan"}, "", "", "lan", false},
+		{"search domain with a space", nil, []string{"a b"}, "", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, DNS: tt.dns, DNSSearch: tt.search, Domainname: tt.domain})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Create = %s, want an error", id)
+				}
+				if n := cluster.lxcCount(); n != 0 {
+					t.Errorf("%d containers created with an invalid DNS config", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			config := cluster.guest(atoi(t, id)).Config
+			for key, want := range map[string]string{"nameserver": tt.wantNameserver, "searchdomain": tt.wantSearchdomain} {
+				got, set := config[key]
+				if want == "" && set {
+					t.Errorf("%s = %v, want it inherited from the node", key, got)
+				}
+				if want != "" && got != want {
+					t.Errorf("%s = %v, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
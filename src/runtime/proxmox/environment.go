@@ -0,0 +1,120 @@
+package proxmox
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Environment variables of Proxmox containers
+// LXC has no native environment, so ContainerConfig.Environment is kept in the
+// cosmos-env.<KEY> labels (encrypted at rest, see metadata_crypto.go) and
+// written to envFile, sourced by login shells, at every Start before the
+// PostInstall commands run. The file is rewritten as a whole, so keys removed
+// by an update disappear from the container; the keys written are recorded in
+// cosmos-env-keys so the file is also removed once the environment is emptied
+
+const (
+	labelEnvPrefix = "cosmos-env."
+	LabelEnvKeys   = "cosmos-env-keys"
+
+	envFile = "/etc/profile.d/cosmos-env.sh"
+)
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateEnvironment rejects keys that cannot be exported by a shell
+func validateEnvironment(env map[string]string) error {
+	for key := range env {
+		if !envKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid environment variable name %q", key)
+		}
+	}
+	return nil
+}
+
+// storeEnvironment replaces the environment labels of a container
+func (p *ProxmoxRuntime) storeEnvironment(vmid int, env map[string]string) {
+	var remove []string
+	for key := range p.metadata.Get(vmid) {
+		if strings.HasPrefix(key, labelEnvPrefix) {
+			remove = append(remove, key)
+		}
+	}
+
+	add := make(map[string]string, len(env))
+	for key, value := range env {
+		add[labelEnvPrefix+key] = value
+	}
+
+	if len(add) > 0 || len(remove) > 0 {
+		_ = p.metadata.UpdateLabels(vmid, add, remove)
+	}
+}
+
+// environment returns the configured environment of a container
+func (p *ProxmoxRuntime) environment(vmid int) map[string]string {
+	env := make(map[string]string)
+	for key, value := range p.metadata.Get(vmid) {
+		if strings.HasPrefix(key, labelEnvPrefix) {
+			env[strings.TrimPrefix(key, labelEnvPrefix)] = value
+		}
+	}
+	return env
+}
+
+// applyEnvironment writes the environment file of a started container
+func (p *ProxmoxRuntime) applyEnvironment(vmid int) error {
+	env := p.environment(vmid)
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	injected := p.metadata.GetLabel(vmid, LabelEnvKeys)
+	if len(keys) == 0 && injected == "" {
+		return nil
+	}
+
+	id := fmt.Sprint(vmid)
+	var result *runtime.ExecResult
+	var err error
+	if len(keys) == 0 {
+		result, err = p.Exec(id, []string{"rm", "-f", envFile}, runtime.ExecOptions{})
+	} else {
+		script := fmt.Sprintf("cat > %[1]s.tmp && chmod 644 %[1]s.tmp && mv %[1]s.tmp %[1]s", envFile)
+		result, err = p.execWithInput(id, []string{"sh", "-c", script}, runtime.ExecOptions{}, strings.NewReader(renderEnvExports(env)))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write the environment of container %s: %w", id, err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("writing the environment of container %s exited with code %d: %s", id, result.ExitCode, result.Stderr)
+	}
+
+	if len(keys) == 0 {
+		_ = p.metadata.UpdateLabels(vmid, nil, []string{LabelEnvKeys})
+	} else {
+		p.metadata.SetLabel(vmid, LabelEnvKeys, strings.Join(keys, ","))
+	}
+
+	utils.Log(fmt.Sprintf("Environment of LXC container VMID %d written: %s", vmid, redactArgs(env)))
+	return nil
+}
+
+// renderEnvExports renders env as exported shell variables with quoted values
+func renderEnvExports(env map[string]string) string {
+	var out strings.Builder
+	out.WriteString("# Managed by Cosmos, changes are overwritten\n")
+	for _, line := range strings.SplitAfter(renderEnvFile(env), "\n") {
+		if line != "" {
+			out.WriteString("export " + line)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,230 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Container lifecycle events
+// Proxmox has no event stream, so Events polls the cluster: finished tasks
+// (vzcreate, vzstart, vzstop...) give the transitions made through Proxmox,
+// even when a container is stopped and started again between two polls, and
+// the container states catch what no task reports, such as a container whose
+// init exited. Each container remembers its last event so a transition seen
+// both ways is only sent once
+
+const eventPollInterval = 5 * time.Second
+
+// taskEventActions maps Proxmox task types to lifecycle events
+var taskEventActions = map[string]runtime.EventAction{
+	"vzcreate":   runtime.EventCreated,
+	"vzrestore":  runtime.EventCreated,
+	"vzclone":    runtime.EventCreated,
+	"vzstart":    runtime.EventStarted,
+	"vzstop":     runtime.EventStopped,
+	"vzshutdown": runtime.EventStopped,
+	"vzdestroy":  runtime.EventRemoved,
+}
+
+// eventContainer is the state of a container at the last poll
+type eventContainer struct {
+	name    string
+	running bool
+}
+
+// eventPoller derives lifecycle events from successive polls of the cluster
+type eventPoller struct {
+	p          *ProxmoxRuntime
+	out        chan runtime.ContainerEvent
+	containers map[int]eventContainer
+	last       map[int]runtime.EventAction
+	tasks      map[string]map[string]interface{} // finished tasks at the last poll, by UPID
+}
+
+// Events polls the cluster for container lifecycle changes until ctx is done
+func (p *ProxmoxRuntime) Events(ctx context.Context) (<-chan runtime.ContainerEvent, error) {
+	if !p.connected {
+		return nil, errNotConnected
+	}
+
+	poller := &eventPoller{
+		p:    p,
+		out:  make(chan runtime.ContainerEvent, 64),
+		last: make(map[int]runtime.EventAction),
+	}
+
+	// The first poll only records the current state
+	containers, err := p.eventContainers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	poller.containers = containers
+	for vmid, c := range containers {
+		poller.last[vmid] = stateEvent(c.running)
+	}
+	poller.tasks, _ = poller.finishedTasks()
+
+	ctx, cancel := context.WithCancel(ctx)
+	p.goBackground(func(root context.Context) {
+		defer context.AfterFunc(root, cancel)()
+		defer cancel()
+		poller.run(ctx)
+	})
+	return poller.out, nil
+}
+
+func (e *eventPoller) run(ctx context.Context) {
+	defer close(e.out)
+
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !e.poll(ctx) {
+			return
+		}
+	}
+}
+
+// poll emits the events since the previous poll, it returns false once ctx is done
+func (e *eventPoller) poll(ctx context.Context) bool {
+	containers, err := e.p.eventContainers()
+	if err != nil {
+		utils.Debug("Proxmox events: failed to list containers: " + err.Error())
+		return true
+	}
+
+	if tasks, err := e.finishedTasks(); err != nil {
+		utils.Debug("Proxmox events: failed to list tasks: " + err.Error())
+	} else if e.tasks == nil {
+		// The task list was unavailable so far, older tasks are not events
+		e.tasks = tasks
+	} else {
+		var fresh []map[string]interface{}
+		for upid, task := range tasks {
+			if _, seen := e.tasks[upid]; !seen {
+				fresh = append(fresh, task)
+			}
+		}
+		sort.Slice(fresh, func(i, j int) bool { return floatValue(fresh[i]["endtime"]) < floatValue(fresh[j]["endtime"]) })
+
+		for _, task := range fresh {
+			vmid, _ := strconv.Atoi(fmt.Sprint(task["id"]))
+			c, current := containers[vmid]
+			if _, previous := e.containers[vmid]; !current && !previous {
+				continue
+			}
+			if !current {
+				c = e.containers[vmid]
+			}
+			taskType, _ := task["type"].(string)
+			if !e.emit(ctx, vmid, c.name, taskEventActions[taskType], int64(floatValue(task["endtime"]))) {
+				return false
+			}
+		}
+		e.tasks = tasks
+	}
+
+	now := time.Now().Unix()
+	for vmid, c := range containers {
+		previous, known := e.containers[vmid]
+		if !known && !e.emit(ctx, vmid, c.name, runtime.EventCreated, now) {
+			return false
+		}
+		if (!known || previous.running != c.running) && !e.emit(ctx, vmid, c.name, stateEvent(c.running), now) {
+			return false
+		}
+	}
+	for vmid, c := range e.containers {
+		if _, ok := containers[vmid]; !ok {
+			if !e.emit(ctx, vmid, c.name, runtime.EventRemoved, now) {
+				return false
+			}
+			delete(e.last, vmid)
+		}
+	}
+
+	e.containers = containers
+	return true
+}
+
+// emit sends an event unless it is the last one sent for the container
+func (e *eventPoller) emit(ctx context.Context, vmid int, name string, action runtime.EventAction, at int64) bool {
+	if action == "" || e.last[vmid] == action {
+		return true
+	}
+	e.last[vmid] = action
+
+	event := runtime.ContainerEvent{ID: strconv.Itoa(vmid), Name: name, Action: action, Time: at}
+	select {
+	case e.out <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// finishedTasks returns the successful container tasks of the cluster task list, by UPID
+func (e *eventPoller) finishedTasks() (map[string]map[string]interface{}, error) {
+	resp, err := e.p.apiRequest("GET", "/cluster/tasks", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make(map[string]map[string]interface{})
+	for _, task := range listItems(resp) {
+		upid, _ := task["upid"].(string)
+		taskType, _ := task["type"].(string)
+		if upid == "" || taskEventActions[taskType] == "" || task["status"] != "OK" {
+			continue
+		}
+		tasks[upid] = task
+	}
+	return tasks, nil
+}
+
+// eventContainers returns the listed containers of the cluster with their state
+func (p *ProxmoxRuntime) eventContainers() (map[int]eventContainer, error) {
+	resp, err := p.apiRequest("GET", "/cluster/resources?type=vm", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := make(map[int]eventContainer)
+	for _, item := range listItems(resp) {
+		if item["type"] != "lxc" || (!p.config.AllNodes && item["node"] != p.node) {
+			continue
+		}
+		vmid, ok := item["vmid"].(float64)
+		if !ok || !p.listed(p.metadata.Get(int(vmid))) {
+			continue
+		}
+
+		name := p.metadata.GetLabel(int(vmid), "cosmos-name")
+		if name == "" {
+			name, _ = item["name"].(string)
+		}
+		containers[int(vmid)] = eventContainer{name: name, running: item["status"] == "running"}
+	}
+	return containers, nil
+}
+
+// stateEvent is the event matching a container state
+func stateEvent(running bool) runtime.EventAction {
+	if running {
+		return runtime.EventStarted
+	}
+	return runtime.EventStopped
+}
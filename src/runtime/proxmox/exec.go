@@ -0,0 +1,261 @@
+package proxmox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Command execution inside Proxmox LXC containers
+// The Proxmox API has no exec endpoint for containers, so commands are run
+// with "pct exec" on the node hosting the container, over an SSH transport to
+// the configured node that hops to the other nodes of the cluster
+
+// ExecTransport runs a shell command on the Proxmox node
+type ExecTransport interface {
+	Run(command string, stdin io.Reader, stdout, stderr io.Writer) (int, error)
+}
+
+// ttyTransport is implemented by transports able to allocate a terminal, used when ExecOptions.TTY is set
+type ttyTransport interface {
+	RunTTY(command string, stdin io.Reader, stdout io.Writer) (int, error)
+}
+
+// Exec runs a command inside a container and returns its output and exit code
+func (p *ProxmoxRuntime) Exec(id string, cmd []string, opts runtime.ExecOptions) (*runtime.ExecResult, error) {
+	return p.execWithInput(id, cmd, opts, nil)
+}
+
+// execWithInput runs a command inside a container, feeding stdin to it
+func (p *ProxmoxRuntime) execWithInput(id string, cmd []string, opts runtime.ExecOptions, stdin io.Reader) (*runtime.ExecResult, error) {
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid container ID: %s", id)
+	}
+
+	if len(cmd) == 0 {
+		return nil, errors.New("no command to execute")
+	}
+
+	transport, err := p.execTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	node := p.nodeFor(vmid)
+	var stdout, stderr bytes.Buffer
+	var exitCode int
+	if tty, ok := transport.(ttyTransport); ok && opts.TTY {
+		exitCode, err = tty.RunTTY(p.nodeCommand(node, buildPctExec(vmid, cmd, opts), true), stdin, &stdout)
+	} else {
+		exitCode, err = transport.Run(p.nodeCommand(node, buildPctExec(vmid, cmd, opts), false), stdin, &stdout, &stderr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to exec in container %s: %w", id, err)
+	}
+
+	return &runtime.ExecResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: exitCode,
+	}, nil
+}
+
+// SetExecTransport overrides the transport used to reach the node
+func (p *ProxmoxRuntime) SetExecTransport(transport ExecTransport) {
+	p.transportMu.Lock()
+	defer p.transportMu.Unlock()
+	p.transport = transport
+}
+
+// execTransport returns the configured transport, creating the SSH one on first use
+func (p *ProxmoxRuntime) execTransport() (ExecTransport, error) {
+	p.transportMu.Lock()
+	defer p.transportMu.Unlock()
+
+	if p.transport != nil {
+		return p.transport, nil
+	}
+
+	if p.config.SSHUser == "" {
+		if p.local {
+			p.transport = localTransport{}
+			return p.transport, nil
+		}
+		return nil, errors.New("exec requires SSH access to the Proxmox node (SSHUser is not configured)")
+	}
+
+	transport, err := newSSHTransport(p.config)
+	if err != nil {
+		return nil, err
+	}
+
+	p.transport = transport
+	return transport, nil
+}
+
+// buildPctExec renders the pct exec command line for the node shell
+func buildPctExec(vmid int, cmd []string, opts runtime.ExecOptions) string {
+	var script strings.Builder
+
+	if opts.WorkingDir != "" {
+		script.WriteString("cd " + shellQuote(opts.WorkingDir) + " && ")
+	}
+
+	if len(opts.Environment) > 0 {
+		keys := make([]string, 0, len(opts.Environment))
+		for k := range opts.Environment {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		script.WriteString("env")
+		for _, k := range keys {
+			script.WriteString(" " + shellQuote(k+"="+opts.Environment[k]))
+		}
+		script.WriteString(" ")
+	}
+
+	quoted := make([]string, len(cmd))
+	for i, arg := range cmd {
+		quoted[i] = shellQuote(arg)
+	}
+	script.WriteString(strings.Join(quoted, " "))
+
+	return fmt.Sprintf("pct exec %d -- sh -c %s", vmid, shellQuote(script.String()))
+}
+
+// nodeCommand wraps a command so it runs on node rather than on the SSH node.
+// Other nodes of the cluster are reached with the root SSH access Proxmox sets
+// up between nodes; tty asks that hop for a terminal
+func (p *ProxmoxRuntime) nodeCommand(node, command string, tty bool) string {
+	if node == "" || node == p.node {
+		return command
+	}
+	ssh := "ssh -o BatchMode=yes "
+	if tty {
+		ssh = "ssh -t -o BatchMode=yes "
+	}
+	return ssh + shellQuote("root@"+node) + " " + shellQuote(command)
+}
+
+// shellQuote quotes a string for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// sshTransport runs node commands over SSH
+type sshTransport struct {
+	address string
+	config  *ssh.ClientConfig
+}
+
+// newSSHTransport builds an SSH transport to the node from the runtime config
+func newSSHTransport(config *Config) (*sshTransport, error) {
+	var auth []ssh.AuthMethod
+
+	if config.SSHKeyPath != "" {
+		key, err := os.ReadFile(config.SSHKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSH key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SSH key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if config.SSHPassword != "" {
+		auth = append(auth, ssh.Password(config.SSHPassword))
+	}
+
+	if len(auth) == 0 {
+		return nil, errors.New("SSH transport requires SSHKeyPath or SSHPassword")
+	}
+
+	// Without a known_hosts file the node key cannot be verified
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if config.SSHKnownHosts != "" {
+		callback, err := knownhosts.New(config.SSHKnownHosts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SSH known hosts: %w", err)
+		}
+		hostKeyCallback = callback
+	}
+
+	host := config.Host
+	if h, _, err := net.SplitHostPort(config.Host); err == nil {
+		host = h
+	}
+
+	port := config.SSHPort
+	if port == 0 {
+		port = 22
+	}
+
+	return &sshTransport{
+		address: net.JoinHostPort(host, strconv.Itoa(port)),
+		config: &ssh.ClientConfig{
+			User:            config.SSHUser,
+			Auth:            auth,
+			HostKeyCallback: hostKeyCallback,
+		},
+	}, nil
+}
+
+// Run executes a command on the node and returns its exit code
+func (t *sshTransport) Run(command string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	return t.run(command, stdin, stdout, stderr, false)
+}
+
+// RunTTY executes a command on the node in a terminal, stderr is merged into stdout
+func (t *sshTransport) RunTTY(command string, stdin io.Reader, stdout io.Writer) (int, error) {
+	return t.run(command, stdin, stdout, stdout, true)
+}
+
+func (t *sshTransport) run(command string, stdin io.Reader, stdout, stderr io.Writer, tty bool) (int, error) {
+	client, err := ssh.Dial("tcp", t.address, t.config)
+	if err != nil {
+		return -1, err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return -1, err
+	}
+	defer session.Close()
+
+	if tty {
+		if err := session.RequestPty("xterm", 24, 80, ssh.TerminalModes{ssh.ECHO: 0}); err != nil {
+			return -1, err
+		}
+	}
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	err = session.Run(command)
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,61 @@
+package proxmox
+
+import (
+	"strings"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestExecOnContainerNode(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    string
+		wantHop bool // commands go through ssh to the container node
+	}{
+		{"SSH node", "pve", false},
+		{"second node", "pve2", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t, "pve", "pve2")
+			cluster.addGuest(100, fakeGuest{Node: tt.node, Config: map[string]interface{}{"hostname": "app"}})
+			p := newTestRuntime(t, cluster)
+			p.metadata.SetLabel(100, LabelPostInstall, `["echo installed"]`)
+
+			transport := &fakeTransport{}
+			p.SetExecTransport(transport)
+
+			if err := p.Start("100"); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			if g := cluster.guest(100); g.Status != "running" {
+				t.Fatalf("status = %s, want running", g.Status)
+			}
+			if _, err := p.Exec("100", []string{"hostname"}, runtime.ExecOptions{}); err != nil {
+				t.Fatalf("Exec: %v", err)
+			}
+
+			transport.mu.Lock()
+			commands := transport.commands
+			transport.mu.Unlock()
+
+			var execs []string
+			for _, command := range commands {
+				if strings.Contains(command, "pct exec 100") {
+					execs = append(execs, command)
+				}
+			}
+			if len(execs) < 2 {
+				t.Fatalf("pct exec ran %d times, want the post-install step and Exec: %q", len(execs), commands)
+			}
+			for _, command := range execs {
+				hop := strings.HasPrefix(command, "ssh -o BatchMode=yes 'root@"+tt.node+"' ")
+				if hop != tt.wantHop {
+					t.Errorf("command %q, want ssh to %s %v", command, tt.node, tt.wantHop)
+				}
+			}
+		})
+	}
+}
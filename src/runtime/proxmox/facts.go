@@ -0,0 +1,93 @@
+package proxmox
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// System facts in container configs
+// Environment values, labels and PostInstall commands can reference values
+// known to the runtime at Create time, expanded once the VMID is allocated:
+//   - {{node}}    node the container is created on
+//   - {{hostIP}}  address of that node
+//   - {{vmid}}    VMID of the container
+//   - {{storage}} storage of the container rootfs
+//   - {{name}}    container name
+// Unknown placeholders are left as is, and expanded values are not expanded again
+
+// systemFacts returns the values of the placeholders for a container
+func (p *ProxmoxRuntime) systemFacts(vmid int, node string, config runtime.ContainerConfig) map[string]string {
+	return map[string]string{
+		"node":    node,
+		"hostIP":  p.nodeAddress(node),
+		"vmid":    strconv.Itoa(vmid),
+		"storage": p.rootfsStorage(config),
+		"name":    config.Name,
+	}
+}
+
+// expandFacts replaces the placeholders of Environment, Labels and PostInstall.
+// The maps and slices are copied so the caller's config is left untouched
+func expandFacts(config runtime.ContainerConfig, facts map[string]string) runtime.ContainerConfig {
+	pairs := make([]string, 0, len(facts)*2)
+	for key, value := range facts {
+		pairs = append(pairs, "{{"+key+"}}", value)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	if config.Environment != nil {
+		env := make(map[string]string, len(config.Environment))
+		for k, v := range config.Environment {
+			env[k] = replacer.Replace(v)
+		}
+		config.Environment = env
+	}
+
+	if config.Labels != nil {
+		labels := make(map[string]string, len(config.Labels))
+		for k, v := range config.Labels {
+			labels[k] = replacer.Replace(v)
+		}
+		config.Labels = labels
+	}
+
+	if config.PostInstall != nil {
+		commands := make([]string, len(config.PostInstall))
+		for i, command := range config.PostInstall {
+			commands[i] = replacer.Replace(command)
+		}
+		config.PostInstall = commands
+	}
+
+	return config
+}
+
+// nodeAddress returns the cluster address of node, or the host of the API
+// endpoint when the cluster status cannot be read
+func (p *ProxmoxRuntime) nodeAddress(node string) string {
+	if resp, err := p.apiRequest("GET", "/cluster/status", nil); err == nil {
+		for _, item := range listItems(resp) {
+			if kind, _ := item["type"].(string); kind != "node" {
+				continue
+			}
+			if name, _ := item["name"].(string); name != node {
+				continue
+			}
+			if ip, _ := item["ip"].(string); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host := p.config.Host
+	if u, err := url.Parse(host); err == nil && u.Host != "" {
+		host = u.Hostname()
+	} else if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host
+}
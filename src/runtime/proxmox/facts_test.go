@@ -0,0 +1,88 @@
+package proxmox
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestExpandFacts(t *testing.T) {
+	facts := map[string]string{"node": "pve", "hostIP": "10.0.0.2", "vmid": "105", "storage": "local-lvm", "name": "{{vmid}}"}
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"plain", "postgres://db:5432", "postgres://db:5432"},
+		{"single", "{{hostIP}}", "10.0.0.2"},
+		{"repeated", "{{vmid}}-{{vmid}}-{{vmid}}", "105-105-105"},
+		{"several", "http://{{hostIP}}:8006/#v1:0:=lxc%2F{{vmid}}:{{node}}", "http://10.0.0.2:8006/#v1:0:=lxc%2F105:pve"},
+		{"adjacent", "{{node}}{{storage}}", "pvelocal-lvm"},
+		{"nested in braces", "{{{{node}}}}", "{{pve}}"},
+		{"nested in a placeholder", "{{node{{vmid}}}}", "{{node105}}"},
+		{"unknown", "{{hostname}} {{vmid}}", "{{hostname}} 105"},
+		{"unclosed", "{{node} {{vmid", "{{node} {{vmid"},
+		{"case sensitive", "{{NODE}} {{hostip}}", "{{NODE}} {{hostip}}"},
+		{"value not expanded again", "{{name}}", "{{vmid}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := runtime.ContainerConfig{
+				Environment: map[string]string{"VALUE": tt.value},
+				Labels:      map[string]string{"value": tt.value},
+				PostInstall: []string{tt.value},
+			}
+			got := expandFacts(config, facts)
+
+			if v := got.Environment["VALUE"]; v != tt.want {
+				t.Errorf("environment = %q, want %q", v, tt.want)
+			}
+			if v := got.Labels["value"]; v != tt.want {
+				t.Errorf("label = %q, want %q", v, tt.want)
+			}
+			if v := got.PostInstall[0]; v != tt.want {
+				t.Errorf("post-install command = %q, want %q", v, tt.want)
+			}
+			if config.Environment["VALUE"] != tt.value || config.Labels["value"] != tt.value || config.PostInstall[0] != tt.value {
+				t.Errorf("caller's config modified")
+			}
+		})
+	}
+}
+
+func TestCreateExpandsFacts(t *testing.T) {
+	cluster := newFakeCluster(t)
+	cluster.handle("GET /cluster/status", func(*http.Request, map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, []map[string]interface{}{
+			{"type": "cluster", "name": "lab"},
+			{"type": "node", "name": "pve", "ip": "10.0.0.2"},
+		}
+	})
+	p := newTestRuntime(t, cluster)
+
+	id, err := p.Create(runtime.ContainerConfig{
+		Name:        "app",
+		Image:       testImage,
+		Environment: map[string]string{"PUBLIC_URL": "http://{{hostIP}}:{{vmid}}", "DATA": "/mnt/{{storage}}/{{name}}"},
+		Labels:      map[string]string{"origin": "{{node}}/{{vmid}}", "literal": "{{unknown}}"},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	wantEnv := map[string]string{"PUBLIC_URL": "http://10.0.0.2:" + id, "DATA": "/mnt/local-lvm/app"}
+	if env := p.environment(atoi(t, id)); !reflect.DeepEqual(env, wantEnv) {
+		t.Errorf("environment = %v, want %v", env, wantEnv)
+	}
+	vmid := atoi(t, id)
+	if origin := p.metadata.GetLabel(vmid, "origin"); origin != "pve/"+id {
+		t.Errorf("origin label = %q, want pve/%s", origin, id)
+	}
+	if literal := p.metadata.GetLabel(vmid, "literal"); literal != "{{unknown}}" {
+		t.Errorf("literal label = %q", literal)
+	}
+}
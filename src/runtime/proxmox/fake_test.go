@@ -0,0 +1,554 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Test doubles
+// fakeCluster serves the Proxmox API calls of the runtime from in-memory
+// guests, with handle overriding single endpoints. fakeTransport stands for
+// the SSH transport, recording the commands run on the node
+
+// fakeGuest is a container or VM of the fake cluster
+type fakeGuest struct {
+	Node   string
+	Type   string // lxc or qemu
+	Status string
+	Lock   string
+	Config map[string]interface{}
+}
+
+// fakeHandler answers a request with a status code and the "data" of the response.
+// Error statuses send data as the body when it is a string
+type fakeHandler func(r *http.Request, body map[string]interface{}) (int, interface{})
+
+type fakeCluster struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	nodes       []string
+	guests      map[int]*fakeGuest
+	maintenance map[string]bool
+	handlers    map[string]fakeHandler
+	calls       []string
+	tasks       int
+	taskLog     []string // log lines of every task
+}
+
+// newFakeCluster starts a fake API with the nodes, the first one hosting the runtime
+func newFakeCluster(t testing.TB, nodes ...string) *fakeCluster {
+	if len(nodes) == 0 {
+		nodes = []string{"pve"}
+	}
+	f := &fakeCluster{
+		nodes:       nodes,
+		guests:      make(map[int]*fakeGuest),
+		maintenance: make(map[string]bool),
+		handlers:    make(map[string]fakeHandler),
+	}
+	f.server = httptest.NewTLSServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// handle overrides an endpoint, route is "METHOD /path" with the query when it must match
+func (f *fakeCluster) handle(route string, handler fakeHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[route] = handler
+}
+
+// addGuest adds a guest with its config, a stopped LXC container on the first node by default
+func (f *fakeCluster) addGuest(vmid int, guest fakeGuest) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if guest.Node == "" {
+		guest.Node = f.nodes[0]
+	}
+	if guest.Type == "" {
+		guest.Type = "lxc"
+	}
+	if guest.Status == "" {
+		guest.Status = "stopped"
+	}
+	if guest.Config == nil {
+		guest.Config = map[string]interface{}{}
+	}
+	f.guests[vmid] = &guest
+}
+
+// removeGuest deletes a guest behind the runtime's back, as done from the Proxmox UI
+func (f *fakeCluster) removeGuest(vmid int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.guests, vmid)
+}
+
+// setLock locks a guest as a running backup or snapshot would, "" unlocks it
+func (f *fakeCluster) setLock(vmid int, lock string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if g, ok := f.guests[vmid]; ok {
+		g.Lock = lock
+	}
+}
+
+// setTaskLog sets the log lines of every task
+func (f *fakeCluster) setTaskLog(lines []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.taskLog = lines
+}
+
+// setMaintenance puts a node in HA maintenance mode, or takes it out
+func (f *fakeCluster) setMaintenance(node string, on bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.maintenance[node] = on
+}
+
+// guest returns a copy of a guest, nil when it does not exist
+func (f *fakeCluster) guest(vmid int) *fakeGuest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	g, ok := f.guests[vmid]
+	if !ok {
+		return nil
+	}
+	c := *g
+	c.Config = make(map[string]interface{}, len(g.Config))
+	for k, v := range g.Config {
+		c.Config[k] = v
+	}
+	return &c
+}
+
+// count returns how many requests matched the route, "METHOD /path" with or without the query
+func (f *fakeCluster) count(route string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, call := range f.calls {
+		if call == route || strings.SplitN(call, "?", 2)[0] == route {
+			n++
+		}
+	}
+	return n
+}
+
+// requests returns the number of requests served
+func (f *fakeCluster) requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+// lxcCount returns the number of LXC containers of the cluster
+func (f *fakeCluster) lxcCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, g := range f.guests {
+		if g.Type == "lxc" {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeCluster) serve(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	if raw, _ := io.ReadAll(r.Body); len(raw) > 0 {
+		_ = json.Unmarshal(raw, &body)
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api2/json")
+	route := r.Method + " " + path
+	call := route
+	if r.URL.RawQuery != "" {
+		call += "?" + r.URL.RawQuery
+	}
+
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	handler, ok := f.handlers[call]
+	if !ok {
+		handler, ok = f.handlers[route]
+	}
+	f.mu.Unlock()
+
+	var status int
+	var data interface{}
+	if ok {
+		status, data = handler(r, body)
+	} else {
+		status, data = f.route(r, path, body)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if status >= 400 {
+		w.WriteHeader(status)
+		if message, ok := data.(string); ok {
+			fmt.Fprint(w, message)
+		}
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+// route answers the requests of the default cluster behaviour
+func (f *fakeCluster) route(r *http.Request, path string, body map[string]interface{}) (int, interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case path == "/version":
+		return http.StatusOK, map[string]interface{}{"version": "8.2.4", "release": "8.2"}
+
+	case path == "/cluster/resources":
+		var items []map[string]interface{}
+		kind := r.URL.Query().Get("type")
+		if kind == "" || kind == "node" {
+			for _, node := range f.nodes {
+				items = append(items, map[string]interface{}{"type": "node", "node": node, "status": "online"})
+			}
+		}
+		if kind == "" || kind == "vm" {
+			items = append(items, f.guestItems("", "")...)
+		}
+		return http.StatusOK, items
+
+	case path == "/cluster/ha/status/manager_status":
+		states := map[string]interface{}{}
+		for _, node := range f.nodes {
+			states[node] = "online"
+			if f.maintenance[node] {
+				states[node] = "maintenance"
+			}
+		}
+		return http.StatusOK, map[string]interface{}{"manager_status": map[string]interface{}{"node_status": states}}
+
+	case len(parts) < 3 || parts[0] != "nodes":
+		return http.StatusNotImplemented, "not implemented by the fake cluster: " + path
+	}
+
+	node := parts[1]
+	switch {
+	case len(parts) == 3 && parts[2] == "storage":
+		return http.StatusOK, []map[string]interface{}{
+			{"storage": "local-lvm", "type": "lvmthin", "content": "rootdir,images", "active": 1},
+			{"storage": "local", "type": "dir", "content": "vztmpl,iso,backup", "active": 1},
+		}
+
+	case len(parts) == 3 && parts[2] == "network":
+		return http.StatusOK, []map[string]interface{}{
+			{"iface": "vmbr0", "type": "bridge"},
+			{"iface": "vmbr1", "type": "bridge"},
+		}
+
+	case len(parts) == 3 && (parts[2] == "lxc" || parts[2] == "qemu") && r.Method == "GET":
+		return http.StatusOK, f.guestItems(node, parts[2])
+
+	case len(parts) == 3 && parts[2] == "lxc" && r.Method == "POST":
+		vmid := int(floatValue(body["vmid"]))
+		if _, taken := f.guests[vmid]; taken {
+			return http.StatusInternalServerError, fmt.Sprintf("CT %d already exists on node '%s'", vmid, node)
+		}
+		config := map[string]interface{}{}
+		for k, v := range body {
+			switch k {
+			case "vmid", "ostemplate", "storage", "password", "start", "pool", "ssh-public-keys":
+				continue
+			}
+			config[k] = v
+		}
+		f.guests[vmid] = &fakeGuest{Node: node, Type: "lxc", Status: "stopped", Config: config}
+		return http.StatusOK, f.task(node)
+
+	case len(parts) >= 4 && parts[2] == "tasks":
+		if len(parts) == 5 && parts[4] == "status" {
+			return http.StatusOK, map[string]interface{}{"status": "stopped", "exitstatus": "OK"}
+		}
+		lines := []map[string]interface{}{}
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		for i := start; i < len(f.taskLog); i++ {
+			lines = append(lines, map[string]interface{}{"n": i + 1, "t": f.taskLog[i]})
+		}
+		return http.StatusOK, lines
+
+	case len(parts) >= 4 && parts[2] == "lxc":
+		vmid, _ := strconv.Atoi(parts[3])
+		guest, ok := f.guests[vmid]
+		if !ok || guest.Node != node || guest.Type != "lxc" {
+			return http.StatusInternalServerError, fmt.Sprintf("Configuration file 'nodes/%s/lxc/%d.conf' does not exist", node, vmid)
+		}
+		return f.container(r.Method, node, vmid, guest, parts[4:], body)
+	}
+	return http.StatusNotImplemented, "not implemented by the fake cluster: " + path
+}
+
+// container answers the requests on /nodes/{node}/lxc/{vmid}, the caller must hold f.mu
+func (f *fakeCluster) container(method, node string, vmid int, guest *fakeGuest, parts []string, body map[string]interface{}) (int, interface{}) {
+	action := strings.Join(parts, "/")
+	switch {
+	case method == "DELETE" && action == "":
+		if guest.Lock != "" {
+			return http.StatusInternalServerError, fmt.Sprintf("CT is locked (%s)", guest.Lock)
+		}
+		delete(f.guests, vmid)
+		return http.StatusOK, f.task(node)
+
+	case method == "GET" && action == "config":
+		config := map[string]interface{}{}
+		for k, v := range guest.Config {
+			config[k] = v
+		}
+		if guest.Lock != "" {
+			config["lock"] = guest.Lock
+		}
+		return http.StatusOK, config
+
+	case method == "PUT" && action == "config":
+		for k, v := range body {
+			if k == "delete" {
+				for _, key := range strings.Split(fmt.Sprint(v), ",") {
+					delete(guest.Config, strings.TrimSpace(key))
+				}
+				continue
+			}
+			if k != "digest" {
+				guest.Config[k] = v
+			}
+		}
+		return http.StatusOK, nil
+
+	case method == "GET" && action == "status/current":
+		return http.StatusOK, f.guestItem(vmid, guest)
+
+	case method == "POST" && strings.HasPrefix(action, "status/"):
+		if guest.Lock != "" {
+			return http.StatusInternalServerError, fmt.Sprintf("CT is locked (%s)", guest.Lock)
+		}
+		switch action {
+		case "status/start", "status/reboot":
+			guest.Status = "running"
+		case "status/stop", "status/shutdown":
+			guest.Status = "stopped"
+		}
+		return http.StatusOK, f.task(node)
+
+	case method == "GET" && action == "pending":
+		return http.StatusOK, []map[string]interface{}{}
+
+	case method == "GET" && action == "snapshot":
+		return http.StatusOK, []map[string]interface{}{{"name": "current"}}
+	}
+	return http.StatusNotImplemented, fmt.Sprintf("not implemented by the fake cluster: %s %s on %d", method, action, vmid)
+}
+
+// guestItems lists the guests of node (every node when empty) of kind (any when empty), by VMID
+func (f *fakeCluster) guestItems(node, kind string) []map[string]interface{} {
+	vmids := make([]int, 0, len(f.guests))
+	for vmid := range f.guests {
+		vmids = append(vmids, vmid)
+	}
+	sort.Ints(vmids)
+
+	items := []map[string]interface{}{}
+	for _, vmid := range vmids {
+		guest := f.guests[vmid]
+		if (node == "" || guest.Node == node) && (kind == "" || guest.Type == kind) {
+			items = append(items, f.guestItem(vmid, guest))
+		}
+	}
+	return items
+}
+
+func (f *fakeCluster) guestItem(vmid int, guest *fakeGuest) map[string]interface{} {
+	item := map[string]interface{}{
+		"vmid":   vmid,
+		"id":     fmt.Sprintf("%s/%d", guest.Type, vmid),
+		"type":   guest.Type,
+		"node":   guest.Node,
+		"status": guest.Status,
+		"name":   guest.Config["hostname"],
+		"tags":   guest.Config["tags"],
+		"maxmem": floatValue(guest.Config["memory"]) * 1024 * 1024,
+		"cpus":   floatValue(guest.Config["cores"]),
+
+		// Usage counters, as reported for a guest at rest
+		"cpu":       0.0,
+		"mem":       0.0,
+		"netin":     0.0,
+		"netout":    0.0,
+		"diskread":  0.0,
+		"diskwrite": 0.0,
+	}
+	if guest.Lock != "" {
+		item["lock"] = guest.Lock
+	}
+	return item
+}
+
+// task returns the UPID of a new task, the caller must hold f.mu
+func (f *fakeCluster) task(node string) string {
+	f.tasks++
+	return fmt.Sprintf("UPID:%s:%08X:00000000:00000000:vzcreate::root@pam:", node, f.tasks)
+}
+
+// testConfig returns the config of a runtime connected to the fake cluster
+func (f *fakeCluster) testConfig() *Config {
+	return &Config{
+		Host:            strings.TrimPrefix(f.server.URL, "https://"),
+		Node:            f.nodes[0],
+		TokenID:         "root@pam!cosmos",
+		TokenSecret:     "test-secret",
+		APITransport:    TransportHTTP,
+		SkipTLSVerify:   true,
+		Storage:         "local-lvm",
+		VMIDStart:       100,
+		VMIDEnd:         200,
+		MaxRetries:      -1,
+		CacheTTL:        -1,
+		StopTimeout:     -1,
+		MetadataBackend: NewMemoryBackend(),
+	}
+}
+
+// newTestRuntime returns a runtime connected to the fake cluster, configure
+// adjusts the config beforehand
+func newTestRuntime(t testing.TB, f *fakeCluster, configure ...func(*Config)) *ProxmoxRuntime {
+	t.Helper()
+	config := f.testConfig()
+	for _, fn := range configure {
+		fn(config)
+	}
+
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := p.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// newTestStore returns a metadata store on backend, encrypting sensitive labels with key when set
+func newTestStore(backend MetadataBackend, key string) *MetadataStore {
+	return &MetadataStore{
+		backend: backend,
+		data:    make(map[int]map[string]string),
+		key:     deriveMetadataKey(key),
+	}
+}
+
+func atoi(t testing.TB, id string) int {
+	t.Helper()
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		t.Fatalf("invalid container ID %q", id)
+	}
+	return vmid
+}
+
+func itoa(n int) string {
+	return strconv.Itoa(n)
+}
+
+// fakeTransport runs node commands with a function of the command and its stdin
+type fakeTransport struct {
+	mu       sync.Mutex
+	commands []string
+	run      func(command, stdin string) (stdout, stderr string, exitCode int)
+}
+
+func (f *fakeTransport) Run(command string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	var input []byte
+	if stdin != nil {
+		input, _ = io.ReadAll(stdin)
+	}
+
+	f.mu.Lock()
+	f.commands = append(f.commands, command)
+	run := f.run
+	f.mu.Unlock()
+
+	if run == nil {
+		return 0, nil
+	}
+	out, errOut, code := run(command, string(input))
+	io.WriteString(stdout, out)
+	io.WriteString(stderr, errOut)
+	return code, nil
+}
+
+// ran returns the commands containing substr
+func (f *fakeTransport) ran(substr string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matched []string
+	for _, command := range f.commands {
+		if strings.Contains(command, substr) {
+			matched = append(matched, command)
+		}
+	}
+	return matched
+}
+
+// execs returns the commands run inside containers through pct exec, unquoted
+func (f *fakeTransport) execs() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var cmds [][]string
+	for _, command := range f.commands {
+		words := shellWords(command)
+		if len(words) == 7 && words[0] == "pct" && words[1] == "exec" && words[5] == "-c" {
+			cmds = append(cmds, shellWords(words[6]))
+		}
+	}
+	return cmds
+}
+
+// shellWords splits a command line built with shellQuote into its words
+func shellWords(line string) []string {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune // the open quote, 0 outside quotes
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
@@ -0,0 +1,71 @@
+package proxmox
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// LXC features
+// ContainerConfig.Features renders into the features option of the container.
+// Unprivileged containers run in a user namespace, where the kernel only lets
+// them mount a few file system types: asking for others (nfs, cifs...) is an
+// error rather than a container whose mounts fail at runtime. keyctl and mknod
+// only exist for unprivileged containers, privileged ones already have them.
+// Without Features, containers get nesting=1 as before
+
+// userNamespaceMounts are the file system types an unprivileged container can mount
+var userNamespaceMounts = map[string]bool{
+	"tmpfs": true, "ramfs": true, "proc": true, "sysfs": true, "devpts": true,
+	"mqueue": true, "overlay": true, "cgroup": true, "cgroup2": true, "fuse": true,
+	"binfmt_misc": true,
+}
+
+var fsTypePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.]*$`)
+
+// lxcFeatures renders the features option of a container
+func lxcFeatures(config runtime.ContainerConfig) (string, error) {
+	features := config.Features
+	if features == nil {
+		return "nesting=1", nil
+	}
+
+	var flags []string
+	if features.Nesting {
+		flags = append(flags, "nesting=1")
+	}
+	if features.Keyctl && !config.Privileged {
+		flags = append(flags, "keyctl=1")
+	}
+	if features.Fuse {
+		flags = append(flags, "fuse=1")
+	}
+	if features.Mknod && !config.Privileged {
+		flags = append(flags, "mknod=1")
+	}
+
+	if len(features.Mount) > 0 {
+		var privileged []string
+		types := make([]string, 0, len(features.Mount))
+		for _, fsType := range features.Mount {
+			fsType = strings.ToLower(strings.TrimSpace(fsType))
+			if !fsTypePattern.MatchString(fsType) {
+				return "", fmt.Errorf("invalid file system type %q in mount features", fsType)
+			}
+			if !config.Privileged && !userNamespaceMounts[fsType] {
+				privileged = append(privileged, fsType)
+			}
+			types = append(types, fsType)
+		}
+		if len(privileged) > 0 {
+			sort.Strings(privileged)
+			return "", fmt.Errorf("mounting %s requires a privileged container", strings.Join(privileged, ", "))
+		}
+		flags = append(flags, "mount="+strings.Join(types, ";"))
+	}
+
+	return strings.Join(flags, ","), nil
+}
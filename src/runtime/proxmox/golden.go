@@ -0,0 +1,166 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Golden images
+// A prepared container (provisioned, post-install done) can be converted to a
+// Proxmox template with MarkAsTemplate, then stamped into new containers with
+// CreateFromTemplate: a full clone that takes the name, networks and resources
+// of the new config and skips provisioning and PostInstall, as the template
+// already went through them. The conversion cannot be undone, a template can
+// no longer be started. Templates carry the cosmos-golden-image label, and
+// containers created from one the cosmos-golden-source label (its ID)
+
+const (
+	// LabelGoldenImage marks containers converted to a template by MarkAsTemplate
+	LabelGoldenImage = "cosmos-golden-image"
+	// LabelGoldenSource records the template a container was created from
+	LabelGoldenSource = "cosmos-golden-source"
+)
+
+// MarkAsTemplate converts a stopped container into a template for CreateFromTemplate
+func (p *ProxmoxRuntime) MarkAsTemplate(id string) error {
+	defer p.cache.invalidate()
+
+	if !p.connected {
+		return errNotConnected
+	}
+
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("invalid container ID: %s", id)
+	}
+	node := p.nodeFor(vmid)
+
+	status, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/status/current", node, vmid), nil)
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to mark container %s as template: %w", id, err)
+	}
+	if state := getStatus(status["status"]); state != "stopped" {
+		return fmt.Errorf("container %s is %s, stop it before marking it as template", id, state)
+	}
+
+	if _, err := p.lockedRequest(node, vmid, "POST", fmt.Sprintf("/nodes/%s/lxc/%d/template", node, vmid), nil); err != nil {
+		return fmt.Errorf("failed to mark container %s as template: %w", id, err)
+	}
+
+	p.metadata.SetLabel(vmid, LabelGoldenImage, "true")
+	p.recordChange(vmid, "template", []runtime.FieldChange{
+		{Field: "Template", Old: "false", New: "true"},
+	})
+
+	utils.Log(fmt.Sprintf("Marked LXC container VMID %d as template", vmid))
+	return nil
+}
+
+// CreateFromTemplate creates a container by full-cloning the template
+// templateID. The name, hostname, memory, CPUs, labels and networks of config
+// are applied to the copy; PostInstall and BuildArgs are ignored. The
+// container is created stopped, like Clone
+func (p *ProxmoxRuntime) CreateFromTemplate(templateID string, config runtime.ContainerConfig) (string, error) {
+	if !p.connected {
+		return "", errNotConnected
+	}
+
+	template, err := strconv.Atoi(templateID)
+	if err != nil {
+		return "", fmt.Errorf("invalid container ID: %s", templateID)
+	}
+	node := p.nodeFor(template)
+
+	var current map[string]interface{}
+	err = p.Client().Get(fmt.Sprintf("/nodes/%s/lxc/%d/config", node, template), &current)
+	if isNotFound(err) {
+		return "", p.notFound(template)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get template %s: %w", templateID, err)
+	}
+	if floatValue(current["template"]) != 1 {
+		return "", fmt.Errorf("container %s is not a template, mark it with MarkAsTemplate first", templateID)
+	}
+	if len(config.PostInstall) > 0 || len(config.BuildArgs) > 0 {
+		utils.Warn(fmt.Sprintf("Ignoring PostInstall and BuildArgs of %s, template %s is already prepared", config.Name, templateID))
+	}
+
+	// Resolve the networks before cloning, so an invalid one leaves nothing behind
+	var interfaces []netInterface
+	if hasNetworkConfig(config) {
+		if interfaces, err = p.networkInterfaces(config, false); err != nil {
+			return "", err
+		}
+	}
+
+	id, err := p.Clone(templateID, config)
+	if err != nil {
+		return "", err
+	}
+	vmid, _ := strconv.Atoi(id)
+
+	if interfaces != nil {
+		if err := p.applyInterfaces(p.nodeFor(vmid), vmid, interfaces); err != nil {
+			if rerr := p.Remove(id); rerr != nil {
+				utils.Warn(fmt.Sprintf("Failed to remove container %s after a failed network setup: %s", id, rerr))
+			}
+			return "", fmt.Errorf("failed to configure the networks of %s: %w", config.Name, err)
+		}
+	}
+
+	// Pending PostInstall commands of the template are dropped, and the source recorded
+	if err := p.metadata.UpdateLabels(vmid, map[string]string{LabelGoldenSource: templateID}, []string{LabelPostInstall}); err != nil {
+		utils.Warn(fmt.Sprintf("Failed to record the template of container %s: %s", id, err))
+	}
+
+	utils.Log(fmt.Sprintf("Created LXC container %s (VMID: %d) from template %s", config.Name, vmid, templateID))
+	return id, nil
+}
+
+// hasNetworkConfig reports whether config sets networks or cosmos-net<N> labels
+func hasNetworkConfig(config runtime.ContainerConfig) bool {
+	if len(config.Networks) > 0 {
+		return true
+	}
+	for key := range config.Labels {
+		if interfaceLabel.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyInterfaces replaces the netN interfaces of a container, deleting the ones past interfaces
+func (p *ProxmoxRuntime) applyInterfaces(node string, vmid int, interfaces []netInterface) error {
+	var current map[string]interface{}
+	if err := p.Client().Get(fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), &current); err != nil {
+		return err
+	}
+
+	update := map[string]interface{}{}
+	for i, iface := range interfaces {
+		update[fmt.Sprintf("net%d", i)] = iface.render(i)
+	}
+	var stale []string
+	for _, key := range configKeys(current, "net") {
+		if _, ok := update[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	if len(stale) > 0 {
+		update["delete"] = strings.Join(stale, ",")
+	}
+
+	body, _ := json.Marshal(update)
+	_, err := p.apiRequest("PUT", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), strings.NewReader(string(body)))
+	return err
+}
@@ -0,0 +1,119 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Connection health of the Proxmox runtime
+// Once connected, the API is pinged every healthInterval. After
+// maxPingFailures consecutive failures the runtime is marked disconnected and
+// Connect is retried with an exponential backoff until it succeeds
+
+const (
+	healthInterval    = 30 * time.Second
+	maxPingFailures   = 3
+	maxReconnectDelay = 5 * time.Minute
+	pingTimeout       = 5 * time.Second
+)
+
+// Ping checks the API with a cheap GET /version, updating the connection state
+func (p *ProxmoxRuntime) Ping() error {
+	p.mutex.RLock()
+	client := p.client
+	p.mutex.RUnlock()
+	if client == nil {
+		return errNotConnected
+	}
+
+	// No retries: a failed ping is counted, the next tick is the retry
+	_, err := p.doAPIRequest("GET", p.apiURL+"/version", nil, pingTimeout)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err == nil {
+		p.lastPing = time.Now()
+		p.pingFailures = 0
+		p.connected = true
+		return nil
+	}
+
+	p.pingFailures++
+	if p.connected && p.pingFailures >= maxPingFailures {
+		p.connected = false
+		utils.Warn(fmt.Sprintf("Proxmox API unreachable after %d failed pings, marking the runtime disconnected: %s", p.pingFailures, err))
+	}
+	return fmt.Errorf("failed to ping Proxmox: %w", err)
+}
+
+// LastPing returns the time of the last successful ping (or connection), zero if none
+func (p *ProxmoxRuntime) LastPing() time.Time {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.lastPing
+}
+
+// startHealthCheck starts the ping loop, the caller must hold p.mutex
+func (p *ProxmoxRuntime) startHealthCheck() {
+	if p.stopHealth != nil {
+		return
+	}
+	stop := make(chan struct{})
+	p.stopHealth = stop
+	p.goBackground(func(ctx context.Context) { p.healthLoop(ctx, stop) })
+}
+
+// stopHealthCheck ends the ping loop, the caller must hold p.mutex
+func (p *ProxmoxRuntime) stopHealthCheck() {
+	if p.stopHealth != nil {
+		close(p.stopHealth)
+		p.stopHealth = nil
+	}
+}
+
+func (p *ProxmoxRuntime) healthLoop(ctx context.Context, stop chan struct{}) {
+	attempt := 0
+	next := time.Now().Add(healthInterval)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		if p.IsConnected() {
+			_ = p.Ping() // failures are counted and logged by Ping
+			attempt = 0
+			next = time.Now().Add(healthInterval)
+			continue
+		}
+
+		if err := p.Connect(); err != nil {
+			delay := reconnectDelay(attempt)
+			attempt++
+			utils.Warn(fmt.Sprintf("Failed to reconnect to Proxmox, retrying in %s: %s", delay, err))
+			next = time.Now().Add(delay)
+			continue
+		}
+
+		utils.Log("Reconnected to Proxmox")
+		attempt = 0
+		next = time.Now().Add(healthInterval)
+	}
+}
+
+// reconnectDelay returns the backoff before reconnection attempt number attempt (from 0)
+func reconnectDelay(attempt int) time.Duration {
+	delay := healthInterval << uint(attempt)
+	if delay <= 0 || delay > maxReconnectDelay {
+		delay = maxReconnectDelay
+	}
+	return delay
+}
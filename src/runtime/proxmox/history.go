@@ -0,0 +1,150 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Per-container change history
+// Every create, update, rename or label change appends a ChangeRecord to the
+// cosmos-history label (JSON encoded), keeping the last maxHistory entries
+
+const (
+	LabelHistory = "cosmos-history"
+
+	maxHistory = 50
+)
+
+// History returns the recorded configuration changes of a container, oldest first
+func (p *ProxmoxRuntime) History(id string) ([]runtime.ChangeRecord, error) {
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid container ID: %s", id)
+	}
+
+	return decodeHistory(p.metadata.GetLabel(vmid, LabelHistory)), nil
+}
+
+// recordChange appends a change record to the container history, skipping empty changes
+func (p *ProxmoxRuntime) recordChange(vmid int, operation string, changes []runtime.FieldChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	history := decodeHistory(p.metadata.GetLabel(vmid, LabelHistory))
+	history = append(history, runtime.ChangeRecord{
+		Timestamp: time.Now().Unix(),
+		Operation: operation,
+		Changes:   changes,
+	})
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+
+	encoded, err := json.Marshal(history)
+	if err != nil {
+		return
+	}
+	p.metadata.SetLabel(vmid, LabelHistory, string(encoded))
+}
+
+func decodeHistory(value string) []runtime.ChangeRecord {
+	var history []runtime.ChangeRecord
+	if value != "" {
+		_ = json.Unmarshal([]byte(value), &history)
+	}
+	return history
+}
+
+// configChanges lists the tracked fields that differ between two configs
+func configChanges(old, new runtime.ContainerConfig) []runtime.FieldChange {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"Name", old.Name, new.Name},
+		{"Image", old.Image, new.Image},
+		{"Hostname", old.Hostname, new.Hostname},
+		{"Memory", formatInt(old.Memory), formatInt(new.Memory)},
+		{"MemorySwap", formatOptionalInt(old.MemorySwap), formatOptionalInt(new.MemorySwap)},
+		{"CPUs", formatFloat(old.CPUs), formatFloat(new.CPUs)},
+		{"CPUShares", formatInt(old.CPUShares), formatInt(new.CPUShares)},
+		{"RootFSSize", formatInt(old.RootFSSize), formatInt(new.RootFSSize)},
+		{"Privileged", strconv.FormatBool(old.Privileged), strconv.FormatBool(new.Privileged)},
+		{"StartupOrder", formatInt(int64(old.StartupOrder)), formatInt(int64(new.StartupOrder))},
+		{"StartupDelay", formatInt(int64(old.StartupDelay)), formatInt(int64(new.StartupDelay))},
+		{"ShutdownDelay", formatInt(int64(old.ShutdownDelay)), formatInt(int64(new.ShutdownDelay))},
+	}
+
+	var changes []runtime.FieldChange
+	for _, f := range fields {
+		if f.old != f.new {
+			changes = append(changes, runtime.FieldChange{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+	return changes
+}
+
+// labelChanges lists the labels that differ between two label sets, ignoring the history itself
+func labelChanges(old, new map[string]string) []runtime.FieldChange {
+	keys := make(map[string]bool)
+	for k := range old {
+		keys[k] = true
+	}
+	for k := range new {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		if k != LabelHistory {
+			sorted = append(sorted, k)
+		}
+	}
+	sort.Strings(sorted)
+
+	var changes []runtime.FieldChange
+	for _, k := range sorted {
+		oldValue, newValue := old[k], new[k]
+		if IsSensitiveLabel(k) {
+			oldValue, newValue = redactValue(oldValue), redactValue(newValue)
+		}
+		if old[k] != new[k] {
+			changes = append(changes, runtime.FieldChange{Field: "Labels." + k, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+func redactValue(v string) string {
+	if v == "" {
+		return ""
+	}
+	return "***"
+}
+
+func formatInt(v int64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+func formatOptionalInt(v *int64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(*v, 10)
+}
+
+func formatFloat(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,91 @@
+package proxmox
+
+import (
+	"reflect"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestHistory(t *testing.T) {
+	cluster := newFakeCluster(t)
+	p := newTestRuntime(t, cluster)
+
+	id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, Memory: 512 << 20, Labels: map[string]string{"tier": "front"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := p.Update(id, runtime.ContainerConfig{Memory: 1 << 30}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := p.Update(id, runtime.ContainerConfig{Memory: 1 << 30}); err != nil {
+		t.Fatalf("Update without change: %v", err)
+	}
+	if _, err := p.LabelMany(runtime.LabelSelector{"tier": "front"}, map[string]string{"owner": "team-a", "stage": "prod"}, nil); err != nil {
+		t.Fatalf("LabelMany: %v", err)
+	}
+	if err := p.Rename(id, "api"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	history, err := p.History(id)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+
+	want := []struct {
+		operation string
+		changes   []runtime.FieldChange
+	}{
+		{"create", []runtime.FieldChange{{Field: "Name", New: "app"}, {Field: "Image", New: testImage}, {Field: "Memory", New: "536870912"}}},
+		{"update", []runtime.FieldChange{{Field: "Memory", Old: "536870912", New: "1073741824"}}},
+		{"labels", []runtime.FieldChange{{Field: "Labels.owner", New: "team-a"}, {Field: "Labels.stage", New: "prod"}}},
+		{"rename", []runtime.FieldChange{{Field: "Name", Old: "app", New: "api"}, {Field: "Hostname", Old: "app", New: "api"}}},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("history has %d entries, want %d: %+v", len(history), len(want), history)
+	}
+	for i, w := range want {
+		if history[i].Operation != w.operation {
+			t.Errorf("entry %d operation = %s, want %s", i, history[i].Operation, w.operation)
+		}
+		if !reflect.DeepEqual(history[i].Changes, w.changes) {
+			t.Errorf("entry %d (%s) changes = %+v, want %+v", i, w.operation, history[i].Changes, w.changes)
+		}
+		if i > 0 && history[i].Timestamp < history[i-1].Timestamp {
+			t.Errorf("entry %d is older than the previous one", i)
+		}
+	}
+}
+
+func TestLabelChangesRedactSecrets(t *testing.T) {
+	got := labelChanges(
+		map[string]string{"cosmos-env.TOKEN": "old", "app": "web", LabelHistory: "[]"},
+		map[string]string{"cosmos-env.TOKEN": "new", "app": "web", LabelHistory: "[{}]"},
+	)
+	want := []runtime.FieldChange{{Field: "Labels.cosmos-env.TOKEN", Old: "***", New: "***"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("labelChanges = %+v, want %+v", got, want)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	cluster := newFakeCluster(t)
+	cluster.addGuest(100, fakeGuest{})
+	backend := NewMemoryBackend()
+	backend.Set(100, map[string]string{"cosmos-name": "app"})
+	p := newTestRuntime(t, cluster, func(c *Config) { c.MetadataBackend = backend })
+
+	for i := 1; i <= maxHistory+5; i++ {
+		p.recordChange(100, "update", []runtime.FieldChange{{Field: "Memory", New: itoa(i)}})
+	}
+	p.recordChange(100, "update", nil)
+
+	history, _ := p.History("100")
+	if len(history) != maxHistory {
+		t.Fatalf("history has %d entries, want %d", len(history), maxHistory)
+	}
+	if first := history[0].Changes[0].New; first != "6" {
+		t.Errorf("oldest kept entry = %s, want 6", first)
+	}
+}
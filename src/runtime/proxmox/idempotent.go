@@ -0,0 +1,90 @@
+package proxmox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Idempotent creation
+// A Create retried after a lost response must not leave two containers. Before
+// allocating a VMID, Create looks for a container of the same name:
+//   - one known to the metadata and still present is returned as is
+//   - one tagged by Cosmos on the cluster but missing from the metadata fails
+//     Create with ErrNameInUse: it may be left by a creation that failed before
+//     storing it, but also belong to another Cosmos instance sharing the
+//     cluster, or to a metadata store that was lost, so it is never removed
+//     on a guess
+// With ContainerConfig.Replace an existing container is removed in both cases.
+// Concurrent calls for a name are serialized by nameLocks
+
+// createdTag is set on the containers created by Cosmos
+const createdTag = "cosmos"
+
+// existingContainer returns the ID of the container Create must return instead
+// of creating one, or "" once nothing is in the way
+func (p *ProxmoxRuntime) existingContainer(config runtime.ContainerConfig) (string, error) {
+	if vmid := p.metadata.FindByName(config.Name); vmid != 0 {
+		status, err := p.containerStatus(p.nodeFor(vmid), vmid)
+		switch {
+		case isNotFound(err):
+			p.notFound(vmid)
+		case err != nil:
+			return "", fmt.Errorf("failed to check for an existing container %s: %w", config.Name, err)
+		case status.Lock != "":
+			return "", fmt.Errorf("container %s (VMID %d) is locked (%s), retry once the operation is done", config.Name, vmid, status.Lock)
+		case config.Replace:
+			if err := p.Remove(strconv.Itoa(vmid)); err != nil {
+				return "", fmt.Errorf("failed to replace container %s: %w", config.Name, err)
+			}
+			utils.Log(fmt.Sprintf("Replacing LXC container %s (VMID: %d)", config.Name, vmid))
+		default:
+			utils.Log(fmt.Sprintf("LXC container %s already exists (VMID: %d), not creating it again", config.Name, vmid))
+			return strconv.Itoa(vmid), nil
+		}
+	}
+
+	// Containers whose creation did not complete
+	guests, err := p.clusterGuests()
+	if err != nil {
+		return "", fmt.Errorf("failed to check for an existing container %s: %w", config.Name, err)
+	}
+	for _, guest := range guests {
+		vmid := int(floatValue(guest["vmid"]))
+		name, _ := guest["name"].(string)
+		tags, _ := guest["tags"].(string)
+		if guest["type"] != "lxc" || vmid <= 0 || p.metadata.Get(vmid) != nil {
+			continue
+		}
+		if name != lxcHostname(config.Name, vmid) || !hasTag(tags, createdTag) {
+			continue
+		}
+		if lock, _ := guest["lock"].(string); lock != "" {
+			return "", fmt.Errorf("container %s (VMID %d) is still being created (%s lock), retry once it is done", config.Name, vmid, lock)
+		}
+
+		if !config.Replace {
+			return "", fmt.Errorf("%w: %s (VMID %d) is tagged by Cosmos but missing from its metadata, remove it or create with Replace",
+				runtime.ErrNameInUse, config.Name, vmid)
+		}
+		utils.Warn(fmt.Sprintf("Replacing LXC container VMID %d named %s, missing from the metadata", vmid, config.Name))
+		if err := p.Remove(strconv.Itoa(vmid)); err != nil {
+			return "", fmt.Errorf("failed to replace container %s (VMID %d): %w", config.Name, vmid, err)
+		}
+	}
+
+	return "", nil
+}
+
+// hasTag reports whether a Proxmox tag list (separated by ";", "," or spaces) contains tag
+func hasTag(tags, tag string) bool {
+	for _, t := range strings.FieldsFunc(tags, func(r rune) bool { return r == ';' || r == ',' || r == ' ' }) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package proxmox
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestCreateIdempotent(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, p *ProxmoxRuntime, cluster *fakeCluster) string // returns the ID of the existing container
+		replace     bool
+		wantCreated bool  // Create makes a new container
+		wantErr     error // of Create
+		want        int   // containers in the end
+	}{
+		{
+			name:  "retried create",
+			setup: createApp,
+			want:  1,
+		},
+		{
+			name:        "retried create with replace",
+			setup:       createApp,
+			replace:     true,
+			wantCreated: true,
+			want:        1,
+		},
+		{
+			name: "removed behind the metadata",
+			setup: func(t *testing.T, p *ProxmoxRuntime, cluster *fakeCluster) string {
+				id := createApp(t, p, cluster)
+				cluster.removeGuest(atoi(t, id))
+				return id
+			},
+			wantCreated: true,
+			want:        1,
+		},
+		{
+			name: "orphan without metadata",
+			setup: func(t *testing.T, p *ProxmoxRuntime, cluster *fakeCluster) string {
+				cluster.addGuest(150, fakeGuest{Config: map[string]interface{}{"hostname": "app", "tags": "cosmos"}})
+				return "150"
+			},
+			wantErr: runtime.ErrNameInUse,
+			want:    1,
+		},
+		{
+			name: "orphan replaced",
+			setup: func(t *testing.T, p *ProxmoxRuntime, cluster *fakeCluster) string {
+				cluster.addGuest(150, fakeGuest{Config: map[string]interface{}{"hostname": "app", "tags": "web;cosmos"}})
+				return "150"
+			},
+			replace:     true,
+			wantCreated: true,
+			want:        1,
+		},
+		{
+			name: "same hostname not created by Cosmos",
+			setup: func(t *testing.T, p *ProxmoxRuntime, cluster *fakeCluster) string {
+				cluster.addGuest(150, fakeGuest{Config: map[string]interface{}{"hostname": "app"}})
+				return "150"
+			},
+			wantCreated: true,
+			want:        2,
+		},
+		{
+			name: "creation still running",
+			setup: func(t *testing.T, p *ProxmoxRuntime, cluster *fakeCluster) string {
+				cluster.addGuest(150, fakeGuest{Lock: "create", Config: map[string]interface{}{"hostname": "app", "tags": "cosmos"}})
+				return "150"
+			},
+			wantErr: errAny,
+			want:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+			existing := tt.setup(t, p, cluster)
+			creates := cluster.count("POST /nodes/pve/lxc")
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, Replace: tt.replace})
+			if tt.wantErr == errAny && err == nil || tt.wantErr != errAny && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Create error = %v, want %v", err, tt.wantErr)
+			}
+			if created := cluster.count("POST /nodes/pve/lxc") > creates; created != tt.wantCreated {
+				t.Errorf("created a container: %v, want %v", created, tt.wantCreated)
+			}
+			if err == nil && !tt.wantCreated && id != existing {
+				t.Errorf("Create = %s, want the existing %s", id, existing)
+			}
+
+			if n := cluster.lxcCount(); n != tt.want {
+				t.Errorf("%d containers, want %d", n, tt.want)
+			}
+			if err == nil && p.metadata.GetLabel(atoi(t, id), "cosmos-name") != "app" {
+				t.Errorf("container %s is not named app in the metadata", id)
+			}
+		})
+	}
+}
+
+func TestCreateResponseLost(t *testing.T) {
+	cluster := newFakeCluster(t)
+	p := newTestRuntime(t, cluster)
+
+	// The container is created but the response never reaches Cosmos
+	var lost sync.Once
+	cluster.handle("POST /nodes/pve/lxc", func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+		status, data := cluster.route(r, strings.TrimPrefix(r.URL.Path, "/api2/json"), body)
+		dropped := false
+		lost.Do(func() { dropped = true })
+		if dropped {
+			return http.StatusGatewayTimeout, "proxy timeout"
+		}
+		return status, data
+	})
+
+	if _, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage}); err == nil {
+		t.Fatal("Create succeeded without a response")
+	}
+
+	// Whether the orphan is a failed creation or belongs to another instance cannot be told
+	if _, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage}); !errors.Is(err, runtime.ErrNameInUse) {
+		t.Fatalf("retried Create error = %v, want %v", err, runtime.ErrNameInUse)
+	}
+	if n := cluster.lxcCount(); n != 1 {
+		t.Fatalf("%d containers after the retry, want 1", n)
+	}
+
+	id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, Replace: true})
+	if err != nil {
+		t.Fatalf("Create with Replace: %v", err)
+	}
+	if n := cluster.lxcCount(); n != 1 {
+		t.Errorf("%d containers after Replace, want 1", n)
+	}
+	if p.metadata.GetLabel(atoi(t, id), "cosmos-name") != "app" {
+		t.Errorf("replacement %s is not named app in the metadata", id)
+	}
+}
+
+// errAny matches any error
+var errAny = errors.New("any error")
+
+func createApp(t *testing.T, p *ProxmoxRuntime, _ *fakeCluster) string {
+	id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return id
+}
+
+func TestCreateConcurrentSameName(t *testing.T) {
+	cluster := newFakeCluster(t)
+	p := newTestRuntime(t, cluster)
+
+	const creates = 5
+	ids := make(chan string, creates)
+	var wg sync.WaitGroup
+	for i := 0; i < creates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage})
+			if err != nil {
+				t.Errorf("Create: %v", err)
+			}
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	first := ""
+	for id := range ids {
+		if first == "" {
+			first = id
+		}
+		if id != first {
+			t.Errorf("Create returned %s and %s", first, id)
+		}
+	}
+	if n := cluster.lxcCount(); n != 1 {
+		t.Errorf("%d containers, want 1", n)
+	}
+}
@@ -1,9 +1,15 @@
 package proxmox
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
+	"path"
+	"sort"
 	"strings"
+	"time"
 
 	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
 	"github.com/azukaar/cosmos-server/src/utils"
@@ -13,123 +19,311 @@ import (
 // Proxmox uses templates (.tar.gz, .tar.zst) instead of Docker images
 // Templates are stored in storage pools (e.g., local:vztmpl/debian-12.tar.zst)
 
-// PullImage downloads an LXC template
+// PullImage downloads an LXC template from the appliance index into the template storage.
+// ref is a template file name ("debian-12-standard_12.7-1_amd64.tar.zst") or a
+// package name ("debian-12-standard"), optionally prefixed with a storage.
+// The returned reader streams the download task log until the task ends.
 func (p *ProxmoxRuntime) PullImage(ref string) (io.ReadCloser, error) {
 	if !p.connected {
-		return nil, fmt.Errorf("not connected to Proxmox")
+		return nil, errNotConnected
 	}
 
 	// Parse template reference
 	// Format: storage:vztmpl/template-name or just template-name
-	storage, template := parseTemplateRef(ref, p.config.Storage)
+	storage, template := parseTemplateRef(ref, p.templateStorage())
+	name := strings.TrimPrefix(template, "vztmpl/")
 
-	utils.Log(fmt.Sprintf("Downloading template %s to storage %s", template, storage))
+	available, err := p.GetAvailableTemplates()
+	if err != nil {
+		return nil, err
+	}
+
+	match, ok := findTemplate(available, name)
+	if !ok {
+		candidates := make([]string, len(available))
+		for i, t := range available {
+			candidates[i] = t.Template
+		}
+		if suggestions := closeMatches(name, candidates, 5); len(suggestions) > 0 {
+			return nil, fmt.Errorf("%w: template %s is not in the appliance index, did you mean: %s", runtime.ErrImageNotFound, name, strings.Join(suggestions, ", "))
+		}
+		return nil, fmt.Errorf("%w: template %s is not in the appliance index", runtime.ErrImageNotFound, name)
+	}
+
+	// Already downloaded
+	if images, err := p.listTemplates(storage); err == nil {
+		for _, image := range images {
+			if image.Name == match.Template {
+				return io.NopCloser(strings.NewReader(fmt.Sprintf("Template %s is already present in %s\n", match.Template, storage))), nil
+			}
+		}
+	}
+
+	utils.Log(fmt.Sprintf("Downloading template %s to storage %s", match.Template, storage))
 
-	// Download template via Proxmox API
-	// POST /nodes/{node}/aplinfo
-	// This triggers template download from Proxmox template repository
+	body, _ := json.Marshal(map[string]string{"storage": storage, "template": match.Template})
+	resp, err := p.apiRequest("POST", fmt.Sprintf("/nodes/%s/aplinfo", p.node), strings.NewReader(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download template %s: %w", match.Template, err)
+	}
+
+	upid := taskUPID(resp)
+	if upid == "" {
+		return io.NopCloser(strings.NewReader(fmt.Sprintf("Downloaded template: %s\n", match.Template))), nil
+	}
+
+	stream := newLogStream()
+	p.goBackground(func(ctx context.Context) { p.streamTaskLog(ctx, stream, upid) })
+	return stream, nil
+}
+
+// streamTaskLog copies the log of a task to the stream until the task stops or the stream or the runtime is closed
+func (p *ProxmoxRuntime) streamTaskLog(ctx context.Context, stream *logStream, upid string) {
+	node := taskNode(upid, p.node)
+	escaped := url.PathEscape(upid)
+	start := 0
+	defer stream.closeOnDone(ctx, errNotConnected)()
+
+	ticker := time.NewTicker(taskPollInterval)
+	defer ticker.Stop()
+
+	for {
+		resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/tasks/%s/log?start=%d", node, escaped, start), nil)
+		if err == nil {
+			for _, line := range listItems(resp) {
+				text, _ := line["t"].(string)
+				if _, err := io.WriteString(stream.writer, text+"\n"); err != nil {
+					return
+				}
+				start++
+			}
+		}
 
-	// For now, return a progress reader
-	progress := strings.NewReader(fmt.Sprintf("Downloading template: %s\n", template))
+		status, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/tasks/%s/status", node, escaped), nil)
+		if err == nil {
+			if state, _ := status["status"].(string); state == "stopped" {
+				if exit, _ := status["exitstatus"].(string); exit != "OK" {
+					stream.writer.CloseWithError(&TaskError{UPID: upid, ExitStatus: exit, Log: p.taskLog(upid)})
+					return
+				}
+				stream.writer.Close()
+				return
+			}
+		}
 
-	return io.NopCloser(progress), nil
+		select {
+		case <-stream.done:
+			return
+		case <-ctx.Done():
+			stream.writer.CloseWithError(errNotConnected)
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
-// ListImages returns available LXC templates
+// ListImages returns the LXC templates present in the template storage
 func (p *ProxmoxRuntime) ListImages() ([]runtime.Image, error) {
 	if !p.connected {
-		return nil, fmt.Errorf("not connected to Proxmox")
+		return nil, errNotConnected
 	}
 
-	// List templates in storage
-	// GET /nodes/{node}/storage/{storage}/content?content=vztmpl
-
-	images := []runtime.Image{}
+	return p.listTemplates(p.templateStorage())
+}
 
-	// Common Proxmox templates (would be fetched from API in production)
-	defaultTemplates := []struct {
-		name string
-		size int64
-	}{
-		{"debian-12-standard_12.0-1_amd64.tar.zst", 120 * 1024 * 1024},
-		{"ubuntu-22.04-standard_22.04-1_amd64.tar.zst", 130 * 1024 * 1024},
-		{"alpine-3.18-default_20230607_amd64.tar.xz", 3 * 1024 * 1024},
+// listTemplates lists the vztmpl content of a storage
+func (p *ProxmoxRuntime) listTemplates(storage string) ([]runtime.Image, error) {
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/storage/%s/content?content=vztmpl", p.node, storage), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates of storage %s: %w", storage, err)
 	}
 
-	for _, t := range defaultTemplates {
+	// Some storages ignore the content filter, other files are skipped
+	images := []runtime.Image{}
+	for _, item := range listItems(resp) {
+		volid, _ := item["volid"].(string)
+		if content, _ := item["content"].(string); volid == "" || content != "" && content != "vztmpl" {
+			continue
+		}
+		name := path.Base(volid)
+		if !isTemplateFile(name) {
+			continue
+		}
 		images = append(images, runtime.Image{
-			ID:   fmt.Sprintf("%s:vztmpl/%s", p.config.Storage, t.name),
-			Name: t.name,
-			Tags: []string{"lxc", "template"},
-			Size: t.size,
+			ID:      volid,
+			Name:    name,
+			Tags:    templateTags(name),
+			Size:    int64(floatValue(item["size"])),
+			Created: int64(floatValue(item["ctime"])),
 		})
 	}
 
 	return images, nil
 }
 
-// RemoveImage removes an LXC template
+// templateExtensions are the archive formats of LXC templates
+var templateExtensions = []string{".tar.gz", ".tar.zst", ".tar.xz", ".tar.bz2", ".tgz"}
+
+// isTemplateFile reports whether a file name is an LXC template archive
+func isTemplateFile(name string) bool {
+	for _, ext := range templateExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// templateTags returns the distribution and version of a template file name,
+// "debian-12-standard_12.2-1_amd64.tar.zst" giving ["debian", "12"]
+func templateTags(name string) []string {
+	for _, ext := range templateExtensions {
+		name = strings.TrimSuffix(name, ext)
+	}
+	pkg, _, _ := strings.Cut(name, "_")
+	parts := strings.Split(pkg, "-")
+	tags := []string{parts[0]}
+	if len(parts) > 1 && parts[1] != "" && parts[1][0] >= '0' && parts[1][0] <= '9' {
+		tags = append(tags, parts[1])
+	}
+	return tags
+}
+
+// RemoveImage deletes an LXC template file from its storage
 func (p *ProxmoxRuntime) RemoveImage(id string) error {
 	if !p.connected {
-		return fmt.Errorf("not connected to Proxmox")
+		return errNotConnected
 	}
 
-	// DELETE /nodes/{node}/storage/{storage}/content/{volume}
-	utils.Log(fmt.Sprintf("Template %s removed", id))
+	storage, template := parseTemplateRef(id, p.templateStorage())
+	volume := url.PathEscape(storage + ":" + template)
+
+	resp, err := p.apiRequest("DELETE", fmt.Sprintf("/nodes/%s/storage/%s/content/%s", p.node, storage, volume), nil)
+	if isNotFound(err) {
+		return fmt.Errorf("%w: %s", runtime.ErrImageNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove template %s: %w", id, err)
+	}
+	if err := p.waitForTask(taskUPID(resp)); err != nil {
+		return fmt.Errorf("failed to remove template %s: %w", id, err)
+	}
 
+	utils.Log(fmt.Sprintf("Template %s removed", id))
 	return nil
 }
 
 // GetAvailableTemplates returns templates available for download from Proxmox repos
 func (p *ProxmoxRuntime) GetAvailableTemplates() ([]TemplateInfo, error) {
 	if !p.connected {
-		return nil, fmt.Errorf("not connected to Proxmox")
-	}
-
-	// GET /nodes/{node}/aplinfo
-	// Returns list of downloadable templates
-
-	templates := []TemplateInfo{
-		{
-			Template:    "debian-12-standard_12.0-1_amd64.tar.zst",
-			Type:        "lxc",
-			OS:          "debian",
-			Version:     "12",
-			Description: "Debian 12 Bookworm (standard)",
-			Source:      "https://download.proxmox.com/",
-		},
-		{
-			Template:    "ubuntu-22.04-standard_22.04-1_amd64.tar.zst",
-			Type:        "lxc",
-			OS:          "ubuntu",
-			Version:     "22.04",
-			Description: "Ubuntu 22.04 Jammy (standard)",
-			Source:      "https://download.proxmox.com/",
-		},
-		{
-			Template:    "alpine-3.18-default_20230607_amd64.tar.xz",
-			Type:        "lxc",
-			OS:          "alpine",
-			Version:     "3.18",
-			Description: "Alpine Linux 3.18",
-			Source:      "https://download.proxmox.com/",
-		},
-		{
-			Template:    "archlinux-base_20230101-1_amd64.tar.zst",
-			Type:        "lxc",
-			OS:          "archlinux",
-			Version:     "latest",
-			Description: "Arch Linux (base)",
-			Source:      "https://download.proxmox.com/",
-		},
+		return nil, errNotConnected
+	}
+
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/aplinfo", p.node), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the appliance index: %w", err)
+	}
+
+	var templates []TemplateInfo
+	for _, item := range listItems(resp) {
+		info := TemplateInfo{}
+		info.Template, _ = item["template"].(string)
+		info.Package, _ = item["package"].(string)
+		info.Type, _ = item["type"].(string)
+		info.OS, _ = item["os"].(string)
+		info.Version, _ = item["version"].(string)
+		info.Description, _ = item["headline"].(string)
+		info.Source, _ = item["location"].(string)
+		if info.Type != "lxc" || info.Template == "" {
+			continue
+		}
+		templates = append(templates, info)
 	}
 
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Template < templates[j].Template })
 	return templates, nil
 }
 
+// findTemplate looks a template up by file name, then by package name (latest version wins)
+func findTemplate(templates []TemplateInfo, name string) (TemplateInfo, bool) {
+	var found TemplateInfo
+	ok := false
+	for _, t := range templates {
+		if t.Template == name {
+			return t, true
+		}
+		if t.Package == name && (!ok || t.Template > found.Template) {
+			found, ok = t, true
+		}
+	}
+	return found, ok
+}
+
+// closeMatches returns up to n candidates closest to name
+func closeMatches(name string, candidates []string, n int) []string {
+	type scored struct {
+		value    string
+		distance int
+	}
+
+	var matches []scored
+	for _, c := range candidates {
+		// Compare with the package part of the file name (before the version)
+		pkg := c
+		if i := strings.Index(c, "_"); i > 0 {
+			pkg = c[:i]
+		}
+		d := levenshtein(name, pkg)
+		if strings.Contains(c, name) {
+			d = 0
+		}
+		if d <= len(name)/2 {
+			matches = append(matches, scored{c, d})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+
+	var result []string
+	for i := 0; i < len(matches) && i < n; i++ {
+		result = append(result, matches[i].value)
+	}
+	return result
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// templateStorage returns the storage holding LXC templates
+func (p *ProxmoxRuntime) templateStorage() string {
+	if p.config.TemplateStorage != "" {
+		return p.config.TemplateStorage
+	}
+	return "local"
+}
+
 // TemplateInfo describes an available LXC template
 type TemplateInfo struct {
 	Template    string `json:"template"`
+	Package     string `json:"package"`
 	Type        string `json:"type"`
 	OS          string `json:"os"`
 	Version     string `json:"version"`
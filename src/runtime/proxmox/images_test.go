@@ -0,0 +1,65 @@
+package proxmox
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestListImages(t *testing.T) {
+	// The listing of a storage ignoring the content filter
+	content := []map[string]interface{}{
+		{"volid": "local:vztmpl/debian-12-standard_12.2-1_amd64.tar.zst", "content": "vztmpl", "format": "tzst", "size": 126e6, "ctime": 1.7e9},
+		{"volid": "local:vztmpl/alpine-3.19-default_20240207_amd64.tar.xz", "content": "vztmpl", "format": "txz", "size": 3e6, "ctime": 1.71e9},
+		{"volid": "local:vztmpl/custom.tar.gz", "size": 1e6},
+		{"volid": "local:iso/debian-12.5.0-amd64-netinst.iso", "content": "iso", "format": "iso", "size": 6e8, "ctime": 1.7e9},
+		{"volid": "local:100/vm-100-disk-0.raw", "content": "images", "format": "raw", "size": 8e9},
+		{"volid": "local:backup/vzdump-lxc-100-2024_01_01-00_00_00.tar.zst", "content": "backup", "format": "tar.zst", "size": 5e8},
+		{"volid": "local:vztmpl/notes.txt", "content": "vztmpl", "size": 10.0},
+		{"content": "vztmpl", "size": 10.0},
+	}
+
+	cluster := newFakeCluster(t)
+	cluster.handle("GET /nodes/pve/storage/local/content", func(r *http.Request, _ map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, content
+	})
+	p := newTestRuntime(t, cluster)
+
+	images, err := p.ListImages()
+	if err != nil {
+		t.Fatalf("ListImages: %v", err)
+	}
+
+	want := []runtime.Image{
+		{ID: "local:vztmpl/debian-12-standard_12.2-1_amd64.tar.zst", Name: "debian-12-standard_12.2-1_amd64.tar.zst", Tags: []string{"debian", "12"}, Size: 126e6, Created: 1.7e9},
+		{ID: "local:vztmpl/alpine-3.19-default_20240207_amd64.tar.xz", Name: "alpine-3.19-default_20240207_amd64.tar.xz", Tags: []string{"alpine", "3.19"}, Size: 3e6, Created: 1.71e9},
+		{ID: "local:vztmpl/custom.tar.gz", Name: "custom.tar.gz", Tags: []string{"custom"}, Size: 1e6},
+	}
+	if !reflect.DeepEqual(images, want) {
+		t.Errorf("ListImages =\n%+v\nwant\n%+v", images, want)
+	}
+}
+
+func TestTemplateTags(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"debian-12-standard_12.2-1_amd64.tar.zst", []string{"debian", "12"}},
+		{"ubuntu-24.04-standard_24.04-2_amd64.tar.zst", []string{"ubuntu", "24.04"}},
+		{"archlinux-base_20240911-1_amd64.tar.zst", []string{"archlinux"}},
+		{"turnkey-wordpress_18.0-1_amd64.tar.gz", []string{"turnkey"}},
+		{"custom.tar.gz", []string{"custom"}},
+		{"centos-9-stream.tgz", []string{"centos", "9"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := templateTags(tt.name); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("templateTags = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package proxmox
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Inspect details of Proxmox containers
+// The container config is turned back into the fields Docker reports:
+// netN interfaces into network endpoints (keyed by bridge, addresses of DHCP
+// interfaces read from the running container), mpN mount points and the
+// tmpfs label into mounts, the published ports label into port bindings,
+// and cores, cpuunits, swap, rootfs, nameserver and features into the config
+
+// parseLXCSize converts a Proxmox size such as "8G" to bytes, plain numbers being gigabytes
+func parseLXCSize(size string) int64 {
+	if size == "" {
+		return 0
+	}
+	if last := size[len(size)-1]; last >= '0' && last <= '9' {
+		size += "G"
+	}
+	bytes, err := runtime.ParseMemory(size)
+	if err != nil {
+		return 0
+	}
+	return bytes
+}
+
+// configKeys returns the keys of a config with the given prefix followed by an index, in index order
+func configKeys(config map[string]interface{}, prefix string) []string {
+	var keys []string
+	for key := range config {
+		if index, err := strconv.Atoi(strings.TrimPrefix(key, prefix)); err == nil && index >= 0 && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(keys[i], prefix))
+		b, _ := strconv.Atoi(strings.TrimPrefix(keys[j], prefix))
+		return a < b
+	})
+	return keys
+}
+
+// inspectNetworks returns the endpoints of the netN interfaces, keyed by bridge, and the bridges in order.
+// live holds the IPv4 address of each interface of a running container
+func inspectNetworks(config map[string]interface{}, live map[string]string) (map[string]runtime.NetworkEndpoint, []string) {
+	endpoints := make(map[string]runtime.NetworkEndpoint)
+	var bridges []string
+	for _, key := range configKeys(config, "net") {
+		value, _ := config[key].(string)
+		bridge := configOption(value, "bridge")
+		name := configOption(value, "name")
+
+		endpoint := runtime.NetworkEndpoint{
+			NetworkID:  bridge,
+			Gateway:    configOption(value, "gw"),
+			MacAddress: configOption(value, "hwaddr"),
+			Interface:  name,
+		}
+		if rate, err := strconv.ParseFloat(configOption(value, "rate"), 64); err == nil {
+			endpoint.RateLimit = rate * 8
+		}
+		if ip, _, err := net.ParseCIDR(configOption(value, "ip")); err == nil {
+			endpoint.IPAddress = ip.String()
+		} else {
+			endpoint.IPAddress = live[name]
+		}
+		if name != "" {
+			endpoint.Aliases = []string{name}
+		}
+
+		id := bridge
+		if _, taken := endpoints[id]; taken || id == "" {
+			id = bridge + "/" + name
+		}
+		endpoints[id] = endpoint
+		bridges = append(bridges, bridge)
+	}
+	return endpoints, bridges
+}
+
+// inspectMounts returns the mpN mount points and the tmpfs mounts of a container
+func inspectMounts(config map[string]interface{}, tmpfs string) []runtime.VolumeMount {
+	var mounts []runtime.VolumeMount
+	for _, key := range configKeys(config, "mp") {
+		value, _ := config[key].(string)
+		source, _, _ := strings.Cut(value, ",")
+
+		mount := runtime.VolumeMount{
+			Type:     runtime.MountTypeVolume,
+			Source:   source,
+			Target:   configOption(value, "mp"),
+			ReadOnly: configOption(value, "ro") == "1",
+		}
+		if strings.HasPrefix(source, "/") {
+			mount.Type = runtime.MountTypeBind
+		} else {
+			mount.Storage, _, _ = strings.Cut(source, ":")
+			mount.Size = parseLXCSize(configOption(value, "size"))
+			if backup := configOption(value, "backup"); backup != "" {
+				enabled := backup == "1"
+				mount.Backup = &enabled
+			}
+		}
+		mounts = append(mounts, mount)
+	}
+
+	if tmpfs != "" {
+		for _, entry := range strings.Split(tmpfs, ";") {
+			target, _, _ := strings.Cut(entry, ":")
+			mounts = append(mounts, runtime.VolumeMount{
+				Type:   runtime.MountTypeTmpfs,
+				Source: "tmpfs",
+				Target: target,
+			})
+		}
+	}
+	return mounts
+}
+
+// inspectFeatures parses the features option of a container
+func inspectFeatures(value string) *runtime.ContainerFeatures {
+	if value == "" {
+		return nil
+	}
+	features := &runtime.ContainerFeatures{
+		Nesting: configOption(value, "nesting") == "1",
+		Keyctl:  configOption(value, "keyctl") == "1",
+		Fuse:    configOption(value, "fuse") == "1",
+		Mknod:   configOption(value, "mknod") == "1",
+	}
+	if mount := configOption(value, "mount"); mount != "" {
+		features.Mount = strings.Split(mount, ";")
+	}
+	return features
+}
+
+// liveAddresses returns the IPv4 address of each interface of a running container
+func (p *ProxmoxRuntime) liveAddresses(node string, vmid int) map[string]string {
+	addresses := make(map[string]string)
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/interfaces", node, vmid), nil)
+	if err != nil {
+		return addresses
+	}
+	for _, iface := range listItems(resp) {
+		name, _ := iface["name"].(string)
+		inet, _ := iface["inet"].(string)
+		if ip, _, err := net.ParseCIDR(inet); err == nil {
+			addresses[name] = ip.String()
+		}
+	}
+	return addresses
+}
+
+// portBindings returns the published ports of a container as mappings and bindings
+func (p *ProxmoxRuntime) portBindings(vmid int) ([]runtime.PortMapping, map[string][]runtime.PortBinding) {
+	rules := p.storedPorts(vmid)
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	mappings := make([]runtime.PortMapping, 0, len(rules))
+	bindings := make(map[string][]runtime.PortBinding)
+	for _, rule := range rules {
+		mappings = append(mappings, runtime.PortMapping{
+			HostIP:        rule.hostIP,
+			HostPort:      strconv.Itoa(rule.hostPort),
+			ContainerPort: strconv.Itoa(rule.port),
+			Protocol:      rule.protocol,
+		})
+		key := fmt.Sprintf("%d/%s", rule.port, rule.protocol)
+		bindings[key] = append(bindings[key], runtime.PortBinding{HostIP: rule.hostIP, HostPort: strconv.Itoa(rule.hostPort)})
+	}
+	return mappings, bindings
+}
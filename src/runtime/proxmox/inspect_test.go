@@ -0,0 +1,125 @@
+package proxmox
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// representativeConfig is the config of a container with a static and a DHCP
+// interface, a volume and a bind mount
+var representativeConfig = map[string]interface{}{
+	"hostname":     "blog",
+	"memory":       1024.0,
+	"swap":         512.0,
+	"cores":        2.0,
+	"cpuunits":     2048.0,
+	"rootfs":       "local-lvm:vm-100-disk-0,size=8G",
+	"unprivileged": 0.0,
+	"onboot":       1.0,
+	"nameserver":   "10.0.0.53 1.1.1.1",
+	"searchdomain": "lan",
+	"features":     "nesting=1,keyctl=1,mount=nfs;cifs",
+	"net0":         "name=eth0,bridge=vmbr0,hwaddr=BC:24:11:00:00:01,ip=10.0.0.20/24,gw=10.0.0.1,type=veth",
+	"net1":         "name=eth1,bridge=vmbr1,hwaddr=BC:24:11:00:00:02,ip=dhcp,rate=12.5,type=veth",
+	"mp0":          "local-lvm:vm-100-disk-1,mp=/var/lib/data,size=16G,backup=0",
+	"mp1":          "/srv/media,mp=/media,ro=1",
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		wantState runtime.ContainerState
+		wantDHCP  string // address of the DHCP interface
+	}{
+		{"running", "running", runtime.StateRunning, "192.168.1.50"},
+		{"stopped", "stopped", runtime.StateExited, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			cluster.addGuest(100, fakeGuest{Status: tt.status, Config: representativeConfig})
+			cluster.handle("GET /nodes/pve/lxc/100/interfaces", func(*http.Request, map[string]interface{}) (int, interface{}) {
+				return http.StatusOK, []map[string]interface{}{
+					{"name": "lo", "inet": "127.0.0.1/8"},
+					{"name": "eth0", "inet": "10.0.0.20/24"},
+					{"name": "eth1", "inet": "192.168.1.50/24"},
+				}
+			})
+
+			p := newTestRuntime(t, cluster)
+			p.metadata.Set(100, map[string]string{
+				"cosmos-name":     "blog",
+				"cosmos-template": testImage,
+				LabelManaged:      "true",
+				LabelPorts:        "192.168.1.10:8080:80/tcp",
+				LabelTmpfs:        "/run/cache:size=64m",
+			})
+
+			details, err := p.Inspect("100")
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+
+			swap := int64(512 << 20)
+			noBackup := false
+			bindings := map[string][]runtime.PortBinding{"80/tcp": {{HostIP: "192.168.1.10", HostPort: "8080"}}}
+			fields := []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"Name", details.Name, "blog"},
+				{"State", details.State, tt.wantState},
+				{"Status", details.Status != "", true},
+				{"Networks", details.Networks, []string{"vmbr0", "vmbr1"}},
+				{"Ports", details.Ports, []runtime.PortMapping{{HostIP: "192.168.1.10", HostPort: "8080", ContainerPort: "80", Protocol: "tcp"}}},
+				{"Config.Image", details.Config.Image, testImage},
+				{"Config.Hostname", details.Config.Hostname, "blog"},
+				{"Config.Memory", details.Config.Memory, int64(1 << 30)},
+				{"Config.MemorySwap", details.Config.MemorySwap, &swap},
+				{"Config.CPUs", details.Config.CPUs, 2.0},
+				{"Config.CPUShares", details.Config.CPUShares, int64(2048)},
+				{"Config.RootFSSize", details.Config.RootFSSize, int64(8 << 30)},
+				{"Config.Privileged", details.Config.Privileged, true},
+				{"Config.RestartPolicy", details.Config.RestartPolicy, runtime.RestartPolicy{Name: "always"}},
+				{"Config.Features", details.Config.Features, &runtime.ContainerFeatures{Nesting: true, Keyctl: true, Mount: []string{"nfs", "cifs"}}},
+				{"NetworkSettings.Networks", details.NetworkSettings.Networks, map[string]runtime.NetworkEndpoint{
+					"vmbr0": {NetworkID: "vmbr0", IPAddress: "10.0.0.20", Gateway: "10.0.0.1", MacAddress: "BC:24:11:00:00:01", Interface: "eth0", Aliases: []string{"eth0"}},
+					"vmbr1": {NetworkID: "vmbr1", IPAddress: tt.wantDHCP, MacAddress: "BC:24:11:00:00:02", Interface: "eth1", RateLimit: 100, Aliases: []string{"eth1"}},
+				}},
+				{"NetworkSettings.IPAddress", details.NetworkSettings.IPAddress, "10.0.0.20"},
+				{"NetworkSettings.Gateway", details.NetworkSettings.Gateway, "10.0.0.1"},
+				{"NetworkSettings.MacAddress", details.NetworkSettings.MacAddress, "BC:24:11:00:00:01"},
+				{"NetworkSettings.Ports", details.NetworkSettings.Ports, bindings},
+				{"Mounts", details.Mounts, []runtime.VolumeMount{
+					{Type: runtime.MountTypeVolume, Source: "local-lvm:vm-100-disk-1", Target: "/var/lib/data", Storage: "local-lvm", Size: 16 << 30, Backup: &noBackup},
+					{Type: runtime.MountTypeBind, Source: "/srv/media", Target: "/media", ReadOnly: true},
+					{Type: runtime.MountTypeTmpfs, Source: "tmpfs", Target: "/run/cache"},
+				}},
+				{"HostConfig.NetworkMode", details.HostConfig.NetworkMode, "bridge"},
+				{"HostConfig.RestartPolicy", details.HostConfig.RestartPolicy, runtime.RestartPolicy{Name: "always"}},
+				{"HostConfig.Privileged", details.HostConfig.Privileged, true},
+				{"HostConfig.Binds", details.HostConfig.Binds, []string{"/srv/media:/media:ro"}},
+				{"HostConfig.PortBindings", details.HostConfig.PortBindings, bindings},
+				{"HostConfig.DNS", details.HostConfig.DNS, []string{"10.0.0.53", "1.1.1.1"}},
+				{"HostConfig.DNSSearch", details.HostConfig.DNSSearch, []string{"lan"}},
+			}
+			for _, f := range fields {
+				if !reflect.DeepEqual(f.got, f.want) {
+					t.Errorf("%s = %#v, want %#v", f.name, f.got, f.want)
+				}
+			}
+		})
+	}
+}
+
+func TestInspectNotFound(t *testing.T) {
+	p := newTestRuntime(t, newFakeCluster(t))
+	if _, err := p.Inspect("150"); err == nil {
+		t.Fatal("Inspect of a missing container succeeded")
+	}
+}
@@ -0,0 +1,300 @@
+package proxmox
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Container network interfaces
+// Each entry of ContainerConfig.Networks becomes an interface (net0, net1...)
+// bridged on the network: a host bridge such as vmbr1 is used as is, other
+// names are resolved to their SDN vnet. Interface N is named ethN, and gets
+// the Interface, MacAddress and RateLimit of the network's entry in NetworkEndpoints.
+// Rate limits are given in Mbps, defaulting to NetworkRateLimit, and sent as
+// the rate option in MB/s, the unit of Proxmox.
+// The cosmos-net<N> labels configure interface N with Proxmox syntax, e.g.
+//   cosmos-net0: bridge=vmbr1,ip=10.0.0.5/24,gw=10.0.0.1,tag=20,name=lan0,hwaddr=BC:24:11:00:00:01,rate=12.5
+// Without networks nor labels, net0 uses DHCP on the default bridge (the
+// DefaultBridge config, vmbr0 when unset), tagged with DefaultVLAN if set
+
+const (
+	// LabelNetworkPrefix prefixes the labels configuring interfaces, followed by the index
+	LabelNetworkPrefix = "cosmos-net"
+
+	defaultBridge = "vmbr0"
+)
+
+var (
+	bridgeNamePattern    = regexp.MustCompile(`^vmbr\d+$`)
+	interfaceLabel       = regexp.MustCompile(`^` + LabelNetworkPrefix + `(\d+)$`)
+	interfaceNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,15}$`) // IFNAMSIZ
+)
+
+// netInterface is the requested configuration of one container interface
+type netInterface struct {
+	name    string // empty for ethN
+	bridge  string
+	ip      string // CIDR, "dhcp" or "manual"
+	gateway string
+	tag     int
+	hwaddr  string  // empty lets Proxmox generate the MAC address
+	rate    float64 // MB/s, 0 for no limit
+}
+
+// render returns the netN value of the interface
+func (n netInterface) render(index int) string {
+	value := fmt.Sprintf("name=%s,bridge=%s,ip=%s", n.interfaceName(index), n.bridge, n.ip)
+	if n.hwaddr != "" {
+		value += ",hwaddr=" + n.hwaddr
+	}
+	if n.gateway != "" {
+		value += ",gw=" + n.gateway
+	}
+	if n.tag > 0 {
+		value += ",tag=" + strconv.Itoa(n.tag)
+	}
+	if n.rate > 0 {
+		value += ",rate=" + strconv.FormatFloat(n.rate, 'f', -1, 64)
+	}
+	return value
+}
+
+// interfaceName returns the name of interface netN inside the container
+func (n netInterface) interfaceName(index int) string {
+	if n.name != "" {
+		return n.name
+	}
+	return fmt.Sprintf("eth%d", index)
+}
+
+// networkInterfaces returns the validated interfaces requested by a container config.
+// Offline, SDN networks are kept by name instead of being resolved to their vnet
+func (p *ProxmoxRuntime) networkInterfaces(config runtime.ContainerConfig, offline bool) ([]netInterface, error) {
+	labelled := make(map[int]string)
+	count := len(config.Networks)
+	for key, value := range config.Labels {
+		m := interfaceLabel.FindStringSubmatch(key)
+		if m == nil {
+			continue
+		}
+		index, _ := strconv.Atoi(m[1])
+		labelled[index] = value
+		if index+1 > count {
+			count = index + 1
+		}
+	}
+	if count == 0 {
+		count = 1
+	}
+
+	interfaces := make([]netInterface, count)
+	for i := range interfaces {
+		iface := netInterface{bridge: p.defaultBridge(), ip: "dhcp", rate: megabytesPerSecond(config.NetworkRateLimit)}
+
+		if i < len(config.Networks) {
+			bridge, err := p.networkBridge(config.Networks[i], offline)
+			if err != nil {
+				return nil, err
+			}
+			iface.bridge = bridge
+
+			endpoint := config.NetworkEndpoints[config.Networks[i]]
+			iface.name = endpoint.Interface
+			iface.hwaddr = endpoint.MacAddress
+			if endpoint.RateLimit != 0 {
+				iface.rate = megabytesPerSecond(endpoint.RateLimit)
+			}
+		} else if _, ok := labelled[i]; !ok && i > 0 {
+			return nil, fmt.Errorf("interface net%d is not configured: set the %s%d label or add a network", i, LabelNetworkPrefix, i)
+		}
+
+		spec, ok := labelled[i]
+		if ok {
+			if err := iface.apply(spec); err != nil {
+				return nil, fmt.Errorf("invalid %s%d label: %w", LabelNetworkPrefix, i, err)
+			}
+		}
+
+		// The default VLAN applies unless the label sets its own tag
+		if iface.bridge == p.defaultBridge() && !strings.Contains(","+spec, ",tag=") {
+			iface.tag = p.config.DefaultVLAN
+		}
+
+		if err := iface.validate(); err != nil {
+			return nil, fmt.Errorf("invalid configuration for interface net%d: %w", i, err)
+		}
+		interfaces[i] = iface
+	}
+
+	names := make(map[string]int)
+	for i, iface := range interfaces {
+		name := iface.interfaceName(i)
+		if other, taken := names[name]; taken {
+			return nil, fmt.Errorf("interfaces net%d and net%d are both named %s", other, i, name)
+		}
+		names[name] = i
+	}
+
+	return interfaces, nil
+}
+
+// megabytesPerSecond converts a rate limit in Mbps to the MB/s of Proxmox
+func megabytesPerSecond(mbps float64) float64 {
+	return mbps / 8
+}
+
+// defaultBridge returns the bridge of interfaces without a network
+func (p *ProxmoxRuntime) defaultBridge() string {
+	if p.config.DefaultBridge != "" {
+		return p.config.DefaultBridge
+	}
+	return defaultBridge
+}
+
+// checkDefaultBridge warns when the default bridge does not exist on the node
+// or the default VLAN is out of range, as every Create would then fail
+func (p *ProxmoxRuntime) checkDefaultBridge() {
+	if vlan := p.config.DefaultVLAN; vlan < 0 || vlan > 4094 {
+		utils.Warn(fmt.Sprintf("Default VLAN %d is out of range (1-4094)", vlan))
+	}
+
+	bridge := p.defaultBridge()
+	networks, err := p.nodeBridges()
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Failed to check the default bridge %s: %s", bridge, err))
+		return
+	}
+
+	var bridges []string
+	for _, network := range networks {
+		if network.Name == bridge {
+			return
+		}
+		bridges = append(bridges, network.Name)
+	}
+	utils.Warn(fmt.Sprintf("Default bridge %s does not exist on node %s (bridges: %s)", bridge, p.node, strings.Join(bridges, ", ")))
+}
+
+// networkBridge returns the bridge an interface on the network is attached to,
+// offline the name of an SDN network is returned as is
+func (p *ProxmoxRuntime) networkBridge(network string, offline bool) (string, error) {
+	switch {
+	case network == "" || network == "default" || network == "bridge":
+		return p.defaultBridge(), nil
+	case bridgeNamePattern.MatchString(network), offline:
+		return network, nil
+	}
+
+	vnet, err := p.resolveVNet(network)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve network %s: %w", network, err)
+	}
+	return vnet, nil
+}
+
+// apply sets the options of a "key=value,..." spec on the interface
+func (n *netInterface) apply(spec string) error {
+	for _, option := range strings.Split(spec, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return fmt.Errorf("option %q must be key=value", option)
+		}
+
+		switch key {
+		case "name":
+			n.name = value
+		case "hwaddr":
+			n.hwaddr = value
+		case "bridge":
+			n.bridge = value
+		case "ip":
+			n.ip = value
+		case "gw":
+			n.gateway = value
+		case "tag":
+			tag, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("VLAN tag %q is not a number", value)
+			}
+			n.tag = tag
+		case "rate":
+			rate, err := strconv.ParseFloat(value, 64)
+			if err != nil || math.IsNaN(rate) || math.IsInf(rate, 0) {
+				return fmt.Errorf("rate limit %q is not a number", value)
+			}
+			if rate <= 0 {
+				return fmt.Errorf("rate limit %q must be positive, omit rate for no limit", value)
+			}
+			n.rate = rate
+		default:
+			return fmt.Errorf("unknown option %q (expected name, bridge, ip, gw, tag, hwaddr or rate)", key)
+		}
+	}
+	return nil
+}
+
+// validate checks the interface addressing before it is sent to Proxmox
+func (n netInterface) validate() error {
+	if n.bridge == "" {
+		return fmt.Errorf("bridge is required")
+	}
+
+	if n.tag < 0 || n.tag > 4094 {
+		return fmt.Errorf("VLAN tag %d is out of range (1-4094)", n.tag)
+	}
+
+	if n.rate < 0 || math.IsNaN(n.rate) || math.IsInf(n.rate, 0) {
+		return fmt.Errorf("rate limit %v must be a positive number, or 0 for none", n.rate)
+	}
+
+	if n.name != "" && !interfaceNamePattern.MatchString(n.name) {
+		return fmt.Errorf("interface name %q must be 1 to 15 letters, digits, '.', '-' or '_'", n.name)
+	}
+
+	if n.hwaddr != "" {
+		mac, err := net.ParseMAC(n.hwaddr)
+		if err != nil || len(mac) != 6 {
+			return fmt.Errorf("MAC address %s must be 6 bytes, e.g. BC:24:11:00:00:01", n.hwaddr)
+		}
+		if mac[0]&1 != 0 {
+			return fmt.Errorf("MAC address %s is a multicast address", n.hwaddr)
+		}
+	}
+
+	if n.ip == "dhcp" || n.ip == "manual" {
+		if n.gateway != "" {
+			return fmt.Errorf("gateway %s requires a static IP", n.gateway)
+		}
+		return nil
+	}
+
+	ip, subnet, err := net.ParseCIDR(n.ip)
+	if err != nil || ip.To4() == nil {
+		return fmt.Errorf("IP address %s must be an IPv4 address in CIDR notation (e.g. 10.0.0.5/24)", n.ip)
+	}
+
+	if n.gateway != "" {
+		gateway := net.ParseIP(n.gateway)
+		if gateway == nil || gateway.To4() == nil {
+			return fmt.Errorf("gateway %s is not a valid IPv4 address", n.gateway)
+		}
+		if !subnet.Contains(gateway) {
+			return fmt.Errorf("gateway %s is outside of %s", n.gateway, subnet)
+		}
+		if gateway.Equal(ip) {
+			return fmt.Errorf("gateway %s is the container address", n.gateway)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package proxmox
+
+import (
+	"strings"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestNetworkRateLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		config runtime.ContainerConfig
+		net0   string // expected rate option, empty when none
+	}{
+		{"no limit", runtime.ContainerConfig{}, ""},
+		{"zero limit", runtime.ContainerConfig{NetworkRateLimit: 0}, ""},
+		{"default limit", runtime.ContainerConfig{NetworkRateLimit: 100}, "rate=12.5"},
+		{"interface limit", runtime.ContainerConfig{
+			Networks:         []string{"vmbr1"},
+			NetworkEndpoints: map[string]runtime.NetworkEndpoint{"vmbr1": {RateLimit: 8}},
+		}, "rate=1"},
+		{"label limit", runtime.ContainerConfig{Labels: map[string]string{"cosmos-net0": "bridge=vmbr1,rate=2.5"}}, "rate=2.5"},
+	}
+
+	p := &ProxmoxRuntime{config: &Config{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lxc, err := p.buildLXCConfig(100, tt.config, true)
+			if err != nil {
+				t.Fatalf("buildLXCConfig: %v", err)
+			}
+			net0, _ := lxc["net0"].(string)
+			if tt.net0 == "" {
+				if strings.Contains(net0, "rate=") {
+					t.Errorf("net0 = %q, expected no rate", net0)
+				}
+				return
+			}
+			if !strings.Contains(net0+",", ","+tt.net0+",") {
+				t.Errorf("net0 = %q, expected %s", net0, tt.net0)
+			}
+		})
+	}
+}
+
+func TestNetworkRateLimitInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config runtime.ContainerConfig
+	}{
+		{"negative limit", runtime.ContainerConfig{NetworkRateLimit: -8}},
+		{"zero label rate", runtime.ContainerConfig{Labels: map[string]string{"cosmos-net0": "rate=0"}}},
+		{"negative label rate", runtime.ContainerConfig{Labels: map[string]string{"cosmos-net0": "rate=-1"}}},
+		{"non-numeric label rate", runtime.ContainerConfig{Labels: map[string]string{"cosmos-net0": "rate=fast"}}},
+		{"infinite label rate", runtime.ContainerConfig{Labels: map[string]string{"cosmos-net0": "rate=Inf"}}},
+	}
+
+	p := &ProxmoxRuntime{config: &Config{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.buildLXCConfig(100, tt.config, true); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestCreateNetworks(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  runtime.ContainerConfig
+		want    []string // net0, net1...
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: []string{"name=eth0,bridge=vmbr0,ip=dhcp"},
+		},
+		{
+			name:   "two networks",
+			config: runtime.ContainerConfig{Networks: []string{"vmbr0", "vmbr1"}},
+			want:   []string{"name=eth0,bridge=vmbr0,ip=dhcp", "name=eth1,bridge=vmbr1,ip=dhcp"},
+		},
+		{
+			name: "interface names and MAC addresses",
+			config: runtime.ContainerConfig{
+				Networks: []string{"vmbr0", "vmbr1"},
+				NetworkEndpoints: map[string]runtime.NetworkEndpoint{
+					"vmbr0": {MacAddress: "BC:24:11:00:00:01"},
+					"vmbr1": {Interface: "lan0", MacAddress: "bc:24:11:00:00:02"},
+				},
+			},
+			want: []string{"name=eth0,bridge=vmbr0,ip=dhcp,hwaddr=BC:24:11:00:00:01", "name=lan0,bridge=vmbr1,ip=dhcp,hwaddr=bc:24:11:00:00:02"},
+		},
+		{
+			name: "label on the second network",
+			config: runtime.ContainerConfig{
+				Networks: []string{"vmbr0", "vmbr1"},
+				Labels:   map[string]string{"cosmos-net1": "ip=10.0.1.5/24,gw=10.0.1.1,tag=20"},
+			},
+			want: []string{"name=eth0,bridge=vmbr0,ip=dhcp", "name=eth1,bridge=vmbr1,ip=10.0.1.5/24,gw=10.0.1.1,tag=20"},
+		},
+		{
+			name: "duplicate interface names",
+			config: runtime.ContainerConfig{
+				Networks:         []string{"vmbr0", "vmbr1"},
+				NetworkEndpoints: map[string]runtime.NetworkEndpoint{"vmbr1": {Interface: "eth0"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "multicast MAC address",
+			config: runtime.ContainerConfig{
+				Networks:         []string{"vmbr1"},
+				NetworkEndpoints: map[string]runtime.NetworkEndpoint{"vmbr1": {MacAddress: "01:00:5E:00:00:01"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "interface name too long",
+			config: runtime.ContainerConfig{
+				Networks:         []string{"vmbr1"},
+				NetworkEndpoints: map[string]runtime.NetworkEndpoint{"vmbr1": {Interface: "a-very-long-interface"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "unconfigured interface",
+			config:  runtime.ContainerConfig{Labels: map[string]string{"cosmos-net2": "bridge=vmbr1"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+
+			config := tt.config
+			config.Name, config.Image = "app", testImage
+			id, err := p.Create(config)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Create succeeded, want an error")
+				}
+				if n := cluster.lxcCount(); n != 0 {
+					t.Errorf("%d containers created", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			guest := cluster.guest(atoi(t, id))
+			for i, want := range tt.want {
+				if got := guest.Config["net"+itoa(i)]; got != want {
+					t.Errorf("net%d = %v, want %s", i, got, want)
+				}
+			}
+			if extra, ok := guest.Config["net"+itoa(len(tt.want))]; ok {
+				t.Errorf("unexpected net%d = %v", len(tt.want), extra)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package proxmox
+
+import (
+	"fmt"
+	"math"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Network and block I/O counters of Proxmox containers
+// As with Docker, NetworkRx/NetworkTx and BlockRead/BlockWrite are cumulative
+// byte counts since the container started, not rates. They are read from the
+// netin/netout/diskread/diskwrite counters of status/current. Proxmox versions
+// omitting those get an estimate from the RRD data of the last hour instead:
+// RRD samples are per-second averages over each step, so every sample is
+// multiplied by the step and the results summed
+
+const rrdDefaultStep = 60 // seconds between samples of the "hour" timeframe
+
+// fillIOStats sets the I/O counters of stats from status/current, falling back to the RRD data
+func (p *ProxmoxRuntime) fillIOStats(stats *runtime.ContainerStats, vmid int, status map[string]interface{}) {
+	counters := []string{"netin", "netout", "diskread", "diskwrite"}
+
+	found := false
+	for _, key := range counters {
+		if _, ok := status[key].(float64); ok {
+			found = true
+		}
+	}
+
+	values := status
+	if !found {
+		resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/rrddata?timeframe=hour&cf=AVERAGE", p.nodeFor(vmid), vmid), nil)
+		if err != nil {
+			return
+		}
+		values = rrdTotals(listItems(resp), counters)
+	}
+
+	stats.NetworkRx = int64(floatValue(values["netin"]))
+	stats.NetworkTx = int64(floatValue(values["netout"]))
+	stats.BlockRead = int64(floatValue(values["diskread"]))
+	stats.BlockWrite = int64(floatValue(values["diskwrite"]))
+}
+
+// rrdTotals integrates per-second RRD samples into byte totals for each key
+func rrdTotals(samples []map[string]interface{}, keys []string) map[string]interface{} {
+	totals := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		totals[key] = 0.0
+	}
+
+	for i, sample := range samples {
+		step := float64(rrdDefaultStep)
+		if i > 0 {
+			if elapsed := floatValue(sample["time"]) - floatValue(samples[i-1]["time"]); elapsed > 0 {
+				step = elapsed
+			}
+		}
+
+		for _, key := range keys {
+			rate, ok := sample[key].(float64)
+			if !ok || math.IsNaN(rate) {
+				continue // RRD gaps (container stopped) have no value
+			}
+			totals[key] = totals[key].(float64) + rate*step
+		}
+	}
+
+	return totals
+}
@@ -0,0 +1,132 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// rrdHour is a trimmed rrddata?timeframe=hour response, with a gap while the container was stopped
+const rrdHour = `[
+	{"time": 1700000000, "cpu": 0.012, "maxcpu": 2, "mem": 104857600, "maxmem": 536870912, "netin": 1024.5, "netout": 256, "diskread": 0, "diskwrite": 4096},
+	{"time": 1700000060, "cpu": 0.02, "maxcpu": 2, "mem": 110100480, "maxmem": 536870912, "netin": 2048, "netout": 512, "diskread": 8192, "diskwrite": 0},
+	{"time": 1700000120, "maxcpu": 2, "maxmem": 536870912},
+	{"time": 1700000240, "cpu": 0.01, "maxcpu": 2, "mem": 99614720, "maxmem": 536870912, "netin": 100, "netout": 10, "diskread": 0, "diskwrite": 1024}
+]`
+
+func TestRRDTotals(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples string
+		want    map[string]float64
+	}{
+		{"no samples", `[]`, map[string]float64{"netin": 0, "netout": 0, "diskread": 0, "diskwrite": 0}},
+		{
+			name:    "single sample uses the default step",
+			samples: `[{"time": 1700000000, "netin": 10, "netout": 1, "diskread": 2, "diskwrite": 3}]`,
+			want:    map[string]float64{"netin": 600, "netout": 60, "diskread": 120, "diskwrite": 180},
+		},
+		{
+			name:    "steps follow the sample times",
+			samples: rrdHour,
+			want: map[string]float64{
+				"netin":     1024.5*60 + 2048*60 + 100*120,
+				"netout":    256*60 + 512*60 + 10*120,
+				"diskread":  8192 * 60,
+				"diskwrite": 4096*60 + 1024*120,
+			},
+		},
+		{
+			name:    "counters as strings are skipped",
+			samples: `[{"time": 1700000000, "netin": "10", "netout": 1}]`,
+			want:    map[string]float64{"netin": 0, "netout": 60, "diskread": 0, "diskwrite": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var samples []map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.samples), &samples); err != nil {
+				t.Fatalf("invalid samples: %v", err)
+			}
+
+			got := rrdTotals(samples, []string{"netin", "netout", "diskread", "diskwrite"})
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("%s = %v, want %v", key, got[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestStatsIOCounters(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  map[string]interface{}
+		rrd     string // rrddata response, empty when it must not be queried
+		want    runtime.ContainerStats
+		wantRRD bool
+	}{
+		{
+			name:   "status counters",
+			status: map[string]interface{}{"cpu": 0.25, "mem": 268435456, "maxmem": 536870912, "netin": 5000, "netout": 3000, "diskread": 1 << 20, "diskwrite": 2 << 20},
+			want: runtime.ContainerStats{
+				ID: "100", Name: "app", CPUPercent: 25, MemoryUsage: 268435456, MemoryLimit: 536870912, MemoryPercent: 50,
+				NetworkRx: 5000, NetworkTx: 3000, BlockRead: 1 << 20, BlockWrite: 2 << 20,
+			},
+		},
+		{
+			name:    "RRD fallback",
+			status:  map[string]interface{}{"cpu": 0.01, "mem": 104857600, "maxmem": 419430400},
+			rrd:     rrdHour,
+			wantRRD: true,
+			want: runtime.ContainerStats{
+				ID: "100", Name: "app", CPUPercent: 1, MemoryUsage: 104857600, MemoryLimit: 419430400, MemoryPercent: 25,
+				NetworkRx: 1024*60 + 30 + 2048*60 + 100*120, NetworkTx: 256*60 + 512*60 + 10*120,
+				BlockRead: 8192 * 60, BlockWrite: 4096*60 + 1024*120,
+			},
+		},
+		{
+			name:    "RRD unavailable",
+			status:  map[string]interface{}{"mem": 1, "maxmem": 0},
+			wantRRD: true,
+			want:    runtime.ContainerStats{ID: "100", Name: "app", MemoryUsage: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			cluster.addGuest(100, fakeGuest{Status: "running", Config: map[string]interface{}{"hostname": "app"}})
+			p := newTestRuntime(t, cluster)
+			p.metadata.Set(100, map[string]string{"cosmos-name": "app"})
+
+			cluster.handle("GET /nodes/pve/lxc/100/status/current", func(*http.Request, map[string]interface{}) (int, interface{}) {
+				return http.StatusOK, tt.status
+			})
+			cluster.handle("GET /nodes/pve/lxc/100/rrddata", func(r *http.Request, _ map[string]interface{}) (int, interface{}) {
+				if tt.rrd == "" {
+					return http.StatusForbidden, "Permission check failed"
+				}
+				if r.URL.Query().Get("timeframe") != "hour" {
+					return http.StatusBadRequest, "unexpected timeframe"
+				}
+				return http.StatusOK, json.RawMessage(tt.rrd)
+			})
+
+			stats, err := p.Stats("100")
+			if err != nil {
+				t.Fatalf("Stats: %v", err)
+			}
+			if *stats != tt.want {
+				t.Errorf("Stats =\n%+v\nwant\n%+v", *stats, tt.want)
+			}
+			if queried := cluster.count("GET /nodes/pve/lxc/100/rrddata") > 0; queried != tt.wantRRD {
+				t.Errorf("RRD data queried = %v, want %v", queried, tt.wantRRD)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Bulk label operations for Proxmox containers
+
+// protectedLabels are managed by the runtime and cannot be changed in bulk
+var protectedLabels = map[string]bool{
+	"cosmos-name":     true,
+	"cosmos-template": true,
+	LabelManaged:      true,
+	LabelNode:         true,
+	LabelProvisioned:  true,
+	LabelPostInstall:  true,
+	LabelStackIndex:   true,
+	LabelHistory:      true,
+	LabelPortRules:    true,
+	LabelSnapshots:    true,
+	LabelProvisioning: true,
+	LabelBuildArgs:    true,
+	LabelGoldenImage:  true,
+	LabelGoldenSource: true,
+	LabelCreated:      true,
+	LabelPorts:        true,
+	LabelReadiness:    true,
+	LabelReady:        true,
+	LabelTmpfs:        true,
+	LabelEnvKeys:      true,
+	LabelExternal:     true,
+	LabelOCIImage:     true,
+
+	LabelAllocatedVolumes: true,
+}
+
+// isReservedLabel reports whether a label holds runtime state, which callers
+// cannot set: the protected labels and the encrypted env and secret labels.
+// LabelNode is not reserved, it pins the node of a new container (see placement.go)
+func isReservedLabel(key string) bool {
+	if key == LabelNode {
+		return false
+	}
+	if protectedLabels[key] {
+		return true
+	}
+	for _, prefix := range sensitivePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// userLabels returns a copy of labels without the reserved ones
+func userLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	filtered := make(map[string]string, len(labels))
+	for key, value := range labels {
+		if !isReservedLabel(key) {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+// LabelMany adds and removes labels on every container matching the selector.
+// It returns the IDs of the updated containers; containers that could not be
+// updated are reported in the joined error without stopping the others.
+func (p *ProxmoxRuntime) LabelMany(selector runtime.LabelSelector, add map[string]string, remove []string) ([]string, error) {
+	for key := range add {
+		if protectedLabels[key] {
+			return nil, fmt.Errorf("label %s is managed by Cosmos and cannot be changed", key)
+		}
+	}
+	for _, key := range remove {
+		if protectedLabels[key] {
+			return nil, fmt.Errorf("label %s is managed by Cosmos and cannot be changed", key)
+		}
+	}
+
+	vmids := p.metadata.FindBySelector(selector)
+	sort.Ints(vmids)
+
+	var affected []string
+	var errs []error
+	for _, vmid := range vmids {
+		before := p.metadata.Get(vmid)
+		if err := p.metadata.UpdateLabels(vmid, add, remove); err != nil {
+			errs = append(errs, fmt.Errorf("container %d: %w", vmid, err))
+			continue
+		}
+		p.recordChange(vmid, "labels", labelChanges(before, p.metadata.Get(vmid)))
+		affected = append(affected, strconv.Itoa(vmid))
+	}
+
+	utils.Log(fmt.Sprintf("Updated labels on %d LXC containers", len(affected)))
+	return affected, errors.Join(errs...)
+}
+
+// UpdateLabels adds and removes labels of a container in a single step
+func (m *MetadataStore) UpdateLabels(vmid int, add map[string]string, remove []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels, ok := m.data[vmid]
+	if !ok {
+		return errors.New("container has no metadata")
+	}
+
+	m.unindex(vmid)
+	for _, key := range remove {
+		delete(labels, key)
+	}
+	for key, value := range add {
+		labels[key] = value
+	}
+	m.reindex(vmid)
+
+	// Auto-save after modification
+	m.markDirty(vmid)
+	return nil
+}
@@ -0,0 +1,101 @@
+package proxmox
+
+import (
+	"reflect"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestLabelMany(t *testing.T) {
+	members := map[int]map[string]string{
+		100: {"cosmos-name": "shop-web-1", LabelStack: "shop", "tier": "front"},
+		101: {"cosmos-name": "shop-db-1", LabelStack: "shop", "tier": "back"},
+		102: {"cosmos-name": "blog-web-1", LabelStack: "blog", "tier": "front"},
+		103: {"cosmos-name": "standalone"},
+	}
+
+	tests := []struct {
+		name     string
+		selector runtime.LabelSelector
+		add      map[string]string
+		remove   []string
+		want     []string
+		wantErr  bool
+	}{
+		{"add to a stack", runtime.LabelSelector{LabelStack: "shop"}, map[string]string{"owner": "team-a"}, nil, []string{"100", "101"}, false},
+		{"remove from a stack", runtime.LabelSelector{LabelStack: "shop"}, nil, []string{"tier"}, []string{"100", "101"}, false},
+		{"several selector labels", runtime.LabelSelector{LabelStack: "shop", "tier": "front"}, map[string]string{"owner": "team-a"}, nil, []string{"100"}, false},
+		{"no match", runtime.LabelSelector{LabelStack: "wiki"}, map[string]string{"owner": "team-a"}, nil, nil, false},
+		{"protected label", runtime.LabelSelector{LabelStack: "shop"}, map[string]string{LabelManaged: "false"}, nil, nil, true},
+		{"protected removal", runtime.LabelSelector{LabelStack: "shop"}, nil, []string{LabelStackIndex}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			backend := NewMemoryBackend()
+			for vmid, labels := range members {
+				cluster.addGuest(vmid, fakeGuest{Config: map[string]interface{}{"hostname": labels["cosmos-name"]}})
+				backend.Set(vmid, labels)
+			}
+			p := newTestRuntime(t, cluster, func(c *Config) { c.MetadataBackend = backend })
+
+			got, err := p.LabelMany(tt.selector, tt.add, tt.remove)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LabelMany error = %v, want error: %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LabelMany = %v, want %v", got, tt.want)
+			}
+
+			updated := make(map[string]bool)
+			for _, id := range got {
+				updated[id] = true
+			}
+			for vmid, before := range members {
+				labels := p.metadata.Get(vmid)
+				if !updated[itoa(vmid)] {
+					for key, value := range before {
+						if labels[key] != value {
+							t.Errorf("container %d not selected but %s changed to %q", vmid, key, labels[key])
+						}
+					}
+					if _, ok := labels["owner"]; ok {
+						t.Errorf("container %d not selected but labelled", vmid)
+					}
+					continue
+				}
+				for key, value := range tt.add {
+					if labels[key] != value {
+						t.Errorf("container %d: %s = %q, want %q", vmid, key, labels[key], value)
+					}
+				}
+				for _, key := range tt.remove {
+					if _, ok := labels[key]; ok {
+						t.Errorf("container %d still has %s", vmid, key)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUserLabels(t *testing.T) {
+	got := userLabels(map[string]string{
+		"app":                    "web",
+		LabelNode:                "pve2",
+		LabelManaged:             "true",
+		LabelPortRules:           "x",
+		"cosmos-env.PASSWORD":    "secret",
+		"cosmos-secret.x":        "y",
+		LabelNetworkPrefix + "0": "bridge=vmbr1",
+	})
+	want := map[string]string{"app": "web", LabelNode: "pve2", LabelNetworkPrefix + "0": "bridge=vmbr1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("userLabels = %v, want %v", got, want)
+	}
+	if userLabels(nil) != nil {
+		t.Error("userLabels(nil) is not nil")
+	}
+}
@@ -0,0 +1,242 @@
+package proxmox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Local API transport
+// When Cosmos runs as root on the node it manages, API calls go through pvesh,
+// the command line client of the same API, instead of HTTPS to the node: no
+// API token is needed and each call skips TLS and the API proxy. Config.
+// APITransport picks the transport: "auto" (the default) uses pvesh when this
+// host is Node, "local" uses it whenever it is installed, "http" never does.
+// Without pvesh the HTTP API is used. Exec and the node scripts also run
+// locally when SSH is not configured. Template uploads always use HTTP
+
+const (
+	TransportAuto  = "auto"
+	TransportLocal = "local"
+	TransportHTTP  = "http"
+)
+
+// pveshCommands maps HTTP methods to pvesh commands
+var pveshCommands = map[string]string{
+	http.MethodGet:    "get",
+	http.MethodPost:   "create",
+	http.MethodPut:    "set",
+	http.MethodDelete: "delete",
+}
+
+// useLocalAPI reports whether API calls of config go through pvesh
+func useLocalAPI(config *Config) (bool, error) {
+	switch config.APITransport {
+	case "", TransportAuto, TransportLocal:
+	case TransportHTTP:
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown proxmox API transport %q, use %s, %s or %s", config.APITransport, TransportAuto, TransportLocal, TransportHTTP)
+	}
+
+	if _, err := exec.LookPath("pvesh"); err != nil {
+		if config.APITransport == TransportLocal {
+			utils.Warn("pvesh is not available on this host, using the HTTP API")
+		}
+		return false, nil
+	}
+
+	return config.APITransport == TransportLocal || onNode(config.Node), nil
+}
+
+// onNode reports whether this process runs as root on the node named node
+func onNode(node string) bool {
+	if os.Geteuid() != 0 {
+		return false
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return false
+	}
+	if i := strings.IndexByte(hostname, '.'); i >= 0 {
+		hostname = hostname[:i]
+	}
+	return strings.EqualFold(hostname, node)
+}
+
+// localRequest performs a single API call with pvesh within timeout, 0 means
+// no deadline. path is relative to the API root and may hold a query string
+func (p *ProxmoxRuntime) localRequest(method, path string, payload []byte, timeout time.Duration) (json.RawMessage, error) {
+	command, ok := pveshCommands[method]
+	if !ok {
+		return nil, fmt.Errorf("unsupported API method %s", method)
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	params, err := pveshParams(u.Query(), payload)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := p.background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	args := append([]string{command, u.Path, "--output-format", "json"}, params...)
+	cmd := exec.CommandContext(ctx, "pvesh", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		err = fmt.Errorf("pvesh %s %s: %w", command, u.Path, ctx.Err())
+	} else if err != nil && stderr.Len() > 0 {
+		err = pveshError(stderr.String())
+	}
+
+	if p.tracing() {
+		call := APICall{
+			Method:       method,
+			Path:         u.Path,
+			Duration:     time.Since(start),
+			RequestBody:  redactBody(payload),
+			ResponseBody: redactBody(stdout.Bytes()),
+			Err:          err,
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			call.StatusCode = apiErr.StatusCode
+		} else if err == nil {
+			call.StatusCode = http.StatusOK
+		}
+		p.traceCall(call)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	output := bytes.TrimSpace(stdout.Bytes())
+	if len(output) == 0 {
+		return nil, nil
+	}
+	return json.RawMessage(output), nil
+}
+
+// pveshParams renders the query and the JSON body of a call as pvesh options
+func pveshParams(query url.Values, payload []byte) ([]string, error) {
+	params := make(map[string][]string)
+	for key, values := range query {
+		params[key] = append(params[key], values...)
+	}
+
+	if len(payload) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+		var body map[string]interface{}
+		if err := decoder.Decode(&body); err != nil {
+			return nil, fmt.Errorf("invalid API request body: %w", err)
+		}
+		for key, value := range body {
+			values, err := pveshValues(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %s: %w", key, err)
+			}
+			params[key] = append(params[key], values...)
+		}
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var args []string
+	for _, key := range keys {
+		for _, value := range params[key] {
+			// --key=value so values starting with a dash are not read as options
+			args = append(args, "--"+key+"="+value)
+		}
+	}
+	return args, nil
+}
+
+// pveshValues formats a JSON body value as option values, lists repeat the option
+func pveshValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case json.Number:
+		return []string{v.String()}, nil
+	case bool:
+		if v {
+			return []string{"1"}, nil
+		}
+		return []string{"0"}, nil
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			formatted, err := pveshValues(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, formatted...)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %T", value)
+	}
+}
+
+// pveshError turns the error output of pvesh into an APIError. pvesh does not
+// report the HTTP status, 500 is used as for most API failures, so messages
+// such as the missing guest configuration are still seen by isNotFound
+func pveshError(output string) error {
+	return &APIError{StatusCode: http.StatusInternalServerError, Body: strings.TrimSpace(output)}
+}
+
+// localTransport runs node commands on this host, used when the runtime runs
+// on the node and SSH is not configured
+type localTransport struct{}
+
+// Run executes a command with sh and returns its exit code
+func (localTransport) Run(command string, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,54 @@
+package proxmox
+
+import (
+	"sync"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// keyedMutex serializes operations sharing the same key
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock acquires the lock for key and returns the function releasing it
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*keyedLock)
+	}
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		k.locks[key] = lock
+	}
+	lock.refs++
+	k.mu.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		k.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// CreateOrGet returns the container named config.Name, creating it if it does not exist.
+// Concurrent calls for the same name are serialized so only one container is created.
+// Create itself behaves this way, unless config.Replace is set.
+func (p *ProxmoxRuntime) CreateOrGet(config runtime.ContainerConfig) (string, error) {
+	config.Replace = false
+	return p.Create(config)
+}
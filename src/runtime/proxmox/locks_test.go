@@ -0,0 +1,84 @@
+package proxmox
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestCreateOrGetConcurrent(t *testing.T) {
+	cluster := newFakeCluster(t)
+	p := newTestRuntime(t, cluster)
+
+	const calls = 20
+	ids := make(chan string, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := p.CreateOrGet(runtime.ContainerConfig{Name: "app", Image: testImage, Replace: true})
+			if err != nil {
+				t.Errorf("CreateOrGet: %v", err)
+			}
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	first := ""
+	for id := range ids {
+		if first == "" {
+			first = id
+		}
+		if id != first {
+			t.Errorf("CreateOrGet returned %s and %s", first, id)
+		}
+	}
+	if n := cluster.lxcCount(); n != 1 {
+		t.Errorf("%d containers created, want 1", n)
+	}
+	if n := cluster.count("POST /nodes/pve/lxc"); n != 1 {
+		t.Errorf("%d create requests, want 1", n)
+	}
+}
+
+func TestKeyedMutex(t *testing.T) {
+	var k keyedMutex
+
+	unlockA := k.Lock("a")
+	locked := make(chan string, 2)
+	go func() {
+		unlock := k.Lock("a")
+		locked <- "a"
+		unlock()
+	}()
+	go func() {
+		unlock := k.Lock("b")
+		locked <- "b"
+		unlock()
+	}()
+
+	if key := <-locked; key != "b" {
+		t.Fatalf("lock %s acquired while a was held", key)
+	}
+	select {
+	case <-locked:
+		t.Fatal("a acquired twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlockA()
+	if key := <-locked; key != "a" {
+		t.Fatalf("got %s, want a", key)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.locks) != 0 {
+		t.Errorf("%d locks left after release", len(k.locks))
+	}
+}
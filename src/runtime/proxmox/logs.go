@@ -0,0 +1,309 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Log streaming for Proxmox containers
+// Logs are read from the container journal through Exec. When exec is not
+// available (no SSH access) the node syslog is used instead, keeping only the
+// lines about the container (pve-container, lxc-start...). With Follow, the
+// returned reader polls for new lines until it is closed
+
+const (
+	logPollInterval = 2 * time.Second
+	defaultLogTail  = 1000
+
+	// nodeCursorPrefix marks a cursor resuming the node syslog at a unix time
+	nodeCursorPrefix = "@"
+)
+
+var journalCursorLine = regexp.MustCompile(`^-- cursor: (.+)$`)
+
+// logQuery selects the log lines to read
+type logQuery struct {
+	cursor     string // resume after this position, ignores tail and since
+	tail       int    // 0 returns every line
+	since      time.Time
+	until      time.Time
+	timestamps bool
+}
+
+// Logs returns container logs
+func (p *ProxmoxRuntime) Logs(id string, opts runtime.LogOptions) (io.ReadCloser, error) {
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid container ID: %s", id)
+	}
+
+	_, err = p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/status/current", p.nodeFor(vmid), vmid), nil)
+	if isNotFound(err) {
+		return nil, p.notFound(vmid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container logs: %w", err)
+	}
+
+	query, err := parseLogOptions(opts, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	lines, cursor, err := p.readLogs(vmid, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container logs: %w", err)
+	}
+
+	if !opts.Follow {
+		return io.NopCloser(strings.NewReader(joinLines(lines))), nil
+	}
+
+	stream := newLogStream()
+	p.goBackground(func(ctx context.Context) {
+		p.followLogs(ctx, stream, vmid, query, cursor, joinLines(lines))
+	})
+	return stream, nil
+}
+
+// followLogs writes the initial lines then polls for new ones until the stream or the runtime is closed
+func (p *ProxmoxRuntime) followLogs(ctx context.Context, stream *logStream, vmid int, query logQuery, cursor, initial string) {
+	defer stream.writer.Close()
+	defer stream.closeOnDone(ctx, nil)()
+
+	if _, err := io.WriteString(stream.writer, initial); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(logPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stream.done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !query.until.IsZero() && time.Now().After(query.until) {
+			return
+		}
+
+		next := query
+		next.cursor = cursor
+		lines, newCursor, err := p.readLogs(vmid, next)
+		if err != nil {
+			continue // transient, retry on next tick
+		}
+		if newCursor != "" {
+			cursor = newCursor
+		}
+
+		if _, err := io.WriteString(stream.writer, joinLines(lines)); err != nil {
+			return
+		}
+	}
+}
+
+// readLogs reads log lines from the container journal, or the node syslog without exec
+func (p *ProxmoxRuntime) readLogs(vmid int, query logQuery) ([]string, string, error) {
+	if strings.HasPrefix(query.cursor, nodeCursorPrefix) {
+		return p.readNodeSyslog(vmid, query)
+	}
+
+	lines, cursor, err := p.readJournal(vmid, query)
+	if err != nil && query.cursor == "" {
+		return p.readNodeSyslog(vmid, query)
+	}
+	return lines, cursor, err
+}
+
+// readJournal runs journalctl inside the container
+func (p *ProxmoxRuntime) readJournal(vmid int, query logQuery) ([]string, string, error) {
+	format := "cat"
+	if query.timestamps {
+		format = "short-iso"
+	}
+
+	cmd := []string{"journalctl", "--no-pager", "--quiet", "--show-cursor", "-o", format}
+	if query.cursor != "" {
+		cmd = append(cmd, "--after-cursor="+query.cursor)
+	} else {
+		if query.tail > 0 {
+			cmd = append(cmd, "-n", strconv.Itoa(query.tail))
+		}
+		if !query.since.IsZero() {
+			cmd = append(cmd, fmt.Sprintf("--since=@%d", query.since.Unix()))
+		}
+	}
+	if !query.until.IsZero() {
+		cmd = append(cmd, fmt.Sprintf("--until=@%d", query.until.Unix()))
+	}
+
+	result, err := p.Exec(strconv.Itoa(vmid), cmd, runtime.ExecOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+	if result.ExitCode != 0 {
+		return nil, "", fmt.Errorf("journalctl exited with code %d: %s", result.ExitCode, result.Stderr)
+	}
+
+	lines, cursor := parseJournal(result.Stdout)
+	if cursor == "" {
+		cursor = query.cursor
+	}
+	return lines, cursor, nil
+}
+
+// parseJournal splits journalctl output into lines and the trailing cursor
+func parseJournal(output string) ([]string, string) {
+	var lines []string
+	cursor := ""
+
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if m := journalCursorLine.FindStringSubmatch(line); m != nil {
+			cursor = m[1]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, cursor
+}
+
+// readNodeSyslog reads the node syslog, keeping the lines about the container
+func (p *ProxmoxRuntime) readNodeSyslog(vmid int, query logQuery) ([]string, string, error) {
+	since := query.since
+	if strings.HasPrefix(query.cursor, nodeCursorPrefix) {
+		if unix, err := strconv.ParseInt(strings.TrimPrefix(query.cursor, nodeCursorPrefix), 10, 64); err == nil {
+			since = time.Unix(unix, 0)
+		}
+	}
+	now := time.Now()
+
+	params := url.Values{}
+	params.Set("limit", "50000")
+	if !since.IsZero() {
+		params.Set("since", since.Format("2006-01-02 15:04:05"))
+	}
+	if !query.until.IsZero() {
+		params.Set("until", query.until.Format("2006-01-02 15:04:05"))
+	}
+
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/syslog?%s", p.nodeFor(vmid), params.Encode()), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var lines []string
+	for _, item := range listItems(resp) {
+		if text, ok := item["t"].(string); ok && mentionsContainer(text, vmid) {
+			lines = append(lines, text)
+		}
+	}
+
+	if query.cursor == "" && query.tail > 0 && len(lines) > query.tail {
+		lines = lines[len(lines)-query.tail:]
+	}
+
+	return lines, nodeCursorPrefix + strconv.FormatInt(now.Unix(), 10), nil
+}
+
+// mentionsContainer reports whether a node syslog line is about the container
+func mentionsContainer(line string, vmid int) bool {
+	id := strconv.Itoa(vmid)
+	for _, marker := range []string{"pve-container@" + id, "CT " + id, "lxc-start " + id, "vzstart:" + id, "vzstop:" + id, "vzshutdown:" + id} {
+		if strings.Contains(line, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseLogOptions converts LogOptions into a query. Since and Until accept RFC3339
+// times, unix timestamps or durations relative to now (e.g. "10m")
+func parseLogOptions(opts runtime.LogOptions, now time.Time) (logQuery, error) {
+	query := logQuery{tail: defaultLogTail, timestamps: opts.Timestamps}
+
+	switch opts.Tail {
+	case "":
+	case "all":
+		query.tail = 0
+	default:
+		tail, err := strconv.Atoi(opts.Tail)
+		if err != nil || tail < 0 {
+			return query, fmt.Errorf("invalid log tail: %s", opts.Tail)
+		}
+		query.tail = tail
+	}
+
+	var err error
+	if query.since, err = parseLogTime(opts.Since, now); err != nil {
+		return query, err
+	}
+	if query.until, err = parseLogTime(opts.Until, now); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+func parseLogTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid log time: %s", value)
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// logStream is the ReadCloser returned by followed Logs calls and template pulls.
+// Closing it stops the polling goroutine.
+type logStream struct {
+	*io.PipeReader
+	writer *io.PipeWriter
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newLogStream() *logStream {
+	reader, writer := io.Pipe()
+	return &logStream{PipeReader: reader, writer: writer, done: make(chan struct{})}
+}
+
+// closeOnDone ends the stream with err once ctx is done, so a write blocked on
+// a reader that stopped reading returns. The returned function stops watching ctx
+func (s *logStream) closeOnDone(ctx context.Context, err error) func() bool {
+	return context.AfterFunc(ctx, func() { s.writer.CloseWithError(err) })
+}
+
+// Close stops following and releases the stream
+func (s *logStream) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.PipeReader.Close()
+}
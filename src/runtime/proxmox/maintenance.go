@@ -0,0 +1,79 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Maintenance handling for Proxmox nodes
+// A node put in HA maintenance mode (or being drained) should not receive
+// new containers nor start existing ones. Read-only operations are unaffected
+
+// ErrNodeMaintenance is returned when a mutating operation targets a node in maintenance
+var ErrNodeMaintenance = errors.New("node in maintenance")
+
+// LabelNode records the node a container was placed on
+const LabelNode = "cosmos-node"
+
+// MaintenanceMode reports whether a node is in maintenance or being drained
+func (p *ProxmoxRuntime) MaintenanceMode(node string) (bool, error) {
+	if !p.connected {
+		return false, errNotConnected
+	}
+
+	resp, err := p.apiRequest("GET", "/cluster/ha/status/manager_status", nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to get HA status: %w", err)
+	}
+
+	return isNodeInMaintenance(resp, node), nil
+}
+
+// isNodeInMaintenance reads the node state from an HA manager_status payload
+func isNodeInMaintenance(managerStatus map[string]interface{}, node string) bool {
+	status, ok := managerStatus["manager_status"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	nodeStatus, ok := status["node_status"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	state, _ := nodeStatus[node].(string)
+	return state == "maintenance" || strings.HasPrefix(state, "fence")
+}
+
+// nodeFor returns the node hosting a container. Guests can migrate, so the
+// cluster resources are checked before the node the container was placed on
+func (p *ProxmoxRuntime) nodeFor(vmid int) string {
+	placed := p.metadata.GetLabel(vmid, LabelNode)
+	if node := p.locateNode(vmid); node != "" {
+		if placed != "" && placed != node {
+			p.metadata.SetLabel(vmid, LabelNode, node)
+			utils.Log(fmt.Sprintf("LXC container VMID %d moved from node %s to %s", vmid, placed, node))
+		}
+		return node
+	}
+	if placed != "" {
+		return placed
+	}
+	return p.node
+}
+
+// checkMaintenance returns ErrNodeMaintenance if the node is in maintenance.
+// HA being unavailable (e.g. not configured) is not treated as maintenance.
+func (p *ProxmoxRuntime) checkMaintenance(node string) error {
+	inMaintenance, err := p.MaintenanceMode(node)
+	if err != nil {
+		return nil
+	}
+	if inMaintenance {
+		return fmt.Errorf("%w: %s", ErrNodeMaintenance, node)
+	}
+	return nil
+}
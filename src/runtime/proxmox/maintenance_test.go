@@ -0,0 +1,109 @@
+package proxmox
+
+import (
+	"errors"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestCreateOnMaintenanceNode(t *testing.T) {
+	tests := []struct {
+		name        string
+		nodes       []string
+		maintenance []string
+		pin         string
+		wantNode    string // empty when Create must fail with ErrNodeMaintenance
+	}{
+		{"node available", []string{"pve", "pve2"}, nil, "", "pve"},
+		{"single node in maintenance", []string{"pve"}, []string{"pve"}, "", ""},
+		{"redirected to another node", []string{"pve", "pve2"}, []string{"pve"}, "", "pve2"},
+		{"every node in maintenance", []string{"pve", "pve2"}, []string{"pve", "pve2"}, "", ""},
+		{"pinned to a node in maintenance", []string{"pve", "pve2"}, []string{"pve2"}, "pve2", ""},
+		{"pinned to an available node", []string{"pve", "pve2"}, []string{"pve"}, "pve2", "pve2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t, tt.nodes...)
+			for _, node := range tt.maintenance {
+				cluster.setMaintenance(node, true)
+			}
+			p := newTestRuntime(t, cluster)
+
+			config := runtime.ContainerConfig{Name: "app", Image: testImage}
+			if tt.pin != "" {
+				config = WithNode(config, tt.pin)
+			}
+			id, err := p.Create(config)
+
+			if tt.wantNode == "" {
+				if !errors.Is(err, ErrNodeMaintenance) {
+					t.Fatalf("Create error = %v, want ErrNodeMaintenance", err)
+				}
+				if n := cluster.lxcCount(); n != 0 {
+					t.Errorf("%d containers created", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if node := cluster.guest(atoi(t, id)).Node; node != tt.wantNode {
+				t.Errorf("created on %s, want %s", node, tt.wantNode)
+			}
+			if label := p.metadata.GetLabel(atoi(t, id), LabelNode); label != tt.wantNode {
+				t.Errorf("%s = %q, want %s", LabelNode, label, tt.wantNode)
+			}
+		})
+	}
+}
+
+func TestStartOnMaintenanceNode(t *testing.T) {
+	cluster := newFakeCluster(t)
+	cluster.addGuest(100, fakeGuest{Config: map[string]interface{}{"hostname": "app"}})
+	p := newTestRuntime(t, cluster)
+
+	cluster.setMaintenance("pve", true)
+	if err := p.Start("100"); !errors.Is(err, ErrNodeMaintenance) {
+		t.Fatalf("Start error = %v, want ErrNodeMaintenance", err)
+	}
+	if status := cluster.guest(100).Status; status != "stopped" {
+		t.Errorf("container is %s", status)
+	}
+
+	cluster.setMaintenance("pve", false)
+	if err := p.Start("100"); err != nil {
+		t.Fatalf("Start after maintenance: %v", err)
+	}
+}
+
+func TestIsNodeInMaintenance(t *testing.T) {
+	tests := []struct {
+		state string
+		want  bool
+	}{
+		{"online", false},
+		{"maintenance", true},
+		{"fence", true},
+		{"fenced", true},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.state, func(t *testing.T) {
+			status := map[string]interface{}{
+				"manager_status": map[string]interface{}{
+					"node_status": map[string]interface{}{"pve": tt.state},
+				},
+			}
+			if got := isNodeInMaintenance(status, "pve"); got != tt.want {
+				t.Errorf("isNodeInMaintenance(%q) = %v, want %v", tt.state, got, tt.want)
+			}
+		})
+	}
+
+	if isNodeInMaintenance(map[string]interface{}{}, "pve") {
+		t.Error("a node without HA status is in maintenance")
+	}
+}
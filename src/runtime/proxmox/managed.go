@@ -0,0 +1,26 @@
+package proxmox
+
+// Cosmos-managed containers
+// Containers created by Cosmos carry cosmos-managed=true. Guests made outside
+// of Cosmos (e.g. in the Proxmox UI) share the node, so List and StatsAll leave
+// them out unless IncludeUnmanaged is set, in which case they are listed with
+// cosmos-external=true. Containers created before the label existed are
+// recognized by their Cosmos name
+
+const (
+	// LabelManaged marks containers created by Cosmos
+	LabelManaged = "cosmos-managed"
+
+	// LabelExternal flags listed containers Cosmos has no metadata for
+	LabelExternal = "cosmos-external"
+)
+
+// isManaged reports whether the container with these labels was created by Cosmos
+func isManaged(labels map[string]string) bool {
+	return labels[LabelManaged] == "true" || labels["cosmos-name"] != ""
+}
+
+// listed reports whether a container with these labels is returned by List and StatsAll
+func (p *ProxmoxRuntime) listed(labels map[string]string) bool {
+	return p.config.IncludeUnmanaged || isManaged(labels)
+}
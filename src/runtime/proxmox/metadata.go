@@ -1,60 +1,95 @@
 package proxmox
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
+	"fmt"
+	"strings"
 	"sync"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
 )
 
 // MetadataStore manages container labels and metadata
 // Since Proxmox LXC doesn't have Docker-style labels,
-// we store metadata in a local JSON file
-
-// Load reads metadata from disk
+// we keep them in memory and persist them through a MetadataBackend
+// (a local JSON file by default).
+// Changes are not written right away: they mark the container dirty and a
+// single flush runs once the store has been quiet for saveDebounce, so bulk
+// updates cost one write instead of one per change. Close flushes synchronously.
+// A store whose Load failed (e.g. a wrong MetadataKey) never writes the backend,
+// so the stored labels are not replaced by the empty in-memory copy
+
+// Load reads metadata from the backend and starts watching it for external changes
 func (m *MetadataStore) Load() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	filePath := filepath.Join(m.path, "containers.json")
-
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(m.path, 0755); err != nil {
-		return err
-	}
-
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		m.data = make(map[int]map[string]string)
-		return nil
+	data, err := m.backend.List()
+	if err == nil {
+		for _, labels := range data {
+			if err = m.decodeLabels(labels); err != nil {
+				break
+			}
+		}
 	}
-
-	data, err := os.ReadFile(filePath)
 	if err != nil {
+		m.mu.Lock()
+		m.loadErr = err
+		m.mu.Unlock()
 		return err
 	}
 
-	return json.Unmarshal(data, &m.data)
+	m.mu.Lock()
+	m.loadErr = nil
+	m.data = data
+	m.rebuildIndex()
+	if m.cancelWatch == nil {
+		m.cancelWatch = m.backend.Watch(m.applyRemote)
+	}
+	m.mu.Unlock()
+
+	return nil
 }
 
-// Save writes metadata to disk
+// Save writes all metadata to the backend, removing the containers it no longer has
 func (m *MetadataStore) Save() error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
 
-	filePath := filepath.Join(m.path, "containers.json")
+	m.mu.RLock()
+	if m.loadErr != nil {
+		m.mu.RUnlock()
+		return fmt.Errorf("metadata not saved, it failed to load: %w", m.loadErr)
+	}
+	encoded := make(map[int]map[string]string, len(m.data))
+	for vmid, labels := range m.data {
+		enc, err := m.encodeLabels(labels)
+		if err != nil {
+			m.mu.RUnlock()
+			return err
+		}
+		encoded[vmid] = enc
+	}
+	m.mu.RUnlock()
 
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(m.path, 0755); err != nil {
-		return err
+	if batch, ok := m.backend.(batchBackend); ok {
+		return batch.SetAll(encoded)
 	}
 
-	data, err := json.MarshalIndent(m.data, "", "  ")
+	stored, err := m.backend.List()
 	if err != nil {
 		return err
 	}
-
-	return os.WriteFile(filePath, data, 0644)
+	for vmid := range stored {
+		if _, ok := encoded[vmid]; !ok {
+			if err := m.backend.Delete(vmid); err != nil {
+				return err
+			}
+		}
+	}
+	for vmid, labels := range encoded {
+		if err := m.backend.Set(vmid, labels); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Get returns all labels for a container
@@ -82,10 +117,18 @@ func (m *MetadataStore) Set(vmid int, labels map[string]string) {
 		m.data = make(map[int]map[string]string)
 	}
 
-	m.data[vmid] = labels
+	// Keep a copy so the index cannot go stale through the caller's map
+	copy := make(map[string]string, len(labels))
+	for k, v := range labels {
+		copy[k] = v
+	}
+
+	m.unindex(vmid)
+	m.data[vmid] = copy
+	m.reindex(vmid)
 
 	// Auto-save after modification
-	go m.saveAsync()
+	m.markDirty(vmid)
 }
 
 // GetLabel returns a specific label
@@ -112,10 +155,12 @@ func (m *MetadataStore) SetLabel(vmid int, key, value string) {
 		m.data[vmid] = make(map[string]string)
 	}
 
+	m.unindex(vmid)
 	m.data[vmid][key] = value
+	m.reindex(vmid)
 
 	// Auto-save after modification
-	go m.saveAsync()
+	m.markDirty(vmid)
 }
 
 // Delete removes all metadata for a container
@@ -123,10 +168,11 @@ func (m *MetadataStore) Delete(vmid int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.unindex(vmid)
 	delete(m.data, vmid)
 
 	// Auto-save after modification
-	go m.saveAsync()
+	m.markDirty(vmid)
 }
 
 // HasLabel checks if a label exists
@@ -146,8 +192,16 @@ func (m *MetadataStore) FindByLabel(key, value string) []int {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	// An empty value also matches containers without the label, which the index cannot list
+	if value != "" {
+		return m.indexed(key, value)
+	}
+
 	var results []int
 	for vmid, labels := range m.data {
+		if vmid == volumeMetadataID {
+			continue
+		}
 		if labels[key] == value {
 			results = append(results, vmid)
 		}
@@ -155,28 +209,212 @@ func (m *MetadataStore) FindByLabel(key, value string) []int {
 	return results
 }
 
-// FindByName finds a container by cosmos-name label
+// FindBySelector finds containers carrying all labels of the selector
+func (m *MetadataStore) FindBySelector(selector runtime.LabelSelector) []int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var results []int
+	for vmid, labels := range m.data {
+		if vmid == volumeMetadataID {
+			continue
+		}
+		if selector.Matches(labels) {
+			results = append(results, vmid)
+		}
+	}
+	return results
+}
+
+// FindByName finds a container by cosmos-name label, the lowest VMID when several match
 func (m *MetadataStore) FindByName(name string) int {
-	results := m.FindByLabel("cosmos-name", name)
-	if len(results) > 0 {
-		return results[0]
+	lowest := 0
+	for _, vmid := range m.FindByLabel("cosmos-name", name) {
+		if lowest == 0 || vmid < lowest {
+			lowest = vmid
+		}
+	}
+	return lowest
+}
+
+// volumeMetadataID is the entry holding volume labels, as "<volume>/<label>" keys.
+// 0 is never a valid VMID, so it cannot clash with a container
+const volumeMetadataID = 0
+
+// VolumeLabels returns the labels of a volume
+func (m *MetadataStore) VolumeLabels(name string) map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	labels := make(map[string]string)
+	prefix := name + "/"
+	for k, v := range m.data[volumeMetadataID] {
+		if strings.HasPrefix(k, prefix) {
+			labels[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return labels
+}
+
+// SetVolumeLabels replaces the labels of a volume
+func (m *MetadataStore) SetVolumeLabels(name string, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.deleteVolumeLabels(name)
+	if len(labels) == 0 {
+		return
+	}
+
+	entry := m.data[volumeMetadataID]
+	if entry == nil {
+		entry = make(map[string]string)
+		m.data[volumeMetadataID] = entry
+	}
+	for k, v := range labels {
+		entry[name+"/"+k] = v
 	}
-	return 0
+
+	m.markDirty(volumeMetadataID)
 }
 
-// saveAsync saves metadata asynchronously
-func (m *MetadataStore) saveAsync() {
+// DeleteVolumeLabels removes the labels of a volume
+func (m *MetadataStore) DeleteVolumeLabels(name string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	filePath := filepath.Join(m.path, "containers.json")
+	m.deleteVolumeLabels(name)
+}
+
+// deleteVolumeLabels removes the labels of a volume, the caller must hold m.mu
+func (m *MetadataStore) deleteVolumeLabels(name string) {
+	entry := m.data[volumeMetadataID]
+	prefix := name + "/"
+	for k := range entry {
+		if strings.HasPrefix(k, prefix) {
+			delete(entry, k)
+		}
+	}
+	if entry != nil && len(entry) == 0 {
+		delete(m.data, volumeMetadataID)
+	}
+	m.markDirty(volumeMetadataID)
+}
+
+// saveDebounce is the quiet period after the last change before metadata is flushed
+const saveDebounce = 500 * time.Millisecond
+
+// markDirty schedules a flush of a changed container, coalescing rapid changes
+func (m *MetadataStore) markDirty(vmid int) {
+	m.dirtyMu.Lock()
+	defer m.dirtyMu.Unlock()
+
+	if m.dirty == nil {
+		m.dirty = make(map[int]bool)
+	}
+	m.dirty[vmid] = true
+
+	if m.saveTimer != nil {
+		m.saveTimer.Stop()
+	}
+	m.saveTimer = time.AfterFunc(saveDebounce, m.flush)
+}
+
+// flush writes the dirty containers to the backend
+func (m *MetadataStore) flush() {
+	m.dirtyMu.Lock()
+	dirty := m.dirty
+	m.dirty = nil
+	m.dirtyMu.Unlock()
 
-	data, err := json.MarshalIndent(m.data, "", "  ")
+	if len(dirty) == 0 {
+		return
+	}
+
+	// A batch backend writes everything at once, cheaper than one write per container
+	if _, ok := m.backend.(batchBackend); ok && len(dirty) > 1 {
+		_ = m.Save()
+		return
+	}
+
+	for vmid := range dirty {
+		_ = m.persist(vmid)
+	}
+}
+
+// Close stops watching the backend and synchronously flushes pending changes,
+// deletes included. A batch backend is saved as a whole; other backends only get
+// the dirty containers, so entries changed by other instances are not overwritten
+func (m *MetadataStore) Close() error {
+	m.dirtyMu.Lock()
+	if m.saveTimer != nil {
+		m.saveTimer.Stop()
+		m.saveTimer = nil
+	}
+	dirty := m.dirty
+	m.dirty = nil
+	m.dirtyMu.Unlock()
+
+	m.mu.Lock()
+	if m.cancelWatch != nil {
+		m.cancelWatch()
+		m.cancelWatch = nil
+	}
+	m.mu.Unlock()
+
+	if _, ok := m.backend.(batchBackend); ok {
+		return m.Save()
+	}
+	for vmid := range dirty {
+		if err := m.persist(vmid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// persist writes the current labels of a container to the backend, deleting
+// the container when it has none
+func (m *MetadataStore) persist(vmid int) error {
+	// Serialize persists so an older state never overwrites a newer one
+	m.persistMu.Lock()
+	defer m.persistMu.Unlock()
+
+	m.mu.RLock()
+	labels, ok := m.data[vmid]
+	encoded, err := m.encodeLabels(labels)
+	loadErr := m.loadErr
+	m.mu.RUnlock()
+
+	if loadErr != nil {
+		return fmt.Errorf("metadata not saved, it failed to load: %w", loadErr)
+	}
 	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return m.backend.Delete(vmid)
+	}
+	return m.backend.Set(vmid, encoded)
+}
+
+// applyRemote applies a change made by another instance sharing the backend
+func (m *MetadataStore) applyRemote(vmid int, labels map[string]string) {
+	if err := m.decodeLabels(labels); err != nil {
 		return
 	}
 
-	_ = os.WriteFile(filePath, data, 0644)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.unindex(vmid)
+	if labels == nil {
+		delete(m.data, vmid)
+		return
+	}
+	m.data[vmid] = labels
+	m.reindex(vmid)
 }
 
 // VMIDMapping stores mapping between container names and VMIDs
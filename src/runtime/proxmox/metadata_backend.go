@@ -0,0 +1,253 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Metadata persistence backends
+// MetadataStore keeps an in-memory copy of every container's labels and
+// writes changes through to a MetadataBackend. The default backend is a local
+// JSON file; a shared backend lets several Cosmos instances manage the same
+// cluster, using Watch to pick up changes made by the other instances
+
+// Backends selectable by name, see NewMetadataBackend
+const (
+	MetadataBackendFile   = "file"
+	MetadataBackendMemory = "memory"
+
+	defaultMetadataPath = "/var/lib/cosmos/proxmox-metadata"
+)
+
+// NewMetadataBackend returns the backend named kind: "file" (the default) keeps
+// containers.json in path (defaultMetadataPath when empty), "memory" keeps
+// nothing across restarts
+func NewMetadataBackend(kind, path string) (MetadataBackend, error) {
+	switch kind {
+	case "", MetadataBackendFile:
+		if path == "" {
+			path = defaultMetadataPath
+		}
+		return NewFileBackend(path), nil
+	case MetadataBackendMemory:
+		return NewMemoryBackend(), nil
+	}
+	return nil, fmt.Errorf("unknown metadata backend %q, use %s or %s", kind, MetadataBackendFile, MetadataBackendMemory)
+}
+
+// MetadataBackend persists container labels
+type MetadataBackend interface {
+	Get(vmid int) (map[string]string, error)
+	Set(vmid int, labels map[string]string) error
+	Delete(vmid int) error
+	List() (map[int]map[string]string, error)
+	// Watch calls fn for every change made outside this process (nil labels on delete)
+	// until the returned cancel function is called
+	Watch(fn func(vmid int, labels map[string]string)) (cancel func())
+}
+
+// batchBackend is implemented by backends able to replace all entries at once
+type batchBackend interface {
+	SetAll(data map[int]map[string]string) error
+}
+
+// FileBackend stores metadata in a containers.json file
+type FileBackend struct {
+	path string
+	data map[int]map[string]string
+	mu   sync.Mutex
+}
+
+// NewFileBackend creates a JSON file backend in the given directory
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{
+		path: path,
+		data: make(map[int]map[string]string),
+	}
+}
+
+// Get returns the labels of a container, nil if unknown
+func (f *FileBackend) Get(vmid int) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return copyLabels(f.data[vmid]), nil
+}
+
+// Set stores the labels of a container and writes the file
+func (f *FileBackend) Set(vmid int, labels map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.data[vmid] = copyLabels(labels)
+	return f.write()
+}
+
+// SetAll replaces every entry and writes the file once
+func (f *FileBackend) SetAll(data map[int]map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.data = make(map[int]map[string]string, len(data))
+	for vmid, labels := range data {
+		f.data[vmid] = copyLabels(labels)
+	}
+	return f.write()
+}
+
+// Delete removes a container and writes the file
+func (f *FileBackend) Delete(vmid int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.data, vmid)
+	return f.write()
+}
+
+// List reads every entry from disk
+func (f *FileBackend) List() (map[int]map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	filePath := filepath.Join(f.path, "containers.json")
+
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(f.path, 0755); err != nil {
+		return nil, err
+	}
+
+	data := make(map[int]map[string]string)
+
+	raw, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		f.data = data
+		return make(map[int]map[string]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	f.data = data
+
+	result := make(map[int]map[string]string, len(data))
+	for vmid, labels := range data {
+		result[vmid] = copyLabels(labels)
+	}
+	return result, nil
+}
+
+// Watch is a no-op: the file is owned by a single Cosmos instance
+func (f *FileBackend) Watch(fn func(vmid int, labels map[string]string)) func() {
+	return func() {}
+}
+
+// write saves the file, the caller must hold f.mu
+func (f *FileBackend) write() error {
+	if err := os.MkdirAll(f.path, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(f.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(f.path, "containers.json"), data, 0644)
+}
+
+// MemoryBackend keeps metadata in memory. Every instance sharing it sees the
+// changes of the others through Watch, which makes it useful for tests and as
+// a reference for shared backends
+type MemoryBackend struct {
+	data     map[int]map[string]string
+	watchers map[int]func(vmid int, labels map[string]string)
+	nextID   int
+	mu       sync.Mutex
+}
+
+// NewMemoryBackend creates an empty in-memory backend
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		data:     make(map[int]map[string]string),
+		watchers: make(map[int]func(vmid int, labels map[string]string)),
+	}
+}
+
+// Get returns the labels of a container, nil if unknown
+func (b *MemoryBackend) Get(vmid int) (map[string]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return copyLabels(b.data[vmid]), nil
+}
+
+// Set stores the labels of a container
+func (b *MemoryBackend) Set(vmid int, labels map[string]string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data[vmid] = copyLabels(labels)
+	b.notify(vmid, labels)
+	return nil
+}
+
+// Delete removes a container
+func (b *MemoryBackend) Delete(vmid int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.data, vmid)
+	b.notify(vmid, nil)
+	return nil
+}
+
+// List returns every entry
+func (b *MemoryBackend) List() (map[int]map[string]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := make(map[int]map[string]string, len(b.data))
+	for vmid, labels := range b.data {
+		result[vmid] = copyLabels(labels)
+	}
+	return result, nil
+}
+
+// Watch registers fn to be called on every change
+func (b *MemoryBackend) Watch(fn func(vmid int, labels map[string]string)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextID
+	b.nextID++
+	b.watchers[id] = fn
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.watchers, id)
+	}
+}
+
+// notify calls the watchers asynchronously, the caller must hold b.mu
+func (b *MemoryBackend) notify(vmid int, labels map[string]string) {
+	for _, fn := range b.watchers {
+		go fn(vmid, copyLabels(labels))
+	}
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	result := make(map[string]string, len(labels))
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
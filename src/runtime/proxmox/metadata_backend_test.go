@@ -0,0 +1,187 @@
+package proxmox
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapBackend is a minimal MetadataBackend, without batch writes nor watching,
+// showing the store only relies on the interface
+type mapBackend struct {
+	mu   sync.Mutex
+	data map[int]map[string]string
+}
+
+func (b *mapBackend) Get(vmid int) (map[string]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return copyLabels(b.data[vmid]), nil
+}
+
+func (b *mapBackend) Set(vmid int, labels map[string]string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.data == nil {
+		b.data = make(map[int]map[string]string)
+	}
+	b.data[vmid] = copyLabels(labels)
+	return nil
+}
+
+func (b *mapBackend) Delete(vmid int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.data, vmid)
+	return nil
+}
+
+func (b *mapBackend) List() (map[int]map[string]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	result := make(map[int]map[string]string, len(b.data))
+	for vmid, labels := range b.data {
+		result[vmid] = copyLabels(labels)
+	}
+	return result, nil
+}
+
+func (b *mapBackend) Watch(func(vmid int, labels map[string]string)) func() {
+	return func() {}
+}
+
+func TestMetadataBackends(t *testing.T) {
+	backends := []struct {
+		name string
+		new  func(t *testing.T) MetadataBackend
+	}{
+		{"file", func(t *testing.T) MetadataBackend { return NewFileBackend(t.TempDir()) }},
+		{"memory", func(*testing.T) MetadataBackend { return NewMemoryBackend() }},
+		{"map", func(*testing.T) MetadataBackend { return &mapBackend{} }},
+	}
+
+	for _, bt := range backends {
+		t.Run(bt.name, func(t *testing.T) {
+			backend := bt.new(t)
+			store := newTestStore(backend, "key")
+			if err := store.Load(); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+
+			store.Set(100, map[string]string{"cosmos-name": "web", "app": "shop"})
+			store.Set(101, map[string]string{"cosmos-name": "db", "app": "shop"})
+			store.Set(102, map[string]string{"cosmos-name": "old"})
+			store.SetLabel(100, "cosmos-env.TOKEN", "secret")
+			if err := store.UpdateLabels(101, map[string]string{"tier": "back"}, []string{"app"}); err != nil {
+				t.Fatalf("UpdateLabels: %v", err)
+			}
+			if err := store.Save(); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			// Deleted before Close, without a Save in between
+			store.Delete(102)
+			if err := store.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			reloaded := newTestStore(backend, "key")
+			if err := reloaded.Load(); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			defer reloaded.Close()
+
+			want := map[int]map[string]string{
+				100: {"cosmos-name": "web", "app": "shop", "cosmos-env.TOKEN": "secret"},
+				101: {"cosmos-name": "db", "tier": "back"},
+			}
+			for vmid, labels := range want {
+				if got := reloaded.Get(vmid); !reflect.DeepEqual(got, labels) {
+					t.Errorf("labels of %d = %v, want %v", vmid, got, labels)
+				}
+			}
+			if reloaded.Get(102) != nil {
+				t.Error("deleted container 102 is back after a reload")
+			}
+			if vmid := reloaded.FindByName("db"); vmid != 101 {
+				t.Errorf("FindByName(db) = %d, want 101", vmid)
+			}
+			matches := reloaded.FindByLabel("app", "shop")
+			sort.Ints(matches)
+			if !reflect.DeepEqual(matches, []int{100}) {
+				t.Errorf("FindByLabel(app=shop) = %v, want [100]", matches)
+			}
+
+			stored, err := backend.List()
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if value := stored[100]["cosmos-env.TOKEN"]; value == "secret" {
+				t.Error("secret label stored in plaintext")
+			}
+		})
+	}
+}
+
+func TestMemoryBackendWatch(t *testing.T) {
+	backend := NewMemoryBackend()
+	first := newTestStore(backend, "")
+	second := newTestStore(backend, "")
+	for _, store := range []*MetadataStore{first, second} {
+		if err := store.Load(); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		defer store.Close()
+	}
+
+	first.Set(100, map[string]string{"cosmos-name": "web"})
+	if err := first.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	waitFor(t, "the change to reach the other store", func() bool { return second.FindByName("web") == 100 })
+
+	first.Delete(100)
+	if err := first.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	waitFor(t, "the delete to reach the other store", func() bool { return second.Get(100) == nil })
+}
+
+func TestNewMetadataBackend(t *testing.T) {
+	tests := []struct {
+		kind    string
+		want    interface{}
+		wantErr bool
+	}{
+		{"", &FileBackend{}, false},
+		{MetadataBackendFile, &FileBackend{}, false},
+		{MetadataBackendMemory, &MemoryBackend{}, false},
+		{"etcd", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			backend, err := NewMetadataBackend(tt.kind, t.TempDir())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewMetadataBackend error = %v, want error: %v", err, tt.wantErr)
+			}
+			if tt.want != nil && reflect.TypeOf(backend) != reflect.TypeOf(tt.want) {
+				t.Errorf("NewMetadataBackend(%q) = %T, want %T", tt.kind, backend, tt.want)
+			}
+		})
+	}
+}
+
+// waitFor polls cond for up to 2 seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,125 @@
+package proxmox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Encryption at rest for sensitive metadata values
+// Labels whose key starts with one of sensitivePrefixes are encrypted with
+// AES-GCM before being written to containers.json and decrypted on Load.
+// Other labels stay in plaintext. In memory, values are always plaintext
+
+const encryptedPrefix = "enc:v1:"
+
+// sensitivePrefixes marks the label keys holding secrets
+var sensitivePrefixes = []string{"cosmos-env.", "cosmos-secret."}
+
+// ErrMetadataDecrypt is returned when stored values cannot be decrypted, usually because of a wrong key
+var ErrMetadataDecrypt = errors.New("failed to decrypt metadata (wrong metadata key?)")
+
+// IsSensitiveLabel reports whether a label is encrypted at rest
+func IsSensitiveLabel(key string) bool {
+	for _, prefix := range sensitivePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// deriveMetadataKey turns the configured secret into an AES-256 key
+func deriveMetadataKey(secret string) []byte {
+	if secret == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// encodeLabels returns a copy of labels with sensitive values encrypted
+func (m *MetadataStore) encodeLabels(labels map[string]string) (map[string]string, error) {
+	encoded := copyLabels(labels)
+	if m.key == nil {
+		return encoded, nil
+	}
+
+	for k, v := range encoded {
+		if !IsSensitiveLabel(k) {
+			continue
+		}
+		enc, err := encryptValue(m.key, v)
+		if err != nil {
+			return nil, err
+		}
+		encoded[k] = enc
+	}
+	return encoded, nil
+}
+
+// decodeLabels decrypts sensitive values in place after they are read from the backend
+func (m *MetadataStore) decodeLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if !strings.HasPrefix(v, encryptedPrefix) {
+			continue
+		}
+		if m.key == nil {
+			return fmt.Errorf("%w: metadata contains encrypted values but no key is configured", ErrMetadataDecrypt)
+		}
+		dec, err := decryptValue(m.key, v)
+		if err != nil {
+			return err
+		}
+		labels[k] = dec
+	}
+	return nil
+}
+
+func encryptValue(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func decryptValue(key []byte, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", ErrMetadataDecrypt
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrMetadataDecrypt
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,102 @@
+package proxmox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetadataEncryptionAtRest(t *testing.T) {
+	dir := t.TempDir()
+	store := newTestStore(NewFileBackend(dir), "first-key")
+	store.Set(100, map[string]string{
+		"cosmos-name":            "app",
+		"cosmos-env.DB_PASSWORD": "hunter2",
+		LabelProvisioning:        `{"user":"deploy"}`,
+	})
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	file := filepath.Join(dir, "containers.json")
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter2", "deploy"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("%q is stored in plaintext: %s", secret, raw)
+		}
+	}
+	if !strings.Contains(string(raw), encryptedPrefix) || !strings.Contains(string(raw), `"app"`) {
+		t.Errorf("expected encrypted secrets and a plaintext name: %s", raw)
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{"same key", "first-key", false},
+		{"wrong key", "other-key", true},
+		{"no key", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reloaded := newTestStore(NewFileBackend(dir), tt.key)
+			err := reloaded.Load()
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Load: %v", err)
+				}
+				if got := reloaded.GetLabel(100, "cosmos-env.DB_PASSWORD"); got != "hunter2" {
+					t.Errorf("decrypted value = %q, want hunter2", got)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrMetadataDecrypt) {
+				t.Fatalf("Load error = %v, want ErrMetadataDecrypt", err)
+			}
+			// The store must not overwrite what it could not read
+			reloaded.Set(101, map[string]string{"cosmos-name": "other"})
+			if err := reloaded.Save(); err == nil {
+				t.Error("Save succeeded after a failed Load")
+			}
+			if after, _ := os.ReadFile(file); string(after) != string(raw) {
+				t.Error("the metadata file was rewritten after a failed Load")
+			}
+		})
+	}
+}
+
+func TestConnectWithWrongMetadataKey(t *testing.T) {
+	dir := t.TempDir()
+	store := newTestStore(NewFileBackend(dir), "first-key")
+	store.Set(100, map[string]string{"cosmos-name": "app", "cosmos-env.TOKEN": "abc"})
+	if err := store.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	cluster := newFakeCluster(t)
+	config := cluster.testConfig()
+	config.MetadataBackend = NewFileBackend(dir)
+	config.MetadataKey = "other-key"
+
+	p, err := New(config)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer p.Close()
+
+	if err := p.Connect(); !errors.Is(err, ErrMetadataDecrypt) {
+		t.Fatalf("Connect error = %v, want ErrMetadataDecrypt", err)
+	}
+	if p.IsConnected() {
+		t.Error("runtime is connected without its metadata")
+	}
+}
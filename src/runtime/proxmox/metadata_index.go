@@ -0,0 +1,64 @@
+package proxmox
+
+// Label index of the metadata store
+// FindByLabel and FindByName run for every container of a List, so the store
+// keeps a (label key, value) -> VMIDs index next to the labels. Every change
+// goes through unindex/reindex around the edit, which costs the labels of one
+// container per write but makes lookups independent of the store size.
+// All of these must be called with m.mu held for writing
+
+// rebuildIndex recomputes the index from the labels
+func (m *MetadataStore) rebuildIndex() {
+	m.index = make(map[string]map[string]map[int]bool)
+	for vmid := range m.data {
+		m.reindex(vmid)
+	}
+}
+
+// unindex removes a container from the index, before its labels change
+func (m *MetadataStore) unindex(vmid int) {
+	if vmid == volumeMetadataID {
+		return
+	}
+	for key, value := range m.data[vmid] {
+		values := m.index[key]
+		delete(values[value], vmid)
+		if len(values[value]) == 0 {
+			delete(values, value)
+		}
+		if len(values) == 0 {
+			delete(m.index, key)
+		}
+	}
+}
+
+// reindex adds a container to the index, after its labels changed
+func (m *MetadataStore) reindex(vmid int) {
+	if vmid == volumeMetadataID {
+		return
+	}
+	if m.index == nil {
+		m.index = make(map[string]map[string]map[int]bool)
+	}
+	for key, value := range m.data[vmid] {
+		values := m.index[key]
+		if values == nil {
+			values = make(map[string]map[int]bool)
+			m.index[key] = values
+		}
+		if values[value] == nil {
+			values[value] = make(map[int]bool)
+		}
+		values[value][vmid] = true
+	}
+}
+
+// indexed returns the containers whose key label is value
+func (m *MetadataStore) indexed(key, value string) []int {
+	vmids := m.index[key][value]
+	results := make([]int, 0, len(vmids))
+	for vmid := range vmids {
+		results = append(results, vmid)
+	}
+	return results
+}
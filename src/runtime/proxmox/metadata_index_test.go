@@ -0,0 +1,116 @@
+package proxmox
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestMetadataIndex(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(m *MetadataStore)
+	}{
+		{"set", func(m *MetadataStore) {
+			m.Set(1, map[string]string{"cosmos-name": "web", "cosmos-stack": "shop"})
+			m.Set(2, map[string]string{"cosmos-name": "db", "cosmos-stack": "shop"})
+		}},
+		{"set replaces the labels", func(m *MetadataStore) {
+			m.Set(1, map[string]string{"cosmos-name": "web", "cosmos-stack": "shop"})
+			m.Set(1, map[string]string{"cosmos-name": "api"})
+		}},
+		{"set label", func(m *MetadataStore) {
+			m.Set(1, map[string]string{"cosmos-name": "web", "cosmos-stack": "shop"})
+			m.SetLabel(1, "cosmos-stack", "blog")
+			m.SetLabel(2, "cosmos-stack", "blog")
+		}},
+		{"delete", func(m *MetadataStore) {
+			m.Set(1, map[string]string{"cosmos-name": "web", "cosmos-stack": "shop"})
+			m.Set(2, map[string]string{"cosmos-name": "db", "cosmos-stack": "shop"})
+			m.Delete(1)
+		}},
+		{"caller map changed after set", func(m *MetadataStore) {
+			labels := map[string]string{"cosmos-name": "web"}
+			m.Set(1, labels)
+			labels["cosmos-name"] = "api"
+		}},
+		{"volume labels are not indexed", func(m *MetadataStore) {
+			m.SetVolumeLabels("data", map[string]string{"cosmos-name": "web"})
+			m.Set(1, map[string]string{"cosmos-name": "web"})
+		}},
+		{"load", func(m *MetadataStore) {
+			m.backend.Set(3, map[string]string{"cosmos-name": "web", "cosmos-stack": "shop"})
+			m.backend.Set(4, map[string]string{"cosmos-name": "db"})
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+		}},
+	}
+
+	queries := [][2]string{
+		{"cosmos-name", "web"}, {"cosmos-name", "api"}, {"cosmos-name", "db"},
+		{"cosmos-stack", "shop"}, {"cosmos-stack", "blog"}, {"cosmos-stack", ""}, {"missing", "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestStore(NewMemoryBackend(), "")
+			defer m.Close()
+			tt.change(m)
+
+			for _, q := range queries {
+				got, want := m.FindByLabel(q[0], q[1]), scanByLabel(m, q[0], q[1])
+				sort.Ints(got)
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("FindByLabel(%s, %q) = %v, want %v", q[0], q[1], got, want)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFindByLabel(b *testing.B) {
+	for _, size := range []int{1000, 5000} {
+		m := newTestStore(NewMemoryBackend(), "")
+		for vmid := 100; vmid < 100+size; vmid++ {
+			m.Set(vmid, map[string]string{
+				"cosmos-name":    fmt.Sprintf("app%d", vmid),
+				"cosmos-stack":   fmt.Sprintf("stack%d", vmid%50),
+				LabelManaged:     "true",
+				"cosmos-network": "vmbr0",
+			})
+		}
+		name := fmt.Sprintf("app%d", 100+size/2)
+
+		b.Run(fmt.Sprintf("index/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if len(m.FindByLabel("cosmos-name", name)) != 1 {
+					b.Fatal("container not found")
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("scan/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if len(scanByLabel(m, "cosmos-name", name)) != 1 {
+					b.Fatal("container not found")
+				}
+			}
+		})
+		m.Close()
+	}
+}
+
+// scanByLabel is FindByLabel without the index, sorted
+func scanByLabel(m *MetadataStore, key, value string) []int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var results []int
+	for vmid, labels := range m.data {
+		if vmid != volumeMetadataID && labels[key] == value {
+			results = append(results, vmid)
+		}
+	}
+	sort.Ints(results)
+	return results
+}
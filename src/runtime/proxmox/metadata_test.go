@@ -0,0 +1,107 @@
+package proxmox
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingBackend counts the writes reaching a backend
+type countingBackend struct {
+	MetadataBackend
+	writes atomic.Int32
+}
+
+func (b *countingBackend) Set(vmid int, labels map[string]string) error {
+	b.writes.Add(1)
+	return b.MetadataBackend.Set(vmid, labels)
+}
+
+func (b *countingBackend) Delete(vmid int) error {
+	b.writes.Add(1)
+	return b.MetadataBackend.Delete(vmid)
+}
+
+// countingBatchBackend also counts batch writes
+type countingBatchBackend struct {
+	*countingBackend
+}
+
+func (b countingBatchBackend) SetAll(data map[int]map[string]string) error {
+	b.writes.Add(1)
+	return b.MetadataBackend.(batchBackend).SetAll(data)
+}
+
+func TestMetadataDebouncedSaves(t *testing.T) {
+	const writes = 200
+	vmids := []int{100, 101, 102, 103}
+
+	tests := []struct {
+		name       string
+		batch      bool
+		close      bool // Close instead of waiting for the debounced flush
+		wantWrites int32
+	}{
+		{"per container", false, false, int32(len(vmids))},
+		{"batch", true, false, 1},
+		{"per container flushed by Close", false, true, int32(len(vmids))},
+		{"batch flushed by Close", true, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counting := &countingBackend{MetadataBackend: NewFileBackend(t.TempDir())}
+			var backend MetadataBackend = counting
+			if tt.batch {
+				backend = countingBatchBackend{counting}
+			}
+			store := newTestStore(backend, "")
+			if err := store.Load(); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+
+			for i := 0; i < writes; i++ {
+				store.SetLabel(vmids[i%len(vmids)], "revision", itoa(i))
+			}
+
+			if tt.close {
+				if err := store.Close(); err != nil {
+					t.Fatalf("Close: %v", err)
+				}
+			} else {
+				time.Sleep(saveDebounce + 200*time.Millisecond)
+			}
+
+			if n := counting.writes.Load(); n != tt.wantWrites {
+				t.Errorf("%d changes caused %d writes, want %d", writes, n, tt.wantWrites)
+			}
+
+			stored, err := counting.List()
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			for i, vmid := range vmids {
+				if got, want := stored[vmid]["revision"], itoa(writes-len(vmids)+i); got != want {
+					t.Errorf("container %d revision = %s, want %s", vmid, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMetadataDebounceWaitsForQuiet(t *testing.T) {
+	counting := &countingBackend{MetadataBackend: NewMemoryBackend()}
+	store := newTestStore(counting, "")
+
+	// Changes closer than saveDebounce keep postponing the flush
+	deadline := time.Now().Add(2 * saveDebounce)
+	for i := 0; time.Now().Before(deadline); i++ {
+		store.SetLabel(100, "revision", itoa(i))
+		time.Sleep(saveDebounce / 10)
+	}
+	if n := counting.writes.Load(); n != 0 {
+		t.Errorf("%d writes while changes kept coming", n)
+	}
+
+	waitFor(t, "the debounced flush", func() bool { return counting.writes.Load() == 1 })
+}
@@ -0,0 +1,273 @@
+package proxmox
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Runtime metrics
+// Config.Metrics receives a counter increment and a duration observation for
+// every API request attempt, every task wait and every lifecycle operation
+// (create, start, stop, restart, remove), labelled with the operation and its
+// outcome. API paths are reduced to their route ("/nodes/{node}/lxc/{id}/...")
+// to keep the number of series bounded. Task waits are tracked apart from API
+// requests, a create is one POST but a task of several minutes.
+// PrometheusMetrics keeps them in memory and serves the text exposition format
+
+// Metric names
+const (
+	MetricAPIRequests        = "proxmox_api_requests_total"
+	MetricAPIRequestDuration = "proxmox_api_request_duration_seconds"
+	MetricTaskWaits          = "proxmox_task_waits_total"
+	MetricTaskWaitDuration   = "proxmox_task_wait_duration_seconds"
+	MetricOperations         = "proxmox_operations_total"
+	MetricOperationDuration  = "proxmox_operation_duration_seconds"
+)
+
+// Outcome label values
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// Metrics receives the runtime metrics, it must be safe for concurrent use
+type Metrics interface {
+	// IncCounter adds one to the counter name
+	IncCounter(name string, labels map[string]string)
+	// ObserveHistogram records value, in seconds, in the histogram name
+	ObserveHistogram(name string, value float64, labels map[string]string)
+}
+
+// NopMetrics discards every metric, it is used when Config.Metrics is nil
+type NopMetrics struct{}
+
+func (NopMetrics) IncCounter(string, map[string]string)                {}
+func (NopMetrics) ObserveHistogram(string, float64, map[string]string) {}
+
+// metrics returns the configured metrics sink
+func (p *ProxmoxRuntime) metrics() Metrics {
+	if p.config.Metrics == nil {
+		return NopMetrics{}
+	}
+	return p.config.Metrics
+}
+
+// outcome returns the outcome label of err
+func outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
+
+// observeAPIRequest records one API request attempt
+func (p *ProxmoxRuntime) observeAPIRequest(method, path string, start time.Time, err error) {
+	labels := map[string]string{"method": method, "route": apiRoute(path), "outcome": outcome(err)}
+	p.metrics().IncCounter(MetricAPIRequests, labels)
+	p.metrics().ObserveHistogram(MetricAPIRequestDuration, time.Since(start).Seconds(), labels)
+}
+
+// observeTaskWait records the wait for a task, labelled with its type (vzcreate, vzstart...)
+func (p *ProxmoxRuntime) observeTaskWait(upid string, start time.Time, err error) {
+	labels := map[string]string{"task": taskType(upid), "outcome": outcome(err)}
+	p.metrics().IncCounter(MetricTaskWaits, labels)
+	p.metrics().ObserveHistogram(MetricTaskWaitDuration, time.Since(start).Seconds(), labels)
+}
+
+// observe records a lifecycle operation
+func (p *ProxmoxRuntime) observe(operation string, start time.Time, err error) {
+	labels := map[string]string{"operation": operation, "outcome": outcome(err)}
+	p.metrics().IncCounter(MetricOperations, labels)
+	p.metrics().ObserveHistogram(MetricOperationDuration, time.Since(start).Seconds(), labels)
+}
+
+// routeParams maps path segments to the placeholder of the segment following them
+var routeParams = map[string]string{
+	"nodes":    "{node}",
+	"storage":  "{storage}",
+	"tasks":    "{upid}",
+	"snapshot": "{snapshot}",
+	"content":  "{volume}",
+	"network":  "{iface}",
+}
+
+// apiRoute reduces an API path to its route, without query string and identifiers
+func apiRoute(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	segments := strings.Split(path, "/")
+	for i := 1; i < len(segments); i++ {
+		if param, ok := routeParams[segments[i-1]]; ok && segments[i] != "" {
+			segments[i] = param
+		} else if _, err := strconv.Atoi(segments[i]); err == nil {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// taskType returns the type of a task as encoded in its UPID
+func taskType(upid string) string {
+	parts := strings.Split(upid, ":")
+	if len(parts) > 5 && parts[5] != "" {
+		return parts[5]
+	}
+	return "unknown"
+}
+
+// DefaultBuckets are the histogram buckets of PrometheusMetrics, in seconds,
+// from fast API calls to long create and backup tasks
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// PrometheusMetrics is an in-memory Metrics serving the Prometheus text
+// exposition format, mount it as the /metrics handler of a scrape target
+type PrometheusMetrics struct {
+	buckets    []float64
+	mu         sync.Mutex
+	counters   map[string]map[string]float64              // name -> labels -> value
+	histograms map[string]map[string]*prometheusHistogram // name -> labels -> histogram
+}
+
+type prometheusHistogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheusMetrics returns an empty PrometheusMetrics, nil buckets use DefaultBuckets
+func NewPrometheusMetrics(buckets []float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &PrometheusMetrics{
+		buckets:    buckets,
+		counters:   make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*prometheusHistogram),
+	}
+}
+
+// IncCounter adds one to the counter name
+func (m *PrometheusMetrics) IncCounter(name string, labels map[string]string) {
+	key := formatLabels(labels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.counters[name] == nil {
+		m.counters[name] = make(map[string]float64)
+	}
+	m.counters[name][key]++
+}
+
+// ObserveHistogram records value in the histogram name
+func (m *PrometheusMetrics) ObserveHistogram(name string, value float64, labels map[string]string) {
+	key := formatLabels(labels)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.histograms[name] == nil {
+		m.histograms[name] = make(map[string]*prometheusHistogram)
+	}
+	h := m.histograms[name][key]
+	if h == nil {
+		h = &prometheusHistogram{counts: make([]uint64, len(m.buckets))}
+		m.histograms[name][key] = h
+	}
+
+	if i := sort.SearchFloat64s(m.buckets, value); i < len(m.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+// ServeHTTP writes every metric in the Prometheus text exposition format
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes every metric in the Prometheus text exposition format
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out strings.Builder
+
+	for _, name := range sortedKeys(m.counters) {
+		fmt.Fprintf(&out, "# TYPE %s counter\n", name)
+		for _, labels := range sortedKeys(m.counters[name]) {
+			fmt.Fprintf(&out, "%s%s %s\n", name, labels, formatSample(m.counters[name][labels]))
+		}
+	}
+
+	for _, name := range sortedKeys(m.histograms) {
+		fmt.Fprintf(&out, "# TYPE %s histogram\n", name)
+		for _, labels := range sortedKeys(m.histograms[name]) {
+			h := m.histograms[name][labels]
+			var cumulative uint64
+			for i, bound := range m.buckets {
+				cumulative += h.counts[i]
+				fmt.Fprintf(&out, "%s_bucket%s %d\n", name, withLabel(labels, "le", formatSample(bound)), cumulative)
+			}
+			fmt.Fprintf(&out, "%s_bucket%s %d\n", name, withLabel(labels, "le", "+Inf"), h.count)
+			fmt.Fprintf(&out, "%s_sum%s %s\n", name, labels, formatSample(h.sum))
+			fmt.Fprintf(&out, "%s_count%s %d\n", name, labels, h.count)
+		}
+	}
+
+	n, err := io.WriteString(w, out.String())
+	return int64(n), err
+}
+
+// formatLabels renders labels as {a="1",b="2"}, sorted by name, "" when empty
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(labels))
+	for _, name := range sortedKeys(labels) {
+		parts = append(parts, name+"="+strconv.Quote(labels[name]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// withLabel adds name="value" to labels rendered by formatLabels
+func withLabel(labels, name, value string) string {
+	label := name + "=" + strconv.Quote(value)
+	if labels == "" {
+		return "{" + label + "}"
+	}
+	return strings.TrimSuffix(labels, "}") + "," + label + "}"
+}
+
+// formatSample renders a sample value as Prometheus expects it
+func formatSample(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
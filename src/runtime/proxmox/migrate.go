@@ -0,0 +1,110 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Migrating containers between Proxmox nodes
+// LXC has no live migration: a running container is either refused or, with
+// Restart, shut down, moved and started again on the target. Containers on
+// local storage (local-lvm, local zfs...) can only be moved while stopped, or
+// to a target storage with TargetStorage
+
+// MigrateOptions tune a container migration
+type MigrateOptions struct {
+	Online        bool          // request an online migration, refused by Proxmox for running containers
+	Restart       bool          // shut a running container down, move it and start it on the target
+	Timeout       time.Duration // shutdown timeout of a restart migration, 0 uses the Proxmox default
+	TargetStorage string        // storage the volumes are moved to on the target, empty keeps their storage
+}
+
+// Migrate moves a container to targetNode and waits for the migration task
+func (p *ProxmoxRuntime) Migrate(id string, targetNode string, opts MigrateOptions) error {
+	defer p.cache.invalidate()
+
+	if !p.connected {
+		return errNotConnected
+	}
+
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("invalid container ID: %s", id)
+	}
+	node := p.nodeFor(vmid)
+	if node == targetNode {
+		return nil
+	}
+
+	if _, err := p.pinnedNode(targetNode); err != nil {
+		return fmt.Errorf("cannot migrate container %s: %w", id, err)
+	}
+
+	body := map[string]interface{}{"target": targetNode}
+	if opts.Online {
+		body["online"] = 1
+	}
+	if opts.Restart {
+		body["restart"] = 1
+		if opts.Timeout > 0 {
+			body["timeout"] = int(opts.Timeout.Seconds())
+		}
+	}
+	if opts.TargetStorage != "" {
+		body["target-storage"] = opts.TargetStorage
+	}
+	encoded, _ := json.Marshal(body)
+
+	resp, err := p.apiRequest("POST", fmt.Sprintf("/nodes/%s/lxc/%d/migrate", node, vmid), strings.NewReader(string(encoded)))
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
+	if err == nil {
+		err = p.waitForTask(taskUPID(resp))
+	}
+	if err != nil {
+		if needsOfflineMigration(err) {
+			return fmt.Errorf("container %s cannot be migrated while running (local storage or no live migration), stop it first or migrate with Restart: %w", id, err)
+		}
+		return fmt.Errorf("failed to migrate container %s to %s: %w", id, targetNode, err)
+	}
+
+	if p.metadata.Get(vmid) != nil {
+		p.metadata.SetLabel(vmid, LabelNode, targetNode)
+	}
+	p.recordChange(vmid, "migrate", []runtime.FieldChange{{Field: "Node", Old: node, New: targetNode}})
+
+	utils.Log(fmt.Sprintf("Migrated LXC container VMID %d from %s to %s", vmid, node, targetNode))
+	return nil
+}
+
+// needsOfflineMigration reports whether a migration failed because the container
+// is running, possibly on storage the target node cannot reach
+func needsOfflineMigration(err error) bool {
+	var message string
+	var apiErr *APIError
+	var taskErr *TaskError
+	switch {
+	case errors.As(err, &apiErr):
+		message = apiErr.Body
+	case errors.As(err, &taskErr):
+		message = taskErr.Error()
+	default:
+		return false
+	}
+
+	message = strings.ToLower(message)
+	for _, marker := range []string{"local disk", "local volume", "is local", "lxc live migration", "use online migration", "not available on node"} {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
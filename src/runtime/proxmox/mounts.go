@@ -0,0 +1,219 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Container mounts
+// ContainerConfig.Volumes become mount points (mp0, mp1...) by type:
+//   - bind mounts a host path as is
+//   - volume mounts a named volume (see CreateVolume) or a Proxmox volume ID.
+//     Without a source a new disk of Size is allocated in Storage (defaults to
+//     the rootfs storage); it belongs to the container and is freed by Remove
+//   - tmpfs has no mount point equivalent in Proxmox: the mounts are kept in
+//     the cosmos-tmpfs label and mounted inside the container at every Start.
+//     Consistency holds the size hint ("64m", "size=1g", "10%"), without it
+//     the kernel default (half of the memory) applies
+// A tmpfs that cannot be mounted (e.g. no exec access) is skipped with a warning
+
+const (
+	// LabelTmpfs holds the tmpfs mounts of a container as "target:options;..."
+	LabelTmpfs = "cosmos-tmpfs"
+
+	// LabelAllocatedVolumes holds the volume IDs allocated for the mount points of a container
+	LabelAllocatedVolumes = "cosmos-allocated-volumes"
+)
+
+var tmpfsSizePattern = regexp.MustCompile(`^\d+[kKmMgG%]?$`)
+
+// mountPoints returns the mpN values of the bind and volume mounts of config.
+// Offline, named volumes are kept by name instead of being resolved to their volume ID
+func (p *ProxmoxRuntime) mountPoints(config runtime.ContainerConfig, offline bool) ([]string, error) {
+	var mps []string
+	for _, vol := range config.Volumes {
+		source := vol.Source
+		switch vol.Type {
+		case runtime.MountTypeTmpfs:
+			continue
+		case runtime.MountTypeVolume:
+			if vol.Source == "" {
+				if vol.Size <= 0 {
+					return nil, fmt.Errorf("volume mount %s needs a source or a size", vol.Target)
+				}
+				storage := vol.Storage
+				if storage == "" {
+					storage = p.rootfsStorage(config)
+				}
+				source = fmt.Sprintf("%s:%d", storage, rootfsSizeGB(vol.Size))
+				break
+			}
+			if offline {
+				break
+			}
+			volid, err := p.resolveVolume(vol.Source)
+			if err != nil {
+				return nil, err
+			}
+			source = volid
+		case runtime.MountTypeBind, "":
+		default:
+			return nil, fmt.Errorf("unsupported mount type %q for %s", vol.Type, vol.Target)
+		}
+
+		mp := fmt.Sprintf("%s,mp=%s", source, vol.Target)
+		if vol.ReadOnly {
+			mp += ",ro=1"
+		}
+		if vol.Backup != nil && vol.Type == runtime.MountTypeVolume {
+			if *vol.Backup {
+				mp += ",backup=1"
+			} else {
+				mp += ",backup=0"
+			}
+		}
+		mps = append(mps, mp)
+	}
+	return mps, nil
+}
+
+// resolveVolume returns the Proxmox volume ID of a named volume. Sources that
+// already are volume IDs (storage:volume) are used as is
+func (p *ProxmoxRuntime) resolveVolume(source string) (string, error) {
+	if strings.Contains(source, ":") {
+		return source, nil
+	}
+
+	volumes, err := p.ListVolumes()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve volume %s: %w", source, err)
+	}
+	for _, volume := range volumes {
+		if volume.Name == source {
+			return volume.Labels[LabelVolumeID], nil
+		}
+	}
+	return "", fmt.Errorf("volume %s not found in storage %s", source, p.config.Storage)
+}
+
+// tmpfsMounts validates the tmpfs mounts of volumes and returns them as the LabelTmpfs value
+func tmpfsMounts(volumes []runtime.VolumeMount) (string, error) {
+	var entries []string
+	for _, vol := range volumes {
+		if vol.Type != runtime.MountTypeTmpfs {
+			continue
+		}
+		if !strings.HasPrefix(vol.Target, "/") || strings.ContainsAny(vol.Target, ":; \t\n") {
+			return "", fmt.Errorf("invalid tmpfs target %q", vol.Target)
+		}
+
+		options := []string{"mode=1777"}
+		if size := strings.TrimPrefix(vol.Consistency, "size="); size != "" {
+			if !tmpfsSizePattern.MatchString(size) {
+				return "", fmt.Errorf("invalid size %q for tmpfs %s (e.g. 64m, 1g or 10%%)", vol.Consistency, vol.Target)
+			}
+			options = append(options, "size="+size)
+		}
+		if vol.ReadOnly {
+			options = append(options, "ro")
+		}
+
+		entries = append(entries, vol.Target+":"+strings.Join(options, ","))
+	}
+	return strings.Join(entries, ";"), nil
+}
+
+// storeTmpfs replaces the tmpfs mounts of a container
+func (p *ProxmoxRuntime) storeTmpfs(vmid int, mounts string) {
+	if mounts == "" {
+		if p.metadata.GetLabel(vmid, LabelTmpfs) != "" {
+			_ = p.metadata.UpdateLabels(vmid, nil, []string{LabelTmpfs})
+		}
+		return
+	}
+	p.metadata.SetLabel(vmid, LabelTmpfs, mounts)
+}
+
+// applyTmpfs mounts the tmpfs mounts of a started container
+func (p *ProxmoxRuntime) applyTmpfs(vmid int) {
+	mounts := p.metadata.GetLabel(vmid, LabelTmpfs)
+	if mounts == "" {
+		return
+	}
+
+	for _, entry := range strings.Split(mounts, ";") {
+		target, options, _ := strings.Cut(entry, ":")
+		script := fmt.Sprintf("mkdir -p %[1]s && (mountpoint -q %[1]s || mount -t tmpfs -o %[2]s tmpfs %[1]s)", shellQuote(target), shellQuote(options))
+
+		result, err := p.Exec(fmt.Sprint(vmid), []string{"sh", "-c", script}, runtime.ExecOptions{})
+		if err == nil && result.ExitCode != 0 {
+			err = fmt.Errorf("exited with code %d: %s", result.ExitCode, result.Stderr)
+		}
+		if err != nil {
+			utils.Warn(fmt.Sprintf("Skipping tmpfs %s of LXC container VMID %d: %s", target, vmid, err))
+		}
+	}
+}
+
+// storeAllocatedVolumes records the volumes owned by a container in its mount points,
+// the disks allocated at creation, so that Remove can free them
+func (p *ProxmoxRuntime) storeAllocatedVolumes(vmid int) {
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/config", p.nodeFor(vmid), vmid), nil)
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Failed to read the mount points of LXC container VMID %d: %s", vmid, err))
+		return
+	}
+
+	owned := fmt.Sprintf(":vm-%d-", vmid)
+	var volids []string
+	for _, key := range configKeys(resp, "mp") {
+		value, _ := resp[key].(string)
+		volid, _, _ := strings.Cut(value, ",")
+		if strings.Contains(volid, owned) || strings.Contains(volid, fmt.Sprintf("/vm-%d-", vmid)) {
+			volids = append(volids, volid)
+		}
+	}
+
+	if len(volids) == 0 {
+		if p.metadata.GetLabel(vmid, LabelAllocatedVolumes) != "" {
+			_ = p.metadata.UpdateLabels(vmid, nil, []string{LabelAllocatedVolumes})
+		}
+		return
+	}
+	p.metadata.SetLabel(vmid, LabelAllocatedVolumes, strings.Join(volids, ","))
+}
+
+// freeAllocatedVolumes frees the allocated volumes of a removed container that
+// Proxmox left behind, e.g. when they were detached before the removal
+func (p *ProxmoxRuntime) freeAllocatedVolumes(vmid int, node, volumes string) {
+	if volumes == "" {
+		return
+	}
+
+	for _, volid := range strings.Split(volumes, ",") {
+		storage, _, _ := strings.Cut(volid, ":")
+		path := fmt.Sprintf("/nodes/%s/storage/%s/content/%s", node, storage, url.PathEscape(volid))
+
+		if _, err := p.apiRequest("GET", path, nil); err != nil {
+			if !isNotFound(err) {
+				utils.Warn(fmt.Sprintf("Failed to check volume %s of removed LXC container VMID %d: %s", volid, vmid, err))
+			}
+			continue
+		}
+
+		resp, err := p.apiRequest("DELETE", path, nil)
+		if err == nil {
+			err = p.waitForTask(taskUPID(resp))
+		}
+		if err != nil {
+			utils.Warn(fmt.Sprintf("Failed to free volume %s of removed LXC container VMID %d: %s", volid, vmid, err))
+			continue
+		}
+		utils.Log(fmt.Sprintf("Freed volume %s of removed LXC container VMID %d", volid, vmid))
+	}
+}
@@ -0,0 +1,266 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestMountTypes(t *testing.T) {
+	tests := []struct {
+		name      string
+		volume    runtime.VolumeMount
+		wantMP    string // mp0, empty when none
+		wantTmpfs string // LabelTmpfs
+		wantErr   bool
+	}{
+		{"bind", runtime.VolumeMount{Type: runtime.MountTypeBind, Source: "/srv/data", Target: "/data"}, "/srv/data,mp=/data", "", false},
+		{"bind without type", runtime.VolumeMount{Source: "/srv/data", Target: "/data"}, "/srv/data,mp=/data", "", false},
+		{"read-only bind", runtime.VolumeMount{Type: runtime.MountTypeBind, Source: "/srv/data", Target: "/data", ReadOnly: true}, "/srv/data,mp=/data,ro=1", "", false},
+		{"volume ID", runtime.VolumeMount{Type: runtime.MountTypeVolume, Source: "local-lvm:vm-999-disk-1", Target: "/data"}, "local-lvm:vm-999-disk-1,mp=/data", "", false},
+		{"named volume", runtime.VolumeMount{Type: runtime.MountTypeVolume, Source: "data", Target: "/data"}, "local-lvm:vm-999-cosmos-data,mp=/data", "", false},
+		{"unknown named volume", runtime.VolumeMount{Type: runtime.MountTypeVolume, Source: "missing", Target: "/data"}, "", "", true},
+		{"tmpfs", runtime.VolumeMount{Type: runtime.MountTypeTmpfs, Target: "/cache"}, "", "/cache:mode=1777", false},
+		{"sized tmpfs", runtime.VolumeMount{Type: runtime.MountTypeTmpfs, Target: "/cache", Consistency: "64m"}, "", "/cache:mode=1777,size=64m", false},
+		{"size option", runtime.VolumeMount{Type: runtime.MountTypeTmpfs, Target: "/cache", Consistency: "size=10%"}, "", "/cache:mode=1777,size=10%", false},
+		{"read-only tmpfs", runtime.VolumeMount{Type: runtime.MountTypeTmpfs, Target: "/cache", Consistency: "1g", ReadOnly: true}, "", "/cache:mode=1777,size=1g,ro", false},
+		{"invalid tmpfs size", runtime.VolumeMount{Type: runtime.MountTypeTmpfs, Target: "/cache", Consistency: "lots"}, "", "", true},
+		{"relative tmpfs target", runtime.VolumeMount{Type: runtime.MountTypeTmpfs, Target: "cache"}, "", "", true},
+		{"tmpfs target with a separator", runtime.VolumeMount{Type: runtime.MountTypeTmpfs, Target: "/a;b"}, "", "", true},
+		{"unsupported type", runtime.VolumeMount{Type: "npipe", Source: `\\.\pipe\docker`, Target: "/pipe"}, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			cluster.handle("GET /nodes/pve/storage/local-lvm/content", func(r *http.Request, _ map[string]interface{}) (int, interface{}) {
+				if r.URL.Query().Get("content") != "images" {
+					return http.StatusOK, []map[string]interface{}{}
+				}
+				return http.StatusOK, []map[string]interface{}{{"volid": "local-lvm:vm-999-cosmos-data", "size": 1 << 30}}
+			})
+			p := newTestRuntime(t, cluster)
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, Volumes: []runtime.VolumeMount{tt.volume}})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Create succeeded, want an error")
+				}
+				if n := cluster.lxcCount(); n != 0 {
+					t.Errorf("%d containers created", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			vmid := atoi(t, id)
+			mp0, _ := cluster.guest(vmid).Config["mp0"].(string)
+			if mp0 != tt.wantMP {
+				t.Errorf("mp0 = %q, want %q", mp0, tt.wantMP)
+			}
+			if tmpfs := p.metadata.GetLabel(vmid, LabelTmpfs); tmpfs != tt.wantTmpfs {
+				t.Errorf("%s = %q, want %q", LabelTmpfs, tmpfs, tt.wantTmpfs)
+			}
+		})
+	}
+}
+
+func TestApplyTmpfs(t *testing.T) {
+	cluster := newFakeCluster(t)
+	p := newTestRuntime(t, cluster)
+
+	id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, Volumes: []runtime.VolumeMount{
+		{Type: runtime.MountTypeTmpfs, Target: "/cache", Consistency: "64m"},
+		{Type: runtime.MountTypeTmpfs, Target: "/run/app"},
+	}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// The first mount fails, the second one is still mounted and Start succeeds
+	transport := &fakeTransport{run: func(command, stdin string) (string, string, int) {
+		if strings.Contains(command, "/cache") {
+			return "", "mount: permission denied", 32
+		}
+		return "", "", 0
+	}}
+	p.SetExecTransport(transport)
+
+	if err := p.Start(id); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	var mounts []string
+	for _, cmd := range transport.execs() {
+		if len(cmd) == 3 && cmd[0] == "sh" {
+			mounts = append(mounts, cmd[2])
+		}
+	}
+	want := []string{
+		"mkdir -p '/cache' && (mountpoint -q '/cache' || mount -t tmpfs -o 'mode=1777,size=64m' tmpfs '/cache')",
+		"mkdir -p '/run/app' && (mountpoint -q '/run/app' || mount -t tmpfs -o 'mode=1777' tmpfs '/run/app')",
+	}
+	if !reflect.DeepEqual(mounts, want) {
+		t.Errorf("mounted %q, want %q", mounts, want)
+	}
+}
+
+func TestAllocatedMountPoints(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name          string
+		volumes       []runtime.VolumeMount
+		wantMPs       []string // mount points sent on creation
+		wantAllocated string   // LabelAllocatedVolumes
+		wantErr       bool
+	}{
+		{
+			name:    "bind",
+			volumes: []runtime.VolumeMount{{Type: runtime.MountTypeBind, Source: "/srv/data", Target: "/data"}},
+			wantMPs: []string{"/srv/data,mp=/data"},
+		},
+		{
+			name:          "allocated in the rootfs storage",
+			volumes:       []runtime.VolumeMount{{Type: runtime.MountTypeVolume, Target: "/data", Size: 10 << 30}},
+			wantMPs:       []string{"local-lvm:10,mp=/data"},
+			wantAllocated: "local-lvm:vm-100-disk-1",
+		},
+		{
+			name:          "size rounded up",
+			volumes:       []runtime.VolumeMount{{Type: runtime.MountTypeVolume, Target: "/data", Size: 1<<30 + 1}},
+			wantMPs:       []string{"local-lvm:2,mp=/data"},
+			wantAllocated: "local-lvm:vm-100-disk-1",
+		},
+		{
+			name: "storage and backup flag per mount",
+			volumes: []runtime.VolumeMount{
+				{Type: runtime.MountTypeVolume, Target: "/data", Size: 10 << 30, Storage: "local-zfs", Backup: &no},
+				{Type: runtime.MountTypeVolume, Target: "/db", Size: 1 << 30, Backup: &yes, ReadOnly: true},
+			},
+			wantMPs:       []string{"local-zfs:10,mp=/data,backup=0", "local-lvm:1,mp=/db,ro=1,backup=1"},
+			wantAllocated: "local-zfs:vm-100-disk-1,local-lvm:vm-100-disk-2",
+		},
+		{
+			name: "bind and allocated",
+			volumes: []runtime.VolumeMount{
+				{Type: runtime.MountTypeBind, Source: "/srv/media", Target: "/media", Backup: &yes},
+				{Type: runtime.MountTypeVolume, Target: "/data", Size: 1 << 30},
+			},
+			wantMPs:       []string{"/srv/media,mp=/media", "local-lvm:1,mp=/data"},
+			wantAllocated: "local-lvm:vm-100-disk-2",
+		},
+		{
+			name:    "no source nor size",
+			volumes: []runtime.VolumeMount{{Type: runtime.MountTypeVolume, Target: "/data"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			disks := newFakeDisks(cluster)
+			p := newTestRuntime(t, cluster)
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, Volumes: tt.volumes})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Create succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			if !reflect.DeepEqual(disks.requested, tt.wantMPs) {
+				t.Errorf("mount points = %q, want %q", disks.requested, tt.wantMPs)
+			}
+			vmid := atoi(t, id)
+			if allocated := p.metadata.GetLabel(vmid, LabelAllocatedVolumes); allocated != tt.wantAllocated {
+				t.Errorf("%s = %q, want %q", LabelAllocatedVolumes, allocated, tt.wantAllocated)
+			}
+
+			// The fake cluster keeps the disks of removed containers, as Proxmox does with detached ones
+			if err := p.Remove(id); err != nil {
+				t.Fatalf("Remove: %v", err)
+			}
+			if left := disks.list(); len(left) != 0 {
+				t.Errorf("volumes left after Remove: %v", left)
+			}
+		})
+	}
+}
+
+// fakeDisks allocates the "storage:size" mount points of created containers
+// and serves the resulting volumes in the storage content
+type fakeDisks struct {
+	mu        sync.Mutex
+	requested []string
+	volumes   map[string]bool
+}
+
+func newFakeDisks(cluster *fakeCluster) *fakeDisks {
+	disks := &fakeDisks{volumes: make(map[string]bool)}
+
+	cluster.handle("POST /nodes/pve/lxc", func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+		disks.mu.Lock()
+		vmid := int(floatValue(body["vmid"]))
+		for i := 0; i < 10; i++ {
+			mp, ok := body["mp"+itoa(i)].(string)
+			if !ok {
+				continue
+			}
+			disks.requested = append(disks.requested, mp)
+			source, options, _ := strings.Cut(mp, ",")
+			if storage, size, ok := strings.Cut(source, ":"); ok && !strings.Contains(size, "-") {
+				volid := fmt.Sprintf("%s:vm-%d-disk-%d", storage, vmid, i+1)
+				disks.volumes[volid] = true
+				body["mp"+itoa(i)] = fmt.Sprintf("%s,%s,size=%sG", volid, options, size)
+			}
+		}
+		disks.mu.Unlock()
+		return cluster.route(r, strings.TrimPrefix(r.URL.Path, "/api2/json"), body)
+	})
+
+	for _, storage := range []string{"local-lvm", "local-zfs"} {
+		for disk := 1; disk <= 2; disk++ {
+			volid := fmt.Sprintf("%s:vm-100-disk-%d", storage, disk)
+			path := "/nodes/pve/storage/" + storage + "/content/" + volid
+			cluster.handle("GET "+path, func(*http.Request, map[string]interface{}) (int, interface{}) {
+				disks.mu.Lock()
+				defer disks.mu.Unlock()
+				if !disks.volumes[volid] {
+					return http.StatusNotFound, "volume does not exist"
+				}
+				return http.StatusOK, map[string]interface{}{"volid": volid}
+			})
+			cluster.handle("DELETE "+path, func(*http.Request, map[string]interface{}) (int, interface{}) {
+				disks.mu.Lock()
+				defer disks.mu.Unlock()
+				delete(disks.volumes, volid)
+				return http.StatusOK, nil
+			})
+		}
+	}
+	return disks
+}
+
+// list returns the allocated volumes left
+func (d *fakeDisks) list() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var volumes []string
+	for volid := range d.volumes {
+		volumes = append(volumes, volid)
+	}
+	return volumes
+}
@@ -0,0 +1,162 @@
+package proxmox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Naming templates for Proxmox containers
+// A template such as "{stack}-{service}-{n}" is expanded from the container
+// labels, {n} being the lowest index not already used by the stack members.
+// Create reserves the index in the metadata store before locking the name, so
+// concurrent creates in a stack get distinct indexes, and a retried Create of
+// a template without {n} resolves to the same name and finds its container.
+// Names are free-form, but LXC hostnames must be DNS labels, so the hostname
+// is a sanitized copy while cosmos-name keeps the name as given
+
+const maxHostnameLength = 63
+
+const (
+	LabelStack      = "cosmos-stack"
+	LabelService    = "cosmos-service"
+	LabelStackIndex = "cosmos-stack-index"
+)
+
+// resolveName applies the name template to a new container with a reserved
+// stack index. release must be called once the container labels are stored
+// or the creation failed
+func (p *ProxmoxRuntime) resolveName(config runtime.ContainerConfig) (runtime.ContainerConfig, func()) {
+	if p.config.NameTemplate == "" {
+		return config, func() {}
+	}
+	index, release := p.metadata.ReserveStackIndex(config.Labels[LabelStack])
+	return p.applyNameTemplate(config, index), release
+}
+
+// applyNameTemplate computes the container name and hostname from the configured template
+func (p *ProxmoxRuntime) applyNameTemplate(config runtime.ContainerConfig, index int) runtime.ContainerConfig {
+	if p.config.NameTemplate == "" {
+		return config
+	}
+
+	name := expandNameTemplate(p.config.NameTemplate, config, index)
+	config.Name = name
+	config.Hostname = name
+
+	// Copy labels so the caller's map is left untouched
+	labels := make(map[string]string, len(config.Labels)+1)
+	for k, v := range config.Labels {
+		labels[k] = v
+	}
+	labels[LabelStackIndex] = strconv.Itoa(index)
+	config.Labels = labels
+
+	return config
+}
+
+// expandNameTemplate replaces the {name}, {stack}, {service} and {n} placeholders
+func expandNameTemplate(tmpl string, config runtime.ContainerConfig, index int) string {
+	service := config.Labels[LabelService]
+	if service == "" {
+		service = config.Name
+	}
+
+	replacer := strings.NewReplacer(
+		"{name}", config.Name,
+		"{stack}", config.Labels[LabelStack],
+		"{service}", service,
+		"{n}", strconv.Itoa(index),
+	)
+
+	return strings.Trim(replacer.Replace(tmpl), "-")
+}
+
+// NextStackIndex returns the lowest index (starting at 1) neither used by a
+// member of the stack nor reserved
+func (m *MetadataStore) NextStackIndex(stack string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.nextStackIndex(stack)
+}
+
+// ReserveStackIndex returns the next index of the stack and keeps it from
+// other callers until release is called
+func (m *MetadataStore) ReserveStackIndex(stack string) (index int, release func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index = m.nextStackIndex(stack)
+	if m.reservedIndexes == nil {
+		m.reservedIndexes = make(map[string]map[int]bool)
+	}
+	if m.reservedIndexes[stack] == nil {
+		m.reservedIndexes[stack] = make(map[int]bool)
+	}
+	m.reservedIndexes[stack][index] = true
+
+	return index, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.reservedIndexes[stack], index)
+		if len(m.reservedIndexes[stack]) == 0 {
+			delete(m.reservedIndexes, stack)
+		}
+	}
+}
+
+// nextStackIndex is NextStackIndex, the caller must hold m.mu
+func (m *MetadataStore) nextStackIndex(stack string) int {
+	used := make(map[int]bool)
+	for n := range m.reservedIndexes[stack] {
+		used[n] = true
+	}
+	for _, labels := range m.data {
+		if labels[LabelStack] != stack {
+			continue
+		}
+		if n, err := strconv.Atoi(labels[LabelStackIndex]); err == nil {
+			used[n] = true
+		}
+	}
+
+	index := 1
+	for used[index] {
+		index++
+	}
+	return index
+}
+
+// sanitizeHostname turns a name into a DNS label: lowercase letters, digits and
+// single hyphens, at most 63 characters. It returns "" when nothing is left
+func sanitizeHostname(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			hyphen = false
+		} else if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+
+	hostname := b.String()
+	if len(hostname) > maxHostnameLength {
+		hostname = hostname[:maxHostnameLength]
+	}
+	return strings.Trim(hostname, "-")
+}
+
+// lxcHostname returns the hostname of a container named name, ct<vmid> when
+// the name has no usable character
+func lxcHostname(name string, vmid int) string {
+	if hostname := sanitizeHostname(name); hostname != "" {
+		return hostname
+	}
+	return fmt.Sprintf("ct%d", vmid)
+}
@@ -0,0 +1,241 @@
+package proxmox
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestExpandNameTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		config   runtime.ContainerConfig
+		index    int
+		want     string
+	}{
+		{"stack service index", "{stack}-{service}-{n}", runtime.ContainerConfig{
+			Name:   "api",
+			Labels: map[string]string{LabelStack: "shop", LabelService: "web"},
+		}, 2, "shop-web-2"},
+		{"service defaults to the name", "{stack}-{service}", runtime.ContainerConfig{
+			Name:   "api",
+			Labels: map[string]string{LabelStack: "shop"},
+		}, 1, "shop-api"},
+		{"missing stack is trimmed", "{stack}-{name}-{n}", runtime.ContainerConfig{Name: "db"}, 3, "db-3"},
+		{"literal text", "ct-{name}", runtime.ContainerConfig{Name: "cache"}, 1, "ct-cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandNameTemplate(tt.template, tt.config, tt.index); got != tt.want {
+				t.Errorf("expandNameTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextStackIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		members map[int]map[string]string
+		stack   string
+		want    int
+	}{
+		{"empty stack", nil, "shop", 1},
+		{"after existing members", map[int]map[string]string{
+			100: {LabelStack: "shop", LabelStackIndex: "1"},
+			101: {LabelStack: "shop", LabelStackIndex: "2"},
+		}, "shop", 3},
+		{"fills gaps", map[int]map[string]string{
+			100: {LabelStack: "shop", LabelStackIndex: "1"},
+			102: {LabelStack: "shop", LabelStackIndex: "3"},
+		}, "shop", 2},
+		{"ignores other stacks", map[int]map[string]string{
+			100: {LabelStack: "blog", LabelStackIndex: "1"},
+			101: {LabelStack: "blog", LabelStackIndex: "2"},
+		}, "shop", 1},
+		{"ignores invalid indexes", map[int]map[string]string{
+			100: {LabelStack: "shop", LabelStackIndex: "first"},
+		}, "shop", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(NewMemoryBackend(), "")
+			for vmid, labels := range tt.members {
+				store.Set(vmid, labels)
+			}
+			if got := store.NextStackIndex(tt.stack); got != tt.want {
+				t.Errorf("NextStackIndex(%q) = %d, want %d", tt.stack, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReserveStackIndex(t *testing.T) {
+	store := newTestStore(NewMemoryBackend(), "")
+	store.Set(100, map[string]string{LabelStack: "shop", LabelStackIndex: "1"})
+
+	const creates = 20
+	indexes := make(chan int, creates)
+	releases := make(chan func(), creates)
+	var wg sync.WaitGroup
+	for i := 0; i < creates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			index, release := store.ReserveStackIndex("shop")
+			indexes <- index
+			releases <- release
+		}()
+	}
+	wg.Wait()
+	close(indexes)
+	close(releases)
+
+	seen := make(map[int]bool)
+	for index := range indexes {
+		if index == 1 {
+			t.Errorf("index 1 of an existing member was reserved")
+		}
+		if seen[index] {
+			t.Errorf("index %d reserved twice", index)
+		}
+		seen[index] = true
+	}
+	if got := store.NextStackIndex("shop"); got != creates+2 {
+		t.Errorf("NextStackIndex with reservations = %d, want %d", got, creates+2)
+	}
+
+	for release := range releases {
+		release()
+	}
+	if got := store.NextStackIndex("shop"); got != 2 {
+		t.Errorf("NextStackIndex after release = %d, want 2", got)
+	}
+}
+
+func TestCreateNameTemplate(t *testing.T) {
+	cluster := newFakeCluster(t)
+	p := newTestRuntime(t, cluster, func(c *Config) { c.NameTemplate = "{stack}-{service}-{n}" })
+
+	config := runtime.ContainerConfig{
+		Name:   "web",
+		Image:  "local:vztmpl/debian-12-standard_12.2-1_amd64.tar.zst",
+		Labels: map[string]string{LabelStack: "shop", LabelService: "web"},
+	}
+
+	first, err := p.Create(config)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	const creates = 5
+	ids := make(chan string, creates)
+	var wg sync.WaitGroup
+	for i := 0; i < creates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, err := p.Create(config)
+			if err != nil {
+				t.Errorf("Create: %v", err)
+			}
+			ids <- id
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	names := map[string]bool{"shop-web-1": true}
+	if got := p.metadata.GetLabel(atoi(t, first), "cosmos-name"); got != "shop-web-1" {
+		t.Errorf("first member is named %q, want shop-web-1", got)
+	}
+	for id := range ids {
+		vmid := atoi(t, id)
+		name := p.metadata.GetLabel(vmid, "cosmos-name")
+		if names[name] {
+			t.Errorf("name %s given twice", name)
+		}
+		names[name] = true
+		if hostname := cluster.guest(vmid).Config["hostname"]; hostname != name {
+			t.Errorf("hostname of %s is %v", name, hostname)
+		}
+	}
+	for n := 2; n <= creates+1; n++ {
+		if name := "shop-web-" + itoa(n); !names[name] {
+			t.Errorf("no member named %s, got %v", name, names)
+		}
+	}
+}
+
+func TestSanitizeHostname(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"valid", "web-1", "web-1"},
+		{"uppercase and spaces", "My Blog App", "my-blog-app"},
+		{"underscores and dots", "db_primary.local", "db-primary-local"},
+		{"runs of invalid characters", "a  __  b", "a-b"},
+		{"leading and trailing", "--_web_--", "web"},
+		{"unicode", "café Über 日本", "caf-ber"},
+		{"only unicode", "日本語", ""},
+		{"all invalid", "!!! ___ ...", ""},
+		{"empty", "", ""},
+		{"too long", strings.Repeat("a", 70), strings.Repeat("a", maxHostnameLength)},
+		{"too long ending on a hyphen", strings.Repeat("a", 62) + " b", strings.Repeat("a", 62)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeHostname(tt.in)
+			if got != tt.want {
+				t.Errorf("sanitizeHostname(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if len(got) > maxHostnameLength {
+				t.Errorf("hostname is %d characters long", len(got))
+			}
+		})
+	}
+}
+
+func TestCreateHostname(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       runtime.ContainerConfig
+		wantHostname string // %d is the VMID
+	}{
+		{"from the name", runtime.ContainerConfig{Name: "My Blog"}, "my-blog"},
+		{"explicit hostname", runtime.ContainerConfig{Name: "blog", Hostname: "Blog_Server"}, "blog-server"},
+		{"unicode name", runtime.ContainerConfig{Name: "Café"}, "caf"},
+		{"all invalid", runtime.ContainerConfig{Name: "日本語"}, "ct%d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+
+			config := tt.config
+			config.Image = testImage
+			id, err := p.Create(config)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			vmid := atoi(t, id)
+			want := strings.ReplaceAll(tt.wantHostname, "%d", id)
+			if hostname := cluster.guest(vmid).Config["hostname"]; hostname != want {
+				t.Errorf("hostname = %v, want %s", hostname, want)
+			}
+			if name := p.metadata.GetLabel(vmid, "cosmos-name"); name != tt.config.Name {
+				t.Errorf("cosmos-name = %q, want %q", name, tt.config.Name)
+			}
+		})
+	}
+}
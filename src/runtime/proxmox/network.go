@@ -1,98 +1,390 @@
 package proxmox
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 
 	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
 	"github.com/azukaar/cosmos-server/src/utils"
 )
 
 // Network operations for Proxmox
-// Proxmox uses bridges (vmbr0, vmbr1, etc.) and VLANs for networking
-// Container-specific networks are implemented via firewall rules and bridge isolation
+// Networks map onto the SDN subsystem: each network is a vnet of the "cosmos"
+// simple zone, with the network name kept as the vnet alias. Containers join a
+// network through an extra interface bridged on the vnet. SDN requires
+// Proxmox VE 8.1+ (or libpve-network-perl installed on older versions)
 
-// CreateNetwork creates a network (in Proxmox context, this is typically a bridge or VLAN tag)
+const sdnZone = "cosmos"
+
+// ErrSDNUnavailable is returned when the cluster does not provide the SDN API
+var ErrSDNUnavailable = errors.New("Proxmox SDN is not available: upgrade to Proxmox VE 8.1+ or install libpve-network-perl on every node")
+
+// CreateNetwork creates an SDN vnet (with its subnet) for the network
 func (p *ProxmoxRuntime) CreateNetwork(config runtime.NetworkConfig) (string, error) {
-	// Proxmox networks are created at the node level, not per-container
-	// For Cosmos compatibility, we'll track "virtual" networks in metadata
-	// and map them to Proxmox bridges or VLAN tags
+	if !p.connected {
+		return "", errNotConnected
+	}
 
-	networkID := "cosmos-" + config.Name
+	if err := p.ensureSDNZone(); err != nil {
+		return "", err
+	}
 
-	// Store network configuration in metadata
-	// Actual bridge creation requires SSH/node-level access
-	utils.Log(fmt.Sprintf("Network '%s' registered (maps to Proxmox bridge)", config.Name))
+	vnet := vnetID(config.Name)
+	_, err := p.sdnRequest("POST", "/cluster/sdn/vnets", map[string]interface{}{
+		"vnet":  vnet,
+		"zone":  sdnZone,
+		"alias": config.Name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create network %s: %w", config.Name, err)
+	}
 
-	// In production, you might:
-	// 1. Create a new Linux bridge via Proxmox API (requires node-level permissions)
-	// 2. Or assign a VLAN tag to isolate traffic
-	// 3. Or use existing bridge with firewall rules
+	if config.IPAM != nil {
+		for _, pool := range config.IPAM.Config {
+			if pool.Subnet == "" {
+				continue
+			}
+			subnet := map[string]interface{}{
+				"subnet": pool.Subnet,
+				"type":   "subnet",
+			}
+			if pool.Gateway != "" {
+				subnet["gateway"] = pool.Gateway
+			}
+			if !config.Internal {
+				subnet["snat"] = 1
+			}
+			if _, err := p.sdnRequest("POST", fmt.Sprintf("/cluster/sdn/vnets/%s/subnets", vnet), subnet); err != nil {
+				return "", fmt.Errorf("failed to create subnet %s for network %s: %w", pool.Subnet, config.Name, err)
+			}
+		}
+	}
+
+	if err := p.applySDN(); err != nil {
+		return "", err
+	}
 
-	return networkID, nil
+	utils.Log(fmt.Sprintf("Created network '%s' as SDN vnet %s", config.Name, vnet))
+	return vnet, nil
 }
 
-// RemoveNetwork removes a network
+// RemoveNetwork deletes the SDN vnet of a network, by vnet ID or network name
 func (p *ProxmoxRuntime) RemoveNetwork(id string) error {
-	// Remove from metadata tracking
-	utils.Log(fmt.Sprintf("Network '%s' removed from tracking", id))
+	if !p.connected {
+		return errNotConnected
+	}
+
+	vnet, err := p.resolveVNet(id)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.sdnRequest("GET", fmt.Sprintf("/cluster/sdn/vnets/%s/subnets", vnet), nil)
+	if err != nil {
+		return fmt.Errorf("failed to remove network %s: %w", id, err)
+	}
+	for _, subnet := range listItems(resp) {
+		name, _ := subnet["subnet"].(string)
+		if _, err := p.sdnRequest("DELETE", fmt.Sprintf("/cluster/sdn/vnets/%s/subnets/%s", vnet, name), nil); err != nil {
+			return fmt.Errorf("failed to remove subnet %s of network %s: %w", name, id, err)
+		}
+	}
+
+	if _, err := p.sdnRequest("DELETE", "/cluster/sdn/vnets/"+vnet, nil); err != nil {
+		return fmt.Errorf("failed to remove network %s: %w", id, err)
+	}
+
+	if err := p.applySDN(); err != nil {
+		return err
+	}
+
+	utils.Log(fmt.Sprintf("Removed network '%s' (SDN vnet %s)", id, vnet))
 	return nil
 }
 
-// ListNetworks returns available networks (Proxmox bridges)
+// ListNetworks returns the bridges of the node and the SDN vnets
 func (p *ProxmoxRuntime) ListNetworks() ([]runtime.Network, error) {
 	if !p.connected {
-		return nil, fmt.Errorf("not connected to Proxmox")
+		return nil, errNotConnected
 	}
 
-	// Get node network configuration
-	// This would list available bridges like vmbr0, vmbr1, etc.
-	networks := []runtime.Network{
-		{
-			ID:     "vmbr0",
-			Name:   "vmbr0",
+	networks, err := p.nodeBridges()
+	if err != nil {
+		utils.Warn("Failed to list the bridges of node " + p.node + ": " + err.Error())
+		networks = []runtime.Network{
+			{
+				ID:     p.defaultBridge(),
+				Name:   p.defaultBridge(),
+				Driver: "bridge",
+				Scope:  "local",
+			},
+		}
+	}
+
+	resp, err := p.sdnRequest("GET", "/cluster/sdn/vnets", nil)
+	if errors.Is(err, ErrSDNUnavailable) {
+		return networks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list networks: %w", err)
+	}
+
+	for _, item := range listItems(resp) {
+		vnet, _ := item["vnet"].(string)
+		if vnet == "" {
+			continue
+		}
+		zone, _ := item["zone"].(string)
+
+		network := runtime.Network{
+			ID:     vnet,
+			Name:   vnet,
+			Driver: "sdn",
+			Scope:  "cluster",
+			Labels: map[string]string{"zone": zone},
+		}
+		if alias, _ := item["alias"].(string); alias != "" {
+			network.Name = alias
+		}
+		network.IPAM = p.vnetIPAM(vnet)
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// nodeBridges returns the Linux and Open vSwitch bridges configured on the node,
+// sorted by name. SDN vnets are listed by ListNetworks from the cluster config
+func (p *ProxmoxRuntime) nodeBridges() ([]runtime.Network, error) {
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/network?type=any_bridge", p.node), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []runtime.Network
+	for _, item := range listItems(resp) {
+		name, _ := item["iface"].(string)
+		kind, _ := item["type"].(string)
+		if name == "" || (kind != "bridge" && kind != "OVSBridge") {
+			continue
+		}
+
+		network := runtime.Network{
+			ID:     name,
+			Name:   name,
 			Driver: "bridge",
 			Scope:  "local",
-		},
+			Labels: map[string]string{"type": kind},
+		}
+		if comment, _ := item["comments"].(string); comment != "" {
+			network.Labels["comment"] = strings.TrimSpace(comment)
+		}
+		if cidr, _ := item["cidr"].(string); cidr != "" {
+			if _, subnet, err := net.ParseCIDR(cidr); err == nil {
+				gateway, _ := item["gateway"].(string)
+				network.IPAM = &runtime.IPAMConfig{
+					Driver: "static",
+					Config: []runtime.IPAMPoolConfig{{Subnet: subnet.String(), Gateway: gateway}},
+				}
+			}
+		}
+		networks = append(networks, network)
 	}
 
-	// In production, query actual node network config:
-	// GET /nodes/{node}/network
-
+	sort.Slice(networks, func(i, j int) bool { return networks[i].Name < networks[j].Name })
 	return networks, nil
 }
 
-// ConnectToNetwork connects a container to a network
+// ConnectToNetwork adds an interface bridged on the network's vnet to the container
 func (p *ProxmoxRuntime) ConnectToNetwork(containerID, networkID string, opts runtime.NetworkConnectOptions) error {
-	// In Proxmox, this means modifying the container's network interface
-	// to use a specific bridge or VLAN
+	vmid, err := strconv.Atoi(containerID)
+	if err != nil {
+		return fmt.Errorf("invalid container ID: %s", containerID)
+	}
 
-	utils.Log(fmt.Sprintf("Container %s connected to network %s", containerID, networkID))
+	vnet, err := p.resolveVNet(networkID)
+	if err != nil {
+		return err
+	}
 
-	// Actual implementation would:
-	// 1. Modify container config to add/update net interface
-	// 2. Set bridge= parameter to the target network
-	// 3. Optionally set IP address if opts.IPAddress is provided
+	iface := netInterface{bridge: vnet, ip: "dhcp"}
+	if opts.IPAddress != "" {
+		iface.ip = opts.IPAddress
+	}
+	if err := iface.validate(); err != nil {
+		return err
+	}
 
+	node := p.nodeFor(vmid)
+	config, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), nil)
+	if err != nil {
+		return fmt.Errorf("failed to get container config: %w", err)
+	}
+
+	index := 0
+	for {
+		if _, used := config[fmt.Sprintf("net%d", index)]; !used {
+			break
+		}
+		index++
+	}
+
+	update := map[string]interface{}{
+		fmt.Sprintf("net%d", index): iface.render(index),
+	}
+	body, _ := json.Marshal(update)
+	if _, err := p.apiRequest("PUT", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), strings.NewReader(string(body))); err != nil {
+		return fmt.Errorf("failed to connect container %s to network %s: %w", containerID, networkID, err)
+	}
+
+	utils.Log(fmt.Sprintf("Container %s connected to network %s (eth%d)", containerID, networkID, index))
 	return nil
 }
 
-// DisconnectFromNetwork disconnects a container from a network
+// DisconnectFromNetwork removes the container interfaces bridged on the network's vnet
 func (p *ProxmoxRuntime) DisconnectFromNetwork(containerID, networkID string) error {
+	vmid, err := strconv.Atoi(containerID)
+	if err != nil {
+		return fmt.Errorf("invalid container ID: %s", containerID)
+	}
+
+	vnet, err := p.resolveVNet(networkID)
+	if err != nil {
+		return err
+	}
+
+	node := p.nodeFor(vmid)
+	config, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), nil)
+	if err != nil {
+		return fmt.Errorf("failed to get container config: %w", err)
+	}
+
+	var interfaces []string
+	for key, value := range config {
+		if s, ok := value.(string); ok && strings.HasPrefix(key, "net") && configOption(s, "bridge") == vnet {
+			interfaces = append(interfaces, key)
+		}
+	}
+	if len(interfaces) == 0 {
+		return fmt.Errorf("container %s is not connected to network %s", containerID, networkID)
+	}
+	sort.Strings(interfaces)
+
+	body, _ := json.Marshal(map[string]interface{}{"delete": strings.Join(interfaces, ",")})
+	if _, err := p.apiRequest("PUT", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), strings.NewReader(string(body))); err != nil {
+		return fmt.Errorf("failed to disconnect container %s from network %s: %w", containerID, networkID, err)
+	}
+
 	utils.Log(fmt.Sprintf("Container %s disconnected from network %s", containerID, networkID))
 	return nil
 }
 
-// ConfigurePortForwarding sets up port forwarding for a container
-// This is done via iptables rules on the Proxmox host
-func (p *ProxmoxRuntime) ConfigurePortForwarding(vmid int, ports []runtime.PortMapping) error {
-	for _, port := range ports {
-		// Generate iptables rule
-		// iptables -t nat -A PREROUTING -i vmbr0 -p tcp --dport {hostPort} -j DNAT --to {containerIP}:{containerPort}
+// vnetID derives a valid vnet ID (max 8 alphanumeric characters) from a network name
+func vnetID(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("cx%06x", h.Sum32()&0xffffff)
+}
 
-		utils.Log(fmt.Sprintf("Port forwarding configured: %s:%s -> container:%s",
-			port.HostIP, port.HostPort, port.ContainerPort))
+// resolveVNet accepts a vnet ID or a network name and returns the vnet ID
+func (p *ProxmoxRuntime) resolveVNet(id string) (string, error) {
+	resp, err := p.sdnRequest("GET", "/cluster/sdn/vnets", nil)
+	if err != nil {
+		return "", err
 	}
 
-	// Store port mapping in metadata for later cleanup
+	for _, item := range listItems(resp) {
+		vnet, _ := item["vnet"].(string)
+		alias, _ := item["alias"].(string)
+		if vnet == id || (alias != "" && alias == id) {
+			return vnet, nil
+		}
+	}
+	return "", fmt.Errorf("network %s not found", id)
+}
+
+// vnetIPAM returns the subnets of a vnet, nil when it has none
+func (p *ProxmoxRuntime) vnetIPAM(vnet string) *runtime.IPAMConfig {
+	resp, err := p.sdnRequest("GET", fmt.Sprintf("/cluster/sdn/vnets/%s/subnets", vnet), nil)
+	if err != nil {
+		return nil
+	}
+
+	var pools []runtime.IPAMPoolConfig
+	for _, item := range listItems(resp) {
+		cidr, _ := item["cidr"].(string)
+		if cidr == "" {
+			cidr, _ = item["subnet"].(string)
+		}
+		gateway, _ := item["gateway"].(string)
+		pools = append(pools, runtime.IPAMPoolConfig{Subnet: cidr, Gateway: gateway})
+	}
+	if len(pools) == 0 {
+		return nil
+	}
+	return &runtime.IPAMConfig{Driver: "sdn", Config: pools}
+}
+
+// ensureSDNZone creates the cosmos simple zone if missing
+func (p *ProxmoxRuntime) ensureSDNZone() error {
+	resp, err := p.sdnRequest("GET", "/cluster/sdn/zones", nil)
+	if err != nil {
+		return err
+	}
+	for _, item := range listItems(resp) {
+		if zone, _ := item["zone"].(string); zone == sdnZone {
+			return nil
+		}
+	}
+
+	_, err = p.sdnRequest("POST", "/cluster/sdn/zones", map[string]interface{}{
+		"zone": sdnZone,
+		"type": "simple",
+		"ipam": "pve",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create SDN zone %s: %w", sdnZone, err)
+	}
 	return nil
 }
+
+// applySDN applies the pending SDN configuration to the nodes
+func (p *ProxmoxRuntime) applySDN() error {
+	resp, err := p.sdnRequest("PUT", "/cluster/sdn", nil)
+	if err != nil {
+		return fmt.Errorf("failed to apply SDN configuration: %w", err)
+	}
+	if err := p.waitForTask(taskUPID(resp)); err != nil {
+		return fmt.Errorf("failed to apply SDN configuration: %w", err)
+	}
+	return nil
+}
+
+// sdnRequest calls the SDN API, mapping missing endpoints to ErrSDNUnavailable
+func (p *ProxmoxRuntime) sdnRequest(method, path string, body map[string]interface{}) (map[string]interface{}, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = strings.NewReader(string(encoded))
+	}
+
+	resp, err := p.apiRequest(method, path, reader)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotImplemented ||
+		(apiErr.StatusCode == http.StatusNotFound && strings.Contains(apiErr.Body, "no such"))) {
+		return nil, ErrSDNUnavailable
+	}
+	return resp, err
+}
@@ -0,0 +1,95 @@
+package proxmox
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// mixedInterfaces is a node network listing with every interface type Proxmox reports
+var mixedInterfaces = []map[string]interface{}{
+	{"iface": "lo", "type": "loopback"},
+	{"iface": "enp1s0", "type": "eth", "active": 1},
+	{"iface": "bond0", "type": "bond", "slaves": "enp2s0 enp3s0"},
+	{"iface": "vmbr1", "type": "bridge", "cidr": "10.0.1.2/24", "gateway": "10.0.1.1", "comments": "lan\n"},
+	{"iface": "vmbr0", "type": "bridge", "bridge_ports": "enp1s0"},
+	{"iface": "vmbr0.20", "type": "vlan", "vlan-raw-device": "vmbr0"},
+	{"iface": "ovs0", "type": "OVSBridge", "cidr": "not a cidr"},
+	{"iface": "ovsport0", "type": "OVSPort"},
+	{"iface": "enp1s0:1", "type": "alias"},
+	{"type": "bridge"},
+}
+
+func TestListNetworks(t *testing.T) {
+	tests := []struct {
+		name    string
+		listing func(r *http.Request, body map[string]interface{}) (int, interface{})
+		vnets   []map[string]interface{} // nil when SDN is not available
+		want    []runtime.Network
+	}{
+		{
+			name: "mixed interface types",
+			listing: func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+				return http.StatusOK, mixedInterfaces
+			},
+			want: []runtime.Network{
+				{ID: "ovs0", Name: "ovs0", Driver: "bridge", Scope: "local", Labels: map[string]string{"type": "OVSBridge"}},
+				{ID: "vmbr0", Name: "vmbr0", Driver: "bridge", Scope: "local", Labels: map[string]string{"type": "bridge"}},
+				{ID: "vmbr1", Name: "vmbr1", Driver: "bridge", Scope: "local", Labels: map[string]string{"type": "bridge", "comment": "lan"},
+					IPAM: &runtime.IPAMConfig{Driver: "static", Config: []runtime.IPAMPoolConfig{{Subnet: "10.0.1.0/24", Gateway: "10.0.1.1"}}}},
+			},
+		},
+		{
+			name: "no bridges",
+			listing: func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+				return http.StatusOK, mixedInterfaces[:3]
+			},
+		},
+		{
+			name: "listing fails",
+			listing: func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+				return http.StatusForbidden, "Permission check failed (/nodes/pve, Sys.Audit)"
+			},
+			want: []runtime.Network{{ID: "vmbr0", Name: "vmbr0", Driver: "bridge", Scope: "local"}},
+		},
+		{
+			name: "bridges and SDN vnets",
+			listing: func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+				return http.StatusOK, mixedInterfaces[3:5]
+			},
+			vnets: []map[string]interface{}{{"vnet": "cosmos1", "zone": sdnZone, "alias": "backend"}, {"zone": sdnZone}},
+			want: []runtime.Network{
+				{ID: "vmbr0", Name: "vmbr0", Driver: "bridge", Scope: "local", Labels: map[string]string{"type": "bridge"}},
+				{ID: "vmbr1", Name: "vmbr1", Driver: "bridge", Scope: "local", Labels: map[string]string{"type": "bridge", "comment": "lan"},
+					IPAM: &runtime.IPAMConfig{Driver: "static", Config: []runtime.IPAMPoolConfig{{Subnet: "10.0.1.0/24", Gateway: "10.0.1.1"}}}},
+				{ID: "cosmos1", Name: "backend", Driver: "sdn", Scope: "cluster", Labels: map[string]string{"zone": sdnZone}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			cluster.handle("GET /nodes/pve/network", tt.listing)
+			if tt.vnets != nil {
+				cluster.handle("GET /cluster/sdn/vnets", func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+					return http.StatusOK, tt.vnets
+				})
+				cluster.handle("GET /cluster/sdn/vnets/cosmos1/subnets", func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+					return http.StatusOK, []map[string]interface{}{}
+				})
+			}
+			p := newTestRuntime(t, cluster)
+
+			networks, err := p.ListNetworks()
+			if err != nil {
+				t.Fatalf("ListNetworks: %v", err)
+			}
+			if !reflect.DeepEqual(networks, tt.want) {
+				t.Errorf("ListNetworks = %+v, want %+v", networks, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package proxmox
+
+import (
+	"fmt"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Multi-node clusters
+// The configured Node is where new containers go by default and the node
+// List covers, unless AllNodes is set. Existing containers are looked up in
+// the cluster resources, so operations follow a guest migrated to another
+// node. WithNode places a new container on a given node
+
+// WithNode returns a copy of config placing the container on node
+func WithNode(config runtime.ContainerConfig, node string) runtime.ContainerConfig {
+	labels := make(map[string]string, len(config.Labels)+1)
+	for k, v := range config.Labels {
+		labels[k] = v
+	}
+	labels[LabelNode] = node
+	config.Labels = labels
+	return config
+}
+
+// locateNode returns the node currently hosting the guest, empty when unknown
+func (p *ProxmoxRuntime) locateNode(vmid int) string {
+	resp, err := p.cachedGet("/cluster/resources?type=vm")
+	if err != nil {
+		return ""
+	}
+	for _, item := range listItems(resp) {
+		if id, ok := item["vmid"].(float64); ok && int(id) == vmid {
+			node, _ := item["node"].(string)
+			return node
+		}
+	}
+	return ""
+}
+
+// pinnedNode checks that a node requested with WithNode can receive containers
+func (p *ProxmoxRuntime) pinnedNode(node string) ([]string, error) {
+	resp, err := p.apiRequest("GET", "/cluster/resources?type=node", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check node %s: %w", node, err)
+	}
+
+	for _, row := range listItems(resp) {
+		if row["node"] != node {
+			continue
+		}
+		if status, _ := row["status"].(string); status != "online" {
+			return nil, fmt.Errorf("node %s is %s", node, status)
+		}
+		if err := p.checkMaintenance(node); err != nil {
+			return nil, err
+		}
+		return []string{node}, nil
+	}
+	return nil, fmt.Errorf("node %s is not part of the cluster", node)
+}
+
+// listAllNodes returns the LXC containers of every node of the cluster
+func (p *ProxmoxRuntime) listAllNodes() ([]runtime.Container, error) {
+	resp, err := p.cachedGet("/cluster/resources?type=vm")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	var containers []runtime.Container
+	for _, item := range listEntries(resp["data"], "the cluster resources") {
+		if item.Type != "lxc" {
+			continue
+		}
+
+		container := p.containerFromStatus(item.VMID.Int(), item.LXCStatus)
+		if !p.listed(container.Labels) {
+			continue
+		}
+		if item.Node != "" {
+			if container.Labels == nil {
+				container.Labels = map[string]string{}
+			}
+			container.Labels[LabelNode] = item.Node
+		}
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
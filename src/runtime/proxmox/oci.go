@@ -0,0 +1,622 @@
+package proxmox
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// OCI/Docker image import for Proxmox
+// CreateFromOCIImage pulls an image from its registry, flattens its layers
+// into a single rootfs tarball, uploads it as an LXC template and creates a
+// container from it. The image env becomes build args and the entrypoint is
+// started by the template's provision script on first boot.
+//
+// Limitations: this is a filesystem conversion, not an OCI runtime.
+//   - the container boots the image's /sbin/init; images without an init
+//     system will not start unless the template provides one
+//   - the entrypoint is started once at provisioning, it is neither
+//     supervised nor restarted on reboot
+//   - device nodes are skipped, volumes, exposed ports and USER are ignored
+//   - only gzip and uncompressed layers are supported (no zstd)
+
+const (
+	LabelOCIImage = "cosmos-oci-image"
+
+	ociEntrypointPath = "/etc/cosmos/entrypoint.sh"
+	ociPlatformArch   = "amd64"
+)
+
+// OCIRef identifies an image in a registry
+type OCIRef struct {
+	Registry   string
+	Repository string
+	Reference  string // tag or digest
+}
+
+// ociImageConfig is the subset of the image config carried over to the container
+type ociImageConfig struct {
+	Env        []string `json:"Env"`
+	Entrypoint []string `json:"Entrypoint"`
+	Cmd        []string `json:"Cmd"`
+	WorkingDir string   `json:"WorkingDir"`
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Config    ociDescriptor   `json:"config"`
+	Layers    []ociDescriptor `json:"layers"`
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+var ociTemplateNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// CreateFromOCIImage converts an OCI/Docker image into an LXC template and creates a container from it
+func (p *ProxmoxRuntime) CreateFromOCIImage(ref string, config runtime.ContainerConfig) (string, error) {
+	if !p.connected {
+		return "", errNotConnected
+	}
+
+	imageRef, err := ParseOCIRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	registry := newOCIRegistryClient()
+
+	manifest, err := registry.resolveManifest(imageRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve image %s: %w", ref, err)
+	}
+
+	var imageConfig struct {
+		Config ociImageConfig `json:"config"`
+	}
+	if err := registry.fetchJSON(imageRef, "blobs/"+manifest.Config.Digest, "", &imageConfig); err != nil {
+		return "", fmt.Errorf("failed to fetch image config: %w", err)
+	}
+
+	config = ociCarryOver(imageConfig.Config, config)
+
+	utils.Log(fmt.Sprintf("Converting image %s (%d layers) to an LXC template", ref, len(manifest.Layers)))
+
+	rootfs, err := os.CreateTemp("", "cosmos-oci-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(rootfs.Name())
+	defer rootfs.Close()
+
+	if err := registry.flatten(imageRef, manifest.Layers, rootfs, ociStartupFiles(config)); err != nil {
+		return "", fmt.Errorf("failed to flatten image %s: %w", ref, err)
+	}
+	if _, err := rootfs.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	templateName := ociTemplateName(imageRef)
+	storage := p.templateStorage()
+	if err := p.uploadTemplate(storage, templateName, rootfs); err != nil {
+		return "", err
+	}
+
+	config.Image = fmt.Sprintf("%s:vztmpl/%s", storage, templateName)
+
+	id, err := p.Create(config)
+	if err != nil {
+		return "", err
+	}
+
+	if vmid, err := strconv.Atoi(id); err == nil {
+		p.metadata.SetLabel(vmid, LabelOCIImage, ref)
+	}
+	return id, nil
+}
+
+// ParseOCIRef parses an image reference such as "nginx", "ghcr.io/org/app:1.2" or "repo@sha256:..."
+func ParseOCIRef(ref string) (OCIRef, error) {
+	if ref == "" || strings.ContainsAny(ref, " \t\n") {
+		return OCIRef{}, fmt.Errorf("invalid image reference: %q", ref)
+	}
+
+	result := OCIRef{Registry: "registry-1.docker.io", Reference: "latest"}
+
+	name, digest, pinned := strings.Cut(ref, "@")
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		result.Reference = name[i+1:]
+		name = name[:i]
+	}
+	// A digest pins the image, the tag of "repo:tag@digest" is informative
+	if pinned {
+		result.Reference = digest
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		result.Registry = parts[0]
+		name = parts[1]
+	}
+	if result.Registry == "docker.io" || result.Registry == "index.docker.io" {
+		result.Registry = "registry-1.docker.io"
+	}
+
+	if result.Registry == "registry-1.docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+
+	if name == "" || result.Reference == "" {
+		return OCIRef{}, fmt.Errorf("invalid image reference: %q", ref)
+	}
+
+	result.Repository = strings.ToLower(name)
+	return result, nil
+}
+
+// ociCarryOver merges the image env, entrypoint and working dir into the container config.
+// Values set on the container config win over the image's. The merged env is also
+// passed as build args so it is exported to the provision script; the build
+// args are set even when empty, so the provision script starts the entrypoint.
+func ociCarryOver(image ociImageConfig, config runtime.ContainerConfig) runtime.ContainerConfig {
+	env := make(map[string]string, len(image.Env)+len(config.Environment))
+	for _, e := range image.Env {
+		if k, v, ok := strings.Cut(e, "="); ok {
+			env[k] = v
+		}
+	}
+	for k, v := range config.Environment {
+		env[k] = v
+	}
+	config.Environment = env
+
+	args := make(map[string]string, len(env)+len(config.BuildArgs))
+	for k, v := range env {
+		args[k] = v
+	}
+	for k, v := range config.BuildArgs {
+		args[k] = v
+	}
+	config.BuildArgs = args
+
+	if len(config.Entrypoint) == 0 {
+		config.Entrypoint = image.Entrypoint
+	}
+	if len(config.Command) == 0 {
+		config.Command = image.Cmd
+	}
+	if config.WorkingDir == "" {
+		config.WorkingDir = image.WorkingDir
+	}
+
+	return config
+}
+
+// ociStartupFiles renders the files added to the rootfs to start the entrypoint:
+// entrypoint.sh runs it with the image env, provision.sh launches it in the background
+func ociStartupFiles(config runtime.ContainerConfig) map[string]string {
+	args := append(append([]string{}, config.Entrypoint...), config.Command...)
+	if len(args) == 0 {
+		return nil
+	}
+
+	var entrypoint strings.Builder
+	entrypoint.WriteString("#!/bin/sh\n")
+
+	keys := make([]string, 0, len(config.Environment))
+	for k := range config.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		entrypoint.WriteString("export " + k + "=" + shellQuote(config.Environment[k]) + "\n")
+	}
+
+	if config.WorkingDir != "" {
+		entrypoint.WriteString("cd " + shellQuote(config.WorkingDir) + "\n")
+	}
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	entrypoint.WriteString("exec " + strings.Join(quoted, " ") + "\n")
+
+	return map[string]string{
+		ociEntrypointPath: entrypoint.String(),
+		provisionScript:   "#!/bin/sh\nnohup " + ociEntrypointPath + " > /var/log/cosmos-entrypoint.log 2>&1 &\n",
+	}
+}
+
+func ociTemplateName(ref OCIRef) string {
+	name := ociTemplateNameChars.ReplaceAllString(ref.Repository+"-"+ref.Reference, "-")
+	return "oci-" + strings.Trim(name, "-") + ".tar.gz"
+}
+
+// uploadTemplate uploads a rootfs tarball as an LXC template and waits for the import task
+func (p *ProxmoxRuntime) uploadTemplate(storage, filename string, content io.Reader) error {
+	// pvesh cannot upload files, this always goes through HTTP
+	authorization, err := p.authorization()
+	if err != nil {
+		return errors.New("uploading templates requires a Proxmox API token")
+	}
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+
+	go func() {
+		err := form.WriteField("content", "vztmpl")
+		if err == nil {
+			var part io.Writer
+			part, err = form.CreateFormFile("filename", filename)
+			if err == nil {
+				_, err = io.Copy(part, content)
+			}
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	url := fmt.Sprintf("%s/nodes/%s/storage/%s/upload", p.apiURL, p.node, storage)
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	// Uploads outlive the API request timeout, no deadline is set
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload template %s: %w", filename, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+	}
+
+	var result struct {
+		Data string `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.Data != "" {
+		if err := p.waitForTask(result.Data); err != nil {
+			return fmt.Errorf("failed to import template %s: %w", filename, err)
+		}
+	}
+
+	utils.Log(fmt.Sprintf("Uploaded LXC template %s to storage %s", filename, storage))
+	return nil
+}
+
+// ociRegistryClient is a minimal anonymous client for the registry v2 API
+type ociRegistryClient struct {
+	client *http.Client
+	tokens map[string]string // repository -> bearer token
+}
+
+func newOCIRegistryClient() *ociRegistryClient {
+	return &ociRegistryClient{
+		client: &http.Client{Timeout: 30 * time.Minute},
+		tokens: make(map[string]string),
+	}
+}
+
+// get performs an authenticated GET, negotiating an anonymous token on 401
+func (c *ociRegistryClient) get(ref OCIRef, resource, accept string) (*http.Response, error) {
+	url := fmt.Sprintf("https://%s/v2/%s/%s", ref.Registry, ref.Repository, resource)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if token := c.tokens[ref.Repository]; token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if err := c.authenticate(ref, challenge); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w: %s", runtime.ErrImageNotFound, url)
+		}
+		if resp.StatusCode >= 400 {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			return nil, fmt.Errorf("registry error %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		return resp, nil
+	}
+
+	return nil, errors.New("registry authentication failed")
+}
+
+// authenticate gets an anonymous pull token from a Bearer challenge
+func (c *ociRegistryClient) authenticate(ref OCIRef, challenge string) error {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return fmt.Errorf("unsupported registry authentication: %q", challenge)
+	}
+
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+			params[k] = strings.Trim(v, `"`)
+		}
+	}
+
+	tokenURL := fmt.Sprintf("%s?service=%s&scope=repository:%s:pull", params["realm"], params["service"], ref.Repository)
+	resp, err := c.client.Get(tokenURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	c.tokens[ref.Repository] = token.Token
+	return nil
+}
+
+func (c *ociRegistryClient) fetchJSON(ref OCIRef, resource, accept string, out interface{}) error {
+	resp, err := c.get(ref, resource, accept)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// resolveManifest returns the image manifest, picking the linux platform from an index
+func (c *ociRegistryClient) resolveManifest(ref OCIRef) (*ociManifest, error) {
+	accept := strings.Join([]string{
+		"application/vnd.oci.image.index.v1+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}, ", ")
+
+	var manifest ociManifest
+	if err := c.fetchJSON(ref, "manifests/"+ref.Reference, accept, &manifest); err != nil {
+		return nil, err
+	}
+
+	if len(manifest.Manifests) > 0 {
+		digest := ""
+		for _, m := range manifest.Manifests {
+			if m.Platform != nil && m.Platform.OS == "linux" && m.Platform.Architecture == ociPlatformArch {
+				digest = m.Digest
+				break
+			}
+		}
+		if digest == "" {
+			return nil, fmt.Errorf("image has no linux/%s variant", ociPlatformArch)
+		}
+		manifest = ociManifest{}
+		if err := c.fetchJSON(ref, "manifests/"+digest, accept, &manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	if manifest.Config.Digest == "" {
+		return nil, errors.New("unsupported manifest format")
+	}
+	return &manifest, nil
+}
+
+// flatten merges the layers into a single gzipped rootfs tarball, applying whiteouts.
+// Layers are downloaded once to temporary files, then read twice: the first pass
+// computes which layer provides each path, the second writes the winning entries.
+func (c *ociRegistryClient) flatten(ref OCIRef, layers []ociDescriptor, out io.Writer, extra map[string]string) error {
+	var files []string
+	defer func() {
+		for _, f := range files {
+			os.Remove(f)
+		}
+	}()
+
+	for _, layer := range layers {
+		if strings.Contains(layer.MediaType, "zstd") {
+			return fmt.Errorf("zstd compressed layers are not supported (%s)", layer.Digest)
+		}
+		file, err := c.download(ref, layer.Digest)
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+
+	// First pass: path -> index of the layer providing it
+	owners := make(map[string]int)
+	for i, file := range files {
+		err := walkLayer(file, func(hdr *tar.Header, _ io.Reader) error {
+			name := path.Clean("/" + hdr.Name)
+			dir, base := path.Split(name)
+
+			switch {
+			case base == ".wh..wh..opq":
+				for p, owner := range owners {
+					if owner < i && strings.HasPrefix(p, dir) {
+						delete(owners, p)
+					}
+				}
+			case strings.HasPrefix(base, ".wh."):
+				target := path.Join(dir, strings.TrimPrefix(base, ".wh."))
+				for p := range owners {
+					if p == target || strings.HasPrefix(p, target+"/") {
+						delete(owners, p)
+					}
+				}
+			default:
+				owners[name] = i
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	// Second pass: copy the winning entries
+	for i, file := range files {
+		err := walkLayer(file, func(hdr *tar.Header, content io.Reader) error {
+			name := path.Clean("/" + hdr.Name)
+			if owners[name] != i || strings.HasPrefix(path.Base(name), ".wh.") || extra[name] != "" {
+				return nil
+			}
+			if hdr.Typeflag == tar.TypeChar || hdr.Typeflag == tar.TypeBlock || hdr.Typeflag == tar.TypeFifo {
+				return nil
+			}
+
+			hdr.Name = "." + name
+			if hdr.Typeflag == tar.TypeDir {
+				hdr.Name += "/"
+			}
+			if hdr.Typeflag == tar.TypeLink {
+				hdr.Linkname = "." + path.Clean("/"+hdr.Linkname)
+			}
+
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			_, err := io.Copy(tw, content)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Add the startup files, replacing any from the image
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := tw.WriteHeader(&tar.Header{
+			Name:     "." + name,
+			Mode:     0755,
+			Size:     int64(len(extra[name])),
+			Typeflag: tar.TypeReg,
+			ModTime:  time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(tw, extra[name]); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// download saves a blob to a temporary file
+func (c *ociRegistryClient) download(ref OCIRef, digest string) (string, error) {
+	resp, err := c.get(ref, "blobs/"+digest, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to download layer %s: %w", digest, err)
+	}
+	defer resp.Body.Close()
+
+	file, err := os.CreateTemp("", "cosmos-oci-layer-*")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to download layer %s: %w", digest, err)
+	}
+	return file.Name(), nil
+}
+
+// walkLayer calls fn for every entry of a (possibly gzipped) layer tarball
+func walkLayer(file string, fn func(hdr *tar.Header, content io.Reader) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if gz, err := gzip.NewReader(f); err == nil {
+		defer gz.Close()
+		reader = gz
+	} else if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package proxmox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestParseOCIRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    OCIRef
+		wantErr bool
+	}{
+		{"nginx", OCIRef{"registry-1.docker.io", "library/nginx", "latest"}, false},
+		{"nginx:1.25-alpine", OCIRef{"registry-1.docker.io", "library/nginx", "1.25-alpine"}, false},
+		{"bitnami/redis:7.2", OCIRef{"registry-1.docker.io", "bitnami/redis", "7.2"}, false},
+		{"docker.io/nginx", OCIRef{"registry-1.docker.io", "library/nginx", "latest"}, false},
+		{"index.docker.io/library/nginx:1", OCIRef{"registry-1.docker.io", "library/nginx", "1"}, false},
+		{"ghcr.io/Org/App:v1.2", OCIRef{"ghcr.io", "org/app", "v1.2"}, false},
+		{"localhost:5000/app", OCIRef{"localhost:5000", "app", "latest"}, false},
+		{"localhost:5000/team/app:dev", OCIRef{"localhost:5000", "team/app", "dev"}, false},
+		{"localhost/app", OCIRef{"localhost", "app", "latest"}, false},
+		{"alpine@sha256:c5b1261d", OCIRef{"registry-1.docker.io", "library/alpine", "sha256:c5b1261d"}, false},
+		{"quay.io/org/app:1.0@sha256:c5b1261d", OCIRef{"quay.io", "org/app", "sha256:c5b1261d"}, false},
+		{"localhost:5000/app@sha256:c5b1261d", OCIRef{"localhost:5000", "app", "sha256:c5b1261d"}, false},
+		{"", OCIRef{}, true},
+		{"nginx latest", OCIRef{}, true},
+		{"nginx:", OCIRef{}, true},
+		{"nginx@", OCIRef{}, true},
+		{"ghcr.io/", OCIRef{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseOCIRef(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseOCIRef(%q) = %+v, want an error", tt.ref, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseOCIRef(%q): %v", tt.ref, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseOCIRef(%q) = %+v, want %+v", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOCICarryOver(t *testing.T) {
+	image := ociImageConfig{
+		Env:        []string{"PATH=/usr/local/bin:/usr/bin", "APP_PORT=8080", "EMPTY=", "INVALID"},
+		Entrypoint: []string{"/docker-entrypoint.sh"},
+		Cmd:        []string{"nginx", "-g", "daemon off;"},
+		WorkingDir: "/srv",
+	}
+
+	tests := []struct {
+		name   string
+		config runtime.ContainerConfig
+		want   runtime.ContainerConfig
+	}{
+		{
+			name:   "image defaults",
+			config: runtime.ContainerConfig{Name: "web"},
+			want: runtime.ContainerConfig{
+				Name:        "web",
+				Environment: map[string]string{"PATH": "/usr/local/bin:/usr/bin", "APP_PORT": "8080", "EMPTY": ""},
+				BuildArgs:   map[string]string{"PATH": "/usr/local/bin:/usr/bin", "APP_PORT": "8080", "EMPTY": ""},
+				Entrypoint:  []string{"/docker-entrypoint.sh"},
+				Command:     []string{"nginx", "-g", "daemon off;"},
+				WorkingDir:  "/srv",
+			},
+		},
+		{
+			name: "container config wins",
+			config: runtime.ContainerConfig{
+				Name:        "web",
+				Environment: map[string]string{"APP_PORT": "9090", "MODE": "prod"},
+				BuildArgs:   map[string]string{"MODE": "build", "VERSION": "2"},
+				Entrypoint:  []string{"/bin/app"},
+				Command:     []string{"serve"},
+				WorkingDir:  "/app",
+			},
+			want: runtime.ContainerConfig{
+				Name:        "web",
+				Environment: map[string]string{"PATH": "/usr/local/bin:/usr/bin", "APP_PORT": "9090", "EMPTY": "", "MODE": "prod"},
+				BuildArgs:   map[string]string{"PATH": "/usr/local/bin:/usr/bin", "APP_PORT": "9090", "EMPTY": "", "MODE": "build", "VERSION": "2"},
+				Entrypoint:  []string{"/bin/app"},
+				Command:     []string{"serve"},
+				WorkingDir:  "/app",
+			},
+		},
+		{
+			name:   "command without entrypoint",
+			config: runtime.ContainerConfig{Name: "web", Command: []string{"nginx", "-t"}},
+			want: runtime.ContainerConfig{
+				Name:        "web",
+				Environment: map[string]string{"PATH": "/usr/local/bin:/usr/bin", "APP_PORT": "8080", "EMPTY": ""},
+				BuildArgs:   map[string]string{"PATH": "/usr/local/bin:/usr/bin", "APP_PORT": "8080", "EMPTY": ""},
+				Entrypoint:  []string{"/docker-entrypoint.sh"},
+				Command:     []string{"nginx", "-t"},
+				WorkingDir:  "/srv",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ociCarryOver(image, tt.config)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ociCarryOver =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOCIStartupFiles(t *testing.T) {
+	if files := ociStartupFiles(runtime.ContainerConfig{}); files != nil {
+		t.Errorf("startup files without an entrypoint: %v", files)
+	}
+
+	files := ociStartupFiles(runtime.ContainerConfig{
+		Environment: map[string]string{"B": "it's", "A": "1"},
+		Entrypoint:  []string{"/docker-entrypoint.sh"},
+		Command:     []string{"nginx", "-g", "daemon off;"},
+		WorkingDir:  "/srv/my app",
+	})
+
+	want := "#!/bin/sh\n" +
+		"export A='1'\n" +
+		"export B='it'\"'\"'s'\n" +
+		"cd '/srv/my app'\n" +
+		"exec '/docker-entrypoint.sh' 'nginx' '-g' 'daemon off;'\n"
+	if got := files[ociEntrypointPath]; got != want {
+		t.Errorf("entrypoint =\n%s\nwant\n%s", got, want)
+	}
+	if !strings.Contains(files[provisionScript], ociEntrypointPath) {
+		t.Errorf("provision script does not start the entrypoint: %q", files[provisionScript])
+	}
+}
+
+func TestOCITemplateName(t *testing.T) {
+	tests := []struct {
+		ref  OCIRef
+		want string
+	}{
+		{OCIRef{"registry-1.docker.io", "library/nginx", "latest"}, "oci-library-nginx-latest.tar.gz"},
+		{OCIRef{"ghcr.io", "org/app", "v1.2"}, "oci-org-app-v1.2.tar.gz"},
+		{OCIRef{"registry-1.docker.io", "library/alpine", "sha256:c5b1261d"}, "oci-library-alpine-sha256-c5b1261d.tar.gz"},
+	}
+
+	for _, tt := range tests {
+		if got := ociTemplateName(tt.ref); got != tt.want {
+			t.Errorf("ociTemplateName(%+v) = %s, want %s", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestOCIEntrypointStarted(t *testing.T) {
+	tests := []struct {
+		name      string
+		image     *ociImageConfig // nil for a container created from a plain template
+		args      map[string]string
+		provision bool
+	}{
+		{"entrypoint and env", &ociImageConfig{Env: []string{"APP_PORT=8080"}, Entrypoint: []string{"/bin/app"}}, nil, true},
+		{"entrypoint without env", &ociImageConfig{Entrypoint: []string{"/bin/app"}}, nil, true},
+		{"command without env", &ociImageConfig{Cmd: []string{"/bin/app", "serve"}}, nil, true},
+		{"plain template", nil, nil, false},
+		{"plain template with build args", nil, map[string]string{"MODE": "prod"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+
+			config := runtime.ContainerConfig{Name: "app", Image: testImage, BuildArgs: tt.args}
+			if tt.image != nil {
+				config = ociCarryOver(*tt.image, config)
+			}
+			id, err := p.Create(config)
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			transport := &fakeTransport{}
+			p.SetExecTransport(transport)
+			if err := p.Start(id); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+
+			provisioned := 0
+			for _, command := range transport.commands {
+				if strings.Contains(command, provisionScript) {
+					provisioned++
+				}
+			}
+			if (provisioned == 1) != tt.provision || provisioned > 1 {
+				t.Errorf("provision script run %d times, want provisioning %v", provisioned, tt.provision)
+			}
+			if got := p.metadata.HasLabel(atoi(t, id), LabelProvisioned); got != tt.provision {
+				t.Errorf("provisioned label set %v, want %v", got, tt.provision)
+			}
+		})
+	}
+}
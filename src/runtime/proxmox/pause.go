@@ -0,0 +1,72 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Pausing Proxmox containers
+// Pause and Unpause map onto the experimental LXC suspend/resume API, which
+// freezes the container processes. Not every setup supports it (suspend to
+// disk needs a storage able to hold the state, and some Proxmox versions do
+// not implement it for containers): those rejections are returned as
+// runtime.ErrNotSupported so callers can fall back to Stop
+
+// Pause suspends a running container
+func (p *ProxmoxRuntime) Pause(id string) error {
+	return p.changeSuspendState(id, "suspend")
+}
+
+// Unpause resumes a suspended container
+func (p *ProxmoxRuntime) Unpause(id string) error {
+	return p.changeSuspendState(id, "resume")
+}
+
+func (p *ProxmoxRuntime) changeSuspendState(id, action string) error {
+	defer p.cache.invalidate()
+
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("invalid container ID: %s", id)
+	}
+
+	node := p.nodeFor(vmid)
+	resp, err := p.lockedRequest(node, vmid, "POST", fmt.Sprintf("/nodes/%s/lxc/%d/status/%s", node, vmid, action), nil)
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
+	if err == nil {
+		err = p.waitForTask(taskUPID(resp))
+	}
+	if err != nil {
+		if isUnsupported(err) {
+			return fmt.Errorf("failed to %s container %s: %w: %v", action, id, runtime.ErrNotSupported, err)
+		}
+		return fmt.Errorf("failed to %s container %s: %w", action, id, err)
+	}
+
+	utils.Log(fmt.Sprintf("LXC container VMID %d: %s done", vmid, action))
+	return nil
+}
+
+// isUnsupported reports whether Proxmox rejected an operation as not implemented
+func isUnsupported(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotImplemented {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, marker := range []string{"not supported", "not implemented", "unable to suspend", "cannot suspend"} {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Placement of new containers across Proxmox nodes
+// The configured node is preferred; other online nodes are only considered
+// when it is in maintenance or when affinity rules require it. A cosmos-node
+// label on the config (see WithNode) pins the container to that node instead
+
+// ErrAffinityUnsatisfiable is returned when no node satisfies every affinity rule
+var ErrAffinityUnsatisfiable = errors.New("affinity rules cannot be satisfied")
+
+// CheckAffinity reports the node a container would be placed on, or why no node fits
+func (p *ProxmoxRuntime) CheckAffinity(config runtime.ContainerConfig) (string, error) {
+	if !p.connected {
+		return "", errNotConnected
+	}
+
+	var candidates []string
+	var err error
+	if pinned := config.Labels[LabelNode]; pinned != "" {
+		candidates, err = p.pinnedNode(pinned)
+	} else {
+		candidates, err = p.candidateNodes(len(config.Affinity) > 0)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return selectAffinityNode(candidates, config.Affinity, p.nodesMatching)
+}
+
+// candidateNodes lists the nodes a container may be placed on, configured node first.
+// Other nodes are only looked up when all is set or the configured node is in maintenance.
+func (p *ProxmoxRuntime) candidateNodes(all bool) ([]string, error) {
+	var candidates []string
+
+	maintenanceErr := p.checkMaintenance(p.node)
+	if maintenanceErr == nil {
+		candidates = append(candidates, p.node)
+		if !all {
+			return candidates, nil
+		}
+	}
+
+	resp, err := p.apiRequest("GET", "/cluster/resources?type=node", nil)
+	if err == nil {
+		for _, row := range listItems(resp) {
+			node, _ := row["node"].(string)
+			if status, _ := row["status"].(string); node == "" || node == p.node || status != "online" {
+				continue
+			}
+			if p.checkMaintenance(node) == nil {
+				candidates = append(candidates, node)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, maintenanceErr
+	}
+	if maintenanceErr != nil {
+		utils.Warn(fmt.Sprintf("Node %s is in maintenance, placing containers on other nodes", p.node))
+	}
+	return candidates, nil
+}
+
+// nodesMatching returns the nodes hosting containers matching the selector
+func (p *ProxmoxRuntime) nodesMatching(selector runtime.LabelSelector) map[string]bool {
+	nodes := make(map[string]bool)
+	for _, vmid := range p.metadata.FindBySelector(selector) {
+		nodes[p.nodeFor(vmid)] = true
+	}
+	return nodes
+}
+
+// selectAffinityNode returns the first candidate satisfying every rule.
+// An affinity rule without any matching container is trivially satisfied.
+func selectAffinityNode(candidates []string, rules []runtime.AffinityRule, nodesMatching func(runtime.LabelSelector) map[string]bool) (string, error) {
+	allowed := make(map[string]bool, len(candidates))
+	for _, node := range candidates {
+		allowed[node] = true
+	}
+
+	for _, rule := range rules {
+		if len(rule.Selector) == 0 {
+			continue
+		}
+
+		members := nodesMatching(rule.Selector)
+		for node := range allowed {
+			if rule.Anti && members[node] {
+				delete(allowed, node)
+			}
+			if !rule.Anti && len(members) > 0 && !members[node] {
+				delete(allowed, node)
+			}
+		}
+
+		if len(allowed) == 0 {
+			kind := "affinity"
+			if rule.Anti {
+				kind = "anti-affinity"
+			}
+			return "", fmt.Errorf("%w: %s rule on %v", ErrAffinityUnsatisfiable, kind, map[string]string(rule.Selector))
+		}
+	}
+
+	for _, node := range candidates {
+		if allowed[node] {
+			return node, nil
+		}
+	}
+	return "", ErrAffinityUnsatisfiable
+}
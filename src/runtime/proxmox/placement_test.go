@@ -0,0 +1,101 @@
+package proxmox
+
+import (
+	"errors"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestSelectAffinityNode(t *testing.T) {
+	// db runs on pve2, cache on pve1 and pve3
+	members := map[string]map[string]bool{
+		"db":    {"pve2": true},
+		"cache": {"pve1": true, "pve3": true},
+	}
+	nodesMatching := func(selector runtime.LabelSelector) map[string]bool {
+		return members[selector["app"]]
+	}
+	candidates := []string{"pve1", "pve2", "pve3"}
+
+	tests := []struct {
+		name  string
+		rules []runtime.AffinityRule
+		want  string // empty when unsatisfiable
+	}{
+		{"no rules", nil, "pve1"},
+		{"affinity", []runtime.AffinityRule{{Selector: runtime.LabelSelector{"app": "db"}}}, "pve2"},
+		{"anti-affinity", []runtime.AffinityRule{{Selector: runtime.LabelSelector{"app": "cache"}, Anti: true}}, "pve2"},
+		{"affinity without members", []runtime.AffinityRule{{Selector: runtime.LabelSelector{"app": "web"}}}, "pve1"},
+		{"empty selector", []runtime.AffinityRule{{Anti: true}}, "pve1"},
+		{"combined", []runtime.AffinityRule{
+			{Selector: runtime.LabelSelector{"app": "cache"}},
+			{Selector: runtime.LabelSelector{"app": "db"}, Anti: true},
+		}, "pve1"},
+		{"conflicting", []runtime.AffinityRule{
+			{Selector: runtime.LabelSelector{"app": "db"}},
+			{Selector: runtime.LabelSelector{"app": "db"}, Anti: true},
+		}, ""},
+		{"anti-affinity with every node", []runtime.AffinityRule{
+			{Selector: runtime.LabelSelector{"app": "db"}, Anti: true},
+			{Selector: runtime.LabelSelector{"app": "cache"}, Anti: true},
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := selectAffinityNode(candidates, tt.rules, nodesMatching)
+			if tt.want == "" {
+				if !errors.Is(err, ErrAffinityUnsatisfiable) {
+					t.Fatalf("selectAffinityNode = %q, %v, want ErrAffinityUnsatisfiable", node, err)
+				}
+				return
+			}
+			if err != nil || node != tt.want {
+				t.Errorf("selectAffinityNode = %q, %v, want %s", node, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateWithAffinity(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []runtime.AffinityRule
+		want  string // empty when Create must fail
+	}{
+		{"next to the database", []runtime.AffinityRule{{Selector: runtime.LabelSelector{"app": "db"}}}, "pve2"},
+		{"away from the database", []runtime.AffinityRule{{Selector: runtime.LabelSelector{"app": "db"}, Anti: true}}, "pve"},
+		{"conflicting rules", []runtime.AffinityRule{
+			{Selector: runtime.LabelSelector{"app": "db"}},
+			{Selector: runtime.LabelSelector{"app": "db"}, Anti: true},
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t, "pve", "pve2", "pve3")
+			cluster.addGuest(100, fakeGuest{Node: "pve2", Config: map[string]interface{}{"hostname": "db"}})
+			backend := NewMemoryBackend()
+			backend.Set(100, map[string]string{"cosmos-name": "db", LabelManaged: "true", LabelNode: "pve2", "app": "db"})
+			p := newTestRuntime(t, cluster, func(c *Config) { c.MetadataBackend = backend })
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "api", Image: testImage, Affinity: tt.rules})
+			if tt.want == "" {
+				if !errors.Is(err, ErrAffinityUnsatisfiable) {
+					t.Fatalf("Create error = %v, want ErrAffinityUnsatisfiable", err)
+				}
+				if n := cluster.lxcCount(); n != 1 {
+					t.Errorf("%d containers on the cluster, want 1", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			if node := cluster.guest(atoi(t, id)).Node; node != tt.want {
+				t.Errorf("created on %s, want %s", node, tt.want)
+			}
+		})
+	}
+}
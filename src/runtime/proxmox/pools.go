@@ -0,0 +1,74 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/url"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Proxmox pools
+// Containers created by Cosmos join the pool of the cosmos-pool label, or
+// Config.Pool, so operators can scope permissions and quotas to them. The pool
+// is passed to the create, clone and restore calls, so a container is never
+// left outside of it. Connect warns when the configured pool does not exist
+
+// LabelPool overrides the pool a container is added to
+const LabelPool = "cosmos-pool"
+
+// containerPool returns the pool of a new container, empty for none
+func (p *ProxmoxRuntime) containerPool(config runtime.ContainerConfig) string {
+	if pool := config.Labels[LabelPool]; pool != "" {
+		return pool
+	}
+	return p.config.Pool
+}
+
+// poolMembers is the response of /pools/{poolid}
+type poolMembers struct {
+	Members []LXCListItem `json:"members"`
+}
+
+// checkPool warns when the configured pool does not exist, as every Create would then fail
+func (p *ProxmoxRuntime) checkPool() {
+	if p.config.Pool == "" {
+		return
+	}
+
+	err := p.Client().Get("/pools/"+url.PathEscape(p.config.Pool), &poolMembers{})
+	if isNotFound(err) {
+		utils.Warn(fmt.Sprintf("Pool %s does not exist, create it in Proxmox or change the Pool setting", p.config.Pool))
+	} else if err != nil {
+		utils.Warn(fmt.Sprintf("Failed to check the pool %s: %s", p.config.Pool, err))
+	}
+}
+
+// ListPool returns the LXC containers of a pool, on any node, without
+// unmanaged ones unless IncludeUnmanaged is set
+func (p *ProxmoxRuntime) ListPool(pool string) ([]runtime.Container, error) {
+	if !p.connected {
+		return nil, errNotConnected
+	}
+
+	var resp poolMembers
+	err := p.Client().Get("/pools/"+url.PathEscape(pool), &resp)
+	if isNotFound(err) {
+		return nil, fmt.Errorf("pool %s does not exist", pool)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pool %s: %w", pool, err)
+	}
+
+	var containers []runtime.Container
+	for _, member := range resp.Members {
+		if member.Type != "lxc" || member.VMID.Int() == 0 {
+			continue
+		}
+		container := p.containerFromStatus(member.VMID.Int(), member.LXCStatus)
+		if p.listed(container.Labels) {
+			containers = append(containers, container)
+		}
+	}
+	return containers, nil
+}
@@ -0,0 +1,318 @@
+package proxmox
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Published ports of Proxmox containers
+// LXC cannot publish ports, so ContainerConfig.Ports become iptables DNAT
+// rules on the node, from HostIP:HostPort to the container address. The rules
+// of a container live in their own chain (COSMOS-PF-<vmid>, in the nat and
+// filter tables) which Start rebuilds once the container has an address, and
+// Remove deletes. The requested ports are kept in cosmos-ports and the rules
+// installed in cosmos-port-rules, so they can be reconciled after a restart.
+// Both labels are reserved: stored ports are validated again before every
+// use and each value is quoted in the scripts run as root on the node.
+// Rules are installed on the node of the container, reached from the SSH node
+// over the cluster SSH; without SSH access they are skipped with a warning
+
+const (
+	// LabelPorts holds the published ports as "hostIP:hostPort:containerPort/protocol,..."
+	LabelPorts = "cosmos-ports"
+
+	// LabelPortRules holds the installed rules as "protocol hostIP:hostPort->ip:port,..."
+	LabelPortRules = "cosmos-port-rules"
+
+	addressWaitTimeout = 30 * time.Second
+	addressPollDelay   = 2 * time.Second
+)
+
+// portRule is one forwarding rule from the node to a container
+type portRule struct {
+	protocol string
+	hostIP   string // empty for every address of the node
+	hostPort int
+	port     int
+}
+
+// validatePorts checks the published ports of a config and renders them as the LabelPorts value
+func validatePorts(ports []runtime.PortMapping) (string, error) {
+	entries := make([]string, 0, len(ports))
+	for _, mapping := range ports {
+		rule, err := parsePortMapping(mapping)
+		if err != nil {
+			return "", err
+		}
+		entries = append(entries, fmt.Sprintf("%s:%d:%d/%s", rule.hostIP, rule.hostPort, rule.port, rule.protocol))
+	}
+	return strings.Join(entries, ","), nil
+}
+
+// parsePortMapping validates a port mapping. The host port defaults to the container port
+func parsePortMapping(mapping runtime.PortMapping) (portRule, error) {
+	rule := portRule{protocol: strings.ToLower(mapping.Protocol)}
+	if rule.protocol == "" {
+		rule.protocol = "tcp"
+	}
+	if rule.protocol != "tcp" && rule.protocol != "udp" {
+		return rule, fmt.Errorf("unsupported protocol %q for port %s (tcp or udp)", mapping.Protocol, mapping.ContainerPort)
+	}
+
+	var err error
+	if rule.port, err = parsePort(mapping.ContainerPort); err != nil {
+		return rule, err
+	}
+	rule.hostPort = rule.port
+	if mapping.HostPort != "" {
+		if rule.hostPort, err = parsePort(mapping.HostPort); err != nil {
+			return rule, err
+		}
+	}
+
+	if mapping.HostIP != "" && mapping.HostIP != "0.0.0.0" {
+		ip := net.ParseIP(mapping.HostIP)
+		if ip == nil || ip.To4() == nil {
+			return rule, fmt.Errorf("host IP %s must be an IPv4 address", mapping.HostIP)
+		}
+		rule.hostIP = mapping.HostIP
+	}
+	return rule, nil
+}
+
+func parsePort(value string) (int, error) {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q", value)
+	}
+	return port, nil
+}
+
+// storedPorts returns the published ports of a container. Each entry is
+// validated again, as the label ends up in commands run on the node
+func (p *ProxmoxRuntime) storedPorts(vmid int) []portRule {
+	var rules []portRule
+	for _, entry := range strings.Split(p.metadata.GetLabel(vmid, LabelPorts), ",") {
+		if entry == "" {
+			continue
+		}
+		rule, err := parseStoredPort(entry)
+		if err != nil {
+			utils.Warn(fmt.Sprintf("Ignoring published port %q of LXC container VMID %d: %s", entry, vmid, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// parseStoredPort parses a "hostIP:hostPort:containerPort/protocol" entry of LabelPorts
+func parseStoredPort(entry string) (portRule, error) {
+	spec, protocol, _ := strings.Cut(entry, "/")
+	parts := strings.Split(spec, ":")
+	if len(parts) != 3 {
+		return portRule{}, fmt.Errorf("expected hostIP:hostPort:containerPort/protocol")
+	}
+	return parsePortMapping(runtime.PortMapping{HostIP: parts[0], HostPort: parts[1], ContainerPort: parts[2], Protocol: protocol})
+}
+
+// applyPorts rebuilds the forwarding rules of a started container
+func (p *ProxmoxRuntime) applyPorts(vmid int) {
+	rules := p.storedPorts(vmid)
+	if len(rules) == 0 {
+		return
+	}
+
+	ip, err := p.waitForAddress(vmid)
+	if err == nil {
+		err = p.runOnNodeAt(p.nodeFor(vmid), portRulesScript(vmid, ip, rules))
+	}
+	if err != nil {
+		utils.Warn(fmt.Sprintf("Skipping published ports of LXC container VMID %d: %s", vmid, err))
+		return
+	}
+
+	installed := make([]string, len(rules))
+	for i, rule := range rules {
+		host := rule.hostIP
+		if host == "" {
+			host = "0.0.0.0"
+		}
+		installed[i] = fmt.Sprintf("%s %s:%d->%s:%d", rule.protocol, host, rule.hostPort, ip, rule.port)
+	}
+	p.metadata.SetLabel(vmid, LabelPortRules, strings.Join(installed, ","))
+	utils.Log(fmt.Sprintf("Published ports of LXC container VMID %d: %s", vmid, strings.Join(installed, ", ")))
+}
+
+// removePorts deletes the forwarding rules of a container from node
+func (p *ProxmoxRuntime) removePorts(vmid int, node string) {
+	if p.metadata.GetLabel(vmid, LabelPortRules) == "" {
+		return
+	}
+	if err := p.runOnNodeAt(node, portCleanupScript(vmid)); err != nil {
+		utils.Warn(fmt.Sprintf("Failed to remove the port rules of LXC container VMID %d: %s", vmid, err))
+	}
+}
+
+// waitForAddress returns the IPv4 address of eth0, waiting for DHCP if needed
+func (p *ProxmoxRuntime) waitForAddress(vmid int) (string, error) {
+	deadline := time.Now().Add(addressWaitTimeout)
+	for {
+		resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/interfaces", p.nodeFor(vmid), vmid), nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to get the container address: %w", err)
+		}
+		for _, iface := range listItems(resp) {
+			if iface["name"] != "eth0" {
+				continue
+			}
+			inet, _ := iface["inet"].(string)
+			if ip, _, err := net.ParseCIDR(inet); err == nil {
+				return ip.String(), nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("container has no IPv4 address on eth0 after %s", addressWaitTimeout)
+		}
+		time.Sleep(addressPollDelay)
+	}
+}
+
+// runOnNode runs a shell script on the node
+func (p *ProxmoxRuntime) runOnNode(script string) error {
+	_, err := p.runOnNodeOutput(script)
+	return err
+}
+
+// runOnNodeAt runs a shell script on node. Other nodes of the cluster are
+// reached from the SSH node with the root SSH access Proxmox sets up between nodes
+func (p *ProxmoxRuntime) runOnNodeAt(node, script string) error {
+	if node != "" && node != p.node {
+		script = p.nodeCommand(node, "sh -c "+shellQuote(script), false)
+	}
+	return p.runOnNode(script)
+}
+
+// runOnNodeOutput runs a shell script on the node and returns its output
+func (p *ProxmoxRuntime) runOnNodeOutput(script string) (string, error) {
+	transport, err := p.execTransport()
+	if err != nil {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode, err := transport.Run("sh -c "+shellQuote(script), nil, &stdout, &stderr)
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("exited with code %d: %s", exitCode, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func portChain(vmid int) string {
+	return fmt.Sprintf("COSMOS-PF-%d", vmid)
+}
+
+// portChainPattern matches the chains of portChain in iptables -S output
+var portChainPattern = regexp.MustCompile(`-N COSMOS-PF-(\d+)`)
+
+// reconcilePorts syncs the forwarding rules of the node with its containers:
+// chains of containers that no longer exist are deleted, and running
+// containers with published ports whose chain is missing get their rules back.
+// live holds the containers of the cluster by VMID. Without SSH access to the
+// node nothing is done
+func (p *ProxmoxRuntime) reconcilePorts(live map[int]map[string]interface{}) {
+	output, err := p.runOnNodeOutput("iptables -t nat -S")
+	if err != nil {
+		utils.Debug("Skipping port rules reconciliation: " + err.Error())
+		return
+	}
+
+	chains := make(map[int]bool)
+	for _, m := range portChainPattern.FindAllStringSubmatch(output, -1) {
+		vmid, _ := strconv.Atoi(m[1])
+		chains[vmid] = true
+	}
+
+	for vmid := range chains {
+		if _, ok := live[vmid]; ok {
+			continue
+		}
+		// Confirm the container is gone, the listing may be partial
+		_, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/status/current", p.nodeFor(vmid), vmid), nil)
+		if !isNotFound(err) {
+			continue
+		}
+		if err := p.runOnNode(portCleanupScript(vmid)); err != nil {
+			utils.Warn(fmt.Sprintf("Failed to remove the port rules of removed LXC container VMID %d: %s", vmid, err))
+			continue
+		}
+		utils.Log(fmt.Sprintf("Removed orphaned port rules of LXC container VMID %d (%s)", vmid, portChain(vmid)))
+	}
+
+	for vmid, item := range live {
+		node, _ := item["node"].(string)
+		status, _ := item["status"].(string)
+		if chains[vmid] || node != p.node || status != "running" || p.metadata.GetLabel(vmid, LabelPorts) == "" {
+			continue
+		}
+		utils.Log(fmt.Sprintf("Restoring the missing port rules of LXC container VMID %d", vmid))
+		p.applyPorts(vmid)
+	}
+}
+
+// portJumps are the rules sending traffic to the chain of a container, by table
+var portJumps = [][2]string{
+	{"nat", "PREROUTING -m addrtype --dst-type LOCAL"},
+	{"nat", "OUTPUT -m addrtype --dst-type LOCAL"},
+	{"filter", "FORWARD"},
+}
+
+// portRulesScript renders the commands (re)creating the chains of a container
+func portRulesScript(vmid int, ip string, rules []portRule) string {
+	chain := portChain(vmid)
+	var script strings.Builder
+	script.WriteString("set -e\n")
+	for _, table := range []string{"nat", "filter"} {
+		fmt.Fprintf(&script, "iptables -t %[1]s -N %[2]s 2>/dev/null || iptables -t %[1]s -F %[2]s\n", table, chain)
+	}
+	for _, jump := range portJumps {
+		fmt.Fprintf(&script, "iptables -t %[1]s -C %[2]s -j %[3]s 2>/dev/null || iptables -t %[1]s -A %[2]s -j %[3]s\n", jump[0], jump[1], chain)
+	}
+
+	for _, rule := range rules {
+		destination := ""
+		if rule.hostIP != "" {
+			destination = "-d " + shellQuote(rule.hostIP) + " "
+		}
+		protocol := shellQuote(rule.protocol)
+		fmt.Fprintf(&script, "iptables -t nat -A %s %s-p %s --dport %d -j DNAT --to-destination %s\n", chain, destination, protocol, rule.hostPort, shellQuote(fmt.Sprintf("%s:%d", ip, rule.port)))
+		fmt.Fprintf(&script, "iptables -t filter -A %s -d %s -p %s --dport %d -j ACCEPT\n", chain, shellQuote(ip), protocol, rule.port)
+	}
+	return script.String()
+}
+
+// portCleanupScript renders the commands deleting the chains of a container
+func portCleanupScript(vmid int) string {
+	chain := portChain(vmid)
+	var script strings.Builder
+	for _, jump := range portJumps {
+		fmt.Fprintf(&script, "while iptables -t %s -D %s -j %s 2>/dev/null; do :; done\n", jump[0], jump[1], chain)
+	}
+	for _, table := range []string{"nat", "filter"} {
+		fmt.Fprintf(&script, "iptables -t %[1]s -F %[2]s 2>/dev/null; iptables -t %[1]s -X %[2]s 2>/dev/null\n", table, chain)
+	}
+	script.WriteString("true\n")
+	return script.String()
+}
@@ -0,0 +1,160 @@
+package proxmox
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Resource pressure for Proxmox nodes
+// Every score is normalized between 0 (idle) and 1 (saturated) so it can be
+// compared across nodes of different sizes by placement and alerting code
+
+// Pressure holds normalized resource pressure scores
+type Pressure struct {
+	Node    string    `json:"node"`
+	CPU     float64   `json:"cpu"`
+	Memory  float64   `json:"memory"`
+	IOWait  float64   `json:"ioWait"`
+	Storage float64   `json:"storage"`
+	Overall float64   `json:"overall"`
+	Cluster *Pressure `json:"cluster,omitempty"` // roll-up across online nodes, nil on single node setups
+}
+
+// NodePressure returns the pressure of the configured node, with a cluster roll-up when multi-node
+func (p *ProxmoxRuntime) NodePressure() (*Pressure, error) {
+	if !p.connected {
+		return nil, errNotConnected
+	}
+
+	status, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/status", p.node), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node status: %w", err)
+	}
+
+	storages, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/storage", p.node), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node storage: %w", err)
+	}
+
+	pressure := computeNodePressure(p.node, status, listItems(storages))
+
+	resources, err := p.apiRequest("GET", "/cluster/resources?type=node", nil)
+	if err == nil {
+		pressure.Cluster = computeClusterPressure(listItems(resources))
+	}
+
+	return pressure, nil
+}
+
+// computeNodePressure builds a Pressure from a /nodes/{node}/status payload and its storage list
+func computeNodePressure(node string, status map[string]interface{}, storages []map[string]interface{}) *Pressure {
+	pressure := &Pressure{Node: node}
+
+	// CPU: the worst of instant usage and 1 minute load per core
+	pressure.CPU = floatValue(status["cpu"])
+	if cpuinfo, ok := status["cpuinfo"].(map[string]interface{}); ok {
+		if cpus := floatValue(cpuinfo["cpus"]); cpus > 0 {
+			if loadavg, ok := status["loadavg"].([]interface{}); ok && len(loadavg) > 0 {
+				pressure.CPU = math.Max(pressure.CPU, floatValue(loadavg[0])/cpus)
+			}
+		}
+	}
+
+	if memory, ok := status["memory"].(map[string]interface{}); ok {
+		pressure.Memory = ratio(floatValue(memory["used"]), floatValue(memory["total"]))
+	}
+
+	pressure.IOWait = floatValue(status["wait"])
+
+	// Storage: the fullest active storage
+	for _, storage := range storages {
+		if active, ok := storage["active"]; ok && floatValue(active) == 0 {
+			continue
+		}
+		pressure.Storage = math.Max(pressure.Storage, ratio(floatValue(storage["used"]), floatValue(storage["total"])))
+	}
+
+	pressure.normalize()
+	return pressure
+}
+
+// computeClusterPressure rolls up /cluster/resources?type=node rows, weighted by node capacity.
+// Returns nil when the cluster has a single node.
+func computeClusterPressure(nodes []map[string]interface{}) *Pressure {
+	var cpuUsed, cpuTotal, memUsed, memTotal, diskUsed, diskTotal float64
+	online := 0
+
+	for _, node := range nodes {
+		if status, _ := node["status"].(string); status != "online" {
+			continue
+		}
+		online++
+
+		maxcpu := floatValue(node["maxcpu"])
+		cpuUsed += floatValue(node["cpu"]) * maxcpu
+		cpuTotal += maxcpu
+		memUsed += floatValue(node["mem"])
+		memTotal += floatValue(node["maxmem"])
+		diskUsed += floatValue(node["disk"])
+		diskTotal += floatValue(node["maxdisk"])
+	}
+
+	if online < 2 {
+		return nil
+	}
+
+	pressure := &Pressure{
+		Node:    "cluster",
+		CPU:     ratio(cpuUsed, cpuTotal),
+		Memory:  ratio(memUsed, memTotal),
+		Storage: ratio(diskUsed, diskTotal),
+	}
+	pressure.normalize()
+	return pressure
+}
+
+// normalize clamps every score to [0,1] and computes the overall score as the worst one
+func (pr *Pressure) normalize() {
+	pr.CPU = clamp01(pr.CPU)
+	pr.Memory = clamp01(pr.Memory)
+	pr.IOWait = clamp01(pr.IOWait)
+	pr.Storage = clamp01(pr.Storage)
+	pr.Overall = math.Max(math.Max(pr.CPU, pr.Memory), math.Max(pr.IOWait, pr.Storage))
+}
+
+func ratio(used, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return used / total
+}
+
+func clamp01(v float64) float64 {
+	return math.Min(math.Max(v, 0), 1)
+}
+
+// floatValue reads a number that Proxmox may encode either as a JSON number or a string
+func floatValue(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case string:
+		f, _ := strconv.ParseFloat(n, 64)
+		return f
+	}
+	return 0
+}
+
+// listItems returns the rows of a list response, skipping anything that is not an object
+func listItems(resp map[string]interface{}) []map[string]interface{} {
+	var items []map[string]interface{}
+	if data, ok := resp["data"].([]interface{}); ok {
+		for _, item := range data {
+			if row, ok := item.(map[string]interface{}); ok {
+				items = append(items, row)
+			}
+		}
+	}
+	return items
+}
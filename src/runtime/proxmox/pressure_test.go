@@ -0,0 +1,120 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// nodeStatus is a /nodes/{node}/status payload recorded on a 16 core node
+const nodeStatus = `{
+	"cpu": 0.25,
+	"wait": 0.05,
+	"loadavg": ["8.00", "6.50", "4.10"],
+	"cpuinfo": {"cpus": 16, "cores": 8, "sockets": 1, "model": "AMD Ryzen 7 5800X"},
+	"memory": {"used": 51539607552, "total": 68719476736, "free": 17179869184},
+	"uptime": 864000
+}`
+
+func decodeJSON(t *testing.T, payload string, out interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(payload), out); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+}
+
+func TestComputeNodePressure(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		storages string
+		want     Pressure
+	}{
+		{"recorded node", nodeStatus, `[
+			{"storage": "local", "used": 20, "total": 100, "active": 1},
+			{"storage": "local-lvm", "used": 90, "total": 100, "active": 1},
+			{"storage": "nfs", "used": 100, "total": 100, "active": 0}
+		]`, Pressure{Node: "pve", CPU: 0.5, Memory: 0.75, IOWait: 0.05, Storage: 0.9, Overall: 0.9}},
+		{"load above the core count is clamped", `{
+			"cpu": 0.9, "loadavg": ["40.0"], "cpuinfo": {"cpus": 8},
+			"memory": {"used": 1, "total": 4}
+		}`, `[]`, Pressure{Node: "pve", CPU: 1, Memory: 0.25, Overall: 1}},
+		{"instant usage above the load", `{"cpu": 0.6, "loadavg": ["1.0"], "cpuinfo": {"cpus": 4}}`, `[]`,
+			Pressure{Node: "pve", CPU: 0.6, Overall: 0.6}},
+		{"numbers as strings", `{"cpu": "0.1", "wait": "0.3", "memory": {"used": "2", "total": "8"}}`,
+			`[{"used": "5", "total": "10"}]`, Pressure{Node: "pve", CPU: 0.1, Memory: 0.25, IOWait: 0.3, Storage: 0.5, Overall: 0.5}},
+		{"missing totals", `{"memory": {"used": 10, "total": 0}}`, `[{"used": 10}]`, Pressure{Node: "pve"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status map[string]interface{}
+			var storages []map[string]interface{}
+			decodeJSON(t, tt.status, &status)
+			decodeJSON(t, tt.storages, &storages)
+
+			got := computeNodePressure("pve", status, storages)
+			assertPressure(t, got, &tt.want)
+		})
+	}
+}
+
+func TestComputeClusterPressure(t *testing.T) {
+	tests := []struct {
+		name  string
+		nodes string
+		want  *Pressure
+	}{
+		{"single node", `[{"node": "pve", "status": "online", "cpu": 0.5, "maxcpu": 8}]`, nil},
+		{"weighted by capacity", `[
+			{"node": "pve1", "status": "online", "cpu": 1.0, "maxcpu": 4, "mem": 6, "maxmem": 8, "disk": 10, "maxdisk": 100},
+			{"node": "pve2", "status": "online", "cpu": 0.25, "maxcpu": 12, "mem": 2, "maxmem": 8, "disk": 30, "maxdisk": 100}
+		]`, &Pressure{Node: "cluster", CPU: 0.4375, Memory: 0.5, Storage: 0.2, Overall: 0.5}},
+		{"offline nodes are skipped", `[
+			{"node": "pve1", "status": "online", "cpu": 0.5, "maxcpu": 4, "mem": 1, "maxmem": 4},
+			{"node": "pve2", "status": "offline", "cpu": 1.0, "maxcpu": 4, "mem": 4, "maxmem": 4},
+			{"node": "pve3", "status": "online", "cpu": 0.5, "maxcpu": 4, "mem": 1, "maxmem": 4}
+		]`, &Pressure{Node: "cluster", CPU: 0.5, Memory: 0.25, Overall: 0.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var nodes []map[string]interface{}
+			decodeJSON(t, tt.nodes, &nodes)
+
+			got := computeClusterPressure(nodes)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("computeClusterPressure = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("computeClusterPressure = nil")
+			}
+			assertPressure(t, got, tt.want)
+		})
+	}
+}
+
+func assertPressure(t *testing.T, got, want *Pressure) {
+	t.Helper()
+	if got.Node != want.Node {
+		t.Errorf("Node = %q, want %q", got.Node, want.Node)
+	}
+	scores := []struct {
+		name      string
+		got, want float64
+	}{
+		{"CPU", got.CPU, want.CPU},
+		{"Memory", got.Memory, want.Memory},
+		{"IOWait", got.IOWait, want.IOWait},
+		{"Storage", got.Storage, want.Storage},
+		{"Overall", got.Overall, want.Overall},
+	}
+	for _, s := range scores {
+		if math.Abs(s.got-s.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", s.name, s.got, s.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package proxmox
+
+import (
+	"context"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Creation phases reported by CreateWithProgress
+const (
+	PhaseAllocate    = "allocate"
+	PhaseCreate      = "create"
+	PhaseWaitTask    = "wait-task"
+	PhaseMetadata    = "metadata"
+	PhaseStart       = "start"
+	PhaseProvision   = "provision"
+	PhasePostInstall = "post-install"
+	PhaseReady       = "ready"
+	PhaseDone        = "done"
+)
+
+// progressFunc receives a phase, a human readable message and a percentage.
+// taskLog marks the lines of a Proxmox task log, which may be dropped
+type progressFunc func(phase, message string, percent int, taskLog bool)
+
+// CreateWithProgress creates and starts a container, emitting a Progress event per
+// phase, per line of the create and start task logs and per PostInstall step.
+// The progress channel is closed once creation ends, after which the single
+// CreateResult is delivered on the result channel. Task log lines are dropped
+// when the reader falls behind; the phase events, PhaseDone included, wait for
+// the reader until the runtime is closed.
+func (p *ProxmoxRuntime) CreateWithProgress(config runtime.ContainerConfig) (<-chan runtime.Progress, <-chan runtime.CreateResult) {
+	progress := make(chan runtime.Progress, 64)
+	result := make(chan runtime.CreateResult, 1)
+
+	p.goBackground(func(ctx context.Context) {
+		defer close(result)
+
+		report := func(phase, message string, percent int, taskLog bool) {
+			event := runtime.Progress{Phase: phase, Message: message, Percent: percent}
+			if taskLog {
+				// Rather than blocking creation on a reader that fell behind
+				select {
+				case progress <- event:
+				default:
+				}
+				return
+			}
+			select {
+			case progress <- event:
+			case <-ctx.Done():
+			}
+		}
+
+		start := time.Now()
+		id, err := p.create(config, report)
+		p.observe("create", start, err)
+		if err == nil {
+			report(PhaseStart, "Starting container", 80, false)
+			start = time.Now()
+			err = p.start(id, report)
+			p.observe("start", start, err)
+		}
+		if err == nil {
+			report(PhaseDone, "Container created", 100, false)
+		}
+
+		close(progress)
+		result <- runtime.CreateResult{ID: id, Error: err}
+	})
+
+	return progress, result
+}
@@ -0,0 +1,141 @@
+package proxmox
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestCreateWithProgress(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      runtime.ContainerConfig
+		failStart   bool
+		wantPhases  []string // distinct consecutive phases
+		wantErr     bool
+		wantCreated bool
+	}{
+		{
+			name:        "successful create",
+			wantPhases:  []string{PhaseAllocate, PhaseCreate, PhaseWaitTask, PhaseMetadata, PhaseStart, PhaseReady, PhaseDone},
+			wantCreated: true,
+		},
+		{
+			name:        "post-install steps",
+			config:      runtime.ContainerConfig{PostInstall: []string{"apt-get update", "apt-get install -y nginx"}},
+			wantPhases:  []string{PhaseAllocate, PhaseCreate, PhaseWaitTask, PhaseMetadata, PhaseStart, PhasePostInstall, PhaseReady, PhaseDone},
+			wantCreated: true,
+		},
+		{
+			name:       "invalid config",
+			config:     runtime.ContainerConfig{CPUs: -1},
+			wantPhases: []string{PhaseAllocate},
+			wantErr:    true,
+		},
+		{
+			name:        "start fails",
+			failStart:   true,
+			wantPhases:  []string{PhaseAllocate, PhaseCreate, PhaseWaitTask, PhaseMetadata, PhaseStart},
+			wantErr:     true,
+			wantCreated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			if tt.failStart {
+				cluster.handle("POST /nodes/pve/lxc/100/status/start", func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+					return http.StatusInternalServerError, "startup for container '100' failed"
+				})
+			}
+			p := newTestRuntime(t, cluster)
+			p.SetExecTransport(&fakeTransport{})
+
+			config := tt.config
+			config.Name, config.Image = "app", testImage
+			progress, result := p.CreateWithProgress(config)
+
+			var phases []string
+			last := 0
+			for event := range progress {
+				if len(phases) == 0 || phases[len(phases)-1] != event.Phase {
+					phases = append(phases, event.Phase)
+				}
+				if event.Percent < last || event.Percent > 100 {
+					t.Errorf("%s at %d%% after %d%%", event.Phase, event.Percent, last)
+				}
+				last = event.Percent
+			}
+			res, ok := <-result
+			if !ok {
+				t.Fatal("result channel closed without a result")
+			}
+
+			if !reflect.DeepEqual(phases, tt.wantPhases) {
+				t.Errorf("phases = %v, want %v", phases, tt.wantPhases)
+			}
+			if (res.Error != nil) != tt.wantErr {
+				t.Errorf("result error = %v, want error %v", res.Error, tt.wantErr)
+			}
+			if !tt.wantErr && res.ID == "" {
+				t.Error("result has no container ID")
+			}
+			if n := cluster.lxcCount(); (n == 1) != tt.wantCreated {
+				t.Errorf("%d containers created", n)
+			}
+			if _, ok := <-result; ok {
+				t.Error("result channel delivered a second result")
+			}
+		})
+	}
+}
+
+func TestCreateWithProgressSlowReader(t *testing.T) {
+	cluster := newFakeCluster(t)
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("extracting file %d", i))
+	}
+	cluster.setTaskLog(lines)
+	p := newTestRuntime(t, cluster)
+
+	progress, result := p.CreateWithProgress(runtime.ContainerConfig{Name: "app", Image: testImage})
+
+	// The reader only starts once the task log has filled the buffer
+	waitFor(t, "the progress buffer to fill", func() bool { return len(progress) == cap(progress) })
+	time.Sleep(100 * time.Millisecond)
+
+	var phases []string
+	var last runtime.Progress
+	logLines := 0
+	for event := range progress {
+		if len(phases) == 0 || phases[len(phases)-1] != event.Phase {
+			phases = append(phases, event.Phase)
+		}
+		if strings.HasPrefix(event.Message, "extracting file") {
+			logLines++
+		}
+		last = event
+	}
+	res := <-result
+
+	if res.Error != nil {
+		t.Fatalf("CreateWithProgress: %v", res.Error)
+	}
+	want := []string{PhaseAllocate, PhaseCreate, PhaseWaitTask, PhaseMetadata, PhaseStart, PhaseReady, PhaseDone}
+	if !reflect.DeepEqual(phases, want) {
+		t.Errorf("phases = %v, want %v", phases, want)
+	}
+	if last.Phase != PhaseDone || last.Percent != 100 {
+		t.Errorf("last event = %+v, want %s at 100%%", last, PhaseDone)
+	}
+	if logLines == 0 || logLines >= 2*len(lines) {
+		t.Errorf("%d task log lines delivered, want some dropped", logLines)
+	}
+}
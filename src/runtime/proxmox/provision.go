@@ -0,0 +1,176 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// First-boot provisioning for Proxmox containers
+// BuildArgs are kept in the cosmos-secret.build-args label (encrypted at rest,
+// see metadata_crypto.go) until the first start, so a restart of Cosmos in
+// between does not skip the provisioning. They are written to buildArgsFile inside the container, then the
+// template's provisionScript (if any) is run with the args exported as env.
+// The cosmos-provisioned label records the template that was provisioned so
+// a container is only provisioned once, even when recreated from the same template.
+// PostInstall commands are kept in the cosmos-post-install label and run once
+// the container is started and provisioned; each successful command is removed
+// from the label so a failed run resumes where it stopped on the next Start
+
+const (
+	LabelProvisioned = "cosmos-provisioned"
+	LabelPostInstall = "cosmos-post-install"
+	LabelBuildArgs   = "cosmos-secret.build-args"
+
+	buildArgsFile   = "/etc/cosmos/build-args.env"
+	provisionScript = "/etc/cosmos/provision.sh"
+)
+
+// storeBuildArgs keeps the build args in the metadata until the first start.
+// Empty but non-nil args still mark the container for provisioning: OCI
+// containers always get them, as provisionScript launches their entrypoint
+func (p *ProxmoxRuntime) storeBuildArgs(vmid int, args map[string]string) {
+	if args == nil {
+		return
+	}
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return
+	}
+	p.metadata.SetLabel(vmid, LabelBuildArgs, string(encoded))
+}
+
+// provision runs the first-boot provisioning of a started container, if still pending
+func (p *ProxmoxRuntime) provision(vmid int, report progressFunc) error {
+	value := p.metadata.GetLabel(vmid, LabelBuildArgs)
+	if value == "" {
+		return nil
+	}
+	if p.metadata.HasLabel(vmid, LabelProvisioned) {
+		return p.metadata.UpdateLabels(vmid, nil, []string{LabelBuildArgs})
+	}
+
+	var args map[string]string
+	if err := json.Unmarshal([]byte(value), &args); err != nil {
+		return fmt.Errorf("invalid build args of container %d: %w", vmid, err)
+	}
+
+	utils.Log(fmt.Sprintf("Provisioning LXC container VMID %d with build args: %s", vmid, redactArgs(args)))
+	report(PhaseProvision, "Running the first-boot provisioning script", 85, false)
+
+	id := fmt.Sprint(vmid)
+	script := fmt.Sprintf(
+		"mkdir -p /etc/cosmos && cat > %[1]s && chmod 600 %[1]s && if [ -x %[2]s ]; then set -a && . %[1]s && set +a && %[2]s; fi",
+		buildArgsFile, provisionScript,
+	)
+
+	result, err := p.execWithInput(id, []string{"sh", "-c", script}, runtime.ExecOptions{}, strings.NewReader(renderEnvFile(args)))
+	if err != nil {
+		return fmt.Errorf("failed to provision container %s: %w", id, err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("provisioning of container %s exited with code %d: %s", id, result.ExitCode, result.Stderr)
+	}
+
+	template := p.metadata.GetLabel(vmid, "cosmos-template")
+	if template == "" {
+		template = "true"
+	}
+	return p.metadata.UpdateLabels(vmid, map[string]string{LabelProvisioned: template}, []string{LabelBuildArgs})
+}
+
+// storePostInstall records the PostInstall commands to run at first start
+func (p *ProxmoxRuntime) storePostInstall(vmid int, commands []string) {
+	if len(commands) == 0 {
+		_ = p.metadata.UpdateLabels(vmid, nil, []string{LabelPostInstall})
+		return
+	}
+	encoded, err := json.Marshal(commands)
+	if err != nil {
+		return
+	}
+	p.metadata.SetLabel(vmid, LabelPostInstall, string(encoded))
+}
+
+// runPostInstall runs the pending PostInstall commands of a started container
+func (p *ProxmoxRuntime) runPostInstall(vmid int, report progressFunc) error {
+	value := p.metadata.GetLabel(vmid, LabelPostInstall)
+	if value == "" {
+		return nil
+	}
+
+	var commands []string
+	if err := json.Unmarshal([]byte(value), &commands); err != nil {
+		return fmt.Errorf("invalid post-install commands: %w", err)
+	}
+
+	id := fmt.Sprint(vmid)
+	total := len(commands)
+	for len(commands) > 0 {
+		utils.Log(fmt.Sprintf("Running post-install command in LXC container VMID %d: %s", vmid, commands[0]))
+		step := total - len(commands)
+		report(PhasePostInstall, fmt.Sprintf("Post-install step %d/%d: %s", step+1, total, commands[0]), 88+step*7/total, false)
+
+		result, err := p.Exec(id, []string{"sh", "-c", commands[0]}, runtime.ExecOptions{})
+		if err != nil {
+			return fmt.Errorf("post-install of container %s failed: %w", id, err)
+		}
+		if result.ExitCode != 0 {
+			return fmt.Errorf("post-install command %q of container %s exited with code %d: %s", commands[0], id, result.ExitCode, result.Stderr)
+		}
+
+		commands = commands[1:]
+		p.storePostInstall(vmid, commands)
+	}
+
+	return nil
+}
+
+// renderEnvFile renders args as a shell-sourceable KEY='value' file
+func renderEnvFile(args map[string]string) string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out strings.Builder
+	for _, k := range keys {
+		out.WriteString(k + "=" + shellQuote(args[k]) + "\n")
+	}
+	return out.String()
+}
+
+// redactArgs formats args for logging, masking values of sensitive keys
+func redactArgs(args map[string]string) string {
+	keys := make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		value := args[k]
+		if isSensitiveKey(k) {
+			value = "***"
+		}
+		parts = append(parts, k+"="+value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// isSensitiveKey reports whether a key name suggests a secret value
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, marker := range []string{"password", "passwd", "secret", "token", "key", "credential"} {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
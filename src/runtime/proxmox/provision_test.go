@@ -0,0 +1,107 @@
+package proxmox
+
+import (
+	"strings"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+const testImage = "local:vztmpl/debian-12-standard_12.2-1_amd64.tar.zst"
+
+func TestBuildArgsProvisioning(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      map[string]string
+		restart   bool // a new runtime on the same metadata starts the container
+		provision bool
+	}{
+		{"no build args", nil, false, false},
+		{"build args", map[string]string{"APP_ENV": "production", "DB_PASSWORD": "it's secret"}, false, true},
+		{"build args after a restart", map[string]string{"APP_ENV": "staging"}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			backend := NewMemoryBackend()
+			p := newTestRuntime(t, cluster, func(c *Config) { c.MetadataBackend = backend })
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, BuildArgs: tt.args})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			if tt.restart {
+				p.Close()
+				p = newTestRuntime(t, cluster, func(c *Config) { c.MetadataBackend = backend })
+			}
+
+			var stdins []string
+			transport := &fakeTransport{run: func(command, stdin string) (string, string, int) {
+				if strings.Contains(command, buildArgsFile) {
+					stdins = append(stdins, stdin)
+				}
+				return "", "", 0
+			}}
+			p.SetExecTransport(transport)
+
+			for i := 0; i < 2; i++ {
+				if err := p.Start(id); err != nil {
+					t.Fatalf("Start %d: %v", i+1, err)
+				}
+				if err := p.Stop(id); err != nil {
+					t.Fatalf("Stop %d: %v", i+1, err)
+				}
+			}
+
+			if !tt.provision {
+				if len(stdins) != 0 {
+					t.Fatalf("provisioned %d times without build args", len(stdins))
+				}
+				return
+			}
+			if len(stdins) != 1 {
+				t.Fatalf("provisioned %d times, want once", len(stdins))
+			}
+			if stdins[0] != renderEnvFile(tt.args) {
+				t.Errorf("build args file = %q, want %q", stdins[0], renderEnvFile(tt.args))
+			}
+
+			vmid := atoi(t, id)
+			if p.metadata.GetLabel(vmid, LabelProvisioned) != testImage {
+				t.Errorf("%s = %q, want %s", LabelProvisioned, p.metadata.GetLabel(vmid, LabelProvisioned), testImage)
+			}
+			if p.metadata.HasLabel(vmid, LabelBuildArgs) {
+				t.Errorf("%s is kept after provisioning", LabelBuildArgs)
+			}
+		})
+	}
+}
+
+func TestRenderEnvFile(t *testing.T) {
+	tests := []struct {
+		name string
+		args map[string]string
+		want string
+	}{
+		{"sorted keys", map[string]string{"B": "2", "A": "1"}, "A='1'\nB='2'\n"},
+		{"quotes", map[string]string{"MOTD": "it's $HOME"}, "MOTD='it'\"'\"'s $HOME'\n"},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderEnvFile(tt.args); got != tt.want {
+				t.Errorf("renderEnvFile = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactArgs(t *testing.T) {
+	got := redactArgs(map[string]string{"DB_PASSWORD": "hunter2", "API_TOKEN": "abc", "APP_ENV": "prod"})
+	if want := "API_TOKEN=***, APP_ENV=prod, DB_PASSWORD=***"; got != want {
+		t.Errorf("redactArgs = %q, want %q", got, want)
+	}
+}
@@ -1,12 +1,16 @@
 package proxmox
 
 import (
-	"crypto/tls"
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,16 +20,74 @@ import (
 	"github.com/azukaar/cosmos-server/src/utils"
 )
 
+// defaultStopTimeout is how long Stop waits for a clean shutdown
+const defaultStopTimeout = 30 * time.Second
+
+// defaultMemoryMB is the memory of containers without a memory limit
+const defaultMemoryMB = 512
+
+// HTTP timeouts of API calls
+const (
+	defaultDialTimeout           = 10 * time.Second
+	defaultResponseHeaderTimeout = 30 * time.Second
+	defaultRequestTimeout        = 60 * time.Second
+)
+
+const (
+	minVMID      = 100       // lower VMIDs are reserved by Proxmox
+	maxVMID      = 999999999 // highest VMID accepted by Proxmox
+	minVMIDRange = 10
+)
+
 // Config holds Proxmox connection settings
 type Config struct {
-	Host          string
-	Node          string
-	TokenID       string
-	TokenSecret   string
-	Storage       string
-	VMIDStart     int
-	VMIDEnd       int
-	SkipTLSVerify bool
+	Host                  string
+	Node                  string
+	TokenID               string
+	TokenSecret           string
+	TokenSecretFile       string // file holding the token secret, see token.go
+	TokenSecretEnv        string // environment variable holding the token secret
+	Storage               string
+	TemplateStorage       string        // storage holding LXC templates, defaults to "local"
+	TaskTimeout           time.Duration // how long write operations wait for their task, defaults to 5 minutes
+	MaxRetries            int           // retries of transient API failures, 0 uses the default, negative disables
+	RetryBaseDelay        time.Duration // first retry delay, doubled on each attempt
+	DryRun                bool          // Create logs the rendered LXC config and creates nothing
+	CacheTTL              time.Duration // how long List and Version results are reused, 0 uses the default, negative disables
+	StopTimeout           time.Duration // clean shutdown delay before Stop forces the container off, 0 uses the default, negative disables
+	IncludeUnmanaged      bool          // List and StatsAll also return containers not created by Cosmos
+	AllNodes              bool          // List and StatsAll cover every node of the cluster instead of Node
+	BatchConcurrency      int           // containers handled in parallel by bulk operations, 0 uses the default
+	DialTimeout           time.Duration // TCP and TLS connection setup, 0 uses the default
+	ResponseHeaderTimeout time.Duration // wait for the response headers of an API call, 0 uses the default
+	RequestTimeout        time.Duration // whole API call including the body, 0 uses the default, negative disables
+	AdoptUnmanaged        bool          // Reconcile takes over containers created outside of Cosmos under their hostname
+	DefaultBridge         string        // bridge of containers without networks, defaults to vmbr0
+	DefaultVLAN           int           // VLAN tag of interfaces on the default bridge, 0 for none
+	DebugAPI              bool          // log every API call with redacted bodies at debug level, see apilog.go
+	APITransport          string        // "auto", "local" or "http", how API calls reach Proxmox, see local.go
+	Pool                  string        // pool containers are added to, overridden by the cosmos-pool label, see pools.go
+	LockTimeout           time.Duration // how long lifecycle operations wait for a container lock to clear, 0 uses the default, negative disables
+	AllocationStrategy    string        // how VMIDs are picked, see allocation.go
+	VMIDStart             int
+	VMIDEnd               int
+	SkipTLSVerify         bool   // disables certificate verification, overrides CACertPath
+	CACertPath            string // PEM bundle of the CA signing the Proxmox certificate, instead of the system roots
+	ClientCertPath        string // PEM client certificate for mutual TLS, with ClientKeyPath
+	ClientKeyPath         string
+	NameTemplate          string // e.g. "{stack}-{service}-{n}", empty keeps the given name
+
+	// SSH access to the node, used to run commands inside containers
+	SSHUser       string
+	SSHPort       int
+	SSHKeyPath    string
+	SSHPassword   string
+	SSHKnownHosts string
+
+	MetadataKey     string          // encrypts sensitive metadata (e.g. environment) at rest
+	MetadataBackend MetadataBackend // nil uses the local JSON file
+	OnAPICall       func(APICall)   // receives every API call with redacted bodies, see apilog.go
+	Metrics         Metrics         // receives API, task and lifecycle metrics, nil records nothing, see metrics.go
 }
 
 // ProxmoxRuntime implements ContainerRuntime for Proxmox LXC
@@ -35,16 +97,50 @@ type ProxmoxRuntime struct {
 	apiURL      string
 	node        string
 	connected   bool
+	local       bool   // API calls go through pvesh, see local.go
+	tokenSecret string // resolved at Connect, see token.go
 	vmidCounter int
 	mutex       sync.RWMutex
 	metadata    *MetadataStore
+	transport   ExecTransport
+	transportMu sync.Mutex // guards transport, used while p.mutex is held (e.g. by Connect)
+	nameLocks   keyedMutex
+
+	reservedVMIDs map[int]bool // VMIDs of in-flight creations
+
+	// Creation time of containers created outside of Cosmos, see created.go
+	adoptedCreated map[int]int64
+	createdMu      sync.Mutex
+
+	cache *responseCache // List and Version responses, see cache.go
+
+	// Connection health, see health.go
+	lastPing     time.Time
+	pingFailures int
+	stopHealth   chan struct{}
+
+	// Runtime context and background goroutines, see shutdown.go
+	life   *lifecycle
+	lifeMu sync.Mutex
 }
 
 // MetadataStore handles container metadata (labels equivalent)
 type MetadataStore struct {
-	path string
-	data map[int]map[string]string // vmid -> labels
-	mu   sync.RWMutex
+	backend     MetadataBackend
+	data        map[int]map[string]string          // vmid -> labels
+	index       map[string]map[string]map[int]bool // label key -> value -> VMIDs
+	key         []byte                             // encrypts sensitive labels at rest, nil disables encryption
+	mu          sync.RWMutex
+	persistMu   sync.Mutex
+	cancelWatch func()
+	loadErr     error // set when Load failed, the backend is then never written
+
+	reservedIndexes map[string]map[int]bool // stack -> indexes of in-flight creates, see naming.go
+
+	// Debounced saves
+	dirty     map[int]bool
+	saveTimer *time.Timer
+	dirtyMu   sync.Mutex
 }
 
 // New creates a new Proxmox runtime
@@ -53,44 +149,126 @@ func New(config *Config) (*ProxmoxRuntime, error) {
 		return nil, errors.New("proxmox config is required")
 	}
 
-	if config.Host == "" {
-		return nil, errors.New("proxmox host is required")
+	host, err := validateHost(config.Host)
+	if err != nil {
+		return nil, err
 	}
+	config.Host = host
 
 	if config.Node == "" {
 		return nil, errors.New("proxmox node is required")
 	}
 
-	if config.TokenID == "" || config.TokenSecret == "" {
+	local, err := useLocalAPI(config)
+	if err != nil {
+		return nil, err
+	}
+	if !local && (config.TokenID == "" || !hasTokenSecret(config)) {
 		return nil, errors.New("proxmox API token is required")
 	}
 
+	if err := validateVMIDRange(config.VMIDStart, config.VMIDEnd); err != nil {
+		return nil, err
+	}
+
+	if err := validateAllocationStrategy(config.AllocationStrategy); err != nil {
+		return nil, err
+	}
+
+	backend := config.MetadataBackend
+	if backend == nil {
+		backend = NewFileBackend(defaultMetadataPath)
+	}
+
 	return &ProxmoxRuntime{
 		config:      config,
 		node:        config.Node,
+		local:       local,
 		vmidCounter: config.VMIDStart,
 		apiURL:      fmt.Sprintf("https://%s/api2/json", config.Host),
+		cache:       newResponseCache(config.CacheTTL),
 		metadata: &MetadataStore{
-			path: "/var/lib/cosmos/proxmox-metadata",
-			data: make(map[int]map[string]string),
+			backend: backend,
+			data:    make(map[int]map[string]string),
+			key:     deriveMetadataKey(config.MetadataKey),
 		},
 	}, nil
 }
 
+// validateHost trims the configured host and checks it is a bare host[:port]
+func validateHost(host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		return "", errors.New("proxmox host is required")
+	}
+	if strings.Contains(host, "://") {
+		return "", fmt.Errorf("proxmox host %q must not include a scheme, use host[:port] (e.g. proxmox.local:8006)", host)
+	}
+	host = strings.TrimSuffix(host, "/")
+	if strings.ContainsAny(host, "/?# ") {
+		return "", fmt.Errorf("proxmox host %q must not include a path, use host[:port] (e.g. proxmox.local:8006)", host)
+	}
+
+	if name, port, err := net.SplitHostPort(host); err == nil {
+		if name == "" {
+			return "", fmt.Errorf("proxmox host %q has no hostname", host)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("proxmox host %q has an invalid port", host)
+		}
+	} else if strings.Count(host, ":") == 1 {
+		return "", fmt.Errorf("proxmox host %q is invalid: %w", host, err)
+	}
+
+	return host, nil
+}
+
+// validateVMIDRange checks the range containers are allocated from, VMIDEnd excluded
+func validateVMIDRange(start, end int) error {
+	if start < minVMID {
+		return fmt.Errorf("VMIDStart must be at least %d, lower VMIDs are reserved by Proxmox (got %d)", minVMID, start)
+	}
+	if end <= start {
+		return fmt.Errorf("VMIDEnd (%d) must be greater than VMIDStart (%d)", end, start)
+	}
+	if end > maxVMID {
+		return fmt.Errorf("VMIDEnd must be at most %d (got %d)", maxVMID, end)
+	}
+	if end-start < minVMIDRange {
+		return fmt.Errorf("VMID range %d-%d is too small, it must hold at least %d containers", start, end, minVMIDRange)
+	}
+	return nil
+}
+
 // Connect establishes connection to Proxmox API
 func (p *ProxmoxRuntime) Connect() error {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
-	// Create HTTP client with optional TLS skip
-	tlsConfig := &tls.Config{
-		InsecureSkipVerify: p.config.SkipTLSVerify,
+	p.renewBackground()
+
+	secret, err := resolveTokenSecret(p.config)
+	if err != nil {
+		return err
 	}
-	transport := &http.Transport{TLSClientConfig: tlsConfig}
-	p.client = &http.Client{
-		Transport: transport,
-		Timeout:   30 * time.Second,
+	p.tokenSecret = secret
+
+	// Create HTTP client with optional TLS skip. The overall deadline is set
+	// per call (see requestTimeout) so streaming requests can go without one
+	tlsConfig, err := p.tlsConfig()
+	if err != nil {
+		return err
+	}
+	dialTimeout := durationOr(p.config.DialTimeout, defaultDialTimeout)
+	transport := &http.Transport{
+		TLSClientConfig:       tlsConfig,
+		DialContext:           (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   dialTimeout,
+		ResponseHeaderTimeout: durationOr(p.config.ResponseHeaderTimeout, defaultResponseHeaderTimeout),
+		MaxIdleConnsPerHost:   defaultBatchConcurrency * 2,
+		IdleConnTimeout:       90 * time.Second,
 	}
+	p.client = &http.Client{Transport: transport}
 
 	// Test connection by getting version
 	resp, err := p.apiRequest("GET", "/version", nil)
@@ -101,57 +279,212 @@ func (p *ProxmoxRuntime) Connect() error {
 	if version, ok := resp["version"].(string); ok {
 		utils.Log(fmt.Sprintf("Connected to Proxmox VE %s", version))
 	}
+	if p.local {
+		utils.Log("Running on Proxmox node " + p.node + ", API calls go through pvesh")
+	}
 
-	// Load metadata
+	// Load metadata. Going on without it would overwrite the stored labels on the next save
 	if err := p.metadata.Load(); err != nil {
-		utils.Warn("Failed to load Proxmox metadata: " + err.Error())
+		return fmt.Errorf("failed to load Proxmox metadata: %w", err)
 	}
 
+	if err := p.reconcile(); err != nil {
+		utils.Warn("Failed to reconcile Proxmox metadata: " + err.Error())
+	}
+
+	p.checkDefaultBridge()
+	p.checkPool()
+
 	// Update VMID counter
 	if err := p.updateVMIDCounter(); err != nil {
 		utils.Warn("Failed to update VMID counter: " + err.Error())
 	}
 
 	p.connected = true
+	p.lastPing = time.Now()
+	p.pingFailures = 0
+	p.startHealthCheck()
 	return nil
 }
 
-// apiRequest makes an authenticated request to the Proxmox API
+// apiRequest makes an authenticated request to the Proxmox API.
+// Transient failures are retried, see shouldRetry.
 func (p *ProxmoxRuntime) apiRequest(method, path string, body io.Reader) (map[string]interface{}, error) {
+	return p.apiRequestTimeout(method, path, body, p.requestTimeout())
+}
+
+// apiRequestTimeout is apiRequest with its own deadline per attempt, 0 means none
+func (p *ProxmoxRuntime) apiRequestTimeout(method, path string, body io.Reader, timeout time.Duration) (map[string]interface{}, error) {
+	data, err := p.rawRequest(method, path, body, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return dataMap(data)
+}
+
+// rawRequest is apiRequestTimeout returning the undecoded "data" field of the response
+func (p *ProxmoxRuntime) rawRequest(method, path string, body io.Reader, timeout time.Duration) (json.RawMessage, error) {
 	url := p.apiURL + path
 
-	req, err := http.NewRequest(method, url, body)
+	// Buffer the body so it can be replayed on retry
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		result, err := p.doAPIRequest(method, url, payload, timeout)
+		p.observeAPIRequest(method, path, start, err)
+		if err == nil || attempt >= p.maxRetries() || !shouldRetry(method, err) {
+			return result, err
+		}
+
+		delay := retryDelay(p.config.RetryBaseDelay, attempt)
+		utils.Debug(fmt.Sprintf("Retrying %s %s in %s after error: %s", method, path, delay, err))
+		time.Sleep(delay)
+	}
+}
+
+// doAPIRequest performs a single API call within timeout, 0 means no deadline
+func (p *ProxmoxRuntime) doAPIRequest(method, url string, payload []byte, timeout time.Duration) (json.RawMessage, error) {
+	if p.local {
+		return p.localRequest(method, strings.TrimPrefix(url, p.apiURL), payload, timeout)
+	}
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	ctx := p.background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set API token authentication
-	req.Header.Set("Authorization", fmt.Sprintf("PVEAPIToken=%s=%s", p.config.TokenID, p.config.TokenSecret))
+	authorization, err := p.authorization()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authorization)
 	req.Header.Set("Content-Type", "application/json")
 
+	start := time.Now()
 	resp, err := p.client.Do(req)
 	if err != nil {
+		if p.tracing() {
+			p.traceCall(APICall{Method: method, Path: req.URL.Path, Duration: time.Since(start), RequestBody: redactBody(payload), Err: err})
+		}
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if p.tracing() {
+		p.traceCall(APICall{
+			Method:       method,
+			Path:         req.URL.Path,
+			StatusCode:   resp.StatusCode,
+			Duration:     time.Since(start),
+			RequestBody:  redactBody(payload),
+			ResponseBody: redactBody(bodyBytes),
+			Err:          err,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	if resp.StatusCode >= 400 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
 	var result struct {
-		Data interface{} `json:"data"`
+		Data json.RawMessage `json:"data"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
 		return nil, err
 	}
+	return result.Data, nil
+}
+
+// dataMap returns the "data" field of a response as a map. Data that is not an
+// object (lists, task UPIDs...) is returned under the "data" key
+func dataMap(data json.RawMessage) (map[string]interface{}, error) {
+	var value interface{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+	}
+
+	if object, ok := value.(map[string]interface{}); ok {
+		return object, nil
+	}
+	return map[string]interface{}{"data": value}, nil
+}
+
+// requestTimeout returns the overall deadline of an API call, 0 when disabled
+func (p *ProxmoxRuntime) requestTimeout() time.Duration {
+	if p.config.RequestTimeout < 0 {
+		return 0
+	}
+	return durationOr(p.config.RequestTimeout, defaultRequestTimeout)
+}
 
-	if data, ok := result.Data.(map[string]interface{}); ok {
-		return data, nil
+// durationOr returns d, or fallback when d is not set
+func durationOr(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
 	}
+	return d
+}
+
+// APIError is returned when the Proxmox API answers with an error status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
 
-	return map[string]interface{}{"data": result.Data}, nil
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Body)
+}
+
+// guestMissing matches the error Proxmox returns (with status 500) for a guest
+// without a configuration file. Other "does not exist" errors, e.g. of a
+// storage or a volume, do not mean the container is gone
+var guestMissing = regexp.MustCompile(`Configuration file '[^']*/(lxc|qemu-server)/\d+\.conf' does not exist`)
+
+// isNotFound reports whether err means the requested guest does not exist
+func isNotFound(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound || guestMissing.MatchString(apiErr.Body)
+}
+
+// errNotConnected is returned by operations needing the API before Connect succeeded
+var errNotConnected = fmt.Errorf("%w to Proxmox", runtime.ErrNotConnected)
+
+// notFound prunes the stale metadata of a removed container and returns ErrContainerNotFound
+func (p *ProxmoxRuntime) notFound(vmid int) error {
+	if p.metadata.Get(vmid) != nil {
+		p.metadata.Delete(vmid)
+		utils.Log(fmt.Sprintf("Pruned metadata of removed LXC container VMID: %d", vmid))
+	}
+	return fmt.Errorf("%w: %d", runtime.ErrContainerNotFound, vmid)
 }
 
 // IsConnected returns whether Proxmox is connected
@@ -164,13 +497,19 @@ func (p *ProxmoxRuntime) IsConnected() bool {
 // Close closes the Proxmox client connection
 func (p *ProxmoxRuntime) Close() error {
 	p.mutex.Lock()
-	defer p.mutex.Unlock()
+	p.stopHealthCheck()
+	p.mutex.Unlock()
 
-	// Save metadata before closing
-	if err := p.metadata.Save(); err != nil {
+	// Background goroutines may need p.mutex to return, so it is not held while waiting
+	p.stopBackground()
+
+	// Flush pending metadata before closing
+	if err := p.metadata.Close(); err != nil {
 		utils.Warn("Failed to save Proxmox metadata: " + err.Error())
 	}
 
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
 	p.client = nil
 	p.connected = false
 	return nil
@@ -187,83 +526,290 @@ func (p *ProxmoxRuntime) Version() string {
 		return "unknown"
 	}
 
-	resp, err := p.apiRequest("GET", "/version", nil)
+	resp, err := p.cachedGet("/version")
 	if err != nil {
 		return "unknown"
 	}
 
-	if version, ok := resp["version"].(string); ok {
-		return version
+	var info VersionInfo
+	if err := decodeInto(resp, &info); err != nil || info.Version == "" {
+		return "unknown"
 	}
-	return "unknown"
+	return info.Version
+}
+
+// maxVMIDAttempts bounds the retries of Create when VMIDs are taken concurrently
+const maxVMIDAttempts = 5
+
+// getNextVMID reserves a VMID of [VMIDStart, VMIDEnd) for a container named name,
+// picked by the allocation strategy (see allocation.go) among those free on the
+// cluster (LXC and QEMU) and not already reserved by an in-flight Create.
+// The reservation must be released with releaseVMID once creation is done.
+func (p *ProxmoxRuntime) getNextVMID(name string) (int, error) {
+	used, err := p.usedVMIDs()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err != nil {
+		// Fall back to the local counter when the cluster cannot be queried
+		utils.Warn("Failed to list cluster VMIDs, using the local counter: " + err.Error())
+		used = nil
+	}
+
+	// Search from the start of the allocation strategy, wrapping around the range
+	span := p.config.VMIDEnd - p.config.VMIDStart
+	offset := p.allocationStart(name, used) - p.config.VMIDStart
+	for i := 0; i < span; i++ {
+		vmid := p.config.VMIDStart + (offset+i)%span
+		if used[vmid] || p.reservedVMIDs[vmid] {
+			continue
+		}
+		if p.reservedVMIDs == nil {
+			p.reservedVMIDs = make(map[int]bool)
+		}
+		p.reservedVMIDs[vmid] = true
+		if vmid >= p.vmidCounter {
+			p.vmidCounter = vmid + 1
+		}
+		return vmid, nil
+	}
+
+	return 0, fmt.Errorf("%w (%d-%d)", runtime.ErrVMIDExhausted, p.config.VMIDStart, p.config.VMIDEnd-1)
 }
 
-// getNextVMID allocates the next available VMID
-func (p *ProxmoxRuntime) getNextVMID() (int, error) {
+// releaseVMID drops the in-flight reservation of a VMID
+func (p *ProxmoxRuntime) releaseVMID(vmid int) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
+	delete(p.reservedVMIDs, vmid)
+}
+
+// usedVMIDs returns every VMID (containers and VMs) existing on the cluster
+func (p *ProxmoxRuntime) usedVMIDs() (map[int]bool, error) {
+	guests, err := p.clusterGuests()
+	if err != nil {
+		return nil, err
+	}
 
-	if p.vmidCounter >= p.config.VMIDEnd {
-		return 0, errors.New("VMID range exhausted")
+	used := make(map[int]bool)
+	for _, item := range guests {
+		if vmid, ok := item["vmid"].(float64); ok {
+			used[int(vmid)] = true
+		}
 	}
+	return used, nil
+}
 
-	vmid := p.vmidCounter
-	p.vmidCounter++
-	return vmid, nil
+// isVMIDInUse reports whether a create failed because the VMID was taken meanwhile
+func isVMIDInUse(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return strings.Contains(apiErr.Body, "already exists") || strings.Contains(apiErr.Body, "already in use")
 }
 
-// updateVMIDCounter updates the VMID counter based on existing containers
+// updateVMIDCounter moves the VMID counter past the guests (LXC and QEMU) of the
+// configured range, from the cluster resources or, without cluster access, from
+// the guests of the local node
 func (p *ProxmoxRuntime) updateVMIDCounter() error {
-	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc", p.node), nil)
+	guests, err := p.clusterGuests()
 	if err != nil {
-		return err
+		utils.Warn("Failed to list cluster guests, using the guests of node " + p.node + ": " + err.Error())
+		if guests, err = p.nodeGuests(); err != nil {
+			return err
+		}
 	}
 
 	maxVMID := p.config.VMIDStart
-	if data, ok := resp["data"].([]interface{}); ok {
-		for _, item := range data {
-			if container, ok := item.(map[string]interface{}); ok {
-				if vmid, ok := container["vmid"].(float64); ok {
-					if int(vmid) >= maxVMID {
-						maxVMID = int(vmid) + 1
-					}
-				}
-			}
+	var constraining []string
+	for _, guest := range guests {
+		vmid := int(floatValue(guest["vmid"]))
+		if vmid < p.config.VMIDStart || vmid >= p.config.VMIDEnd {
+			continue
+		}
+		if vmid >= maxVMID {
+			maxVMID = vmid + 1
 		}
+		kind, _ := guest["type"].(string)
+		node, _ := guest["node"].(string)
+		constraining = append(constraining, fmt.Sprintf("%s %d on %s", kind, vmid, node))
+	}
+
+	if len(constraining) > 0 {
+		sort.Strings(constraining)
+		utils.Log(fmt.Sprintf("VMID counter set to %d by existing guests in range: %s", maxVMID, strings.Join(constraining, ", ")))
 	}
 
 	p.vmidCounter = maxVMID
 	return nil
 }
 
+// clusterGuests returns the containers and VMs of every node of the cluster
+func (p *ProxmoxRuntime) clusterGuests() ([]map[string]interface{}, error) {
+	resp, err := p.apiRequest("GET", "/cluster/resources?type=vm", nil)
+	if err != nil {
+		return nil, err
+	}
+	return listItems(resp), nil
+}
+
+// nodeGuests returns the containers and VMs of the local node
+func (p *ProxmoxRuntime) nodeGuests() ([]map[string]interface{}, error) {
+	var guests []map[string]interface{}
+	for _, kind := range []string{"lxc", "qemu"} {
+		resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/%s", p.node, kind), nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, guest := range listItems(resp) {
+			guest["type"] = kind
+			guest["node"] = p.node
+			guests = append(guests, guest)
+		}
+	}
+	return guests, nil
+}
+
 // Create creates a new LXC container
 func (p *ProxmoxRuntime) Create(config runtime.ContainerConfig) (string, error) {
+	start := time.Now()
+	id, err := p.create(config, nil)
+	p.observe("create", start, err)
+	return id, err
+}
+
+// create runs the creation steps, reporting each phase to report when set
+func (p *ProxmoxRuntime) create(config runtime.ContainerConfig, report progressFunc) (string, error) {
+	defer p.cache.invalidate()
+
+	if report == nil {
+		report = func(string, string, int, bool) {}
+	}
+
 	if !p.connected {
-		return "", errors.New("not connected to Proxmox")
+		return "", errNotConnected
 	}
 
-	vmid, err := p.getNextVMID()
+	// Labels holding runtime state are set by Cosmos only
+	config.Labels = userLabels(config.Labels)
+
+	report(PhaseAllocate, "Allocating VMID", 10, false)
+	node, err := p.CheckAffinity(config)
 	if err != nil {
 		return "", err
 	}
 
-	// Build LXC configuration
-	lxcConfig := p.buildLXCConfig(vmid, config)
+	if err := p.validateStorage(node, p.rootfsStorage(config)); err != nil {
+		return "", err
+	}
 
-	// Create the container via API
-	configJSON, _ := json.Marshal(lxcConfig)
-	_, err = p.apiRequest("POST", fmt.Sprintf("/nodes/%s/lxc", p.node), strings.NewReader(string(configJSON)))
+	if err := validateEnvironment(config.Environment); err != nil {
+		return "", err
+	}
+
+	tmpfs, err := tmpfsMounts(config.Volumes)
+	if err != nil {
+		return "", err
+	}
+
+	ports, err := validatePorts(config.Ports)
 	if err != nil {
+		return "", err
+	}
+
+	if _, err := lxcDevices(config.Devices); err != nil {
+		return "", err
+	}
+	if err := p.checkDevices(node, config.Devices); err != nil {
+		return "", err
+	}
+
+	if p.config.DryRun {
+		config = p.applyNameTemplate(config, p.metadata.NextStackIndex(config.Labels[LabelStack]))
+		return p.dryRunCreate(node, config)
+	}
+
+	// Create is idempotent by name, a retried call returns the container created first.
+	// The name is resolved with a reserved stack index, released once the labels are stored
+	config, releaseIndex := p.resolveName(config)
+	defer releaseIndex()
+	unlock := p.nameLocks.Lock(config.Name)
+	defer unlock()
+	if existing, err := p.existingContainer(config); err != nil || existing != "" {
+		return existing, err
+	}
+
+	// VMIDs can be taken by another controller between allocation and creation,
+	// in which case the next free one is tried
+	var vmid int
+	var resp map[string]interface{}
+	var reserved []int
+	defer func() {
+		for _, id := range reserved {
+			p.releaseVMID(id)
+		}
+	}()
+
+	for attempt := 0; ; attempt++ {
+		vmid, err = p.getNextVMID(config.Name)
+		if err != nil {
+			return "", err
+		}
+		reserved = append(reserved, vmid)
+
+		// Build LXC configuration
+		lxcConfig, err := p.buildLXCConfig(vmid, config, false)
+		if err != nil {
+			return "", err
+		}
+
+		// Create the container via API
+		report(PhaseCreate, fmt.Sprintf("Creating LXC container %s (VMID: %d)", config.Name, vmid), 30, false)
+		configJSON, _ := json.Marshal(lxcConfig)
+		resp, err = p.apiRequest("POST", fmt.Sprintf("/nodes/%s/lxc", node), strings.NewReader(string(configJSON)))
+		if err == nil {
+			break
+		}
+		if !isVMIDInUse(err) || attempt >= maxVMIDAttempts {
+			return "", fmt.Errorf("failed to create LXC container: %w", err)
+		}
+
+		// The reservation is kept until the end so the next attempt skips this VMID
+		utils.Warn(fmt.Sprintf("VMID %d is already in use, retrying with the next free VMID", vmid))
+	}
+
+	report(PhaseWaitTask, "Waiting for Proxmox to finish creating the container", 50, false)
+	if err := p.followTask(taskUPID(resp), func(line string) { report(PhaseWaitTask, line, 50, true) }); err != nil {
 		return "", fmt.Errorf("failed to create LXC container: %w", err)
 	}
 
-	// Store metadata (labels)
+	// Store metadata (labels), with the system facts known now that the VMID is allocated
+	report(PhaseMetadata, "Storing container metadata", 70, false)
+	config = expandFacts(config, p.systemFacts(vmid, node, config))
 	if len(config.Labels) > 0 {
 		p.metadata.Set(vmid, config.Labels)
 	}
 
 	// Store name mapping
 	p.metadata.SetLabel(vmid, "cosmos-name", config.Name)
+	p.metadata.SetLabel(vmid, "cosmos-template", config.Image)
+	p.metadata.SetLabel(vmid, LabelManaged, "true")
+	p.metadata.SetLabel(vmid, LabelNode, node)
+	p.metadata.SetLabel(vmid, LabelCreated, createdLabel())
+
+	p.storeBuildArgs(vmid, config.BuildArgs)
+	p.storeProvisioning(vmid, config.Provisioning)
+	p.storeReadiness(vmid, config.Readiness)
+	p.storeEnvironment(vmid, config.Environment)
+	p.storeTmpfs(vmid, tmpfs)
+	p.storeAllocatedVolumes(vmid)
+	if ports != "" {
+		p.metadata.SetLabel(vmid, LabelPorts, ports)
+	}
+	p.storePostInstall(vmid, config.PostInstall)
+	p.recordChange(vmid, "create", configChanges(runtime.ContainerConfig{}, config))
 
 	containerID := strconv.Itoa(vmid)
 	utils.Log(fmt.Sprintf("Created LXC container %s (VMID: %d)", config.Name, vmid))
@@ -271,127 +817,373 @@ func (p *ProxmoxRuntime) Create(config runtime.ContainerConfig) (string, error)
 	return containerID, nil
 }
 
-// buildLXCConfig converts runtime.ContainerConfig to Proxmox LXC config
-func (p *ProxmoxRuntime) buildLXCConfig(vmid int, config runtime.ContainerConfig) map[string]interface{} {
+// dryRunCreate logs the LXC config Create would send, without allocating a VMID
+func (p *ProxmoxRuntime) dryRunCreate(node string, config runtime.ContainerConfig) (string, error) {
+	rendered, err := p.renderLXCConfig(config, false)
+	if err != nil {
+		return "", err
+	}
+
+	utils.Log(fmt.Sprintf("[dry-run] Would create LXC container %s on node %s with config:\n%s", config.Name, node, rendered))
+	return "dry-run-" + config.Name, nil
+}
+
+// Describe returns the LXC config Create would send for config as indented
+// JSON, without allocating a VMID nor calling the API. SDN networks and named
+// volumes are shown by name, Create resolves them to their vnet and volume ID
+func (p *ProxmoxRuntime) Describe(config runtime.ContainerConfig) (string, error) {
+	if err := validateEnvironment(config.Environment); err != nil {
+		return "", err
+	}
+	if _, err := tmpfsMounts(config.Volumes); err != nil {
+		return "", err
+	}
+	if _, err := validatePorts(config.Ports); err != nil {
+		return "", err
+	}
+
+	return p.renderLXCConfig(config, true)
+}
+
+// renderLXCConfig returns the LXC config of config as indented JSON, without the VMID and the password
+func (p *ProxmoxRuntime) renderLXCConfig(config runtime.ContainerConfig, offline bool) (string, error) {
+	lxcConfig, err := p.buildLXCConfig(0, config, offline)
+	if err != nil {
+		return "", err
+	}
+	delete(lxcConfig, "vmid")
+	delete(lxcConfig, "password")
+
+	rendered, err := json.MarshalIndent(lxcConfig, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
+
+// buildLXCConfig converts runtime.ContainerConfig to Proxmox LXC config.
+// Offline, no API call is made, see networkInterfaces and mountPoints
+func (p *ProxmoxRuntime) buildLXCConfig(vmid int, config runtime.ContainerConfig, offline bool) (map[string]interface{}, error) {
 	lxc := map[string]interface{}{
 		"vmid":         vmid,
-		"hostname":     config.Hostname,
 		"ostemplate":   config.Image,
-		"storage":      p.config.Storage,
+		"storage":      p.rootfsStorage(config),
 		"password":     generateSecurePassword(),
 		"unprivileged": !config.Privileged,
 		"start":        false,
 	}
 
+	// The name is kept as is in cosmos-name, the hostname must be a DNS label
 	if config.Hostname == "" {
-		lxc["hostname"] = config.Name
+		lxc["hostname"] = lxcHostname(config.Name, vmid)
+	} else {
+		lxc["hostname"] = lxcHostname(config.Hostname, vmid)
 	}
 
 	// Memory (convert bytes to MB)
 	if config.Memory > 0 {
 		lxc["memory"] = config.Memory / (1024 * 1024)
 	} else {
-		lxc["memory"] = 512
+		lxc["memory"] = defaultMemoryMB
 	}
 
-	// Swap
-	if config.MemorySwap > 0 {
-		lxc["swap"] = config.MemorySwap / (1024 * 1024)
+	// Swap, as much as memory when unset, none when 0
+	if config.MemorySwap != nil {
+		if *config.MemorySwap < 0 {
+			return nil, fmt.Errorf("invalid swap size %d", *config.MemorySwap)
+		}
+		lxc["swap"] = *config.MemorySwap / (1024 * 1024)
 	} else {
-		lxc["swap"] = 512
+		lxc["swap"] = lxc["memory"]
 	}
 
 	// CPUs
-	if config.CPUs > 0 {
-		lxc["cores"] = int(config.CPUs)
-	} else {
-		lxc["cores"] = 1
+	cpu, err := lxcCPU(config)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range cpu {
+		lxc[key] = value
 	}
 
 	// Network
-	lxc["net0"] = "name=eth0,bridge=vmbr0,ip=dhcp"
+	interfaces, err := p.networkInterfaces(config, offline)
+	if err != nil {
+		return nil, err
+	}
+	for i, iface := range interfaces {
+		lxc[fmt.Sprintf("net%d", i)] = iface.render(i)
+	}
+
+	// DNS, inherited from the node when unset
+	nameserver, searchdomain, err := lxcDNS(config)
+	if err != nil {
+		return nil, err
+	}
+	if nameserver != "" {
+		lxc["nameserver"] = nameserver
+	}
+	if searchdomain != "" {
+		lxc["searchdomain"] = searchdomain
+	}
 
 	// Mount points
-	mpIndex := 0
-	for _, vol := range config.Volumes {
-		mpKey := fmt.Sprintf("mp%d", mpIndex)
-		mpValue := fmt.Sprintf("%s,mp=%s", vol.Source, vol.Target)
-		if vol.ReadOnly {
-			mpValue += ",ro=1"
-		}
-		lxc[mpKey] = mpValue
-		mpIndex++
+	mountPoints, err := p.mountPoints(config, offline)
+	if err != nil {
+		return nil, err
+	}
+	for i, mp := range mountPoints {
+		lxc[fmt.Sprintf("mp%d", i)] = mp
+	}
+
+	// Devices
+	devices, err := lxcDevices(config.Devices)
+	if err != nil {
+		return nil, err
+	}
+	for i, dev := range devices {
+		lxc[fmt.Sprintf("dev%d", i)] = dev
+	}
+
+	// Boot sequencing
+	startup, err := lxcStartup(config)
+	if err != nil {
+		return nil, err
+	}
+	if startup != "" {
+		lxc["startup"] = startup
+	}
+
+	// Marks the containers created by Cosmos, see existingContainer
+	lxc["tags"] = createdTag
+
+	if pool := p.containerPool(config); pool != "" {
+		lxc["pool"] = pool
 	}
 
 	// Root filesystem
-	lxc["rootfs"] = fmt.Sprintf("%s:8", p.config.Storage)
+	lxc["rootfs"] = fmt.Sprintf("%s:%d", p.rootfsStorage(config), rootfsSizeGB(config.RootFSSize))
+
+	// SSH keys of root, other provisioning runs at the first start
+	if err := validateProvisioning(config.Provisioning); err != nil {
+		return nil, err
+	}
+	if keys := rootSSHKeys(config.Provisioning); keys != "" {
+		lxc["ssh-public-keys"] = keys
+	}
 
 	// Features
-	lxc["features"] = "nesting=1"
+	features, err := lxcFeatures(config)
+	if err != nil {
+		return nil, err
+	}
+	if features != "" {
+		lxc["features"] = features
+	}
 
-	return lxc
+	return lxc, nil
 }
 
 // Start starts a container
 func (p *ProxmoxRuntime) Start(id string) error {
+	start := time.Now()
+	err := p.start(id, nil)
+	p.observe("start", start, err)
+	return err
+}
+
+// start starts a container, reporting the boot and first-start steps
+func (p *ProxmoxRuntime) start(id string, report progressFunc) error {
+	defer p.cache.invalidate()
+
+	if report == nil {
+		report = func(string, string, int, bool) {}
+	}
+
 	vmid, err := strconv.Atoi(id)
 	if err != nil {
 		return fmt.Errorf("invalid container ID: %s", id)
 	}
 
-	_, err = p.apiRequest("POST", fmt.Sprintf("/nodes/%s/lxc/%d/status/start", p.node, vmid), nil)
+	node := p.nodeFor(vmid)
+	if err := p.checkMaintenance(node); err != nil {
+		return fmt.Errorf("failed to start container %s: %w", id, err)
+	}
+
+	resp, err := p.lockedRequest(node, vmid, "POST", fmt.Sprintf("/nodes/%s/lxc/%d/status/start", node, vmid), nil)
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to start container %s: %w", id, err)
 	}
+	if err := p.followTask(taskUPID(resp), func(line string) { report(PhaseStart, line, 80, true) }); err != nil {
+		return fmt.Errorf("failed to start container %s: %w", id, err)
+	}
 
 	utils.Log(fmt.Sprintf("Started LXC container VMID: %d", vmid))
-	return nil
+
+	if err := p.provision(vmid, report); err != nil {
+		return err
+	}
+	if err := p.applyProvisioning(vmid, report); err != nil {
+		return err
+	}
+
+	p.applyTmpfs(vmid)
+	p.applyPorts(vmid)
+
+	if err := p.applyEnvironment(vmid); err != nil {
+		return err
+	}
+
+	if err := p.runPostInstall(vmid, report); err != nil {
+		return err
+	}
+
+	report(PhaseReady, "Waiting for the container to be ready", 95, false)
+	return p.waitReady(vmid)
 }
 
-// Stop stops a container
+// Stop shuts a container down cleanly, forcing it off after the configured StopTimeout
 func (p *ProxmoxRuntime) Stop(id string) error {
+	timeout := p.config.StopTimeout
+	if timeout == 0 {
+		timeout = defaultStopTimeout
+	}
+	return p.StopWithTimeout(id, timeout)
+}
+
+// StopWithTimeout asks the container to shut down and waits up to timeout for
+// it to stop, then stops it hard. A timeout <= 0 stops it hard right away
+func (p *ProxmoxRuntime) StopWithTimeout(id string, timeout time.Duration) (err error) {
+	defer p.cache.invalidate()
+	start := time.Now()
+	defer func() { p.observe("stop", start, err) }()
+
 	vmid, err := strconv.Atoi(id)
 	if err != nil {
 		return fmt.Errorf("invalid container ID: %s", id)
 	}
+	node := p.nodeFor(vmid)
+
+	if timeout > 0 {
+		err := p.shutdown(node, vmid, timeout)
+		if isNotFound(err) {
+			return p.notFound(vmid)
+		}
+		if err == nil {
+			utils.Log(fmt.Sprintf("Shut down LXC container VMID: %d", vmid))
+			return nil
+		}
+		utils.Warn(fmt.Sprintf("Clean shutdown of LXC container VMID %d failed, forcing stop: %s", vmid, err))
+	}
 
-	_, err = p.apiRequest("POST", fmt.Sprintf("/nodes/%s/lxc/%d/status/stop", p.node, vmid), nil)
+	resp, err := p.lockedRequest(node, vmid, "POST", fmt.Sprintf("/nodes/%s/lxc/%d/status/stop", node, vmid), nil)
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to stop container %s: %w", id, err)
 	}
+	if err := p.waitForTask(taskUPID(resp)); err != nil {
+		return fmt.Errorf("failed to stop container %s: %w", id, err)
+	}
 
 	utils.Log(fmt.Sprintf("Stopped LXC container VMID: %d", vmid))
 	return nil
 }
 
-// Restart restarts a container
-func (p *ProxmoxRuntime) Restart(id string) error {
-	if err := p.Stop(id); err != nil {
-		utils.Warn("Stop before restart failed: " + err.Error())
+// shutdown requests a clean shutdown and checks the container stopped within timeout
+func (p *ProxmoxRuntime) shutdown(node string, vmid int, timeout time.Duration) error {
+	seconds := int(timeout.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	body, _ := json.Marshal(map[string]interface{}{"timeout": seconds})
+
+	resp, err := p.lockedRequest(node, vmid, "POST", fmt.Sprintf("/nodes/%s/lxc/%d/status/shutdown", node, vmid), body)
+	if err != nil {
+		return err
+	}
+	if err := p.waitForTask(taskUPID(resp)); err != nil {
+		return err
+	}
+
+	status, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/status/current", node, vmid), nil)
+	if err != nil {
+		return err
+	}
+	if state := getStatus(status["status"]); state != "stopped" {
+		return fmt.Errorf("container is still %s after %s", state, timeout)
+	}
+	return nil
+}
+
+// restartStopMargin is how long Restart keeps polling past the stop timeout,
+// covering the forced stop that follows a shutdown which ran out of time
+const restartStopMargin = 15 * time.Second
+
+// Restart shuts a container down cleanly and starts it again once it is
+// really stopped. If it never stops, it is left alone and the error returned
+func (p *ProxmoxRuntime) Restart(id string) (err error) {
+	start := time.Now()
+	defer func() { p.observe("restart", start, err) }()
+
+	timeout := p.config.StopTimeout
+	if timeout == 0 {
+		timeout = defaultStopTimeout
 	}
 
-	time.Sleep(2 * time.Second)
+	stopErr := p.StopWithTimeout(id, timeout)
+	if errors.Is(stopErr, runtime.ErrContainerNotFound) {
+		return stopErr
+	}
+	if stopErr != nil {
+		utils.Warn("Stop before restart failed: " + stopErr.Error())
+	}
+
+	if err := p.WaitForState(id, runtime.StateExited, timeout+restartStopMargin); err != nil {
+		if stopErr != nil {
+			return fmt.Errorf("failed to restart container %s: %w", id, stopErr)
+		}
+		return fmt.Errorf("failed to restart container %s: %w", id, err)
+	}
 
 	return p.Start(id)
 }
 
 // Remove deletes a container
-func (p *ProxmoxRuntime) Remove(id string) error {
+func (p *ProxmoxRuntime) Remove(id string) (err error) {
+	defer p.cache.invalidate()
+	start := time.Now()
+	defer func() { p.observe("remove", start, err) }()
+
 	vmid, err := strconv.Atoi(id)
 	if err != nil {
 		return fmt.Errorf("invalid container ID: %s", id)
 	}
 
-	// Stop first if running
-	_ = p.Stop(id)
-	time.Sleep(2 * time.Second)
+	// Stop first if running, no need for a clean shutdown
+	_ = p.StopWithTimeout(id, 0)
+
+	node := p.nodeFor(vmid)
+	allocated := p.metadata.GetLabel(vmid, LabelAllocatedVolumes)
 
-	_, err = p.apiRequest("DELETE", fmt.Sprintf("/nodes/%s/lxc/%d", p.node, vmid), nil)
+	resp, err := p.lockedRequest(node, vmid, "DELETE", fmt.Sprintf("/nodes/%s/lxc/%d", node, vmid), nil)
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to delete container %s: %w", id, err)
 	}
+	if err := p.waitForTask(taskUPID(resp)); err != nil {
+		return fmt.Errorf("failed to delete container %s: %w", id, err)
+	}
 
-	// Remove metadata
+	// Free allocated volumes, remove port rules and metadata
+	p.freeAllocatedVolumes(vmid, node, allocated)
+	p.removePorts(vmid, node)
 	p.metadata.Delete(vmid)
 
 	utils.Log(fmt.Sprintf("Removed LXC container VMID: %d", vmid))
@@ -400,92 +1192,271 @@ func (p *ProxmoxRuntime) Remove(id string) error {
 
 // Recreate recreates a container with new config
 func (p *ProxmoxRuntime) Recreate(id string, config runtime.ContainerConfig) (string, error) {
-	if err := p.Remove(id); err != nil {
-		utils.Warn("Remove during recreate failed: " + err.Error())
+	// Keep the first-boot provisioning marker when recreating from the same template
+	provisioned := ""
+	history := ""
+	var previous runtime.ContainerConfig
+	if vmid, err := strconv.Atoi(id); err == nil {
+		if p.metadata.GetLabel(vmid, "cosmos-template") == config.Image {
+			provisioned = p.metadata.GetLabel(vmid, LabelProvisioned)
+		}
+		history = p.metadata.GetLabel(vmid, LabelHistory)
+		previous = runtime.ContainerConfig{
+			Name:  p.metadata.GetLabel(vmid, "cosmos-name"),
+			Image: p.metadata.GetLabel(vmid, "cosmos-template"),
+		}
+	}
+
+	// A container that is already gone is recreated, any other failure would leave two
+	if err := p.Remove(id); err != nil && !errors.Is(err, runtime.ErrContainerNotFound) {
+		return "", fmt.Errorf("failed to recreate container %s: %w", id, err)
+	}
+
+	newID, err := p.Create(config)
+	if err != nil {
+		return "", err
 	}
 
-	return p.Create(config)
+	newVMID, _ := strconv.Atoi(newID)
+	if provisioned != "" {
+		p.metadata.SetLabel(newVMID, LabelProvisioned, provisioned)
+	}
+
+	// Carry the history over to the new container
+	p.metadata.SetLabel(newVMID, LabelHistory, history)
+	p.recordChange(newVMID, "recreate", configChanges(previous, runtime.ContainerConfig{Name: config.Name, Image: config.Image}))
+
+	return newID, nil
 }
 
-// List returns all LXC containers
+// List returns the LXC containers of the node, or of the cluster with AllNodes,
+// without unmanaged ones unless IncludeUnmanaged is set
 func (p *ProxmoxRuntime) List() ([]runtime.Container, error) {
 	if !p.connected {
-		return nil, errors.New("not connected to Proxmox")
+		return nil, errNotConnected
 	}
 
-	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc", p.node), nil)
+	if p.config.AllNodes {
+		return p.listAllNodes()
+	}
+
+	resp, err := p.cachedGet(fmt.Sprintf("/nodes/%s/lxc", p.node))
 	if err != nil {
 		return nil, fmt.Errorf("failed to list containers: %w", err)
 	}
 
 	var containers []runtime.Container
-	if data, ok := resp["data"].([]interface{}); ok {
-		for _, item := range data {
-			if r, ok := item.(map[string]interface{}); ok {
-				vmid := int(r["vmid"].(float64))
-				container := runtime.Container{
-					ID:     strconv.Itoa(vmid),
-					Name:   p.metadata.GetLabel(vmid, "cosmos-name"),
-					Status: getStatus(r["status"]),
-					State:  mapProxmoxState(r["status"]),
-					Labels: p.metadata.Get(vmid),
-				}
-
-				if container.Name == "" {
-					if name, ok := r["name"].(string); ok {
-						container.Name = name
-					}
-				}
-
-				containers = append(containers, container)
-			}
+	for _, item := range listEntries(resp["data"], "the container list") {
+		container := p.containerFromStatus(item.VMID.Int(), item.LXCStatus)
+		if p.listed(container.Labels) {
+			containers = append(containers, container)
 		}
 	}
 
 	return containers, nil
 }
 
+// containerFromStatus builds a Container from a container status returned by the API
+func (p *ProxmoxRuntime) containerFromStatus(vmid int, status LXCStatus) runtime.Container {
+	container := runtime.Container{
+		ID:     strconv.Itoa(vmid),
+		Name:   p.metadata.GetLabel(vmid, "cosmos-name"),
+		Status: status.Status,
+		State:  mapProxmoxState(status.Status),
+		Labels: p.metadata.Get(vmid),
+	}
+	container.Created = p.createdAt(vmid, container.Labels)
+	if container.Status == "" {
+		container.Status = "unknown"
+	}
+
+	if container.Name == "" {
+		container.Name = status.Name
+	}
+
+	if !isManaged(container.Labels) {
+		if container.Labels == nil {
+			container.Labels = map[string]string{}
+		}
+		container.Labels[LabelExternal] = "true"
+	}
+
+	return container
+}
+
+// FindByName returns the container named name, looked up in the metadata index
+// then, for containers named outside of Cosmos, in List. Cosmos keeps names
+// unique; when several containers still share a name the lowest VMID wins
+func (p *ProxmoxRuntime) FindByName(name string) (*runtime.Container, error) {
+	if !p.connected {
+		return nil, errNotConnected
+	}
+
+	if vmid := p.metadata.FindByName(name); vmid != 0 {
+		status, err := p.containerStatus(p.nodeFor(vmid), vmid)
+		if err == nil {
+			container := p.containerFromStatus(vmid, status)
+			return &container, nil
+		}
+		if !isNotFound(err) {
+			return nil, fmt.Errorf("failed to get container %s: %w", name, err)
+		}
+		p.notFound(vmid) // stale index entry, fall back to List
+	}
+
+	containers, err := p.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *runtime.Container
+	lowest := 0
+	for i := range containers {
+		vmid, _ := strconv.Atoi(containers[i].ID)
+		if containers[i].Name == name && (found == nil || vmid < lowest) {
+			found, lowest = &containers[i], vmid
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s", runtime.ErrContainerNotFound, name)
+	}
+	return found, nil
+}
+
+// WaitForState polls status/current until the container is in state or timeout elapses
+func (p *ProxmoxRuntime) WaitForState(id string, state runtime.ContainerState, timeout time.Duration) error {
+	if !p.connected {
+		return errNotConnected
+	}
+
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("invalid container ID: %s", id)
+	}
+
+	return runtime.PollState(id, state, timeout, func() (runtime.ContainerState, error) {
+		status, err := p.containerStatus(p.nodeFor(vmid), vmid)
+		if isNotFound(err) {
+			return "", p.notFound(vmid)
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get container state: %w", err)
+		}
+		return mapProxmoxState(status.Status), nil
+	})
+}
+
 // Inspect returns detailed container information
 func (p *ProxmoxRuntime) Inspect(id string) (*runtime.ContainerDetails, error) {
 	vmid, err := strconv.Atoi(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid container ID: %s", id)
 	}
+	node := p.nodeFor(vmid)
 
-	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/config", p.node, vmid), nil)
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), nil)
+	if isNotFound(err) {
+		return nil, p.notFound(vmid)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to inspect container: %w", err)
 	}
 
-	hostname := ""
-	if h, ok := resp["hostname"].(string); ok {
-		hostname = h
+	status, err := p.containerStatus(node, vmid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
 	}
+	container := p.containerFromStatus(vmid, status)
 
-	memory := int64(0)
-	if m, ok := resp["memory"].(float64); ok {
-		memory = int64(m) * 1024 * 1024
+	live := map[string]string{}
+	if container.State == runtime.StateRunning {
+		live = p.liveAddresses(node, vmid)
+	}
+	endpoints, bridges := inspectNetworks(resp, live)
+	mounts := inspectMounts(resp, p.metadata.GetLabel(vmid, LabelTmpfs))
+	ports, bindings := p.portBindings(vmid)
+	container.Networks = bridges
+	container.Ports = ports
+
+	hostname, _ := resp["hostname"].(string)
+	nameserver, _ := resp["nameserver"].(string)
+	searchdomain, _ := resp["searchdomain"].(string)
+	features, _ := resp["features"].(string)
+	rootfs, _ := resp["rootfs"].(string)
+	privileged := floatValue(resp["unprivileged"]) == 0
+	swap := int64(floatValue(resp["swap"])) * 1024 * 1024
+
+	config := runtime.ContainerConfig{
+		Name:        container.Name,
+		Image:       p.metadata.GetLabel(vmid, "cosmos-template"),
+		Hostname:    hostname,
+		Environment: p.environment(vmid),
+		Labels:      container.Labels,
+		Ports:       ports,
+		Volumes:     mounts,
+		Networks:    bridges,
+		Memory:      int64(floatValue(resp["memory"])) * 1024 * 1024,
+		MemorySwap:  &swap,
+		CPUs:        configCPUs(resp),
+		CPUShares:   int64(floatValue(resp["cpuunits"])),
+		RootFSSize:  parseLXCSize(configOption(rootfs, "size")),
+		Privileged:  privileged,
+		DNS:         strings.Fields(nameserver),
+		DNSSearch:   strings.Fields(searchdomain),
+		Features:    inspectFeatures(features),
+	}
+	if startup, _ := resp["startup"].(string); startup != "" {
+		parseStartup(startup, &config)
+	}
+	if floatValue(resp["onboot"]) == 1 {
+		config.RestartPolicy = runtime.RestartPolicy{Name: "always"}
 	}
 
 	details := &runtime.ContainerDetails{
-		Container: runtime.Container{
-			ID:     id,
-			Name:   p.metadata.GetLabel(vmid, "cosmos-name"),
-			Labels: p.metadata.Get(vmid),
+		Container: container,
+		Config:    config,
+		NetworkSettings: runtime.NetworkSettings{
+			Networks: endpoints,
+			Ports:    bindings,
 		},
-		Config: runtime.ContainerConfig{
-			Name:     p.metadata.GetLabel(vmid, "cosmos-name"),
-			Hostname: hostname,
-			Memory:   memory,
+		Mounts: mounts,
+		HostConfig: runtime.HostConfig{
+			NetworkMode:   "bridge",
+			RestartPolicy: config.RestartPolicy,
+			Privileged:    privileged,
+			PortBindings:  bindings,
+			DNS:           config.DNS,
+			DNSSearch:     config.DNSSearch,
 		},
 	}
 
-	return details, nil
-}
+	// eth0 holds the primary address, as eth0 of a Docker bridge network
+	for _, endpoint := range endpoints {
+		if len(endpoints) == 1 || len(endpoint.Aliases) > 0 && endpoint.Aliases[0] == "eth0" {
+			details.NetworkSettings.IPAddress = endpoint.IPAddress
+			details.NetworkSettings.Gateway = endpoint.Gateway
+			details.NetworkSettings.MacAddress = endpoint.MacAddress
+		}
+	}
 
-// Logs returns container logs
-func (p *ProxmoxRuntime) Logs(id string, opts runtime.LogOptions) (io.ReadCloser, error) {
-	return io.NopCloser(strings.NewReader("Log streaming not yet implemented for Proxmox LXC\n")), nil
+	for _, mount := range mounts {
+		if mount.Type == runtime.MountTypeBind {
+			bind := mount.Source + ":" + mount.Target
+			if mount.ReadOnly {
+				bind += ":ro"
+			}
+			details.HostConfig.Binds = append(details.HostConfig.Binds, bind)
+		}
+	}
+
+	// Reading the disk usage costs an exec, skipped when it cannot succeed
+	if container.State == runtime.StateRunning && p.canExec() {
+		if usage, err := p.execDiskUsage(id, configMountTargets(resp)); err == nil {
+			details.DiskUsage = usage
+		}
+	}
+
+	return details, nil
 }
 
 // Stats returns container resource usage
@@ -495,47 +1466,70 @@ func (p *ProxmoxRuntime) Stats(id string) (*runtime.ContainerStats, error) {
 		return nil, fmt.Errorf("invalid container ID: %s", id)
 	}
 
-	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/status/current", p.node, vmid), nil)
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/status/current", p.nodeFor(vmid), vmid), nil)
+	if isNotFound(err) {
+		return nil, p.notFound(vmid)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get stats: %w", err)
 	}
 
+	return p.statsFromStatus(vmid, resp), nil
+}
+
+// statsFromStatus builds the stats of a container from its status/current or
+// cluster resources row, which share the cpu, mem and I/O counter fields
+func (p *ProxmoxRuntime) statsFromStatus(vmid int, resp map[string]interface{}) *runtime.ContainerStats {
 	stats := &runtime.ContainerStats{
-		ID:   id,
+		ID:   strconv.Itoa(vmid),
 		Name: p.metadata.GetLabel(vmid, "cosmos-name"),
 	}
 
-	if cpu, ok := resp["cpu"].(float64); ok {
-		stats.CPUPercent = cpu * 100
-	}
-
-	if mem, ok := resp["mem"].(float64); ok {
-		stats.MemoryUsage = int64(mem)
+	// Numbers may come as strings, anything else counts as 0
+	stats.CPUPercent = floatValue(resp["cpu"]) * 100
+	stats.MemoryUsage = int64(floatValue(resp["mem"]))
+	stats.MemoryLimit = int64(floatValue(resp["maxmem"]))
+	if stats.MemoryLimit > 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
 	}
 
-	if maxmem, ok := resp["maxmem"].(float64); ok {
-		stats.MemoryLimit = int64(maxmem)
-		if stats.MemoryLimit > 0 {
-			stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
-		}
-	}
+	p.fillIOStats(stats, vmid, resp)
 
-	return stats, nil
+	return stats
 }
 
-// StatsAll returns stats for all containers
+// StatsAll returns stats for all listed containers (see List). They are read in one
+// call from /cluster/resources, going through Stats per container only when
+// that endpoint is unavailable (e.g. a token without Sys.Audit on /)
 func (p *ProxmoxRuntime) StatsAll() ([]runtime.ContainerStats, error) {
-	containers, err := p.List()
+	if !p.connected {
+		return nil, errNotConnected
+	}
+
+	resp, err := p.apiRequest("GET", "/cluster/resources?type=vm", nil)
 	if err != nil {
-		return nil, err
+		utils.Warn(fmt.Sprintf("Cluster resources unavailable, reading stats per container: %s", err))
+		return p.statsEach()
 	}
 
 	var allStats []runtime.ContainerStats
-	for _, c := range containers {
-		stats, err := p.Stats(c.ID)
-		if err != nil {
+	for _, item := range listItems(resp) {
+		if item["type"] != "lxc" || (!p.config.AllNodes && item["node"] != p.node) {
 			continue
 		}
+		vmid := int(floatValue(item["vmid"]))
+		if vmid <= 0 {
+			utils.Warn(fmt.Sprintf("Skipping cluster resource without VMID: %v", item))
+			continue
+		}
+		if !p.listed(p.metadata.Get(vmid)) {
+			continue
+		}
+
+		stats := p.statsFromStatus(vmid, item)
+		if stats.Name == "" {
+			stats.Name, _ = item["name"].(string)
+		}
 		allStats = append(allStats, *stats)
 	}
 
@@ -0,0 +1,328 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestIsNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"not an API error", errors.New("Configuration file 'nodes/pve/lxc/100.conf' does not exist"), false},
+		{"404", &APIError{StatusCode: http.StatusNotFound, Body: "Not Found"}, true},
+		{"missing container", &APIError{StatusCode: http.StatusInternalServerError, Body: "Configuration file 'nodes/pve/lxc/100.conf' does not exist"}, true},
+		{"missing VM", &APIError{StatusCode: http.StatusInternalServerError, Body: "Configuration file 'nodes/pve2/qemu-server/101.conf' does not exist"}, true},
+		{"wrapped", fmt.Errorf("failed to start container 100: %w", &APIError{StatusCode: http.StatusInternalServerError, Body: "Configuration file 'nodes/pve/lxc/100.conf' does not exist\n"}), true},
+		{"missing storage", &APIError{StatusCode: http.StatusInternalServerError, Body: "storage 'nfs-data' does not exist"}, false},
+		{"missing volume", &APIError{StatusCode: http.StatusInternalServerError, Body: "volume 'local-lvm:vm-100-disk-1' does not exist"}, false},
+		{"missing bridge", &APIError{StatusCode: http.StatusInternalServerError, Body: "bridge 'vmbr9' does not exist"}, false},
+		{"locked", &APIError{StatusCode: http.StatusInternalServerError, Body: "CT is locked (backup)"}, false},
+		{"server error", &APIError{StatusCode: http.StatusInternalServerError, Body: "internal error"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNotFound(tt.err); got != tt.want {
+				t.Errorf("isNotFound(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemovedContainer(t *testing.T) {
+	operations := []struct {
+		name string
+		run  func(p *ProxmoxRuntime, id string) error
+	}{
+		{"Inspect", func(p *ProxmoxRuntime, id string) error { _, err := p.Inspect(id); return err }},
+		{"Start", func(p *ProxmoxRuntime, id string) error { return p.Start(id) }},
+		{"Stop", func(p *ProxmoxRuntime, id string) error { return p.Stop(id) }},
+	}
+
+	for _, op := range operations {
+		t.Run(op.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+			vmid := atoi(t, id)
+			cluster.removeGuest(vmid)
+
+			err = op.run(p, id)
+			if !errors.Is(err, runtime.ErrContainerNotFound) {
+				t.Fatalf("%s error = %v, want ErrContainerNotFound", op.name, err)
+			}
+			if p.metadata.Get(vmid) != nil {
+				t.Errorf("metadata of the removed container is kept")
+			}
+		})
+	}
+}
+
+func TestStorageErrorKeepsMetadata(t *testing.T) {
+	cluster := newFakeCluster(t)
+	p := newTestRuntime(t, cluster)
+
+	id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	cluster.handle("POST /nodes/pve/lxc/"+id+"/status/start", func(r *http.Request, body map[string]interface{}) (int, interface{}) {
+		return http.StatusInternalServerError, "storage 'nfs-data' does not exist"
+	})
+
+	err = p.Start(id)
+	if err == nil || errors.Is(err, runtime.ErrContainerNotFound) {
+		t.Fatalf("Start error = %v, want a storage error", err)
+	}
+	if p.metadata.Get(atoi(t, id)) == nil {
+		t.Errorf("metadata pruned on a storage error")
+	}
+}
+
+func TestStatsAll(t *testing.T) {
+	for _, bulk := range []bool{true, false} {
+		t.Run(map[bool]string{true: "cluster resources", false: "per container"}[bulk], func(t *testing.T) {
+			cluster := newFakeCluster(t, "pve", "pve2")
+			p := newTestRuntime(t, cluster)
+			addManagedGuests(p, cluster, 3)
+			cluster.addGuest(103, fakeGuest{Config: map[string]interface{}{"hostname": "manual"}}) // unmanaged
+			cluster.addGuest(104, fakeGuest{Type: "qemu"})
+			cluster.addGuest(105, fakeGuest{Node: "pve2", Config: map[string]interface{}{"hostname": "remote"}})
+			p.metadata.Set(105, map[string]string{"cosmos-name": "remote", LabelManaged: "true"})
+			if !bulk {
+				cluster.handle("GET /cluster/resources?type=vm", func(*http.Request, map[string]interface{}) (int, interface{}) {
+					return http.StatusForbidden, "Permission check failed (/, Sys.Audit)"
+				})
+			}
+
+			stats, err := p.StatsAll()
+			if err != nil {
+				t.Fatalf("StatsAll: %v", err)
+			}
+			var names []string
+			for _, s := range stats {
+				names = append(names, s.ID+" "+s.Name)
+				if s.MemoryLimit != 512*1024*1024 {
+					t.Errorf("container %s memory limit = %d", s.ID, s.MemoryLimit)
+				}
+			}
+			if want := []string{"100 app0", "101 app1", "102 app2"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("StatsAll = %q, want %q", names, want)
+			}
+			if statusCalls := cluster.count("GET /nodes/pve/lxc/100/status/current"); (statusCalls > 0) == bulk {
+				t.Errorf("%d status requests for container 100", statusCalls)
+			}
+		})
+	}
+}
+
+// BenchmarkStatsAll compares the requests made by StatsAll through the cluster
+// resources and, when they are unavailable, container by container
+func BenchmarkStatsAll(b *testing.B) {
+	for _, bulk := range []bool{true, false} {
+		b.Run(map[bool]string{true: "cluster resources", false: "per container"}[bulk], func(b *testing.B) {
+			cluster := newFakeCluster(b)
+			p := newTestRuntime(b, cluster)
+			addManagedGuests(p, cluster, 50)
+			if !bulk {
+				cluster.handle("GET /cluster/resources?type=vm", func(*http.Request, map[string]interface{}) (int, interface{}) {
+					return http.StatusForbidden, "Permission check failed (/, Sys.Audit)"
+				})
+			}
+			if _, err := p.StatsAll(); err != nil {
+				b.Fatal(err)
+			}
+			before := cluster.requests()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.StatsAll(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			b.ReportMetric(float64(cluster.requests()-before)/float64(b.N), "requests/op")
+		})
+	}
+}
+
+func TestMalformedPayloads(t *testing.T) {
+	// Rows of a container list, the valid ones being 100 and 101
+	rows := []interface{}{
+		nil,
+		"garbage",
+		42,
+		map[string]interface{}{"name": "no-vmid", "status": "running"},
+		map[string]interface{}{"vmid": "101", "name": "string-vmid", "status": "running"},
+		map[string]interface{}{"vmid": 102, "status": 5},
+		map[string]interface{}{"vmid": -3, "status": "running"},
+		map[string]interface{}{"vmid": 103, "maxmem": "lots"},
+		map[string]interface{}{"vmid": map[string]interface{}{"x": 1}},
+		map[string]interface{}{"vmid": 100, "name": "ok", "status": "running", "mem": "1024", "maxmem": 2048},
+	}
+	resourceRows := make([]interface{}, len(rows))
+	for i, row := range rows {
+		resourceRows[i] = row
+		if item, ok := row.(map[string]interface{}); ok {
+			copy := map[string]interface{}{"type": "lxc", "node": "pve"}
+			for k, v := range item {
+				copy[k] = v
+			}
+			resourceRows[i] = copy
+		}
+	}
+
+	tests := []struct {
+		name     string
+		allNodes bool
+		call     func(p *ProxmoxRuntime) ([]string, error)
+		want     []string
+	}{
+		{"List", false, listIDs, []string{"100", "101"}},
+		{"List on all nodes", true, listIDs, []string{"100", "101"}},
+		{"StatsAll", false, func(p *ProxmoxRuntime) ([]string, error) {
+			stats, err := p.StatsAll()
+			var ids []string
+			for _, s := range stats {
+				ids = append(ids, s.ID)
+			}
+			return ids, err
+		}, []string{"100", "101", "102", "103"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			cluster.handle("GET /nodes/pve/lxc", func(*http.Request, map[string]interface{}) (int, interface{}) {
+				return http.StatusOK, rows
+			})
+			cluster.handle("GET /cluster/resources?type=vm", func(*http.Request, map[string]interface{}) (int, interface{}) {
+				return http.StatusOK, resourceRows
+			})
+			p := newTestRuntime(t, cluster, func(c *Config) {
+				c.AllNodes = tt.allNodes
+				c.IncludeUnmanaged = true
+			})
+
+			got, err := tt.call(p)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s returned %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMalformedContainer(t *testing.T) {
+	cluster := newFakeCluster(t)
+	cluster.addGuest(100, fakeGuest{Status: "running", Config: map[string]interface{}{
+		"hostname": 7.0,
+		"memory":   "lots",
+		"swap":     nil,
+		"cores":    []interface{}{1.0},
+		"net0":     5.0,
+		"net1":     "bridge=vmbr0,ip=not-an-ip",
+		"mp0":      nil,
+		"mp1":      "",
+		"features": true,
+		"rootfs":   map[string]interface{}{"size": "8G"},
+	}})
+	cluster.handle("GET /nodes/pve/lxc/100/status/current", func(*http.Request, map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, map[string]interface{}{"vmid": "100", "status": "running", "cpu": "0.5", "mem": "abc", "maxmem": nil, "netin": []interface{}{}}
+	})
+	p := newTestRuntime(t, cluster)
+
+	stats, err := p.Stats("100")
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if stats.CPUPercent != 50 || stats.MemoryUsage != 0 || stats.MemoryPercent != 0 {
+		t.Errorf("Stats = %+v, want 50%% CPU and no memory", stats)
+	}
+
+	details, err := p.Inspect("100")
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	if details.State != runtime.StateRunning || details.Config.Memory != 0 || details.Config.Hostname != "" {
+		t.Errorf("Inspect = %+v", details)
+	}
+}
+
+func listIDs(p *ProxmoxRuntime) ([]string, error) {
+	containers, err := p.List()
+	var ids []string
+	for _, c := range containers {
+		ids = append(ids, c.ID)
+	}
+	return ids, err
+}
+
+func TestCreateSwap(t *testing.T) {
+	swap := func(bytes int64) *int64 { return &bytes }
+
+	tests := []struct {
+		name     string
+		memory   int64
+		swap     *int64
+		wantSwap float64 // MiB
+		wantErr  bool
+	}{
+		{"unset", 1 << 30, nil, 1024, false},
+		{"unset with the default memory", 0, nil, defaultMemoryMB, false},
+		{"explicitly zero", 1 << 30, swap(0), 0, false},
+		{"positive", 1 << 30, swap(256 << 20), 256, false},
+		{"larger than memory", 512 << 20, swap(2 << 30), 2048, false},
+		{"less than a MiB", 1 << 30, swap(1000), 0, false},
+		{"negative", 1 << 30, swap(-1), 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, Memory: tt.memory, MemorySwap: tt.swap})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Create succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			got, set := cluster.guest(atoi(t, id)).Config["swap"]
+			if !set || floatValue(got) != tt.wantSwap {
+				t.Errorf("swap = %v (set: %v), want %g", got, set, tt.wantSwap)
+			}
+
+			details, err := p.Inspect(id)
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+			if want := int64(tt.wantSwap) << 20; details.Config.MemorySwap == nil || *details.Config.MemorySwap != want {
+				t.Errorf("Inspect MemorySwap = %v, want %d", details.Config.MemorySwap, want)
+			}
+		})
+	}
+}
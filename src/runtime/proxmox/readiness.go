@@ -0,0 +1,114 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// First-boot readiness for Proxmox containers
+// The probe is stored in the cosmos-readiness label at Create and checked by
+// Start through Exec, so Start only returns once the app inside is ready.
+// Routes for a container are registered after Start, hence only once ready
+
+const (
+	LabelReadiness = "cosmos-readiness"
+	LabelReady     = "cosmos-ready"
+
+	defaultReadinessInterval = 2 * time.Second
+	defaultReadinessTimeout  = 2 * time.Minute
+)
+
+// ErrReadinessTimeout is returned when a readiness probe did not succeed in time
+var ErrReadinessTimeout = errors.New("readiness probe timed out")
+
+// storeReadiness persists the readiness probe of a container
+func (p *ProxmoxRuntime) storeReadiness(vmid int, probe *runtime.ReadinessProbe) {
+	if probe == nil {
+		return
+	}
+	encoded, err := json.Marshal(probe)
+	if err != nil {
+		return
+	}
+	p.metadata.SetLabel(vmid, LabelReadiness, string(encoded))
+}
+
+// waitReady blocks until the container's readiness probe succeeds, if it has one
+func (p *ProxmoxRuntime) waitReady(vmid int) error {
+	value := p.metadata.GetLabel(vmid, LabelReadiness)
+	if value == "" {
+		return nil
+	}
+
+	var probe runtime.ReadinessProbe
+	if err := json.Unmarshal([]byte(value), &probe); err != nil {
+		return fmt.Errorf("invalid readiness probe: %w", err)
+	}
+
+	id := strconv.Itoa(vmid)
+	err := pollReadiness(probe, func() (bool, error) {
+		return p.checkReadiness(id, probe)
+	})
+	if err != nil {
+		return fmt.Errorf("container %s is not ready: %w", id, err)
+	}
+
+	p.metadata.SetLabel(vmid, LabelReady, "true")
+	utils.Log(fmt.Sprintf("LXC container VMID %d is ready", vmid))
+	return nil
+}
+
+// checkReadiness runs the probe once inside the container
+func (p *ProxmoxRuntime) checkReadiness(id string, probe runtime.ReadinessProbe) (bool, error) {
+	cmd := probe.Command
+	if len(cmd) == 0 {
+		cmd = []string{"test", "-e", probe.File}
+	}
+
+	result, err := p.Exec(id, cmd, runtime.ExecOptions{})
+	if err != nil {
+		return false, err
+	}
+	return result.ExitCode == 0, nil
+}
+
+// pollReadiness calls check every probe interval until it succeeds or the probe times out.
+// Exec errors are retried, as the container may still be booting.
+func pollReadiness(probe runtime.ReadinessProbe, check func() (bool, error)) error {
+	if probe.File == "" && len(probe.Command) == 0 {
+		return nil
+	}
+
+	interval := time.Duration(probe.Interval)
+	if interval <= 0 {
+		interval = defaultReadinessInterval
+	}
+	timeout := time.Duration(probe.Timeout)
+	if timeout <= 0 {
+		timeout = defaultReadinessTimeout
+	}
+
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for {
+		ready, err := check()
+		if ready {
+			return nil
+		}
+		lastErr = err
+
+		if time.Now().Add(interval).After(deadline) {
+			if lastErr != nil {
+				return fmt.Errorf("%w after %s: %v", ErrReadinessTimeout, timeout, lastErr)
+			}
+			return fmt.Errorf("%w after %s", ErrReadinessTimeout, timeout)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,121 @@
+package proxmox
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestPollReadiness(t *testing.T) {
+	tests := []struct {
+		name      string
+		probe     runtime.ReadinessProbe
+		results   []bool  // result of each check, the last one repeats
+		errs      []error // error of the failed checks, the last one repeats
+		wantCalls int
+		wantErr   error
+	}{
+		{"no probe", runtime.ReadinessProbe{}, []bool{false}, nil, 0, nil},
+		{"ready at once", *fileProbe(time.Second), []bool{true}, nil, 1, nil},
+		{"ready after polling", *fileProbe(time.Second), []bool{false, false, true}, nil, 3, nil},
+		{"exec errors while booting", *fileProbe(time.Second), []bool{false, false, true}, []error{errors.New("not running"), errors.New("not running")}, 3, nil},
+		{"never ready", *fileProbe(50 * time.Millisecond), []bool{false}, nil, -1, ErrReadinessTimeout},
+		{"failing until the timeout", *fileProbe(50 * time.Millisecond), []bool{false}, []error{errors.New("exec failed")}, -1, ErrReadinessTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := pollReadiness(tt.probe, func() (bool, error) {
+				i := calls
+				calls++
+				ready := tt.results[min(i, len(tt.results)-1)]
+				if ready || len(tt.errs) == 0 {
+					return ready, nil
+				}
+				return false, tt.errs[min(i, len(tt.errs)-1)]
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("pollReadiness error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantCalls >= 0 && calls != tt.wantCalls {
+				t.Errorf("checked %d times, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr != nil && calls < 2 {
+				t.Errorf("checked %d times before timing out", calls)
+			}
+			if len(tt.errs) > 0 && tt.wantErr != nil && !strings.Contains(err.Error(), tt.errs[0].Error()) {
+				t.Errorf("error %q does not report the check error", err)
+			}
+		})
+	}
+}
+
+func TestStartWaitsForReadiness(t *testing.T) {
+	tests := []struct {
+		name      string
+		probe     *runtime.ReadinessProbe
+		readyAt   int // probe run that succeeds, 0 for never
+		wantReady bool
+		wantErr   error
+	}{
+		{"no probe", nil, 0, false, nil},
+		{"file probe", fileProbe(time.Second), 3, true, nil},
+		{"command probe", &runtime.ReadinessProbe{Command: []string{"pg_isready"}, Interval: int64(10 * time.Millisecond), Timeout: int64(time.Second)}, 2, true, nil},
+		{"timeout", fileProbe(50 * time.Millisecond), 0, false, ErrReadinessTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage, Readiness: tt.probe})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			var mu sync.Mutex
+			probes := 0
+			p.SetExecTransport(&fakeTransport{run: func(command, stdin string) (string, string, int) {
+				if !strings.Contains(command, "/run/app.ready") && !strings.Contains(command, "pg_isready") {
+					return "", "", 0
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				probes++
+				if tt.readyAt > 0 && probes >= tt.readyAt {
+					return "", "", 0
+				}
+				return "", "", 1
+			}})
+
+			err = p.Start(id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Start error = %v, want %v", err, tt.wantErr)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.readyAt > 0 && probes != tt.readyAt {
+				t.Errorf("probed %d times, want %d", probes, tt.readyAt)
+			}
+			if tt.probe == nil && probes != 0 {
+				t.Errorf("probed %d times without a probe", probes)
+			}
+			if ready := p.metadata.GetLabel(atoi(t, id), LabelReady) == "true"; ready != tt.wantReady {
+				t.Errorf("%s = %v, want %v", LabelReady, ready, tt.wantReady)
+			}
+		})
+	}
+}
+
+// fileProbe waits for /run/app.ready, polling every 10ms
+func fileProbe(timeout time.Duration) *runtime.ReadinessProbe {
+	return &runtime.ReadinessProbe{File: "/run/app.ready", Interval: int64(10 * time.Millisecond), Timeout: int64(timeout)}
+}
@@ -0,0 +1,101 @@
+package proxmox
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Metadata reconciliation
+// Containers deleted or renamed outside of Cosmos (Proxmox UI, pct) leave the
+// metadata store out of date. Reconcile, run on Connect, prunes the metadata
+// of containers that no longer exist, after confirming each one is gone so a
+// partial listing (missing permissions, node down) never loses labels. It
+// also updates the node of migrated containers and, with AdoptUnmanaged,
+// takes over containers created outside of Cosmos under their hostname.
+// Port forwarding rules are reconciled too, see reconcilePorts
+
+// Reconcile syncs the metadata store with the containers of the cluster
+func (p *ProxmoxRuntime) Reconcile() error {
+	if !p.connected {
+		return errNotConnected
+	}
+	return p.reconcile()
+}
+
+func (p *ProxmoxRuntime) reconcile() error {
+	resp, err := p.apiRequest("GET", "/cluster/resources?type=vm", nil)
+	if err != nil {
+		return fmt.Errorf("failed to list containers: %w", err)
+	}
+
+	live := make(map[int]map[string]interface{})
+	for _, item := range listItems(resp) {
+		if vmid, ok := item["vmid"].(float64); ok && item["type"] == "lxc" {
+			live[int(vmid)] = item
+		}
+	}
+
+	var pruned, adopted, moved []string
+	for _, vmid := range p.metadata.IDs() {
+		item, ok := live[vmid]
+		if !ok {
+			_, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/status/current", p.nodeFor(vmid), vmid), nil)
+			if isNotFound(err) {
+				p.metadata.Delete(vmid)
+				pruned = append(pruned, fmt.Sprint(vmid))
+			}
+			continue
+		}
+
+		node, _ := item["node"].(string)
+		if placed := p.metadata.GetLabel(vmid, LabelNode); placed != "" && node != "" && placed != node {
+			p.metadata.SetLabel(vmid, LabelNode, node)
+			moved = append(moved, fmt.Sprint(vmid))
+		}
+	}
+
+	if p.config.AdoptUnmanaged {
+		for vmid, item := range live {
+			if isManaged(p.metadata.Get(vmid)) {
+				continue
+			}
+			name, _ := item["name"].(string)
+			if name == "" {
+				name = fmt.Sprintf("ct%d", vmid)
+			}
+			node, _ := item["node"].(string)
+
+			p.metadata.SetLabel(vmid, "cosmos-name", name)
+			p.metadata.SetLabel(vmid, LabelManaged, "true")
+			p.metadata.SetLabel(vmid, LabelNode, node)
+			adopted = append(adopted, fmt.Sprintf("%d (%s)", vmid, name))
+		}
+		sort.Strings(adopted)
+	}
+
+	p.reconcilePorts(live)
+
+	if len(pruned)+len(adopted)+len(moved) > 0 {
+		utils.Log(fmt.Sprintf("Reconciled Proxmox metadata: %d pruned [%s], %d adopted [%s], %d moved [%s]",
+			len(pruned), strings.Join(pruned, ", "), len(adopted), strings.Join(adopted, ", "), len(moved), strings.Join(moved, ", ")))
+	}
+	return nil
+}
+
+// IDs returns the VMIDs having metadata, in ascending order
+func (m *MetadataStore) IDs() []int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]int, 0, len(m.data))
+	for vmid := range m.data {
+		if vmid != volumeMetadataID {
+			ids = append(ids, vmid)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
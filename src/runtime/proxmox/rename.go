@@ -0,0 +1,100 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Renaming containers
+// The name of a container is its cosmos-name label, and its hostname in
+// Proxmox. Rename claims the new name in the metadata store first, so a
+// concurrent Create or Rename cannot take it, then changes the hostname and
+// gives the old name back if Proxmox refuses it
+
+// Rename changes the name and hostname of a container
+func (p *ProxmoxRuntime) Rename(id string, newName string) error {
+	defer p.cache.invalidate()
+
+	if !p.connected {
+		return errNotConnected
+	}
+
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("invalid container ID: %s", id)
+	}
+	if newName == "" {
+		return fmt.Errorf("container name cannot be empty")
+	}
+
+	unlock := p.nameLocks.Lock(newName)
+	defer unlock()
+
+	node := p.nodeFor(vmid)
+	current, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), nil)
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to rename container %s: %w", id, err)
+	}
+	hostname, _ := current["hostname"].(string)
+
+	oldName, err := p.metadata.claimName(vmid, newName)
+	if err != nil {
+		return err
+	}
+
+	newHostname := lxcHostname(newName, vmid)
+	body, _ := json.Marshal(map[string]interface{}{"hostname": newHostname})
+	if _, err := p.apiRequest("PUT", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), strings.NewReader(string(body))); err != nil {
+		if oldName == "" {
+			p.metadata.UpdateLabels(vmid, nil, []string{"cosmos-name"})
+		} else {
+			p.metadata.SetLabel(vmid, "cosmos-name", oldName)
+		}
+		return fmt.Errorf("failed to rename container %s: %w", id, err)
+	}
+	p.warnPending(node, vmid)
+
+	p.recordChange(vmid, "rename", configChanges(
+		runtime.ContainerConfig{Name: oldName, Hostname: hostname},
+		runtime.ContainerConfig{Name: newName, Hostname: newHostname},
+	))
+
+	utils.Log(fmt.Sprintf("Renamed LXC container VMID %d from %s to %s", vmid, oldName, newName))
+	return nil
+}
+
+// claimName sets the cosmos-name label of a container unless another container
+// has that name, and returns the previous name
+func (m *MetadataStore) claimName(vmid int, name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, other := range m.indexed("cosmos-name", name) {
+		if other != vmid {
+			return "", fmt.Errorf("%w: %s (VMID %d)", runtime.ErrNameInUse, name, other)
+		}
+	}
+
+	if m.data == nil {
+		m.data = make(map[int]map[string]string)
+	}
+	if m.data[vmid] == nil {
+		m.data[vmid] = make(map[string]string)
+	}
+	oldName := m.data[vmid]["cosmos-name"]
+	m.unindex(vmid)
+	m.data[vmid]["cosmos-name"] = name
+	m.reindex(vmid)
+
+	// Auto-save after modification
+	m.markDirty(vmid)
+	return oldName, nil
+}
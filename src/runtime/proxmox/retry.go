@@ -0,0 +1,71 @@
+package proxmox
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// Retry of transient Proxmox API failures
+// GET requests are retried on connection failures and on transient server
+// errors (5xx, including the 595/596 returned during node failover). Other
+// methods are not idempotent and are only retried on connection failures.
+// 4xx answers are never retried
+
+const (
+	defaultMaxRetries     = 3
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	maxRetryDelay         = 10 * time.Second
+)
+
+// maxRetries returns the number of retries allowed per request
+func (p *ProxmoxRuntime) maxRetries() int {
+	switch {
+	case p.config.MaxRetries < 0:
+		return 0
+	case p.config.MaxRetries == 0:
+		return defaultMaxRetries
+	default:
+		return p.config.MaxRetries
+	}
+}
+
+// shouldRetry reports whether a failed request may be sent again
+func shouldRetry(method string, err error) bool {
+	if isConnectionError(err) {
+		return true
+	}
+
+	var apiErr *APIError
+	if method == http.MethodGet && errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	return false
+}
+
+// isConnectionError reports whether err is a network-level failure rather than an API answer
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// retryDelay returns the exponential backoff, with jitter, before retry number attempt (from 0)
+func retryDelay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+
+	delay := base << uint(attempt)
+	if delay <= 0 || delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
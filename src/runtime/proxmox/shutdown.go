@@ -0,0 +1,85 @@
+package proxmox
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Background work of the Proxmox runtime
+// Goroutines outliving the call that started them (health checks, followed
+// logs, template downloads, event polling, CreateWithProgress) are started
+// with goBackground and stop once the runtime context is done. Close cancels
+// it, which also aborts API calls in flight as they derive from it, then
+// waits up to closeTimeout for the goroutines before flushing the metadata.
+// Connect starts a new context when the runtime is reused after Close
+
+const closeTimeout = 10 * time.Second
+
+// lifecycle holds the context and goroutines of one Connect-Close cycle
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// background returns the runtime context
+func (p *ProxmoxRuntime) background() context.Context {
+	return p.currentLifecycle().ctx
+}
+
+func (p *ProxmoxRuntime) currentLifecycle() *lifecycle {
+	p.lifeMu.Lock()
+	defer p.lifeMu.Unlock()
+
+	if p.life == nil {
+		p.life = newLifecycle()
+	}
+	return p.life
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// renewBackground starts a new runtime context if Close cancelled the current one
+func (p *ProxmoxRuntime) renewBackground() {
+	p.lifeMu.Lock()
+	defer p.lifeMu.Unlock()
+
+	if p.life == nil || p.life.ctx.Err() != nil {
+		p.life = newLifecycle()
+	}
+}
+
+// goBackground runs fn in a goroutine Close waits for, fn must return once ctx is done
+func (p *ProxmoxRuntime) goBackground(fn func(ctx context.Context)) {
+	life := p.currentLifecycle()
+	life.wg.Add(1)
+	go func() {
+		defer life.wg.Done()
+		fn(life.ctx)
+	}()
+}
+
+// stopBackground cancels the runtime context and waits for the background goroutines
+func (p *ProxmoxRuntime) stopBackground() {
+	life := p.currentLifecycle()
+	life.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		life.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(closeTimeout):
+		utils.Warn(fmt.Sprintf("Proxmox background tasks still running %s after Close, leaving them behind", closeTimeout))
+	}
+}
@@ -0,0 +1,166 @@
+package proxmox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestCloseStopsBackgroundGoroutines(t *testing.T) {
+	tests := []struct {
+		name   string
+		follow bool
+		events bool
+		pull   bool
+		copy   bool
+		want   int // background goroutines before Close
+	}{
+		{"health monitor", false, false, false, false, 1},
+		{"follow logs", true, false, false, false, 2},
+		{"events", false, true, false, false, 2},
+		{"template pull", false, false, true, false, 2},
+		{"copy from container", false, false, false, true, 2},
+		{"everything", true, true, true, true, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			p := newTestRuntime(t, cluster)
+			p.SetExecTransport(&fakeTransport{run: func(command, stdin string) (string, string, int) {
+				if strings.Contains(command, "journalctl") {
+					return "booted\n-- cursor: s=1\n", "", 0
+				}
+				if strings.Contains(command, "/etc/hostname") {
+					return "app\n", "", 0
+				}
+				return "", "", 0
+			}})
+
+			id, err := p.Create(runtime.ContainerConfig{Name: "app", Image: testImage})
+			if err != nil {
+				t.Fatalf("Create: %v", err)
+			}
+
+			var logs io.ReadCloser
+			if tt.follow {
+				if logs, err = p.Logs(id, runtime.LogOptions{Follow: true}); err != nil {
+					t.Fatalf("Logs: %v", err)
+				}
+				defer logs.Close()
+			}
+			if tt.events {
+				// Left open on purpose, Close must stop the poller on its own
+				if _, err := p.Events(context.Background()); err != nil {
+					t.Fatalf("Events: %v", err)
+				}
+			}
+
+			var pull io.ReadCloser
+			if tt.pull {
+				handleTemplateDownload(cluster)
+				if pull, err = p.PullImage("alpine-3.19-default"); err != nil {
+					t.Fatalf("PullImage: %v", err)
+				}
+				defer pull.Close()
+			}
+
+			var copied io.ReadCloser
+			if tt.copy {
+				if copied, err = p.CopyFromContainer(id, "/etc/hostname"); err != nil {
+					t.Fatalf("CopyFromContainer: %v", err)
+				}
+				defer copied.Close()
+			}
+
+			if n := len(backgroundGoroutines()); n != tt.want {
+				t.Fatalf("%d background goroutines before Close, want %d", n, tt.want)
+			}
+
+			// Nothing reads the streams, so they end up blocked writing their first lines
+			streams := 0
+			if tt.follow {
+				streams++
+			}
+			if tt.pull {
+				streams++
+			}
+			if tt.copy {
+				streams++
+			}
+			waitFor(t, "streams blocked on their reader", func() bool {
+				blocked := 0
+				for _, stack := range backgroundGoroutines() {
+					if strings.Contains(stack, "io.(*pipe).write") {
+						blocked++
+					}
+				}
+				return blocked == streams
+			})
+
+			start := time.Now()
+			p.Close()
+			if elapsed := time.Since(start); elapsed > closeTimeout/2 {
+				t.Errorf("Close took %s", elapsed)
+			}
+			if leaked := backgroundGoroutines(); len(leaked) > 0 {
+				t.Fatalf("%d goroutines left after Close:\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+			}
+
+			if tt.follow {
+				if _, err := io.ReadAll(logs); err != nil {
+					t.Errorf("log stream not ended by Close: %v", err)
+				}
+			}
+			if tt.pull {
+				if _, err := io.ReadAll(pull); !errors.Is(err, errNotConnected) {
+					t.Errorf("pull stream error = %v, want %v", err, errNotConnected)
+				}
+			}
+			if tt.copy {
+				if _, err := io.ReadAll(copied); !errors.Is(err, errNotConnected) {
+					t.Errorf("copy stream error = %v, want %v", err, errNotConnected)
+				}
+			}
+		})
+	}
+}
+
+// handleTemplateDownload serves a template download task that never ends and
+// logs a line at every poll
+func handleTemplateDownload(cluster *fakeCluster) {
+	const upid = "UPID:pve:00000001:00000000:00000000:download::root@pam:"
+	cluster.handle("GET /nodes/pve/aplinfo", func(*http.Request, map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, []map[string]interface{}{{"template": "alpine-3.19-default_20240207_amd64.tar.xz", "package": "alpine-3.19-default", "type": "lxc"}}
+	})
+	cluster.handle("POST /nodes/pve/aplinfo", func(*http.Request, map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, upid
+	})
+	cluster.handle("GET /nodes/pve/tasks/"+upid+"/log", func(*http.Request, map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, []map[string]interface{}{{"t": "downloading..."}}
+	})
+	cluster.handle("GET /nodes/pve/tasks/"+upid+"/status", func(*http.Request, map[string]interface{}) (int, interface{}) {
+		return http.StatusOK, map[string]interface{}{"status": "running"}
+	})
+}
+
+// backgroundGoroutines returns the stacks of the goroutines started by goBackground
+func backgroundGoroutines() []string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:goruntime.Stack(buf, true)]
+
+	var stacks []string
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		if strings.Contains(stack, "(*ProxmoxRuntime).goBackground") {
+			stacks = append(stacks, stack)
+		}
+	}
+	return stacks
+}
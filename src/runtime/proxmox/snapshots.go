@@ -0,0 +1,227 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// Container snapshots
+// Snapshots give Cosmos a rollback point around updates and post-install
+// steps. They need storage supporting them (ZFS, LVM-thin, Ceph...); Proxmox
+// refuses them on plain directories. Proxmox keeps the description in the
+// container config and may shorten or reflow it, in which case the full text
+// is kept in the cosmos-snapshots label and returned by ListSnapshots
+
+// LabelSnapshots holds the descriptions Proxmox did not keep verbatim, as a JSON object by snapshot name
+const LabelSnapshots = "cosmos-snapshots"
+
+var snapshotNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{1,39}$`)
+
+// Snapshot is a snapshot of a container
+type Snapshot struct {
+	Name        string
+	Description string
+	Parent      string // snapshot this one was taken after, empty for the first
+	Created     int64  // unix timestamp
+}
+
+// Snapshot takes a snapshot of a container and waits for the task
+func (p *ProxmoxRuntime) Snapshot(id, name, description string) error {
+	vmid, node, err := p.snapshotTarget(id, name)
+	if err != nil {
+		return err
+	}
+	defer p.cache.invalidate()
+
+	body, _ := json.Marshal(map[string]interface{}{"snapname": name, "description": description})
+	resp, err := p.apiRequest("POST", fmt.Sprintf("/nodes/%s/lxc/%d/snapshot", node, vmid), strings.NewReader(string(body)))
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
+	if err == nil {
+		err = p.waitForTask(taskUPID(resp))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to snapshot container %s: %w", id, err)
+	}
+
+	if description != "" {
+		snapshots, err := p.ListSnapshots(id)
+		if err == nil {
+			for _, snapshot := range snapshots {
+				if snapshot.Name == name && snapshot.Description != description {
+					p.setSnapshotDescription(vmid, name, description)
+				}
+			}
+		}
+	}
+
+	p.recordChange(vmid, "snapshot", []runtime.FieldChange{{Field: "Snapshot", New: name}})
+
+	utils.Log(fmt.Sprintf("Created snapshot %s of LXC container VMID %d", name, vmid))
+	return nil
+}
+
+// ListSnapshots returns the snapshots of a container, oldest first
+func (p *ProxmoxRuntime) ListSnapshots(id string) ([]Snapshot, error) {
+	if !p.connected {
+		return nil, errNotConnected
+	}
+
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid container ID: %s", id)
+	}
+
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/snapshot", p.nodeFor(vmid), vmid), nil)
+	if isNotFound(err) {
+		return nil, p.notFound(vmid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of container %s: %w", id, err)
+	}
+
+	descriptions := p.snapshotDescriptions(vmid)
+	var snapshots []Snapshot
+	for _, item := range listItems(resp) {
+		name, _ := item["name"].(string)
+		// "current" is the running state, not a snapshot
+		if name == "" || name == "current" {
+			continue
+		}
+		snapshot := Snapshot{
+			Name:    name,
+			Created: int64(floatValue(item["snaptime"])),
+		}
+		snapshot.Description, _ = item["description"].(string)
+		snapshot.Parent, _ = item["parent"].(string)
+		if description, ok := descriptions[name]; ok {
+			snapshot.Description = description
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Created < snapshots[j].Created })
+	return snapshots, nil
+}
+
+// RollbackSnapshot restores a container to a snapshot and waits for the task.
+// A running container is stopped by the rollback and started again afterwards
+func (p *ProxmoxRuntime) RollbackSnapshot(id, name string) error {
+	vmid, node, err := p.snapshotTarget(id, name)
+	if err != nil {
+		return err
+	}
+	defer p.cache.invalidate()
+
+	status, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/status/current", node, vmid), nil)
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to roll back container %s: %w", id, err)
+	}
+
+	body := map[string]interface{}{}
+	if status["status"] == "running" {
+		body["start"] = 1
+	}
+	encoded, _ := json.Marshal(body)
+
+	resp, err := p.apiRequest("POST", fmt.Sprintf("/nodes/%s/lxc/%d/snapshot/%s/rollback", node, vmid, name), strings.NewReader(string(encoded)))
+	if err == nil {
+		err = p.waitForTask(taskUPID(resp))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to roll back container %s to snapshot %s: %w", id, name, err)
+	}
+
+	p.recordChange(vmid, "rollback", []runtime.FieldChange{{Field: "Snapshot", New: name}})
+
+	utils.Log(fmt.Sprintf("Rolled back LXC container VMID %d to snapshot %s", vmid, name))
+	return nil
+}
+
+// DeleteSnapshot deletes a snapshot of a container and waits for the task
+func (p *ProxmoxRuntime) DeleteSnapshot(id, name string) error {
+	vmid, node, err := p.snapshotTarget(id, name)
+	if err != nil {
+		return err
+	}
+	defer p.cache.invalidate()
+
+	resp, err := p.apiRequest("DELETE", fmt.Sprintf("/nodes/%s/lxc/%d/snapshot/%s", node, vmid, name), nil)
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
+	if err == nil {
+		err = p.waitForTask(taskUPID(resp))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot %s of container %s: %w", name, id, err)
+	}
+
+	p.setSnapshotDescription(vmid, name, "")
+	p.recordChange(vmid, "delete-snapshot", []runtime.FieldChange{{Field: "Snapshot", Old: name}})
+
+	utils.Log(fmt.Sprintf("Deleted snapshot %s of LXC container VMID %d", name, vmid))
+	return nil
+}
+
+// snapshotTarget validates the arguments of a snapshot operation and returns the VMID and node
+func (p *ProxmoxRuntime) snapshotTarget(id, name string) (int, string, error) {
+	if !p.connected {
+		return 0, "", errNotConnected
+	}
+
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid container ID: %s", id)
+	}
+	if !snapshotNamePattern.MatchString(name) || name == "current" {
+		return 0, "", fmt.Errorf("invalid snapshot name %q: 2 to 40 letters, digits, - or _, starting with a letter", name)
+	}
+	return vmid, p.nodeFor(vmid), nil
+}
+
+// snapshotDescriptions returns the descriptions kept in metadata, by snapshot name
+func (p *ProxmoxRuntime) snapshotDescriptions(vmid int) map[string]string {
+	descriptions := map[string]string{}
+	if encoded := p.metadata.GetLabel(vmid, LabelSnapshots); encoded != "" {
+		if err := json.Unmarshal([]byte(encoded), &descriptions); err != nil {
+			utils.Warn(fmt.Sprintf("Ignoring invalid snapshot descriptions of LXC container VMID %d: %s", vmid, err))
+		}
+	}
+	return descriptions
+}
+
+// setSnapshotDescription keeps the description of a snapshot in metadata, or forgets it when empty
+func (p *ProxmoxRuntime) setSnapshotDescription(vmid int, name, description string) {
+	if p.metadata.Get(vmid) == nil {
+		return
+	}
+
+	descriptions := p.snapshotDescriptions(vmid)
+	if description == "" {
+		if _, ok := descriptions[name]; !ok {
+			return
+		}
+		delete(descriptions, name)
+	} else {
+		descriptions[name] = description
+	}
+
+	if len(descriptions) == 0 {
+		p.metadata.UpdateLabels(vmid, nil, []string{LabelSnapshots})
+		return
+	}
+	encoded, _ := json.Marshal(descriptions)
+	p.metadata.SetLabel(vmid, LabelSnapshots, string(encoded))
+}
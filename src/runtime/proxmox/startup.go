@@ -0,0 +1,53 @@
+package proxmox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Boot sequencing of Proxmox containers
+// StartupOrder, StartupDelay and ShutdownDelay become the startup option
+// ("order=N,up=S,down=S"). Proxmox applies it to the containers started with
+// the node: lower orders start first and stop last, up is the pause before
+// the next container starts and down the time allowed to shut down
+
+// lxcStartup returns the startup option of config, "" when nothing is set
+func lxcStartup(config runtime.ContainerConfig) (string, error) {
+	if config.StartupOrder < 0 {
+		return "", fmt.Errorf("invalid startup order %d, must not be negative", config.StartupOrder)
+	}
+	if config.StartupDelay < 0 || config.ShutdownDelay < 0 {
+		return "", fmt.Errorf("invalid startup delays (up %ds, down %ds), must not be negative", config.StartupDelay, config.ShutdownDelay)
+	}
+
+	var parts []string
+	if config.StartupOrder > 0 {
+		parts = append(parts, "order="+strconv.Itoa(config.StartupOrder))
+	}
+	if config.StartupDelay > 0 {
+		parts = append(parts, "up="+strconv.Itoa(config.StartupDelay))
+	}
+	if config.ShutdownDelay > 0 {
+		parts = append(parts, "down="+strconv.Itoa(config.ShutdownDelay))
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// parseStartup sets the boot sequencing of config from a startup option
+func parseStartup(value string, config *runtime.ContainerConfig) {
+	for _, part := range strings.Split(value, ",") {
+		key, v, _ := strings.Cut(part, "=")
+		n, _ := strconv.Atoi(v)
+		switch key {
+		case "order":
+			config.StartupOrder = n
+		case "up":
+			config.StartupDelay = n
+		case "down":
+			config.ShutdownDelay = n
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package proxmox
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
 	"github.com/azukaar/cosmos-server/src/utils"
@@ -11,53 +17,221 @@ import (
 // Proxmox uses storage pools (local, local-lvm, NFS, etc.)
 // Volumes are typically bind mounts or dedicated storage volumes
 
-// CreateVolume creates a storage volume
+const (
+	// LabelStorage overrides the storage holding a container's rootfs
+	LabelStorage = "cosmos-storage"
+
+	// LabelVolumeID holds the Proxmox volume ID of a listed volume
+	LabelVolumeID = "cosmos-volume-id"
+
+	defaultRootFSSizeGB = 8
+	gigabyte            = 1024 * 1024 * 1024
+)
+
+var (
+	volumeNamePattern   = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	cosmosVolumePattern = regexp.MustCompile(`^[^:]+:(?:\d+/)?vm-\d+-cosmos-(.+?)(?:\.raw)?$`)
+)
+
+// rootfsStorage returns the storage for a container's rootfs, honoring the cosmos-storage label
+func (p *ProxmoxRuntime) rootfsStorage(config runtime.ContainerConfig) string {
+	if storage := config.Labels[LabelStorage]; storage != "" {
+		return storage
+	}
+	return p.config.Storage
+}
+
+// rootfsSizeGB converts a rootfs size in bytes to whole gigabytes, rounding up
+func rootfsSizeGB(size int64) int64 {
+	if size <= 0 {
+		return defaultRootFSSizeGB
+	}
+	return (size + gigabyte - 1) / gigabyte
+}
+
+// validateStorage checks that storage is active on node and can hold container rootfs
+func (p *ProxmoxRuntime) validateStorage(node, storage string) error {
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/storage?content=rootdir", node), nil)
+	if err != nil {
+		return fmt.Errorf("failed to list storages of node %s: %w", node, err)
+	}
+
+	var available []string
+	for _, item := range listItems(resp) {
+		name, _ := item["storage"].(string)
+		if active, ok := item["active"].(float64); ok && active == 0 {
+			continue
+		}
+		if name == storage {
+			return nil
+		}
+		available = append(available, name)
+	}
+
+	sort.Strings(available)
+	return fmt.Errorf("storage %q is not available for containers on node %s (available: %s)", storage, node, strings.Join(available, ", "))
+}
+
+// CreateVolume allocates a disk image in the configured storage. Volumes are
+// owned by VMIDEnd, outside the container range, so that removing a container
+// never frees them, and are named vm-<owner>-cosmos-<name>
 func (p *ProxmoxRuntime) CreateVolume(config runtime.VolumeConfig) (string, error) {
-	// In Proxmox, volumes are typically:
-	// 1. Bind mounts from host paths
-	// 2. Storage volumes in a storage pool (local-lvm, etc.)
+	if !p.connected {
+		return "", errNotConnected
+	}
 
-	volumeID := fmt.Sprintf("cosmos-vol-%s", config.Name)
+	if !volumeNamePattern.MatchString(config.Name) {
+		return "", fmt.Errorf("invalid volume name: %s", config.Name)
+	}
 
-	// For bind mounts, just ensure the directory exists
-	// For storage volumes, would need to create via:
-	// POST /nodes/{node}/storage/{storage}/content
+	storage := p.config.Storage
+	filename := volumeFilename(p.volumeOwner(), config.Name)
+	if p.isFileStorage(storage) {
+		filename += ".raw"
+	}
 
-	utils.Log(fmt.Sprintf("Volume '%s' created in storage '%s'", config.Name, p.config.Storage))
+	body, _ := json.Marshal(map[string]interface{}{
+		"vmid":     p.volumeOwner(),
+		"filename": filename,
+		"size":     fmt.Sprintf("%dG", rootfsSizeGB(config.Size)),
+		"format":   "raw",
+	})
+	resp, err := p.apiRequest("POST", fmt.Sprintf("/nodes/%s/storage/%s/content", p.node, storage), strings.NewReader(string(body)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create volume %s: %w", config.Name, err)
+	}
 
-	return volumeID, nil
+	volid, _ := resp["data"].(string)
+	if volid == "" {
+		volid = storage + ":" + filename
+	}
+
+	p.metadata.SetVolumeLabels(config.Name, config.Labels)
+
+	utils.Log(fmt.Sprintf("Volume '%s' created in storage '%s' (%s)", config.Name, storage, volid))
+	return volid, nil
 }
 
-// RemoveVolume removes a storage volume
+// RemoveVolume frees a volume, by volume ID or name
 func (p *ProxmoxRuntime) RemoveVolume(id string) error {
-	// Remove volume from Proxmox storage
-	// DELETE /nodes/{node}/storage/{storage}/content/{volume}
+	if !p.connected {
+		return errNotConnected
+	}
 
-	utils.Log(fmt.Sprintf("Volume '%s' removed", id))
-	return nil
+	volumes, err := p.ListVolumes()
+	if err != nil {
+		return err
+	}
+
+	for _, volume := range volumes {
+		volid := volume.Labels[LabelVolumeID]
+		if volid != id && volume.Name != id {
+			continue
+		}
+
+		storage, _, _ := strings.Cut(volid, ":")
+		resp, err := p.apiRequest("DELETE", fmt.Sprintf("/nodes/%s/storage/%s/content/%s", p.node, storage, url.PathEscape(volid)), nil)
+		if err != nil {
+			return fmt.Errorf("failed to remove volume %s: %w", id, err)
+		}
+		if err := p.waitForTask(taskUPID(resp)); err != nil {
+			return fmt.Errorf("failed to remove volume %s: %w", id, err)
+		}
+
+		p.metadata.DeleteVolumeLabels(volume.Name)
+
+		utils.Log(fmt.Sprintf("Volume '%s' removed", id))
+		return nil
+	}
+
+	return fmt.Errorf("volume %s not found", id)
 }
 
-// ListVolumes returns available volumes
+// ListVolumes returns the disk images and container volumes of the configured storage
 func (p *ProxmoxRuntime) ListVolumes() ([]runtime.Volume, error) {
 	if !p.connected {
-		return nil, fmt.Errorf("not connected to Proxmox")
+		return nil, errNotConnected
 	}
 
-	// List volumes in storage pool
-	// GET /nodes/{node}/storage/{storage}/content
-
+	storage := p.config.Storage
 	volumes := []runtime.Volume{}
 
-	// In production, query actual storage content
-	// Filter for cosmos-vol-* prefix
+	for _, content := range []string{"images", "rootdir"} {
+		resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/storage/%s/content?content=%s", p.node, storage, content), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list volumes: %w", err)
+		}
+
+		for _, item := range listItems(resp) {
+			volid, _ := item["volid"].(string)
+			if volid == "" {
+				continue
+			}
+
+			volume := runtime.Volume{
+				Name:       volid,
+				Driver:     "proxmox",
+				Mountpoint: volid,
+				Size:       int64(floatValue(item["size"])),
+				Labels:     map[string]string{LabelVolumeID: volid},
+			}
+			if path, ok := item["path"].(string); ok && path != "" {
+				volume.Mountpoint = path
+			}
+			if ctime := int64(floatValue(item["ctime"])); ctime > 0 {
+				volume.CreatedAt = time.Unix(ctime, 0).UTC().Format(time.RFC3339)
+			}
+
+			// Cosmos volumes are listed by name with their labels
+			if name := cosmosVolumeName(volid); name != "" {
+				volume.Name = name
+				for k, v := range p.metadata.VolumeLabels(name) {
+					volume.Labels[k] = v
+				}
+			}
+
+			volumes = append(volumes, volume)
+		}
+	}
 
 	return volumes, nil
 }
 
+// volumeOwner returns the VMID owning Cosmos volumes
+func (p *ProxmoxRuntime) volumeOwner() int {
+	return p.config.VMIDEnd
+}
+
+// isFileStorage reports whether a storage keeps disk images as files, which need an extension
+func (p *ProxmoxRuntime) isFileStorage(storage string) bool {
+	resp, err := p.apiRequest("GET", "/storage/"+storage, nil)
+	if err != nil {
+		return false
+	}
+	switch resp["type"] {
+	case "dir", "nfs", "cifs", "glusterfs", "cephfs", "btrfs":
+		return true
+	}
+	return false
+}
+
+func volumeFilename(owner int, name string) string {
+	return fmt.Sprintf("vm-%d-cosmos-%s", owner, name)
+}
+
+// cosmosVolumeName returns the Cosmos name of a volume ID, empty for other volumes
+func cosmosVolumeName(volid string) string {
+	m := cosmosVolumePattern.FindStringSubmatch(volid)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
 // GetStorageInfo returns information about a storage pool
 func (p *ProxmoxRuntime) GetStorageInfo() (map[string]interface{}, error) {
 	if !p.connected {
-		return nil, fmt.Errorf("not connected to Proxmox")
+		return nil, errNotConnected
 	}
 
 	// GET /nodes/{node}/storage/{storage}/status
@@ -0,0 +1,106 @@
+package proxmox
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Runtime health summary
+// Health aggregates the connection state, the Proxmox version, the node
+// status, the VMID range usage and the state of the managed containers, from
+// /version (cached) and a single /cluster/resources call, for status widgets
+// and monitoring probes
+
+// vmidUsageWarning is the VMID range usage, in percent, from which Health warns
+const vmidUsageWarning = 90
+
+// RuntimeHealth is the health report of the Proxmox runtime
+type RuntimeHealth struct {
+	Connected  bool
+	Version    string
+	LastPing   time.Time
+	Node       string
+	NodeOnline bool
+	Nodes      map[string]bool // online state of every node of the cluster
+
+	VMIDStart int
+	VMIDEnd   int
+	VMIDsUsed int     // guests (containers and VMs) in [VMIDStart, VMIDEnd)
+	VMIDUsage float64 // percent of the range in use
+
+	Running int // managed containers running
+	Stopped int // managed containers in any other state
+
+	Warnings []string
+}
+
+// Health returns the health report of the runtime. When the runtime is not
+// connected the report only holds the connection state and errNotConnected is returned
+func (p *ProxmoxRuntime) Health() (RuntimeHealth, error) {
+	health := RuntimeHealth{
+		Connected: p.IsConnected(),
+		LastPing:  p.LastPing(),
+		Node:      p.node,
+		VMIDStart: p.config.VMIDStart,
+		VMIDEnd:   p.config.VMIDEnd,
+		Nodes:     map[string]bool{},
+	}
+	if !health.Connected {
+		return health, errNotConnected
+	}
+
+	health.Version = p.Version()
+
+	resp, err := p.apiRequest("GET", "/cluster/resources", nil)
+	if err != nil {
+		return health, fmt.Errorf("failed to read cluster resources: %w", err)
+	}
+
+	for _, item := range listItems(resp) {
+		kind, _ := item["type"].(string)
+		status, _ := item["status"].(string)
+		switch kind {
+		case "node":
+			name, _ := item["node"].(string)
+			health.Nodes[name] = status == "online"
+		case "lxc", "qemu":
+			vmid := int(floatValue(item["vmid"]))
+			if vmid >= health.VMIDStart && vmid < health.VMIDEnd {
+				health.VMIDsUsed++
+			}
+			if kind != "lxc" || !isManaged(p.metadata.Get(vmid)) {
+				continue
+			}
+			if status == "running" {
+				health.Running++
+			} else {
+				health.Stopped++
+			}
+		}
+	}
+	health.NodeOnline = health.Nodes[p.node]
+
+	if size := health.VMIDEnd - health.VMIDStart; size > 0 {
+		health.VMIDUsage = float64(health.VMIDsUsed) / float64(size) * 100
+	}
+
+	if !health.NodeOnline {
+		health.Warnings = append(health.Warnings, fmt.Sprintf("node %s is offline", p.node))
+	}
+	var offline []string
+	for name, online := range health.Nodes {
+		if !online && name != p.node {
+			offline = append(offline, name)
+		}
+	}
+	sort.Strings(offline)
+	for _, name := range offline {
+		health.Warnings = append(health.Warnings, fmt.Sprintf("cluster node %s is offline", name))
+	}
+	if health.VMIDUsage >= vmidUsageWarning {
+		health.Warnings = append(health.Warnings, fmt.Sprintf("%.0f%% of the VMID range %d-%d is in use", health.VMIDUsage, health.VMIDStart, health.VMIDEnd-1))
+	}
+
+	return health, nil
+}
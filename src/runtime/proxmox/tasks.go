@@ -0,0 +1,151 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Task handling for Proxmox
+// Write operations (create, start, stop, delete...) return a UPID and run
+// asynchronously on the node, so callers have to poll the task status. A failed
+// task returns a *TaskError carrying the end of the task log, where Proxmox
+// writes the actual cause ("TASK ERROR: mount point mp0 ...")
+
+const (
+	taskPollInterval   = 1 * time.Second
+	defaultTaskTimeout = 5 * time.Minute
+	taskLogTail        = 10 // log lines kept in a TaskError
+)
+
+// ErrTaskFailed is matched by errors.Is for every *TaskError
+var ErrTaskFailed = errors.New("Proxmox task failed")
+
+// TaskError describes a task that stopped with an error
+type TaskError struct {
+	UPID       string
+	ExitStatus string
+	Log        []string // last lines of the task log
+}
+
+func (e *TaskError) Error() string {
+	message := e.ExitStatus
+	for _, line := range e.Log {
+		if cause, ok := strings.CutPrefix(line, "TASK ERROR: "); ok {
+			message = cause
+		}
+	}
+	return fmt.Sprintf("task %s failed: %s", e.UPID, message)
+}
+
+// Is makes errors.Is(err, ErrTaskFailed) match task errors
+func (e *TaskError) Is(target error) bool {
+	return target == ErrTaskFailed
+}
+
+// taskUPID extracts the UPID returned by an asynchronous API call
+func taskUPID(resp map[string]interface{}) string {
+	if upid, ok := resp["data"].(string); ok && strings.HasPrefix(upid, "UPID:") {
+		return upid
+	}
+	return ""
+}
+
+// taskNode returns the node a task runs on, as encoded in its UPID
+func taskNode(upid, fallback string) string {
+	parts := strings.Split(upid, ":")
+	if len(parts) > 1 && parts[1] != "" {
+		return parts[1]
+	}
+	return fallback
+}
+
+// waitForTask polls a task until it has stopped and checks its exit status
+func (p *ProxmoxRuntime) waitForTask(upid string) error {
+	return p.followTask(upid, nil)
+}
+
+// followTask is waitForTask passing the new task log lines to onLog at every poll
+func (p *ProxmoxRuntime) followTask(upid string, onLog func(line string)) (err error) {
+	if upid == "" {
+		return nil
+	}
+	start := time.Now()
+	defer func() { p.observeTaskWait(upid, start, err) }()
+
+	timeout := p.config.TaskTimeout
+	if timeout <= 0 {
+		timeout = defaultTaskTimeout
+	}
+
+	deadline := time.Now().Add(timeout)
+	path := fmt.Sprintf("/nodes/%s/tasks/%s/status", taskNode(upid, p.node), url.PathEscape(upid))
+	logLines := 0
+
+	for {
+		if onLog != nil {
+			logLines = p.readTaskLog(upid, logLines, onLog)
+		}
+
+		resp, err := p.apiRequest("GET", path, nil)
+		if err != nil {
+			return fmt.Errorf("failed to get task status: %w", err)
+		}
+
+		if status, _ := resp["status"].(string); status == "stopped" {
+			if onLog != nil {
+				p.readTaskLog(upid, logLines, onLog)
+			}
+			exitStatus, _ := resp["exitstatus"].(string)
+			if exitStatus != "OK" {
+				return &TaskError{UPID: upid, ExitStatus: exitStatus, Log: p.taskLog(upid)}
+			}
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for task %s", timeout, upid)
+		}
+
+		time.Sleep(taskPollInterval)
+	}
+}
+
+// taskLog returns the last non-empty lines of a task log, nil when it cannot be read
+func (p *ProxmoxRuntime) taskLog(upid string) []string {
+	path := fmt.Sprintf("/nodes/%s/tasks/%s/log?limit=50000", taskNode(upid, p.node), url.PathEscape(upid))
+	resp, err := p.apiRequest("GET", path, nil)
+	if err != nil {
+		return nil
+	}
+
+	var lines []string
+	for _, item := range listItems(resp) {
+		if text, _ := item["t"].(string); strings.TrimSpace(text) != "" && text != "no content" {
+			lines = append(lines, text)
+		}
+	}
+	if len(lines) > taskLogTail {
+		lines = lines[len(lines)-taskLogTail:]
+	}
+	return lines
+}
+
+// readTaskLog passes the task log lines from start on to onLog and returns the next start
+func (p *ProxmoxRuntime) readTaskLog(upid string, start int, onLog func(line string)) int {
+	path := fmt.Sprintf("/nodes/%s/tasks/%s/log?start=%d&limit=500", taskNode(upid, p.node), url.PathEscape(upid), start)
+	resp, err := p.apiRequest("GET", path, nil)
+	if err != nil {
+		return start
+	}
+
+	for _, item := range listItems(resp) {
+		if text, _ := item["t"].(string); text != "" && text != "no content" {
+			onLog(text)
+		}
+		start++
+	}
+	return start
+}
@@ -0,0 +1,48 @@
+package proxmox
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLS to the Proxmox API
+// Proxmox ships a self-signed certificate by default. Rather than disabling
+// verification, CACertPath trusts the CA of the cluster (e.g. the
+// /etc/pve/pve-root-ca.pem of a node) instead of the system roots, and
+// ClientCertPath/ClientKeyPath add a client certificate for proxies requiring
+// mutual TLS. Files that cannot be loaded fail Connect rather than falling
+// back to the system roots. SkipTLSVerify still turns verification off
+
+// tlsConfig builds the TLS configuration of the API client
+func (p *ProxmoxRuntime) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: p.config.SkipTLSVerify,
+	}
+
+	if p.config.CACertPath != "" {
+		pem, err := os.ReadFile(p.config.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Proxmox CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificate found in Proxmox CA file %s", p.config.CACertPath)
+		}
+		config.RootCAs = pool
+	}
+
+	if p.config.ClientCertPath != "" || p.config.ClientKeyPath != "" {
+		if p.config.ClientCertPath == "" || p.config.ClientKeyPath == "" {
+			return nil, fmt.Errorf("Proxmox client certificate needs both ClientCertPath and ClientKeyPath")
+		}
+		cert, err := tls.LoadX509KeyPair(p.config.ClientCertPath, p.config.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Proxmox client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
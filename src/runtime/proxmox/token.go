@@ -0,0 +1,74 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// API token secret
+// The secret of TokenID does not have to be stored in the Cosmos config:
+// TokenSecretFile names a file holding it (e.g. a Docker or systemd secret)
+// and TokenSecretEnv an environment variable. They are read at Connect, in
+// the order TokenSecret, TokenSecretFile, TokenSecretEnv, and a source that is
+// configured but missing or empty fails Connect. The secret is only sent in
+// the Authorization header, and Config masks it when formatted, as well as
+// the SSH password and the metadata key
+
+const redactedSecret = "[redacted]"
+
+// hasTokenSecret reports whether config sets a source for the token secret
+func hasTokenSecret(config *Config) bool {
+	return config.TokenSecret != "" || config.TokenSecretFile != "" || config.TokenSecretEnv != ""
+}
+
+// resolveTokenSecret returns the token secret from the first configured source,
+// empty when there is none
+func resolveTokenSecret(config *Config) (string, error) {
+	switch {
+	case config.TokenSecret != "":
+		return config.TokenSecret, nil
+
+	case config.TokenSecretFile != "":
+		content, err := os.ReadFile(config.TokenSecretFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read the Proxmox token secret file: %w", err)
+		}
+		secret := strings.TrimSpace(string(content))
+		if secret == "" {
+			return "", fmt.Errorf("Proxmox token secret file %s is empty", config.TokenSecretFile)
+		}
+		return secret, nil
+
+	case config.TokenSecretEnv != "":
+		secret, ok := os.LookupEnv(config.TokenSecretEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s holding the Proxmox token secret is not set", config.TokenSecretEnv)
+		}
+		if secret = strings.TrimSpace(secret); secret == "" {
+			return "", fmt.Errorf("environment variable %s holding the Proxmox token secret is empty", config.TokenSecretEnv)
+		}
+		return secret, nil
+	}
+	return "", nil
+}
+
+// authorization returns the Authorization header of API requests
+func (p *ProxmoxRuntime) authorization() (string, error) {
+	if p.config.TokenID == "" || p.tokenSecret == "" {
+		return "", errors.New("no Proxmox API token is configured")
+	}
+	return fmt.Sprintf("PVEAPIToken=%s=%s", p.config.TokenID, p.tokenSecret), nil
+}
+
+// String formats the config with the secrets masked, so it can be logged
+func (c Config) String() string {
+	for _, secret := range []*string{&c.TokenSecret, &c.SSHPassword, &c.MetadataKey} {
+		if *secret != "" {
+			*secret = redactedSecret
+		}
+	}
+	type plain Config
+	return fmt.Sprintf("%+v", plain(c))
+}
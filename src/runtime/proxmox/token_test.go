@@ -0,0 +1,42 @@
+package proxmox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConfigStringMasksSecrets(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		secret string
+	}{
+		{"token secret", Config{TokenID: "root@pam!cosmos", TokenSecret: "token-value"}, "token-value"},
+		{"SSH password", Config{SSHUser: "root", SSHPassword: "ssh-value"}, "ssh-value"},
+		{"metadata key", Config{MetadataKey: "key-value"}, "key-value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, formatted := range []string{tt.config.String(), fmt.Sprintf("%v", tt.config), fmt.Sprintf("%+v", tt.config)} {
+				if strings.Contains(formatted, tt.secret) {
+					t.Errorf("formatted config contains the secret: %s", formatted)
+				}
+				if !strings.Contains(formatted, redactedSecret) {
+					t.Errorf("formatted config does not show %s: %s", redactedSecret, formatted)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigStringKeepsEmptySecrets(t *testing.T) {
+	formatted := Config{Host: "pve.local"}.String()
+	if strings.Contains(formatted, redactedSecret) {
+		t.Errorf("empty secrets are masked: %s", formatted)
+	}
+	if !strings.Contains(formatted, "pve.local") {
+		t.Errorf("formatted config misses the host: %s", formatted)
+	}
+}
@@ -0,0 +1,89 @@
+package proxmox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Process listing for Proxmox containers, the equivalent of "docker top"
+// Processes are listed with ps inside the container. Minimal images without
+// ps (or with a busybox ps lacking -o) are read from /proc instead, in which
+// case CPU and memory usage are not available
+
+// psColumns is the ps output format parsed by parseProcesses
+const psColumns = "pid,user,pcpu,pmem,args"
+
+// procScript prints /proc in the same format as "ps -eo pid,user,pcpu,pmem,args"
+const procScript = `echo "PID USER %CPU %MEM COMMAND"
+for d in /proc/[0-9]*; do
+  pid=${d#/proc/}
+  uid=$(awk '/^Uid:/ {print $2}' "$d/status" 2>/dev/null) || continue
+  [ -n "$uid" ] || continue
+  user=$(awk -F: -v u="$uid" '$3 == u {print $1; exit}' /etc/passwd 2>/dev/null)
+  cmd=$(tr '\0' ' ' < "$d/cmdline" 2>/dev/null)
+  [ -n "$cmd" ] || cmd="[$(cat "$d/comm" 2>/dev/null)]"
+  echo "$pid ${user:-$uid} 0.0 0.0 $cmd"
+done`
+
+// Top lists the processes running inside a container
+func (p *ProxmoxRuntime) Top(id string) ([]runtime.ProcessInfo, error) {
+	if _, err := strconv.Atoi(id); err != nil {
+		return nil, fmt.Errorf("invalid container ID: %s", id)
+	}
+
+	result, err := p.Exec(id, []string{"ps", "-eo", psColumns}, runtime.ExecOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	if result.ExitCode != 0 {
+		result, err = p.Exec(id, []string{"sh", "-c", procScript}, runtime.ExecOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list processes: %w", err)
+		}
+		if result.ExitCode != 0 {
+			return nil, fmt.Errorf("failed to list processes: exit code %d: %s", result.ExitCode, result.Stderr)
+		}
+	}
+
+	return parseProcesses(result.Stdout), nil
+}
+
+// parseProcesses parses "PID USER %CPU %MEM COMMAND" lines. The command is the
+// rest of the line so arguments keep their spaces.
+func parseProcesses(output string) []runtime.ProcessInfo {
+	var processes []runtime.ProcessInfo
+
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue // header
+		}
+		cpu, _ := strconv.ParseFloat(fields[2], 64)
+		mem, _ := strconv.ParseFloat(fields[3], 64)
+
+		// Skip the first four columns, keeping the command as is
+		rest := strings.TrimSpace(line)
+		for i := 0; i < 4; i++ {
+			rest = strings.TrimLeft(rest[len(fields[i]):], " \t")
+		}
+
+		processes = append(processes, runtime.ProcessInfo{
+			PID:     pid,
+			User:    fields[1],
+			CPU:     cpu,
+			Memory:  mem,
+			Command: strings.TrimSpace(rest),
+		})
+	}
+
+	return processes
+}
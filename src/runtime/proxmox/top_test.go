@@ -0,0 +1,111 @@
+package proxmox
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+func TestParseProcesses(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []runtime.ProcessInfo
+	}{
+		{"empty", "", nil},
+		{"header only", "    PID USER     %CPU %MEM COMMAND\n", nil},
+		{
+			name: "ps output",
+			output: "    PID USER     %CPU %MEM COMMAND\n" +
+				"      1 root      0.0  0.1 /sbin/init\n" +
+				"    212 www-data  1.5  2.3 nginx: worker process\n",
+			want: []runtime.ProcessInfo{
+				{PID: 1, User: "root", CPU: 0, Memory: 0.1, Command: "/sbin/init"},
+				{PID: 212, User: "www-data", CPU: 1.5, Memory: 2.3, Command: "nginx: worker process"},
+			},
+		},
+		{
+			name:   "arguments keep their spaces",
+			output: "  305 postgres 12.0 4.0 postgres: app  db  [local]   idle\n",
+			want:   []runtime.ProcessInfo{{PID: 305, User: "postgres", CPU: 12, Memory: 4, Command: "postgres: app  db  [local]   idle"}},
+		},
+		{
+			name:   "tabs and trailing spaces",
+			output: "42\troot\t0.0\t0.0\tsleep 60  \r\n",
+			want:   []runtime.ProcessInfo{{PID: 42, User: "root", Command: "sleep 60"}},
+		},
+		{
+			name:   "numeric user",
+			output: "7 1000 0.0 0.0 python3 -m http.server 8000\n",
+			want:   []runtime.ProcessInfo{{PID: 7, User: "1000", Command: "python3 -m http.server 8000"}},
+		},
+		{
+			name:   "short and garbled lines",
+			output: "1 root 0.0 0.0\nnot a process line here\n9 root 0.0 0.0 [kthreadd]\n",
+			want:   []runtime.ProcessInfo{{PID: 9, User: "root", Command: "[kthreadd]"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseProcesses(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProcesses =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTop(t *testing.T) {
+	tests := []struct {
+		name     string
+		psExit   int
+		procExit int
+		want     []string // commands of the processes
+		wantErr  bool
+	}{
+		{"ps", 0, 0, []string{"/sbin/init", "nginx: master process"}, false},
+		{"without ps", 127, 0, []string{"/sbin/init", "[kworker]"}, false},
+		{"both failing", 127, 1, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			cluster.addGuest(100, fakeGuest{Status: "running", Config: map[string]interface{}{"hostname": "app"}})
+			p := newTestRuntime(t, cluster)
+
+			p.SetExecTransport(&fakeTransport{run: func(command, stdin string) (string, string, int) {
+				switch {
+				case strings.Contains(command, "/proc/[0-9]"):
+					return "PID USER %CPU %MEM COMMAND\n1 root 0.0 0.0 /sbin/init \n57 root 0.0 0.0 [kworker]\n", "", tt.procExit
+				case strings.Contains(command, psColumns):
+					if tt.psExit != 0 {
+						return "", "ps: not found", tt.psExit
+					}
+					return "PID USER %CPU %MEM COMMAND\n1 root 0.0 0.1 /sbin/init\n80 root 0.3 1.2 nginx: master process\n", "", 0
+				}
+				return "", "", 0
+			}})
+
+			processes, err := p.Top("100")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Top = %+v, want an error", processes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Top: %v", err)
+			}
+			var commands []string
+			for _, process := range processes {
+				commands = append(commands, process.Command)
+			}
+			if !reflect.DeepEqual(commands, tt.want) {
+				t.Errorf("commands = %q, want %q", commands, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package proxmox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+	"github.com/azukaar/cosmos-server/src/utils"
+)
+
+// In-place container updates
+// Memory, swap, cores and CPU units are cgroup limits that LXC hotplugs, so
+// Update applies them to running containers without a restart. Changes Proxmox
+// can only apply at the next start are left pending and logged. The template
+// and the privileged mode are fixed at creation and need a Recreate.
+// Only the options that change are sent, so the netN, mpN and other options
+// of the container are kept; fields that are not set, or not in the
+// UpdateFields mask, keep their current value (see types/update.go)
+
+// Update changes the resources, hostname and labels of a container in place
+func (p *ProxmoxRuntime) Update(id string, config runtime.ContainerConfig) error {
+	defer p.cache.invalidate()
+
+	if !p.connected {
+		return errNotConnected
+	}
+
+	vmid, err := strconv.Atoi(id)
+	if err != nil {
+		return fmt.Errorf("invalid container ID: %s", id)
+	}
+	if err := config.ValidateUpdateFields(); err != nil {
+		return err
+	}
+	node := p.nodeFor(vmid)
+
+	current, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), nil)
+	if isNotFound(err) {
+		return p.notFound(vmid)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update container %s: %w", id, err)
+	}
+
+	if template := p.metadata.GetLabel(vmid, "cosmos-template"); config.Updates(runtime.UpdateImage, config.Image != "") && config.Image != template {
+		return fmt.Errorf("%w: template %s -> %s", runtime.ErrRecreateRequired, template, config.Image)
+	}
+	if privileged := floatValue(current["unprivileged"]) == 0; config.Updates(runtime.UpdatePrivileged, config.Privileged) && config.Privileged != privileged {
+		return fmt.Errorf("%w: privileged mode", runtime.ErrRecreateRequired)
+	}
+
+	hostname, _ := current["hostname"].(string)
+	swap := int64(floatValue(current["swap"])) * 1024 * 1024
+	previous := runtime.ContainerConfig{
+		Hostname:   hostname,
+		Memory:     int64(floatValue(current["memory"])) * 1024 * 1024,
+		MemorySwap: &swap,
+		CPUs:       configCPUs(current),
+		CPUShares:  int64(floatValue(current["cpuunits"])),
+	}
+	if startup, _ := current["startup"].(string); startup != "" {
+		parseStartup(startup, &previous)
+	}
+	next := previous
+
+	update := map[string]interface{}{}
+	var remove []string
+	if config.Updates(runtime.UpdateMemory, config.Memory > 0) {
+		// LXC containers always have a limit, without one they get the default of Create
+		memory := config.Memory / (1024 * 1024)
+		if config.Memory < 0 {
+			return fmt.Errorf("invalid memory limit %d", config.Memory)
+		} else if memory == 0 {
+			memory = defaultMemoryMB
+		}
+		update["memory"] = memory
+		next.Memory = memory * 1024 * 1024
+	}
+	if config.Updates(runtime.UpdateMemorySwap, config.MemorySwap != nil) {
+		// Without a value, swap goes back to the default of Create, the memory size
+		nextSwap := next.Memory
+		if config.MemorySwap != nil {
+			if *config.MemorySwap < 0 {
+				return fmt.Errorf("invalid swap size %d", *config.MemorySwap)
+			}
+			nextSwap = *config.MemorySwap / (1024 * 1024) * 1024 * 1024
+		}
+		update["swap"] = nextSwap / (1024 * 1024)
+		next.MemorySwap = &nextSwap
+	}
+	cpu, err := lxcCPU(runtime.ContainerConfig{CPUs: config.CPUs, CPUShares: config.CPUShares})
+	if err != nil {
+		return err
+	}
+	if config.Updates(runtime.UpdateCPUs, config.CPUs > 0) {
+		update["cores"] = cpu["cores"]
+		if config.CPUs > 0 {
+			update["cpulimit"] = cpu["cpulimit"]
+		} else {
+			remove = append(remove, "cpulimit")
+		}
+		next.CPUs = config.CPUs
+		if config.CPUs == 0 {
+			next.CPUs = floatValue(cpu["cores"])
+		}
+	}
+	if config.Updates(runtime.UpdateCPUShares, config.CPUShares > 0) {
+		if config.CPUShares > 0 {
+			update["cpuunits"] = cpu["cpuunits"]
+		} else {
+			remove = append(remove, "cpuunits")
+		}
+		next.CPUShares = config.CPUShares
+	}
+
+	// Boot sequencing fields are overlaid one by one on the current startup option
+	startupChanged := false
+	for _, field := range []struct {
+		name          string
+		value, target *int
+	}{
+		{runtime.UpdateStartupOrder, &config.StartupOrder, &next.StartupOrder},
+		{runtime.UpdateStartupDelay, &config.StartupDelay, &next.StartupDelay},
+		{runtime.UpdateShutdownDelay, &config.ShutdownDelay, &next.ShutdownDelay},
+	} {
+		if config.Updates(field.name, *field.value != 0) {
+			*field.target = *field.value
+			startupChanged = true
+		}
+	}
+	if startupChanged {
+		startup, err := lxcStartup(next)
+		if err != nil {
+			return err
+		}
+		if startup != "" {
+			update["startup"] = startup
+		} else {
+			remove = append(remove, "startup")
+		}
+	}
+
+	if config.Updates(runtime.UpdateHostname, config.Hostname != "") {
+		if config.Hostname == "" {
+			return fmt.Errorf("hostname cannot be empty")
+		}
+		next.Hostname = lxcHostname(config.Hostname, vmid)
+		update["hostname"] = next.Hostname
+	}
+	if len(remove) > 0 {
+		update["delete"] = strings.Join(remove, ",")
+	}
+
+	changes := configChanges(previous, next)
+	if len(changes) > 0 {
+		body, _ := json.Marshal(update)
+		if _, err := p.apiRequest("PUT", fmt.Sprintf("/nodes/%s/lxc/%d/config", node, vmid), strings.NewReader(string(body))); err != nil {
+			return fmt.Errorf("failed to update container %s: %w", id, err)
+		}
+		p.warnPending(node, vmid)
+	}
+
+	if config.Updates(runtime.UpdateLabels, config.Labels != nil) {
+		changes = append(changes, p.reconcileLabels(vmid, config.Labels)...)
+	}
+
+	p.recordChange(vmid, "update", changes)
+
+	utils.Log(fmt.Sprintf("Updated LXC container VMID: %d", vmid))
+	return nil
+}
+
+// reconcileLabels replaces the labels of a container with labels, keeping the
+// labels Cosmos manages, and returns the changes
+func (p *ProxmoxRuntime) reconcileLabels(vmid int, labels map[string]string) []runtime.FieldChange {
+	old := p.metadata.Get(vmid)
+
+	add := make(map[string]string, len(labels))
+	for key, value := range labels {
+		if !protectedLabels[key] {
+			add[key] = value
+		}
+	}
+	var remove []string
+	for key := range old {
+		if _, ok := labels[key]; !ok && !strings.HasPrefix(key, "cosmos-") {
+			remove = append(remove, key)
+		}
+	}
+
+	if old == nil {
+		p.metadata.Set(vmid, add)
+	} else if err := p.metadata.UpdateLabels(vmid, add, remove); err != nil {
+		utils.Warn(fmt.Sprintf("Failed to update labels of LXC container VMID %d: %s", vmid, err))
+		return nil
+	}
+	return labelChanges(old, p.metadata.Get(vmid))
+}
+
+// warnPending logs the config changes Proxmox will only apply at the next start
+func (p *ProxmoxRuntime) warnPending(node string, vmid int) {
+	resp, err := p.apiRequest("GET", fmt.Sprintf("/nodes/%s/lxc/%d/pending", node, vmid), nil)
+	if err != nil {
+		return
+	}
+
+	var pending []string
+	for _, item := range listItems(resp) {
+		if _, ok := item["pending"]; ok {
+			pending = append(pending, fmt.Sprint(item["key"]))
+		}
+	}
+	if len(pending) > 0 {
+		utils.Warn(fmt.Sprintf("LXC container VMID %d applies %s at its next start", vmid, strings.Join(pending, ", ")))
+	}
+}
@@ -0,0 +1,139 @@
+package proxmox
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	runtime "github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// updateConfig is the config of the container updated by TestUpdatePartial
+func updateConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"hostname":     "app",
+		"ostemplate":   testImage,
+		"unprivileged": float64(1),
+		"memory":       float64(512),
+		"swap":         float64(256),
+		"cores":        float64(2),
+		"cpulimit":     float64(1.5),
+		"cpuunits":     float64(2048),
+		"startup":      "order=2,up=10",
+		"net0":         "name=eth0,bridge=vmbr0,ip=dhcp",
+		"net1":         "name=eth1,bridge=vmbr1,ip=10.0.1.5/24,gw=10.0.1.1",
+		"mp0":          "local-lvm:vm-100-disk-1,mp=/data,size=16G",
+		"rootfs":       "local-lvm:vm-100-disk-0,size=8G",
+	}
+}
+
+func TestUpdatePartial(t *testing.T) {
+	swap := int64(0)
+
+	tests := []struct {
+		name    string
+		config  runtime.ContainerConfig
+		changed map[string]interface{} // options changed by Update, nil when removed
+		wantErr error                  // errAny for any error
+	}{
+		{
+			name:    "memory alone",
+			config:  runtime.ContainerConfig{Memory: 1 << 30},
+			changed: map[string]interface{}{"memory": float64(1024)},
+		},
+		{
+			name:    "memory in the field mask",
+			config:  runtime.ContainerConfig{Memory: 2 << 30, CPUs: 4, Hostname: "other", UpdateFields: []string{runtime.UpdateMemory}},
+			changed: map[string]interface{}{"memory": float64(2048)},
+		},
+		{
+			name:    "swap set to zero",
+			config:  runtime.ContainerConfig{MemorySwap: &swap},
+			changed: map[string]interface{}{"swap": float64(0)},
+		},
+		{
+			name:    "CPU limit removed",
+			config:  runtime.ContainerConfig{UpdateFields: []string{runtime.UpdateCPUs}},
+			changed: map[string]interface{}{"cores": float64(1), "cpulimit": nil},
+		},
+		{
+			name:    "cores",
+			config:  runtime.ContainerConfig{CPUs: 4},
+			changed: map[string]interface{}{"cores": float64(4), "cpulimit": float64(4)},
+		},
+		{
+			name:    "startup delay",
+			config:  runtime.ContainerConfig{StartupDelay: 30},
+			changed: map[string]interface{}{"startup": "order=2,up=30"},
+		},
+		{
+			name:    "hostname",
+			config:  runtime.ContainerConfig{Hostname: "web"},
+			changed: map[string]interface{}{"hostname": "web"},
+		},
+		{
+			name:   "nothing set",
+			config: runtime.ContainerConfig{},
+		},
+		{
+			name:    "networks in the field mask",
+			config:  runtime.ContainerConfig{Networks: []string{"vmbr1"}, UpdateFields: []string{"Networks"}},
+			wantErr: errAny,
+		},
+		{
+			name:    "privileged",
+			config:  runtime.ContainerConfig{Privileged: true},
+			wantErr: runtime.ErrRecreateRequired,
+		},
+		{
+			name:    "empty hostname in the field mask",
+			config:  runtime.ContainerConfig{UpdateFields: []string{runtime.UpdateHostname}},
+			wantErr: errAny,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := newFakeCluster(t)
+			cluster.addGuest(100, fakeGuest{Config: updateConfig()})
+			p := newTestRuntime(t, cluster)
+
+			err := p.Update("100", tt.config)
+			if tt.wantErr != nil {
+				if err == nil || (tt.wantErr != errAny && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("Update = %v, want %v", err, tt.wantErr)
+				}
+				if n := cluster.count("PUT /nodes/pve/lxc/100/config"); n != 0 {
+					t.Errorf("config updated %d times", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+
+			want := updateConfig()
+			for key, value := range tt.changed {
+				if value == nil {
+					delete(want, key)
+				} else {
+					want[key] = value
+				}
+			}
+			got := cluster.guest(100).Config
+			for key, value := range want {
+				if fmt.Sprint(got[key]) != fmt.Sprint(value) {
+					t.Errorf("%s = %v, want %v", key, got[key], value)
+				}
+			}
+			for key, value := range got {
+				if _, ok := want[key]; !ok {
+					t.Errorf("unexpected %s = %v", key, value)
+				}
+			}
+			if n := cluster.count("PUT /nodes/pve/lxc/100/config"); (n != 0) != (len(tt.changed) > 0) {
+				t.Errorf("config updated %d times for %d changes", n, len(tt.changed))
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package runtime
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/azukaar/cosmos-server/src/runtime/types"
+)
+
+// Runtime backend registry
+// InitRuntime builds the runtime through the factory registered under
+// RuntimeConfig.Type. Docker and Proxmox are registered below; other backends
+// register themselves from an init function of their package:
+//
+//	func init() {
+//		runtime.RegisterRuntime("podman", func(config runtime.RuntimeConfig) (runtime.ContainerRuntime, error) {
+//			return podman.New(...)
+//		})
+//	}
+
+// RuntimeFactory creates a runtime from its configuration
+type RuntimeFactory func(config types.RuntimeConfig) (types.ContainerRuntime, error)
+
+var (
+	factories   = make(map[types.RuntimeType]RuntimeFactory)
+	factoriesMu sync.RWMutex
+)
+
+func init() {
+	RegisterRuntime(string(types.RuntimeDocker), func(config types.RuntimeConfig) (types.ContainerRuntime, error) {
+		return NewDockerRuntime(config.Docker)
+	})
+	RegisterRuntime(string(types.RuntimeProxmox), func(config types.RuntimeConfig) (types.ContainerRuntime, error) {
+		return NewProxmoxRuntime(config.Proxmox)
+	})
+}
+
+// RegisterRuntime makes a backend available under name, replacing any previous registration
+func RegisterRuntime(name string, factory RuntimeFactory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[types.RuntimeType(name)] = factory
+}
+
+// RegisteredRuntimes returns the names of the registered backends, sorted
+func RegisteredRuntimes() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newRuntime creates a runtime with the factory registered for config.Type
+func newRuntime(config types.RuntimeConfig) (types.ContainerRuntime, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[config.Type]
+	factoriesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown container runtime %q (registered: %s)", config.Type, strings.Join(RegisteredRuntimes(), ", "))
+	}
+	return factory(config)
+}
@@ -1,6 +1,79 @@
 package types
 
-import "io"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Errors returned by runtimes, wrapped with details. Use errors.Is to test them
+var (
+	// ErrNotConnected is returned when the runtime is used before Connect succeeded
+	ErrNotConnected = errors.New("not connected")
+
+	// ErrContainerNotFound is returned when a container does not exist (anymore)
+	ErrContainerNotFound = errors.New("container not found")
+
+	// ErrImageNotFound is returned when an image or template does not exist
+	ErrImageNotFound = errors.New("image not found")
+
+	// ErrVMIDExhausted is returned when no VMID is free in the configured range
+	ErrVMIDExhausted = errors.New("VMID range exhausted")
+
+	// ErrNotSupported is returned when the backend cannot perform an operation
+	ErrNotSupported = errors.New("operation not supported")
+
+	// ErrRecreateRequired is returned by Update for changes that need the container rebuilt
+	ErrRecreateRequired = errors.New("change requires recreating the container")
+
+	// ErrNameInUse is returned when a container name is taken by another container
+	ErrNameInUse = errors.New("container name already in use")
+
+	// ErrNotFound is kept for existing callers, it is ErrContainerNotFound
+	ErrNotFound = ErrContainerNotFound
+)
+
+// StateWaitInterval is the delay between two state checks of WaitForState
+const StateWaitInterval = 500 * time.Millisecond
+
+// WaitTimeoutError is returned by WaitForState when the container did not reach
+// the wanted state in time. Last is the last state observed
+type WaitTimeoutError struct {
+	ID      string
+	Want    ContainerState
+	Last    ContainerState
+	Timeout time.Duration
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return fmt.Sprintf("container %s did not become %s within %s (last state: %s)", e.ID, e.Want, e.Timeout, e.Last)
+}
+
+// PollState calls current every StateWaitInterval until it returns want, for
+// WaitForState implementations. It stops early when current fails or when the
+// container is dead, which it cannot leave on its own
+func PollState(id string, want ContainerState, timeout time.Duration, current func() (ContainerState, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		state, err := current()
+		if err != nil {
+			return err
+		}
+		if state == want {
+			return nil
+		}
+		if state == StateDead {
+			return fmt.Errorf("container %s is dead, it will not become %s", id, want)
+		}
+		if time.Now().Add(StateWaitInterval).After(deadline) {
+			return &WaitTimeoutError{ID: id, Want: want, Last: state, Timeout: timeout}
+		}
+		time.Sleep(StateWaitInterval)
+	}
+}
 
 // RuntimeType identifies the container runtime backend
 type RuntimeType string
@@ -12,48 +85,82 @@ const (
 
 // ContainerConfig defines container creation parameters (runtime-agnostic)
 type ContainerConfig struct {
-	Name        string
-	Image       string // Docker image or LXC template
-	Hostname    string
-	Domainname  string
-	User        string
-	WorkingDir  string
-	Entrypoint  []string
-	Command     []string
-	Environment map[string]string
-	Labels      map[string]string
-	Ports       []PortMapping
-	Volumes     []VolumeMount
-	Networks    []string
+	Name        string            `json:"container_name,omitempty" yaml:"container_name,omitempty"`
+	Image       string            `json:"image,omitempty" yaml:"image,omitempty"` // Docker image or LXC template
+	Hostname    string            `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	Domainname  string            `json:"domainname,omitempty" yaml:"domainname,omitempty"`
+	User        string            `json:"user,omitempty" yaml:"user,omitempty"`
+	WorkingDir  string            `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+	Entrypoint  []string          `json:"entrypoint,omitempty" yaml:"entrypoint,omitempty"`
+	Command     []string          `json:"command,omitempty" yaml:"command,omitempty"`
+	Environment map[string]string `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Ports       []PortMapping     `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Volumes     []VolumeMount     `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	Devices     []DeviceMapping   `json:"devices,omitempty" yaml:"devices,omitempty"`
+	Networks    []string          `json:"networks,omitempty" yaml:"networks,omitempty"`
+
+	// Per network settings keyed by an entry of Networks, MacAddress, Interface and RateLimit are used
+	NetworkEndpoints map[string]NetworkEndpoint `json:"network_endpoints,omitempty" yaml:"network_endpoints,omitempty"`
+
+	// Bandwidth cap of every interface in Mbps, 0 for none (LXC runtimes only)
+	NetworkRateLimit float64 `json:"network_rate_limit,omitempty" yaml:"network_rate_limit,omitempty"`
 
 	// Resource limits
-	Memory     int64   // bytes
-	MemorySwap int64   // bytes
-	CPUs       float64
-	CPUShares  int64
+	Memory     int64   `json:"mem_limit,omitempty" yaml:"mem_limit,omitempty"`         // bytes
+	MemorySwap *int64  `json:"memswap_limit,omitempty" yaml:"memswap_limit,omitempty"` // bytes, nil keeps the runtime default, 0 disables swap (Docker also needs Memory set)
+	CPUs       float64 `json:"cpus,omitempty" yaml:"cpus,omitempty"`
+	CPUShares  int64   `json:"cpu_shares,omitempty" yaml:"cpu_shares,omitempty"`
+	RootFSSize int64   `json:"rootfs_size,omitempty" yaml:"rootfs_size,omitempty"` // bytes, rounded up to whole GB (LXC runtimes only)
 
 	// Behavior
-	RestartPolicy RestartPolicy
-	Privileged    bool
-	TTY           bool
-	StdinOpen     bool
+	RestartPolicy RestartPolicy `json:"restart,omitempty" yaml:"restart,omitempty"`
+	Privileged    bool          `json:"privileged,omitempty" yaml:"privileged,omitempty"`
+	TTY           bool          `json:"tty,omitempty" yaml:"tty,omitempty"`
+	StdinOpen     bool          `json:"stdin_open,omitempty" yaml:"stdin_open,omitempty"`
+
+	// Boot sequencing of containers started with the node (LXC runtimes only)
+	StartupOrder  int `json:"startup_order,omitempty" yaml:"startup_order,omitempty"`   // lower starts first, 0 for no order
+	StartupDelay  int `json:"startup_delay,omitempty" yaml:"startup_delay,omitempty"`   // seconds before the next container starts
+	ShutdownDelay int `json:"shutdown_delay,omitempty" yaml:"shutdown_delay,omitempty"` // seconds allowed to shut down with the node
 
 	// Health check
-	HealthCheck *HealthCheckConfig
+	HealthCheck *HealthCheckConfig `json:"healthcheck,omitempty" yaml:"healthcheck,omitempty"`
+
+	// One-time readiness check run after start, before the container is reported ready
+	Readiness *ReadinessProbe `json:"readiness,omitempty" yaml:"readiness,omitempty"`
 
 	// DNS
-	DNS        []string
-	DNSSearch  []string
-	ExtraHosts []string
+	DNS        []string `json:"dns,omitempty" yaml:"dns,omitempty"`
+	DNSSearch  []string `json:"dns_search,omitempty" yaml:"dns_search,omitempty"`
+	ExtraHosts []string `json:"extra_hosts,omitempty" yaml:"extra_hosts,omitempty"`
 
 	// Security
-	CapAdd      []string
-	CapDrop     []string
-	SecurityOpt []string
+	CapAdd      []string `json:"cap_add,omitempty" yaml:"cap_add,omitempty"`
+	CapDrop     []string `json:"cap_drop,omitempty" yaml:"cap_drop,omitempty"`
+	SecurityOpt []string `json:"security_opt,omitempty" yaml:"security_opt,omitempty"`
+
+	// Kernel features of system containers (LXC runtimes only), nil keeps the runtime default
+	Features *ContainerFeatures `json:"features,omitempty" yaml:"features,omitempty"`
 
 	// Cosmos-specific
-	Routes      []RouteConfig
-	PostInstall []string
+	Routes      []RouteConfig `json:"routes,omitempty" yaml:"routes,omitempty"`
+	PostInstall []string      `json:"post_install,omitempty" yaml:"post_install,omitempty"`
+
+	// One-time parameters passed to the first-boot provisioning script
+	BuildArgs map[string]string `json:"build_args,omitempty" yaml:"build_args,omitempty"`
+
+	// Account, SSH keys and commands set up at first boot (LXC runtimes only)
+	Provisioning *Provisioning `json:"provisioning,omitempty" yaml:"provisioning,omitempty"`
+
+	// Placement relative to other containers (multi-node runtimes)
+	Affinity []AffinityRule `json:"affinity,omitempty" yaml:"affinity,omitempty"`
+
+	// Create removes a container of the same name first instead of returning it (LXC runtimes only)
+	Replace bool `json:"replace,omitempty" yaml:"replace,omitempty"`
+
+	// Fields Update applies, zero values included, see update.go
+	UpdateFields []string `json:"update_fields,omitempty" yaml:"-"`
 }
 
 // Container represents a running or stopped container
@@ -81,6 +188,24 @@ const (
 	StateDead       ContainerState = "dead"
 )
 
+// EventAction is the lifecycle change reported by a ContainerEvent
+type EventAction string
+
+const (
+	EventCreated EventAction = "created"
+	EventStarted EventAction = "started"
+	EventStopped EventAction = "stopped"
+	EventRemoved EventAction = "removed"
+)
+
+// ContainerEvent is a lifecycle change of a container
+type ContainerEvent struct {
+	ID     string
+	Name   string
+	Action EventAction
+	Time   int64 // unix timestamp
+}
+
 // ContainerDetails provides full container inspection data
 type ContainerDetails struct {
 	Container
@@ -88,6 +213,21 @@ type ContainerDetails struct {
 	NetworkSettings NetworkSettings
 	Mounts          []VolumeMount
 	HostConfig      HostConfig
+	DiskUsage       *DiskUsage
+}
+
+// DiskUsage reports filesystem usage of a container
+type DiskUsage struct {
+	RootFS FilesystemUsage
+	Mounts []FilesystemUsage
+}
+
+// FilesystemUsage reports usage of one mounted filesystem, in bytes
+type FilesystemUsage struct {
+	Path      string
+	Used      int64
+	Total     int64
+	Available int64
 }
 
 // ContainerStats holds resource usage statistics
@@ -106,19 +246,29 @@ type ContainerStats struct {
 
 // PortMapping defines port exposure
 type PortMapping struct {
-	HostIP        string
-	HostPort      string
-	ContainerPort string
-	Protocol      string // tcp, udp
+	HostIP        string `json:"host_ip,omitempty" yaml:"host_ip,omitempty"`
+	HostPort      string `json:"published,omitempty" yaml:"published,omitempty"`
+	ContainerPort string `json:"target,omitempty" yaml:"target,omitempty"`
+	Protocol      string `json:"protocol,omitempty" yaml:"protocol,omitempty"` // tcp, udp
 }
 
 // VolumeMount defines storage mounting
 type VolumeMount struct {
-	Type        MountType
-	Source      string
-	Target      string
-	ReadOnly    bool
-	Consistency string
+	Type        MountType `json:"type,omitempty" yaml:"type,omitempty"`
+	Source      string    `json:"source,omitempty" yaml:"source,omitempty"`
+	Target      string    `json:"target,omitempty" yaml:"target,omitempty"`
+	ReadOnly    bool      `json:"read_only,omitempty" yaml:"read_only,omitempty"`
+	Consistency string    `json:"consistency,omitempty" yaml:"consistency,omitempty"`
+	Size        int64     `json:"size,omitempty" yaml:"size,omitempty"`       // bytes, allocates a new volume when Source is empty (LXC runtimes only)
+	Storage     string    `json:"storage,omitempty" yaml:"storage,omitempty"` // storage of the allocated volume (LXC runtimes only)
+	Backup      *bool     `json:"backup,omitempty" yaml:"backup,omitempty"`   // include the volume in backups (LXC runtimes only)
+}
+
+// DeviceMapping passes a host device (e.g. /dev/dri/renderD128) into the container
+type DeviceMapping struct {
+	HostPath      string `json:"host_path" yaml:"host_path"`
+	ContainerPath string `json:"container_path,omitempty" yaml:"container_path,omitempty"` // defaults to HostPath, LXC runtimes only support HostPath
+	Permissions   string `json:"permissions,omitempty" yaml:"permissions,omitempty"`       // cgroup permissions, "rwm" when empty
 }
 
 // MountType identifies volume mount types
@@ -135,6 +285,7 @@ type VolumeConfig struct {
 	Name   string
 	Driver string
 	Labels map[string]string
+	Size   int64 // bytes, used by runtimes allocating fixed-size volumes
 }
 
 // Volume represents a storage volume
@@ -144,6 +295,7 @@ type Volume struct {
 	Mountpoint string
 	Labels     map[string]string
 	CreatedAt  string
+	Size       int64 // bytes, 0 when unknown
 }
 
 // NetworkConfig defines network creation
@@ -195,6 +347,8 @@ type NetworkEndpoint struct {
 	IPAddress  string
 	Gateway    string
 	MacAddress string
+	Interface  string  // interface name inside the container, e.g. eth1 (LXC runtimes only)
+	RateLimit  float64 // bandwidth cap in Mbps overriding NetworkRateLimit, 0 for none (LXC runtimes only)
 	Aliases    []string
 }
 
@@ -227,17 +381,43 @@ type HostConfig struct {
 
 // RestartPolicy defines container restart behavior
 type RestartPolicy struct {
-	Name              string // always, unless-stopped, on-failure, no
-	MaximumRetryCount int
+	Name              string `json:"name,omitempty" yaml:"name,omitempty"` // always, unless-stopped, on-failure, no
+	MaximumRetryCount int    `json:"maximum_retry_count,omitempty" yaml:"maximum_retry_count,omitempty"`
 }
 
 // HealthCheckConfig defines container health monitoring
 type HealthCheckConfig struct {
-	Test        []string
-	Interval    int64 // nanoseconds
-	Timeout     int64
-	Retries     int
-	StartPeriod int64
+	Test        []string `json:"test,omitempty" yaml:"test,omitempty"`
+	Interval    int64    `json:"interval,omitempty" yaml:"interval,omitempty"` // nanoseconds
+	Timeout     int64    `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Retries     int      `json:"retries,omitempty" yaml:"retries,omitempty"`
+	StartPeriod int64    `json:"start_period,omitempty" yaml:"start_period,omitempty"`
+}
+
+// ReadinessProbe defines the first-boot readiness check of a container.
+// Unlike HealthCheckConfig it only runs until the container is ready once.
+type ReadinessProbe struct {
+	File     string   `json:"file,omitempty" yaml:"file,omitempty"`         // path that must exist inside the container
+	Command  []string `json:"command,omitempty" yaml:"command,omitempty"`   // command that must exit with 0
+	Interval int64    `json:"interval,omitempty" yaml:"interval,omitempty"` // nanoseconds
+	Timeout  int64    `json:"timeout,omitempty" yaml:"timeout,omitempty"`   // nanoseconds
+}
+
+// Provisioning is the first-boot setup of a system container
+type Provisioning struct {
+	User         string   `json:"user,omitempty" yaml:"user,omitempty"`                   // account to create, root when empty
+	PasswordHash string   `json:"password_hash,omitempty" yaml:"password_hash,omitempty"` // crypt(3) hash of the account password
+	SSHKeys      []string `json:"ssh_keys,omitempty" yaml:"ssh_keys,omitempty"`           // authorized public keys, OpenSSH format
+	Commands     []string `json:"commands,omitempty" yaml:"commands,omitempty"`           // run once as root, before PostInstall
+}
+
+// ContainerFeatures are the kernel features a system container may use
+type ContainerFeatures struct {
+	Nesting bool     `json:"nesting,omitempty" yaml:"nesting,omitempty"` // run containers inside the container
+	Keyctl  bool     `json:"keyctl,omitempty" yaml:"keyctl,omitempty"`   // keyctl() system call, needed by some Docker setups
+	Fuse    bool     `json:"fuse,omitempty" yaml:"fuse,omitempty"`       // FUSE file systems
+	Mknod   bool     `json:"mknod,omitempty" yaml:"mknod,omitempty"`     // create device nodes
+	Mount   []string `json:"mount,omitempty" yaml:"mount,omitempty"`     // file system types the container may mount, e.g. nfs
 }
 
 // Image represents a container image or LXC template
@@ -258,6 +438,76 @@ type LogOptions struct {
 	Until      string
 }
 
+// LabelSelector matches containers carrying all of the given labels
+type LabelSelector map[string]string
+
+// Matches reports whether labels satisfy the selector
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for k, v := range s {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// AffinityRule places a container on the same node as the containers matching
+// Selector, or away from them when Anti is set
+type AffinityRule struct {
+	Selector LabelSelector
+	Anti     bool
+}
+
+// ChangeRecord describes one configuration change of a container
+type ChangeRecord struct {
+	Timestamp int64 // unix seconds
+	Operation string
+	Changes   []FieldChange
+}
+
+// FieldChange holds the old and new value of a changed field
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// ExecOptions configures a command run inside a container
+type ExecOptions struct {
+	WorkingDir  string
+	Environment map[string]string
+	TTY         bool
+}
+
+// ExecResult holds the output of a command run inside a container
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// ProcessInfo describes a process running inside a container
+type ProcessInfo struct {
+	PID     int
+	User    string
+	CPU     float64 // percent
+	Memory  float64 // percent
+	Command string
+}
+
+// Progress reports one phase of a multi-step operation such as container creation
+type Progress struct {
+	Phase   string
+	Message string
+	Percent int // 0-100
+}
+
+// CreateResult holds the outcome of an asynchronous container creation
+type CreateResult struct {
+	ID    string
+	Error error
+}
+
 // RouteConfig for Cosmos reverse proxy routes
 type RouteConfig struct {
 	Name          string
@@ -285,18 +535,40 @@ type ContainerRuntime interface {
 
 	// Container Lifecycle
 	Create(config ContainerConfig) (string, error)
+	// Describe returns the backend-native form of config (LXC config, Docker
+	// create request) as text, without creating anything nor calling the backend
+	Describe(config ContainerConfig) (string, error)
 	Start(id string) error
 	Stop(id string) error
 	Restart(id string) error
 	Remove(id string) error
+	Pause(id string) error
+	Unpause(id string) error
 	Recreate(id string, config ContainerConfig) (string, error)
+	// Update changes the resources of a container in place, running or not.
+	// Zero resource values keep the current setting and non-nil Labels replace
+	// the container labels, unless config.UpdateFields lists the fields to
+	// apply (see update.go); changing the image or the privileged mode returns
+	// ErrRecreateRequired
+	Update(id string, config ContainerConfig) error
+	// Rename changes the name of a container, failing with ErrNameInUse when
+	// another container has it
+	Rename(id string, newName string) error
 
 	// Container Info
 	List() ([]Container, error)
+	// FindByName returns the container with the given name, or ErrContainerNotFound.
+	// Runtimes keep names unique; if several containers share one, which is returned is runtime-defined
+	FindByName(name string) (*Container, error)
 	Inspect(id string) (*ContainerDetails, error)
+	// WaitForState returns once the container is in state, or a *WaitTimeoutError after timeout
+	WaitForState(id string, state ContainerState, timeout time.Duration) error
 	Logs(id string, opts LogOptions) (io.ReadCloser, error)
 	Stats(id string) (*ContainerStats, error)
 	StatsAll() ([]ContainerStats, error)
+	// Events streams container lifecycle changes, each transition once, until
+	// ctx is done; the channel is then closed
+	Events(ctx context.Context) (<-chan ContainerEvent, error)
 
 	// Network Operations
 	CreateNetwork(config NetworkConfig) (string, error)
@@ -310,6 +582,20 @@ type ContainerRuntime interface {
 	RemoveVolume(id string) error
 	ListVolumes() ([]Volume, error)
 
+	// Exec runs a command inside a running container
+	Exec(id string, cmd []string, opts ExecOptions) (*ExecResult, error)
+
+	// File Operations
+	// CopyTo copies a local file or directory to containerPath; CopyFrom writes
+	// containerPath to localWriter as a tar stream
+	CopyTo(id string, localPath string, containerPath string) error
+	CopyFrom(id string, containerPath string, localWriter io.Writer) error
+	// CopyToContainer streams content into the file dstPath with the permission
+	// bits of mode, creating its parent directories; CopyFromContainer streams
+	// the content of the file srcPath
+	CopyToContainer(id string, dstPath string, content io.Reader, mode os.FileMode) error
+	CopyFromContainer(id string, srcPath string) (io.ReadCloser, error)
+
 	// Image/Template Operations
 	PullImage(ref string) (io.ReadCloser, error)
 	ListImages() ([]Image, error)
@@ -336,12 +622,50 @@ type DockerConfig struct {
 
 // ProxmoxConfig for Proxmox LXC runtime
 type ProxmoxConfig struct {
-	Host          string // proxmox.local:8006
-	Node          string // pve
-	TokenID       string // user@realm!tokenid
-	TokenSecret   string
-	Storage       string // local-lvm
-	VMIDStart     int    // Starting VMID for containers
-	VMIDEnd       int    // Ending VMID range
-	SkipTLSVerify bool
+	Host                  string // proxmox.local:8006
+	Node                  string // pve
+	TokenID               string // user@realm!tokenid
+	TokenSecret           string
+	TokenSecretFile       string // file holding the token secret, used when TokenSecret is empty
+	TokenSecretEnv        string // environment variable holding the token secret, used when both are empty
+	Storage               string // local-lvm
+	TemplateStorage       string // local, storage holding LXC templates
+	VMIDStart             int    // Starting VMID for containers
+	VMIDEnd               int    // Ending VMID range
+	SkipTLSVerify         bool
+	CACertPath            string // PEM bundle of the CA signing the Proxmox certificate, instead of the system roots
+	ClientCertPath        string // PEM client certificate for mutual TLS, with ClientKeyPath
+	ClientKeyPath         string
+	NameTemplate          string // {stack}, {service}, {name} and {n} placeholders
+	TaskTimeout           int    // seconds to wait for Proxmox tasks, 0 uses the default
+	MaxRetries            int    // retries of transient API failures, 0 uses the default, negative disables
+	RetryBaseDelay        int    // milliseconds before the first retry, doubled on each attempt
+	DryRun                bool   // log the rendered LXC config instead of creating containers
+	CacheTTL              int    // milliseconds List and Version results are cached, 0 uses the default, negative disables
+	StopTimeout           int    // seconds Stop waits for a clean shutdown, 0 uses the default, negative disables
+	IncludeUnmanaged      bool   // list containers not created by Cosmos
+	AllNodes              bool   // list the containers of every node, not only Node
+	BatchConcurrency      int    // parallel operations of bulk helpers, 0 uses the default
+	DialTimeout           int    // seconds to connect to the API, 0 uses the default
+	ResponseHeaderTimeout int    // seconds to wait for API response headers, 0 uses the default
+	RequestTimeout        int    // seconds an API call may take in total, 0 uses the default, negative disables
+	AdoptUnmanaged        bool   // Reconcile takes over containers created outside of Cosmos
+	DefaultBridge         string // bridge of containers without networks, defaults to vmbr0
+	DefaultVLAN           int    // VLAN tag of interfaces on the default bridge, 0 for none
+	DebugAPI              bool   // log every API call with redacted bodies at debug level
+	APITransport          string // "auto" (default) uses pvesh when running on Node, "local" forces it, "http" never does
+	Pool                  string // Proxmox pool containers are added to, empty for none
+	LockTimeout           int    // seconds lifecycle operations wait for a container lock to clear, 0 uses the default, negative disables
+	AllocationStrategy    string // how VMIDs are picked: "lowest-free" (default), "sequential" or "name-hashed"
+
+	// SSH access to the node, used to run commands inside containers
+	SSHUser       string
+	SSHPort       int
+	SSHKeyPath    string
+	SSHPassword   string
+	SSHKnownHosts string
+
+	MetadataKey     string // encrypts sensitive metadata (e.g. environment) at rest
+	MetadataBackend string // where labels are stored: "file" (default) or "memory"
+	MetadataPath    string // directory of the file backend, /var/lib/cosmos/proxmox-metadata by default
 }
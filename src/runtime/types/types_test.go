@@ -0,0 +1,158 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// fullConfig sets every field of ContainerConfig
+func fullConfig() ContainerConfig {
+	swap := int64(0)
+	backup := false
+	return ContainerConfig{
+		Name:        "web",
+		Image:       "nginx:1.25",
+		Hostname:    "web",
+		Domainname:  "example.com",
+		User:        "www-data",
+		WorkingDir:  "/srv",
+		Entrypoint:  []string{"/docker-entrypoint.sh"},
+		Command:     []string{"nginx", "-g", "daemon off;"},
+		Environment: map[string]string{"TZ": "UTC", "EMPTY": ""},
+		Labels:      map[string]string{"cosmos-stack": "blog"},
+		Ports:       []PortMapping{{HostIP: "127.0.0.1", HostPort: "8080", ContainerPort: "80", Protocol: "tcp"}},
+		Volumes: []VolumeMount{
+			{Type: MountTypeBind, Source: "/srv/www", Target: "/usr/share/nginx/html", ReadOnly: true},
+			{Type: MountTypeVolume, Target: "/data", Size: 10 << 30, Storage: "local-lvm", Backup: &backup},
+			{Type: MountTypeTmpfs, Target: "/cache", Consistency: "64m"},
+		},
+		Devices:  []DeviceMapping{{HostPath: "/dev/dri/renderD128", Permissions: "rw"}},
+		Networks: []string{"vmbr0", "vmbr1"},
+		NetworkEndpoints: map[string]NetworkEndpoint{
+			"vmbr1": {IPAddress: "10.0.0.5/24", Gateway: "10.0.0.1", MacAddress: "BC:24:11:00:00:01", Interface: "eth1", RateLimit: 100, Aliases: []string{"web"}},
+		},
+		NetworkRateLimit: 50,
+		Memory:           512 << 20,
+		MemorySwap:       &swap,
+		CPUs:             1.5,
+		CPUShares:        512,
+		RootFSSize:       8 << 30,
+		RestartPolicy:    RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+		Privileged:       true,
+		TTY:              true,
+		StdinOpen:        true,
+		StartupOrder:     2,
+		StartupDelay:     10,
+		ShutdownDelay:    30,
+		HealthCheck:      &HealthCheckConfig{Test: []string{"CMD", "curl", "-f", "http://localhost"}, Interval: 30e9, Timeout: 5e9, Retries: 3, StartPeriod: 10e9},
+		Readiness:        &ReadinessProbe{File: "/run/ready", Interval: 1e9, Timeout: 60e9},
+		DNS:              []string{"1.1.1.1"},
+		DNSSearch:        []string{"example.com"},
+		ExtraHosts:       []string{"db:10.0.0.2"},
+		CapAdd:           []string{"NET_ADMIN"},
+		CapDrop:          []string{"MKNOD"},
+		SecurityOpt:      []string{"no-new-privileges"},
+		Features:         &ContainerFeatures{Nesting: true, Mount: []string{"nfs"}},
+		Routes:           []RouteConfig{{Name: "web", UseHost: true, Host: "blog.example.com", Target: "http://web:80", Mode: "SERVAPP", SmartShield: SmartShieldConfig{Enabled: true}}},
+		PostInstall:      []string{"apt-get update"},
+		BuildArgs:        map[string]string{"VERSION": "2"},
+		Provisioning:     &Provisioning{User: "admin", SSHKeys: []string{"ssh-ed25519 AAAA admin"}, Commands: []string{"echo hi"}},
+		Affinity:         []AffinityRule{{Selector: LabelSelector{"app": "db"}}, {Selector: LabelSelector{"app": "web"}, Anti: true}},
+		Replace:          true,
+		UpdateFields:     []string{"Memory"},
+	}
+}
+
+func TestContainerConfigRoundTrip(t *testing.T) {
+	config := fullConfig()
+
+	// A new field must be added to fullConfig so its serialization is covered
+	value := reflect.ValueOf(config)
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).IsZero() {
+			t.Errorf("fullConfig does not set %s", value.Type().Field(i).Name)
+		}
+	}
+
+	formats := []struct {
+		name      string
+		marshal   func(interface{}) ([]byte, error)
+		unmarshal func([]byte, interface{}) error
+		skipped   []string // fields not serialized in this format
+	}{
+		{"json", json.Marshal, json.Unmarshal, nil},
+		{"yaml", yaml.Marshal, yaml.Unmarshal, []string{"UpdateFields"}},
+	}
+
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			data, err := f.marshal(config)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+
+			var decoded ContainerConfig
+			if err := f.unmarshal(data, &decoded); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+
+			want := config
+			for _, field := range f.skipped {
+				reflect.ValueOf(&want).Elem().FieldByName(field).SetZero()
+			}
+			if !reflect.DeepEqual(decoded, want) {
+				t.Errorf("round trip changed the config\n got: %+v\nwant: %+v", decoded, want)
+			}
+
+			// A second round trip gives the same document
+			again, err := f.marshal(decoded)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if f.name == "json" && string(again) != string(data) {
+				t.Errorf("second round trip differs:\n%s\n%s", data, again)
+			}
+		})
+	}
+}
+
+func TestContainerConfigFieldNames(t *testing.T) {
+	data, err := json.Marshal(ContainerConfig{
+		Name:          "web",
+		Memory:        1 << 30,
+		RestartPolicy: RestartPolicy{Name: "always"},
+		HealthCheck:   &HealthCheckConfig{Test: []string{"NONE"}},
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	// Compose names, zero values omitted
+	want := `{"container_name":"web","mem_limit":1073741824,"restart":{"name":"always"},"healthcheck":{"test":["NONE"]}}`
+	if string(data) != want {
+		t.Errorf("json = %s, want %s", data, want)
+	}
+
+	data, err = yaml.Marshal(ContainerConfig{Name: "web", UpdateFields: []string{"Memory"}})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(data), "update_fields") {
+		t.Errorf("yaml has the update fields: %s", data)
+	}
+}
+
+func TestReadinessProbeLegacyJSON(t *testing.T) {
+	// Probes stored before the field names were set
+	var probe ReadinessProbe
+	if err := json.Unmarshal([]byte(`{"File":"/run/ready","Command":null,"Interval":1000000000,"Timeout":0}`), &probe); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if want := (ReadinessProbe{File: "/run/ready", Interval: 1e9}); !reflect.DeepEqual(probe, want) {
+		t.Errorf("probe = %+v, want %+v", probe, want)
+	}
+}
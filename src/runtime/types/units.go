@@ -0,0 +1,105 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Resource quantities written by humans
+// Memory sizes take a binary unit, as in Docker and Kubernetes: "512m",
+// "512M", "512Mi" and "512MiB" are all 512 MiB, and a size without unit is in
+// bytes. CPUs are a number of cores ("1.5") or millicores ("500m")
+
+var memoryUnits = map[string]int64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+	"t": 1 << 40,
+	"p": 1 << 50,
+}
+
+// formatUnits are the units of FormatMemory, largest first
+var formatUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"Pi", 1 << 50},
+	{"Ti", 1 << 40},
+	{"Gi", 1 << 30},
+	{"Mi", 1 << 20},
+	{"Ki", 1 << 10},
+}
+
+// ParseMemory converts a memory size such as "512Mi", "2g" or "1.5Gi" to bytes
+func ParseMemory(value string) (int64, error) {
+	s := strings.TrimSpace(value)
+	number := strings.TrimRightFunc(s, func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	})
+	unit := strings.ToLower(strings.TrimSpace(s[len(number):]))
+	if len(unit) > 1 {
+		unit = strings.TrimSuffix(unit, "b")
+		if len(unit) == 2 {
+			unit = strings.TrimSuffix(unit, "i")
+		}
+	}
+
+	multiplier, ok := memoryUnits[unit]
+	if !ok || number == "" {
+		return 0, fmt.Errorf("invalid memory size %q, expected a number with an optional unit (e.g. 512Mi, 2Gi)", value)
+	}
+
+	size, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || math.IsNaN(size) || math.IsInf(size, 0) {
+		return 0, fmt.Errorf("invalid memory size %q, expected a number with an optional unit (e.g. 512Mi, 2Gi)", value)
+	}
+	if size < 0 {
+		return 0, fmt.Errorf("invalid memory size %q, it must not be negative", value)
+	}
+
+	bytes := math.Round(size * float64(multiplier))
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("memory size %q is too large", value)
+	}
+	return int64(bytes), nil
+}
+
+// FormatMemory renders bytes with the largest binary unit dividing it exactly,
+// e.g. "512Mi" or "1536Mi", the inverse of ParseMemory
+func FormatMemory(bytes int64) string {
+	if bytes != 0 {
+		for _, unit := range formatUnits {
+			if bytes%unit.size == 0 {
+				return strconv.FormatInt(bytes/unit.size, 10) + unit.suffix
+			}
+		}
+	}
+	return strconv.FormatInt(bytes, 10)
+}
+
+// ParseCPU converts a CPU quantity such as "1.5" or "500m" to a number of cores
+func ParseCPU(value string) (float64, error) {
+	s := strings.TrimSpace(value)
+	divisor := 1.0
+	if number, ok := strings.CutSuffix(s, "m"); ok {
+		s, divisor = number, 1000
+	}
+
+	cpus, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(cpus) || math.IsInf(cpus, 0) {
+		return 0, fmt.Errorf("invalid CPU quantity %q, expected cores (e.g. 1.5) or millicores (e.g. 500m)", value)
+	}
+	if cpus < 0 {
+		return 0, fmt.Errorf("invalid CPU quantity %q, it must not be negative", value)
+	}
+	return cpus / divisor, nil
+}
+
+// FormatCPU renders a number of cores, e.g. "1.5", the inverse of ParseCPU
+func FormatCPU(cpus float64) string {
+	return strconv.FormatFloat(cpus, 'f', -1, 64)
+}
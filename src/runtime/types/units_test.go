@@ -0,0 +1,153 @@
+package types
+
+import "testing"
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "0", want: 0},
+		{value: "1024", want: 1024},
+		{value: "512b", want: 512},
+		{value: "1k", want: 1 << 10},
+		{value: "512m", want: 512 << 20},
+		{value: "512M", want: 512 << 20},
+		{value: "512Mi", want: 512 << 20},
+		{value: "512MiB", want: 512 << 20},
+		{value: "512MB", want: 512 << 20},
+		{value: "2Gi", want: 2 << 30},
+		{value: "1.5Gi", want: 3 << 29},
+		{value: "0.5g", want: 1 << 29},
+		{value: "0.25Ti", want: 1 << 38},
+		{value: " 2 Gi ", want: 2 << 30},
+		{value: "8191Pi", want: 8191 << 50},
+		{value: "", wantErr: true},
+		{value: "Mi", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "12x", wantErr: true},
+		{value: "1ii", wantErr: true},
+		{value: "1KiBB", wantErr: true},
+		{value: "1.2.3Gi", wantErr: true},
+		{value: "-1Gi", wantErr: true},
+		{value: "NaN", wantErr: true},
+		{value: "Inf", wantErr: true},
+		{value: "8192Pi", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseMemory(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseMemory(%q) = %d, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMemory(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMemory(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatMemory(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{0, "0"},
+		{1000, "1000"},
+		{1 << 10, "1Ki"},
+		{512 << 20, "512Mi"},
+		{1536 << 20, "1536Mi"},
+		{2 << 30, "2Gi"},
+		{3 << 40, "3Ti"},
+		{1 << 50, "1Pi"},
+		{1<<30 + 1, "1073741825"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := FormatMemory(tt.bytes)
+			if got != tt.want {
+				t.Errorf("FormatMemory(%d) = %q, want %q", tt.bytes, got, tt.want)
+			}
+			if back, err := ParseMemory(got); err != nil || back != tt.bytes {
+				t.Errorf("ParseMemory(%q) = %d, %v, want %d", got, back, err, tt.bytes)
+			}
+		})
+	}
+}
+
+func TestParseCPU(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "0", want: 0},
+		{value: "1", want: 1},
+		{value: "1.5", want: 1.5},
+		{value: "0.25", want: 0.25},
+		{value: "500m", want: 0.5},
+		{value: "1500m", want: 1.5},
+		{value: " 250m ", want: 0.25},
+		{value: "", wantErr: true},
+		{value: "m", wantErr: true},
+		{value: "two", wantErr: true},
+		{value: "500M", wantErr: true},
+		{value: "1 core", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "-500m", wantErr: true},
+		{value: "NaN", wantErr: true},
+		{value: "Inf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseCPU(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseCPU(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCPU(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCPU(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCPU(t *testing.T) {
+	tests := []struct {
+		cpus float64
+		want string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{1.5, "1.5"},
+		{0.25, "0.25"},
+		{16, "16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got := FormatCPU(tt.cpus)
+			if got != tt.want {
+				t.Errorf("FormatCPU(%v) = %q, want %q", tt.cpus, got, tt.want)
+			}
+			if back, err := ParseCPU(got); err != nil || back != tt.cpus {
+				t.Errorf("ParseCPU(%q) = %v, %v, want %v", got, back, err, tt.cpus)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Partial updates
+// Update overlays a ContainerConfig on the current config of a container.
+// Without UpdateFields, the fields holding their zero value (nil for Labels
+// and MemorySwap, false for Privileged) keep the current setting, so a config
+// with only Memory set changes the memory and nothing else. Zero can then not
+// be set: UpdateFields is a field mask naming the fields to apply, by their Go
+// name, zero values included, and every other field is ignored. E.g. removing
+// the CPU limit of a container while keeping the rest:
+//   Update(id, ContainerConfig{UpdateFields: []string{UpdateCPUs}})
+// Networks, mounts and the other fields are never changed by Update
+
+const (
+	UpdateImage         = "Image"
+	UpdatePrivileged    = "Privileged"
+	UpdateHostname      = "Hostname"
+	UpdateMemory        = "Memory"
+	UpdateMemorySwap    = "MemorySwap"
+	UpdateCPUs          = "CPUs"
+	UpdateCPUShares     = "CPUShares"
+	UpdateStartupOrder  = "StartupOrder"
+	UpdateStartupDelay  = "StartupDelay"
+	UpdateShutdownDelay = "ShutdownDelay"
+	UpdateLabels        = "Labels"
+)
+
+var updatableFields = map[string]bool{
+	UpdateImage:         true,
+	UpdatePrivileged:    true,
+	UpdateHostname:      true,
+	UpdateMemory:        true,
+	UpdateMemorySwap:    true,
+	UpdateCPUs:          true,
+	UpdateCPUShares:     true,
+	UpdateStartupOrder:  true,
+	UpdateStartupDelay:  true,
+	UpdateShutdownDelay: true,
+	UpdateLabels:        true,
+}
+
+// ValidateUpdateFields checks UpdateFields only names fields Update can change
+func (c ContainerConfig) ValidateUpdateFields() error {
+	for _, field := range c.UpdateFields {
+		if !updatableFields[field] {
+			fields := make([]string, 0, len(updatableFields))
+			for name := range updatableFields {
+				fields = append(fields, name)
+			}
+			sort.Strings(fields)
+			return fmt.Errorf("field %q cannot be updated (expected %s)", field, strings.Join(fields, ", "))
+		}
+	}
+	return nil
+}
+
+// Updates reports whether Update applies field: listed in UpdateFields, or
+// set (non-zero) when there is no field mask
+func (c ContainerConfig) Updates(field string, set bool) bool {
+	if len(c.UpdateFields) == 0 {
+		return set
+	}
+	for _, f := range c.UpdateFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
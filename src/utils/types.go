@@ -216,14 +216,52 @@ type DockerConfig struct {
 
 // ProxmoxConfig for Proxmox LXC runtime
 type ProxmoxConfig struct {
-	Host          string // proxmox.local:8006
-	Node          string // pve
-	TokenID       string // user@realm!tokenid
-	TokenSecret   string
-	Storage       string // local-lvm
-	VMIDStart     int    // Starting VMID for containers
-	VMIDEnd       int    // Ending VMID range
-	SkipTLSVerify bool
+	Host                  string // proxmox.local:8006
+	Node                  string // pve
+	TokenID               string // user@realm!tokenid
+	TokenSecret           string
+	TokenSecretFile       string // file holding the token secret, used when TokenSecret is empty
+	TokenSecretEnv        string // environment variable holding the token secret, used when both are empty
+	Storage               string // local-lvm
+	TemplateStorage       string // local, storage holding LXC templates
+	VMIDStart             int    // Starting VMID for containers
+	VMIDEnd               int    // Ending VMID range
+	SkipTLSVerify         bool
+	CACertPath            string // PEM bundle of the CA signing the Proxmox certificate, instead of the system roots
+	ClientCertPath        string // PEM client certificate for mutual TLS, with ClientKeyPath
+	ClientKeyPath         string
+	NameTemplate          string // {stack}, {service}, {name} and {n} placeholders
+	TaskTimeout           int    // seconds to wait for Proxmox tasks, 0 uses the default
+	MaxRetries            int    // retries of transient API failures, 0 uses the default, negative disables
+	RetryBaseDelay        int    // milliseconds before the first retry, doubled on each attempt
+	DryRun                bool   // log the rendered LXC config instead of creating containers
+	CacheTTL              int    // milliseconds List and Version results are cached, 0 uses the default, negative disables
+	StopTimeout           int    // seconds Stop waits for a clean shutdown, 0 uses the default, negative disables
+	IncludeUnmanaged      bool   // list containers not created by Cosmos
+	AllNodes              bool   // list the containers of every node, not only Node
+	BatchConcurrency      int    // parallel operations of bulk helpers, 0 uses the default
+	DialTimeout           int    // seconds to connect to the API, 0 uses the default
+	ResponseHeaderTimeout int    // seconds to wait for API response headers, 0 uses the default
+	RequestTimeout        int    // seconds an API call may take in total, 0 uses the default, negative disables
+	AdoptUnmanaged        bool   // Reconcile takes over containers created outside of Cosmos
+	DefaultBridge         string // bridge of containers without networks, defaults to vmbr0
+	DefaultVLAN           int    // VLAN tag of interfaces on the default bridge, 0 for none
+	DebugAPI              bool   // log every API call with redacted bodies at debug level
+	APITransport          string // "auto" (default) uses pvesh when running on Node, "local" forces it, "http" never does
+	Pool                  string // Proxmox pool containers are added to, empty for none
+	LockTimeout           int    // seconds lifecycle operations wait for a container lock to clear, 0 uses the default, negative disables
+	AllocationStrategy    string // how VMIDs are picked: "lowest-free" (default), "sequential" or "name-hashed"
+
+	// SSH access to the node, used to run commands inside containers
+	SSHUser       string
+	SSHPort       int
+	SSHKeyPath    string
+	SSHPassword   string
+	SSHKnownHosts string
+
+	MetadataKey     string // encrypts sensitive metadata (e.g. environment) at rest
+	MetadataBackend string // where labels are stored: "file" (default) or "memory"
+	MetadataPath    string // directory of the file backend, /var/lib/cosmos/proxmox-metadata by default
 }
 
 type ProxyConfig struct {